@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -11,13 +10,23 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/analytics"
 	"github.com/urlshortener/internal/cache"
+	"github.com/urlshortener/internal/clicks"
 	"github.com/urlshortener/internal/config"
+	"github.com/urlshortener/internal/filewatch"
+	"github.com/urlshortener/internal/geo"
 	httphandler "github.com/urlshortener/internal/http"
+	"github.com/urlshortener/internal/live"
+	"github.com/urlshortener/internal/netutil"
 	"github.com/urlshortener/internal/obs"
+	"github.com/urlshortener/internal/privacy"
 	"github.com/urlshortener/internal/rate"
 	"github.com/urlshortener/internal/repo"
+	"github.com/urlshortener/internal/safety"
 	"github.com/urlshortener/internal/service"
+	"github.com/urlshortener/internal/unfurl"
+	"github.com/urlshortener/internal/webhook"
 )
 
 func main() {
@@ -54,42 +63,350 @@ func main() {
 	defer redisCache.Close()
 
 	// Initialize rate limiter
-	rateLimiter := rate.NewLimiter(rate.Config{
-		GlobalRPS:  cfg.RateLimit.GlobalRPS,
-		PerIPRPS:   cfg.RateLimit.PerIPRPS,
-		BurstSize:  cfg.RateLimit.BurstSize,
-		WindowSize: cfg.RateLimit.WindowSize,
-	})
+	newLimiter := func(name string, config rate.Config) rate.RateLimiter {
+		config.WindowSize = cfg.RateLimit.WindowSize
+		config.IdleTimeout = cfg.RateLimit.IdleTimeout
+		config.MaxKeys = cfg.RateLimit.MaxKeys
+		var limiter rate.RateLimiter = rate.NewLimiter(config)
+		if cfg.RateLimit.Backend == "redis" {
+			// Limits are shared by all instances; the local limiter takes over while Redis is down
+			limiter = rate.NewRedisLimiter(redisCache.Client(), config, rate.RedisConfig{
+				Prefix:  "ratelimit:" + name + ":",
+				Timeout: cfg.RateLimit.RedisTimeout,
+			}, limiter)
+		}
+		return limiter
+	}
+	var quotas rate.QuotaCounter = rate.NewMemoryQuota()
+	if cfg.RateLimit.Backend == "redis" {
+		quotas = rate.NewRedisQuota(redisCache.Client(), rate.RedisConfig{Timeout: cfg.RateLimit.RedisTimeout}, quotas)
+	}
+
+	policyConfig := rate.PolicyConfig{
+		Default: rate.Policy{
+			GlobalRPS: cfg.RateLimit.GlobalRPS,
+			PerIPRPS:  cfg.RateLimit.PerIPRPS,
+			BurstSize: cfg.RateLimit.BurstSize,
+		},
+		Plans:       make(map[string]rate.Plan),
+		DefaultPlan: cfg.RateLimit.DefaultPlan,
+		ExemptPaths: cfg.RateLimit.ExemptPaths,
+	}
+	for _, policy := range cfg.RateLimit.Policies {
+		policyConfig.Policies = append(policyConfig.Policies, rate.Policy{
+			Name:      policy.Name,
+			Routes:    policy.Routes,
+			GlobalRPS: policy.GlobalRPS,
+			PerIPRPS:  policy.PerIPRPS,
+			BurstSize: policy.BurstSize,
+		})
+	}
+	for name, plan := range cfg.RateLimit.Plans {
+		policyConfig.Plans[name] = rate.Plan{
+			RPS:        plan.RPS,
+			BurstSize:  plan.BurstSize,
+			DailyQuota: plan.DailyQuota,
+		}
+	}
+	apiKeys := make(map[string]rate.Principal)
+	for _, key := range cfg.RateLimit.APIKeys {
+		apiKeys[key.Key] = rate.Principal{ID: "key:" + key.Principal, Plan: key.Plan}
+	}
+
+	rateLimiter, err := rate.NewPolicyLimiter(policyConfig, newLimiter, quotas,
+		rate.APIKeyIdentifier(apiKeys, cfg.RateLimit.TrustUserHeader))
+	if err != nil {
+		logger.Fatal("Failed to initialize rate limiter", "error", err)
+	}
 	defer rateLimiter.Close()
 
 	// Initialize service
 	serviceConfig := service.Config{
-		BaseURL:      fmt.Sprintf("http://localhost:%s", cfg.Server.Port),
-		CodeLength:   8,
-		MaxURLLength: 2048,
-		AllowedHosts: cfg.Security.AllowedHosts,
-		BlockedHosts: cfg.Security.BlockedDomains,
-	}
+		BaseURL:         cfg.GetBaseURL(),
+		CodeLength:      8,
+		MaxURLLength:    2048,
+		AllowedHosts:    cfg.Security.AllowedHosts,
+		HonorDoNotTrack: cfg.Privacy.HonorDoNotTrack,
 
-	shortenerService := service.NewShortenerService(db, redisCache, serviceConfig)
+		ShortenerDomains: cfg.Security.ShortenerDomains,
 
-	// Initialize HTTP handler
-	handler := httphandler.NewHandler(shortenerService, serviceConfig.BaseURL)
+		ReportReviewThreshold:  cfg.Abuse.ReviewThreshold,
+		ReportSuspendThreshold: cfg.Abuse.SuspendThreshold,
+		ReporterSecret:         []byte(cfg.Abuse.ReporterSecret),
+	}
+	if len(serviceConfig.ReporterSecret) == 0 {
+		serviceConfig.ReporterSecret = []byte(cfg.Security.AdminSecret)
+	}
 
 	// Initialize observability
 	metrics := obs.NewMetrics()
 	tracer := obs.NewTracer()
 
+	// Initialize click ingestion pipeline
+	clickPipeline, err := clicks.NewPipeline(db, clicks.Config{
+		QueueSize:     cfg.Clicks.QueueSize,
+		Workers:       cfg.Clicks.Workers,
+		BatchSize:     cfg.Clicks.BatchSize,
+		FlushInterval: cfg.Clicks.FlushInterval,
+		DropPolicy:    clicks.DropPolicy(cfg.Clicks.DropPolicy),
+		BlockTimeout:  cfg.Clicks.BlockTimeout,
+	}, metrics, func(count int, err error) {
+		logger.Error("Failed to write click batch", "count", count, "error", err)
+	})
+	if err != nil {
+		logger.Fatal("Invalid clicks configuration", "error", err)
+	}
+	clickPipeline.Start()
+
+	var clickSink service.ClickSink = clickPipeline
+
+	// Buffer clicks in a Redis Stream, falling back to the in-memory
+	// pipeline while Redis is unavailable
+	var clickConsumer *clicks.StreamConsumer
+	if cfg.Clicks.Stream.Enabled {
+		consumerName := cfg.Clicks.Stream.Consumer
+		if consumerName == "" {
+			consumerName, _ = os.Hostname()
+		}
+
+		streamConfig := clicks.StreamConfig{
+			Stream:        cfg.Clicks.Stream.Name,
+			Group:         cfg.Clicks.Stream.Group,
+			Consumer:      consumerName,
+			MaxLen:        cfg.Clicks.Stream.MaxLen,
+			AddTimeout:    cfg.Clicks.Stream.AddTimeout,
+			BatchSize:     cfg.Clicks.BatchSize,
+			ClaimInterval: cfg.Clicks.Stream.ClaimInterval,
+			ClaimMinIdle:  cfg.Clicks.Stream.ClaimMinIdle,
+			MaxDeliveries: cfg.Clicks.Stream.MaxDeliveries,
+		}
+
+		clickConsumer = clicks.NewStreamConsumer(redisCache.Client(), db, streamConfig, metrics,
+			func(count int, err error) {
+				logger.Error("Failed to drain click stream", "count", count, "error", err)
+			},
+		)
+		if err := clickConsumer.Start(context.Background()); err != nil {
+			logger.Fatal("Failed to start click stream consumer", "error", err)
+		}
+
+		clickSink = clicks.NewStreamProducer(redisCache.Client(), streamConfig, clickPipeline, metrics)
+	}
+
+	serviceOptions := []service.Option{service.WithClickSink(clickSink)}
+
+	// Initialize destination metadata unfurling
+	if cfg.Unfurl.Enabled {
+		fetcher := unfurl.NewFetcher(unfurl.Config{
+			Timeout:      cfg.Unfurl.Timeout,
+			MaxBodyBytes: cfg.Unfurl.MaxBodyBytes,
+			MaxRedirects: cfg.Unfurl.MaxRedirects,
+			UserAgent:    cfg.Unfurl.UserAgent,
+		})
+		previewQueue := unfurl.NewQueue(fetcher, db, cfg.Unfurl.Workers, cfg.Unfurl.QueueSize,
+			func(code string, err error) {
+				logger.Debugw("Failed to unfurl destination", "code", code, "error", err)
+			},
+		)
+		previewQueue.Start()
+		defer previewQueue.Stop()
+
+		serviceOptions = append(serviceOptions, service.WithPreviewQueue(previewQueue))
+	}
+
+	// Resolve click countries from a local GeoIP database
+	if cfg.Geo.DatabasePath != "" {
+		locator := geo.NewLocator(cfg.Geo.DatabasePath, cfg.Geo.ReloadInterval, func(err error) {
+			logger.Warnw("Failed to load GeoIP database", "path", cfg.Geo.DatabasePath, "error", err)
+		})
+		locator.Start()
+		defer locator.Stop()
+
+		serviceOptions = append(serviceOptions, service.WithGeoResolver(locator))
+	}
+
+	// Anonymize client IPs before clicks are stored
+	ipMode, err := privacy.ParseMode(cfg.Privacy.IPMode)
+	if err != nil {
+		logger.Fatal("Invalid privacy configuration", "error", err)
+	}
+	anonymizer, err := privacy.NewAnonymizer(ipMode, privacy.NewRedisSalts(redisCache.Client()))
+	if err != nil {
+		logger.Fatal("Invalid privacy configuration", "error", err)
+	}
+	serviceOptions = append(serviceOptions, service.WithClickAnonymizer(anonymizer))
+
+	// Estimate unique visitors with HyperLogLogs keyed by salted visitor IDs
+	if cfg.Analytics.UniqueVisitors {
+		visitorCounter := analytics.NewVisitorCounter(redisCache.Client(), anonymizer, db, analytics.VisitorConfig{
+			FlushInterval: cfg.Analytics.VisitorFlush,
+		}, func(err error) {
+			logger.Warnw("Failed to count unique visitors", "error", err)
+		})
+		visitorCounter.Start()
+		defer visitorCounter.Stop()
+
+		serviceOptions = append(serviceOptions, service.WithVisitorCounter(visitorCounter))
+	}
+
+	// Stream clicks to live dashboards through Redis pub/sub
+	var liveHub *live.Hub
+	if cfg.Live.Enabled {
+		liveHub = live.NewHub(redisCache.Client(), live.Config{
+			SubscriberBuffer:  cfg.Live.SubscriberBuffer,
+			MaxStreams:        cfg.Live.MaxStreams,
+			MaxStreamsPerLink: cfg.Live.MaxStreamsPerLink,
+			Heartbeat:         cfg.Live.Heartbeat,
+			MaxDuration:       cfg.Live.MaxDuration,
+		}, func(err error) {
+			logger.Warnw("Live click stream error", "error", err)
+		})
+		liveHub.Start()
+
+		serviceOptions = append(serviceOptions, service.WithLiveFeed(liveHub))
+	}
+
+	// Initialize click rollup aggregator
+	aggregator := analytics.NewAggregator(db, analytics.AggregatorConfig{
+		Interval: cfg.Analytics.RollupInterval,
+		Lookback: cfg.Analytics.RollupLookback,
+		Settle:   cfg.Analytics.RollupSettle,
+	}, func(err error) {
+		logger.Error("Failed to roll up clicks", "error", err)
+	})
+	aggregator.Start()
+	defer aggregator.Stop()
+
+	// Purge click events past the retention period
+	if cfg.Privacy.Retention.Days > 0 {
+		retentionAction, err := privacy.ParseRetentionAction(cfg.Privacy.Retention.Action)
+		if err != nil {
+			logger.Fatal("Invalid privacy configuration", "error", err)
+		}
+		retention := privacy.NewRetention(db, privacy.RetentionConfig{
+			MaxAge:         time.Duration(cfg.Privacy.Retention.Days) * 24 * time.Hour,
+			Action:         retentionAction,
+			Interval:       cfg.Privacy.Retention.Interval,
+			BatchSize:      cfg.Privacy.Retention.BatchSize,
+			RollupLookback: cfg.Analytics.RollupLookback,
+		}, func(err error) {
+			logger.Error("Failed to purge old click events", "error", err)
+		}, func(count int64) {
+			logger.Infow("Purged old click events", "count", count, "action", retentionAction)
+		})
+		retention.Start()
+		defer retention.Stop()
+	}
+
+	// Deliver link events from the webhook outbox
+	if cfg.Webhooks.Enabled {
+		webhookWorker := webhook.NewWorker(db, webhook.Config{
+			Interval:     cfg.Webhooks.Interval,
+			BatchSize:    cfg.Webhooks.BatchSize,
+			Concurrency:  cfg.Webhooks.Concurrency,
+			Timeout:      cfg.Webhooks.Timeout,
+			MaxAttempts:  cfg.Webhooks.MaxAttempts,
+			BaseBackoff:  cfg.Webhooks.BaseBackoff,
+			MaxBackoff:   cfg.Webhooks.MaxBackoff,
+			LogRetention: cfg.Webhooks.LogRetention,
+		}, func(err error) {
+			logger.Error("Failed to process webhooks", "error", err)
+		})
+		webhookWorker.Start()
+		defer webhookWorker.Stop()
+	}
+
+	// Check destinations against local blocklists
+	var safetyChecks safety.Chain
+	if len(cfg.Security.BlockedDomains) > 0 {
+		safetyChecks = append(safetyChecks, safety.NewDomainList("security.blocked_domains", safety.ThreatBlocked, cfg.Security.BlockedDomains))
+	}
+	var feeds []*safety.Feed
+	onFeedError := func(err error) {
+		logger.Warnw("Failed to load safety list", "error", err)
+	}
+	for _, list := range cfg.Safety.DomainLists {
+		feeds = append(feeds, safety.NewDomainFeed(list.Path, list.Threat, onFeedError))
+	}
+	for _, list := range cfg.Safety.HashLists {
+		prefixSize := list.PrefixSize
+		if prefixSize == 0 {
+			prefixSize = safety.FullHashSize
+		}
+		if prefixSize < safety.FullHashSize {
+			// Matches reject new links and suspend existing ones, but nothing
+			// confirms that a short prefix match is really listed
+			logger.Warnw("Hash list prefixes can match URLs that are not listed", "path", list.Path, "prefix_size", prefixSize)
+		}
+		feeds = append(feeds, safety.NewHashFeed(list.Path, list.Threat, prefixSize, onFeedError))
+	}
+	for _, feed := range feeds {
+		safetyChecks = append(safetyChecks, feed)
+	}
+	if len(cfg.Safety.Rules) > 0 {
+		rules := make([]safety.RegexRule, 0, len(cfg.Safety.Rules))
+		for _, rule := range cfg.Safety.Rules {
+			rules = append(rules, safety.RegexRule{Pattern: rule.Pattern, Threat: rule.Threat})
+		}
+		regexList, err := safety.NewRegexList("safety.rules", rules)
+		if err != nil {
+			logger.Fatal("Invalid safety configuration", "error", err)
+		}
+		safetyChecks = append(safetyChecks, regexList)
+	}
+	if len(feeds) > 0 {
+		reloaders := make([]filewatch.Reloader, 0, len(feeds))
+		for _, feed := range feeds {
+			reloaders = append(reloaders, feed)
+		}
+		feedPoller := filewatch.NewPoller(cfg.Safety.ReloadInterval, reloaders...)
+		feedPoller.Start()
+		defer feedPoller.Stop()
+	}
+	if len(safetyChecks) > 0 {
+		serviceOptions = append(serviceOptions, service.WithSafetyChecker(safetyChecks))
+	}
+
+	shortenerService := service.NewShortenerService(db, redisCache, serviceConfig, serviceOptions...)
+
+	// Suspend existing links whose destination has since been listed
+	if len(safetyChecks) > 0 && cfg.Safety.RescanInterval > 0 {
+		scanner := safety.NewScanner(db, safetyChecks, shortenerService, safety.ScannerConfig{
+			Interval:  cfg.Safety.RescanInterval,
+			BatchSize: cfg.Safety.RescanBatchSize,
+		}, func(err error) {
+			logger.Error("Failed to rescan link destinations", "error", err)
+		}, func(code string, verdict *safety.Verdict) {
+			logger.Warnw("Suspended link with unsafe destination", "code", code, "threat", verdict.Threat, "source", verdict.Source, "rule", verdict.Rule)
+		})
+		scanner.Start()
+		defer scanner.Stop()
+	}
+
+	// Initialize HTTP handler
+	handler := httphandler.NewHandler(shortenerService, serviceConfig.BaseURL)
+
+	// Resolve client addresses through trusted proxies only
+	proxies, err := netutil.NewProxyResolver(cfg.Security.TrustedProxies)
+	if err != nil {
+		logger.Fatal("Failed to parse trusted proxies", "error", err)
+	}
+
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
+	// Client addresses come from netutil.ClientIP; gin must not trust any proxy
+	if err := router.SetTrustedProxies(nil); err != nil {
+		logger.Fatal("Failed to configure router", "error", err)
+	}
 
 	// Add middleware
 	router.Use(
+		proxies.Middleware(),
 		obs.LoggingMiddleware(logger),
 		obs.RecoveryMiddleware(logger),
 		obs.CORSMiddleware(cfg.Security.AllowedOrigins),
-		rate.RateLimitMiddleware(rateLimiter),
+		rateLimiter.Middleware(),
 		obs.MetricsMiddleware(metrics),
 		obs.TracingMiddleware(tracer),
 	)
@@ -106,14 +423,38 @@ func main() {
 	{
 		api.POST("/shorten", handler.CreateShortURL)
 		api.GET("/urls/:code", handler.GetURLMetadata)
+		api.GET("/urls/:code/qr", handler.GetQRCode)
+		api.GET("/urls/:code/stats", handler.GetClickStats)
+		api.GET("/urls/:code/clicks/export", handler.ExportURLClicks)
+		api.GET("/urls/:code/live", handler.StreamLiveClicks)
 		api.DELETE("/urls/:code", handler.DeleteURL)
+		api.POST("/urls/:code/tags", handler.AddTags)
+		api.DELETE("/urls/:code/tags/:tag", handler.RemoveTag)
+		api.PUT("/urls/:code/campaign", handler.SetURLCampaign)
+		api.POST("/urls/:code/report", handler.ReportURL)
 		api.GET("/users/:user/urls", handler.GetUserURLs)
+		api.GET("/users/:user/campaigns", handler.GetUserCampaigns)
+		api.GET("/users/:user/clicks/export", handler.ExportUserClicks)
+
+		api.POST("/campaigns", handler.CreateCampaign)
+		api.GET("/campaigns/:id", handler.GetCampaign)
+		api.GET("/campaigns/:id/urls", handler.GetCampaignURLs)
+		api.GET("/campaigns/:id/stats", handler.GetCampaignStats)
+
+		api.POST("/webhooks", handler.CreateWebhook)
+		api.GET("/webhooks", handler.GetWebhooks)
+		api.DELETE("/webhooks/:id", handler.DeleteWebhook)
+		api.GET("/webhooks/:id/deliveries", handler.GetWebhookDeliveries)
+		api.POST("/webhooks/:id/deliveries/:delivery/retry", handler.RetryWebhookDelivery)
 	}
 
 	// Admin routes
-	admin := router.Group("/api/v1/admin")
+	admin := router.Group("/api/v1/admin", obs.AdminAuthMiddleware(cfg.Security.AdminSecret))
 	{
 		admin.POST("/cleanup", handler.CleanupExpired)
+		admin.POST("/urls/:code/suspend", handler.SuspendURL)
+		admin.POST("/urls/:code/unsuspend", handler.UnsuspendURL)
+		admin.GET("/urls/:code/reports", handler.GetURLReports)
 	}
 
 	// Redirect route (must be last to avoid conflicts)
@@ -128,6 +469,11 @@ func main() {
 		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
+	// End live streams on shutdown; they would otherwise hold it open
+	if liveHub != nil {
+		srv.RegisterOnShutdown(liveHub.Stop)
+	}
+
 	// Start server in a goroutine
 	go func() {
 		logger.Info("Starting HTTP server", "port", cfg.Server.Port)
@@ -154,6 +500,15 @@ func main() {
 		logger.Fatal("Server forced to shutdown", "error", err)
 	}
 
+	if clickConsumer != nil {
+		clickConsumer.Stop()
+	}
+
+	// Flush queued clicks now that no new requests are being served
+	if err := clickPipeline.Close(ctx); err != nil {
+		logger.Error("Failed to flush click events", "error", err)
+	}
+
 	logger.Info("Server exited")
 }
 
@@ -0,0 +1,134 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// maxRollupSpan caps the range recomputed in one transaction so catching up
+// after downtime happens in manageable steps
+const maxRollupSpan = 24 * time.Hour
+
+// RollupStore maintains the click rollup tables
+type RollupStore interface {
+	GetRollupWatermark(ctx context.Context) (time.Time, error)
+	RollupClicks(ctx context.Context, from, to time.Time) error
+}
+
+// AggregatorConfig holds rollup aggregator configuration
+type AggregatorConfig struct {
+	Interval time.Duration // how often rollups are refreshed
+	Lookback time.Duration // already rolled up hours recomputed to catch late clicks
+	Settle   time.Duration // how long an hour is left open for in-flight clicks
+	Timeout  time.Duration // time limit for a single rollup run
+}
+
+// Aggregator periodically folds click_events into hourly rollups
+type Aggregator struct {
+	store   RollupStore
+	config  AggregatorConfig
+	onError func(error)
+	now     func() time.Time
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewAggregator creates a new rollup aggregator
+func NewAggregator(store RollupStore, config AggregatorConfig, onError func(error)) *Aggregator {
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Minute
+	}
+	if config.Lookback < 0 {
+		config.Lookback = 0
+	}
+	if config.Settle <= 0 {
+		config.Settle = 2 * time.Minute
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Minute
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	return &Aggregator{
+		store:   store,
+		config:  config,
+		onError: onError,
+		now:     time.Now,
+	}
+}
+
+// Start runs the aggregator in the background until Stop is called
+func (a *Aggregator) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	a.cancel = cancel
+
+	a.wg.Add(1)
+	go a.run(ctx)
+}
+
+// Stop cancels any in-flight run and waits for the aggregator to exit
+func (a *Aggregator) Stop() {
+	if a.cancel != nil {
+		a.cancel()
+	}
+	a.wg.Wait()
+}
+
+// run refreshes rollups on every tick
+func (a *Aggregator) run(ctx context.Context) {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		a.runWithTimeout(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runWithTimeout performs one rollup run, reporting any error
+func (a *Aggregator) runWithTimeout(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, a.config.Timeout)
+	defer cancel()
+
+	if err := a.RunOnce(ctx); err != nil && ctx.Err() == nil {
+		a.onError(err)
+	}
+}
+
+// RunOnce recomputes rollups from shortly before the current watermark up
+// to the last hour that has settled
+func (a *Aggregator) RunOnce(ctx context.Context) error {
+	watermark, err := a.store.GetRollupWatermark(ctx)
+	if err != nil {
+		return err
+	}
+
+	target := a.now().Add(-a.config.Settle).Truncate(time.Hour)
+	if watermark.IsZero() {
+		watermark = target
+	}
+	from := watermark.Add(-a.config.Lookback).Truncate(time.Hour)
+
+	for from.Before(target) {
+		to := from.Add(maxRollupSpan)
+		if to.After(target) {
+			to = target
+		}
+		if err := a.store.RollupClicks(ctx, from, to); err != nil {
+			return err
+		}
+		from = to
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeRollupStore records the ranges it is asked to roll up
+type fakeRollupStore struct {
+	watermark time.Time
+	ranges    [][2]time.Time
+	err       error
+}
+
+func (s *fakeRollupStore) GetRollupWatermark(ctx context.Context) (time.Time, error) {
+	return s.watermark, nil
+}
+
+func (s *fakeRollupStore) RollupClicks(ctx context.Context, from, to time.Time) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.ranges = append(s.ranges, [2]time.Time{from, to})
+	if to.After(s.watermark) {
+		s.watermark = to
+	}
+	return nil
+}
+
+func newTestAggregator(store RollupStore, now time.Time, config AggregatorConfig) *Aggregator {
+	a := NewAggregator(store, config, nil)
+	a.now = func() time.Time { return now }
+	return a
+}
+
+func TestRunOnceRecomputesLookbackUpToSettledHour(t *testing.T) {
+	watermark := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	store := &fakeRollupStore{watermark: watermark}
+	now := time.Date(2024, 5, 1, 12, 1, 0, 0, time.UTC)
+
+	a := newTestAggregator(store, now, AggregatorConfig{Lookback: time.Hour, Settle: 2 * time.Minute})
+	if err := a.RunOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// 12:01 minus the settle delay is still inside 11:00-12:00, so that hour stays open
+	want := [2]time.Time{
+		time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC),
+		time.Date(2024, 5, 1, 11, 0, 0, 0, time.UTC),
+	}
+	if len(store.ranges) != 1 || store.ranges[0] != want {
+		t.Errorf("expected range %v, got %v", want, store.ranges)
+	}
+}
+
+func TestRunOnceCatchesUpInChunks(t *testing.T) {
+	watermark := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	store := &fakeRollupStore{watermark: watermark}
+	now := time.Date(2024, 5, 3, 12, 30, 0, 0, time.UTC)
+
+	a := newTestAggregator(store, now, AggregatorConfig{Settle: time.Minute})
+	if err := a.RunOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(store.ranges) != 3 {
+		t.Fatalf("expected 3 chunks, got %d: %v", len(store.ranges), store.ranges)
+	}
+	for i, r := range store.ranges {
+		if r[1].Sub(r[0]) > maxRollupSpan {
+			t.Errorf("chunk %d spans %v", i, r[1].Sub(r[0]))
+		}
+		if i > 0 && !r[0].Equal(store.ranges[i-1][1]) {
+			t.Errorf("chunk %d does not continue from the previous chunk", i)
+		}
+	}
+	if end := store.ranges[2][1]; !end.Equal(time.Date(2024, 5, 3, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected rollups to end at 12:00, got %v", end)
+	}
+}
+
+func TestRunOnceSkipsWhenUpToDate(t *testing.T) {
+	watermark := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store := &fakeRollupStore{watermark: watermark}
+	now := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+
+	a := newTestAggregator(store, now, AggregatorConfig{Settle: time.Minute})
+	if err := a.RunOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(store.ranges) != 0 {
+		t.Errorf("expected no rollups, got %v", store.ranges)
+	}
+}
+
+func TestRunOnceReturnsStoreErrors(t *testing.T) {
+	store := &fakeRollupStore{
+		watermark: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		err:       errors.New("database unavailable"),
+	}
+	now := time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)
+
+	a := newTestAggregator(store, now, AggregatorConfig{})
+	if err := a.RunOnce(context.Background()); err == nil {
+		t.Error("expected error")
+	}
+}
@@ -0,0 +1,215 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// visitorKeyTTL keeps a day's HyperLogLogs around long enough for the final
+// flush after the day ends
+const visitorKeyTTL = 72 * time.Hour
+
+// dayLayout formats the UTC day in visitor keys
+const dayLayout = "2006-01-02"
+
+// VisitorIDs derives pseudonymous visitor identifiers
+type VisitorIDs interface {
+	VisitorID(ctx context.Context, ipAddress, userAgent string) (string, error)
+}
+
+// VisitorStore persists daily unique visitor estimates
+type VisitorStore interface {
+	SaveDailyVisitors(ctx context.Context, day time.Time, visitors map[string]int64) error
+}
+
+// VisitorConfig holds unique visitor counter configuration
+type VisitorConfig struct {
+	Prefix        string        // Redis key prefix
+	AddTimeout    time.Duration // how long the redirect path waits for Redis
+	FlushInterval time.Duration // how often estimates are persisted
+	BatchSize     int           // links counted per Redis round trip
+	Timeout       time.Duration // time limit for a single flush
+}
+
+// VisitorCounter estimates unique visitors per link and UTC day with Redis
+// HyperLogLogs and periodically persists the estimates. Each day has one
+// HyperLogLog per link plus a set of the links seen that day, so a flush
+// only touches links that were clicked.
+type VisitorCounter struct {
+	client  redis.Cmdable
+	ids     VisitorIDs
+	store   VisitorStore
+	config  VisitorConfig
+	onError func(error)
+	now     func() time.Time
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewVisitorCounter creates a unique visitor counter
+func NewVisitorCounter(client redis.Cmdable, ids VisitorIDs, store VisitorStore, config VisitorConfig, onError func(error)) *VisitorCounter {
+	if config.Prefix == "" {
+		config.Prefix = "visitors:"
+	}
+	if config.AddTimeout <= 0 {
+		config.AddTimeout = 50 * time.Millisecond
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = 5 * time.Minute
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 5 * time.Minute
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	return &VisitorCounter{
+		client:  client,
+		ids:     ids,
+		store:   store,
+		config:  config,
+		onError: onError,
+		now:     time.Now,
+	}
+}
+
+// Add counts a visit to a link. Failures are reported to the error handler
+// and never block the redirect for longer than the add timeout.
+func (v *VisitorCounter) Add(ctx context.Context, code, ipAddress, userAgent string) {
+	ctx, cancel := context.WithTimeout(ctx, v.config.AddTimeout)
+	defer cancel()
+
+	id, err := v.ids.VisitorID(ctx, ipAddress, userAgent)
+	if err != nil {
+		v.onError(err)
+		return
+	}
+
+	day := v.now().UTC().Format(dayLayout)
+	hll, seen := v.hllKey(day, code), v.seenKey(day)
+
+	_, err = v.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.PFAdd(ctx, hll, id)
+		pipe.Expire(ctx, hll, visitorKeyTTL)
+		pipe.SAdd(ctx, seen, code)
+		pipe.Expire(ctx, seen, visitorKeyTTL)
+		return nil
+	})
+	if err != nil {
+		v.onError(err)
+	}
+}
+
+// Start persists estimates in the background until Stop is called
+func (v *VisitorCounter) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	v.cancel = cancel
+
+	v.wg.Add(1)
+	go v.run(ctx)
+}
+
+// Stop cancels any in-flight flush and waits for the counter to exit
+func (v *VisitorCounter) Stop() {
+	if v.cancel != nil {
+		v.cancel()
+	}
+	v.wg.Wait()
+}
+
+// run flushes estimates on every tick
+func (v *VisitorCounter) run(ctx context.Context) {
+	defer v.wg.Done()
+
+	ticker := time.NewTicker(v.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		flushCtx, cancel := context.WithTimeout(ctx, v.config.Timeout)
+		if err := v.Flush(flushCtx); err != nil && ctx.Err() == nil {
+			v.onError(err)
+		}
+		cancel()
+	}
+}
+
+// Flush persists the estimates for today and yesterday. Yesterday is
+// flushed again so visits recorded just before midnight are not lost; once
+// it has been written, its set of seen links is removed.
+func (v *VisitorCounter) Flush(ctx context.Context) error {
+	today := v.now().UTC().Truncate(24 * time.Hour)
+	yesterday := today.Add(-24 * time.Hour)
+
+	if err := v.flushDay(ctx, yesterday); err != nil {
+		return err
+	}
+	if err := v.client.Del(ctx, v.seenKey(yesterday.Format(dayLayout))).Err(); err != nil {
+		return err
+	}
+
+	return v.flushDay(ctx, today)
+}
+
+// flushDay persists the estimates for every link seen on day
+func (v *VisitorCounter) flushDay(ctx context.Context, day time.Time) error {
+	key := day.Format(dayLayout)
+	var cursor uint64
+
+	for {
+		codes, next, err := v.client.SScan(ctx, v.seenKey(key), cursor, "", int64(v.config.BatchSize)).Result()
+		if err != nil {
+			return err
+		}
+
+		if len(codes) > 0 {
+			counts := make([]*redis.IntCmd, len(codes))
+			_, err := v.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				for i, code := range codes {
+					counts[i] = pipe.PFCount(ctx, v.hllKey(key, code))
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			visitors := make(map[string]int64, len(codes))
+			for i, code := range codes {
+				if n := counts[i].Val(); n > 0 {
+					visitors[code] = n
+				}
+			}
+			if err := v.store.SaveDailyVisitors(ctx, day, visitors); err != nil {
+				return err
+			}
+		}
+
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// hllKey returns the HyperLogLog key for a link on a day
+func (v *VisitorCounter) hllKey(day, code string) string {
+	return v.config.Prefix + "hll:" + day + ":" + code
+}
+
+// seenKey returns the key of the set of links visited on a day
+func (v *VisitorCounter) seenKey(day string) string {
+	return v.config.Prefix + "links:" + day
+}
@@ -0,0 +1,134 @@
+package analytics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// plainVisitorIDs identifies visitors by their address and User-Agent
+type plainVisitorIDs struct {
+	err error
+}
+
+func (p plainVisitorIDs) VisitorID(ctx context.Context, ipAddress, userAgent string) (string, error) {
+	return ipAddress + "|" + userAgent, p.err
+}
+
+// fakeVisitorStore keeps the largest estimate saved for each link and day,
+// like the upsert in the repository
+type fakeVisitorStore struct {
+	saved map[string]map[string]int64
+	saves int
+}
+
+func (s *fakeVisitorStore) SaveDailyVisitors(ctx context.Context, day time.Time, visitors map[string]int64) error {
+	s.saves++
+	key := day.Format(dayLayout)
+	if s.saved[key] == nil {
+		s.saved[key] = make(map[string]int64)
+	}
+	for code, count := range visitors {
+		if count > s.saved[key][code] {
+			s.saved[key][code] = count
+		}
+	}
+	return nil
+}
+
+func newTestVisitorCounter(t *testing.T, now *time.Time, ids VisitorIDs, onError func(error)) (*VisitorCounter, *fakeVisitorStore, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	store := &fakeVisitorStore{saved: make(map[string]map[string]int64)}
+	v := NewVisitorCounter(client, ids, store, VisitorConfig{AddTimeout: time.Second, BatchSize: 2}, onError)
+	v.now = func() time.Time { return *now }
+	return v, store, server
+}
+
+func TestVisitorCounterCountsDistinctVisitorsPerDay(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	v, store, server := newTestVisitorCounter(t, &now, plainVisitorIDs{}, nil)
+	ctx := context.Background()
+
+	v.Add(ctx, "abc", "203.0.113.7", "Firefox")
+	v.Add(ctx, "abc", "203.0.113.7", "Firefox")
+	v.Add(ctx, "abc", "198.51.100.9", "Safari")
+	v.Add(ctx, "xyz", "203.0.113.7", "Firefox")
+	v.Add(ctx, "def", "192.0.2.1", "Chrome")
+
+	if !server.Exists("visitors:hll:2024-05-01:abc") {
+		t.Fatal("expected a HyperLogLog for the link")
+	}
+	if ttl := server.TTL("visitors:hll:2024-05-01:abc"); ttl != visitorKeyTTL {
+		t.Errorf("expected the HyperLogLog to expire in %v, got %v", visitorKeyTTL, ttl)
+	}
+	if members, _ := server.SMembers("visitors:links:2024-05-01"); len(members) != 3 {
+		t.Errorf("expected 3 links seen today, got %v", members)
+	}
+
+	if err := v.Flush(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]int64{"abc": 2, "xyz": 1, "def": 1}
+	for code, count := range expected {
+		if got := store.saved["2024-05-01"][code]; got != count {
+			t.Errorf("%s: expected %d visitors, got %d", code, count, got)
+		}
+	}
+	// Three links in batches of two
+	if store.saves < 2 {
+		t.Errorf("expected the links to be saved in batches, got %d saves", store.saves)
+	}
+}
+
+func TestVisitorCounterFlushesYesterdayOnceMore(t *testing.T) {
+	now := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	v, store, server := newTestVisitorCounter(t, &now, plainVisitorIDs{}, nil)
+	ctx := context.Background()
+
+	v.Add(ctx, "abc", "203.0.113.7", "Firefox")
+	if err := v.Flush(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// A visit just before midnight, after the last flush of the day
+	v.Add(ctx, "abc", "198.51.100.9", "Safari")
+
+	now = time.Date(2024, 5, 2, 0, 5, 0, 0, time.UTC)
+	v.Add(ctx, "abc", "203.0.113.7", "Firefox")
+	if err := v.Flush(ctx); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := store.saved["2024-05-01"]["abc"]; got != 2 {
+		t.Errorf("expected yesterday's late visit to be persisted, got %d", got)
+	}
+	if got := store.saved["2024-05-02"]["abc"]; got != 1 {
+		t.Errorf("expected 1 visitor today, got %d", got)
+	}
+	if server.Exists("visitors:links:2024-05-01") {
+		t.Error("expected yesterday's set of links to be removed after its final flush")
+	}
+	if !server.Exists("visitors:links:2024-05-02") {
+		t.Error("expected today's set of links to be kept")
+	}
+}
+
+func TestVisitorCounterReportsFailures(t *testing.T) {
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	var errs []error
+	v, _, server := newTestVisitorCounter(t, &now, plainVisitorIDs{err: errors.New("no salt")}, func(err error) {
+		errs = append(errs, err)
+	})
+
+	v.Add(context.Background(), "abc", "203.0.113.7", "Firefox")
+	if len(errs) != 1 || server.Exists("visitors:links:2024-05-01") {
+		t.Errorf("expected the visit to be dropped and reported, got %v", errs)
+	}
+}
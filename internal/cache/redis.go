@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/urlshortener/internal/errors"
 	"github.com/urlshortener/internal/models"
 )
 
@@ -19,10 +20,16 @@ type RedisCache struct {
 
 // CachedURL represents a URL stored in cache
 type CachedURL struct {
-	LongURL   string     `json:"long_url"`
-	ExpireAt  *time.Time `json:"expire_at,omitempty"`
-	IsDeleted bool       `json:"is_deleted"`
-	CreatedAt time.Time  `json:"created_at"`
+	LongURL          string     `json:"long_url"`
+	ExpireAt         *time.Time `json:"expire_at,omitempty"`
+	IsDeleted        bool       `json:"is_deleted"`
+	CreatedAt        time.Time  `json:"created_at"`
+	Title            *string    `json:"title,omitempty"`
+	ShowInterstitial bool       `json:"show_interstitial,omitempty"`
+	OGTitle          *string    `json:"og_title,omitempty"`
+	OGDescription    *string    `json:"og_description,omitempty"`
+	OGImage          *string    `json:"og_image,omitempty"`
+	Status           string     `json:"status,omitempty"`
 }
 
 // NewRedisCache creates a new Redis cache instance
@@ -43,6 +50,12 @@ func NewRedisCache(addr, password string, db int, ttl, negativeTTL time.Duration
 	}
 }
 
+// Client returns the underlying Redis client so other components can share
+// its connection pool
+func (c *RedisCache) Client() *redis.Client {
+	return c.client
+}
+
 // Close closes the Redis connection
 func (c *RedisCache) Close() error {
 	return c.client.Close()
@@ -75,12 +88,24 @@ func (c *RedisCache) Get(ctx context.Context, code string) (*models.ShortURL, er
 		return nil, ErrURLExpired
 	}
 
+	// Entries cached before links had a status are active
+	status := cached.Status
+	if status == "" {
+		status = models.LinkActive
+	}
+
 	return &models.ShortURL{
-		Code:      code,
-		LongURL:   cached.LongURL,
-		CreatedAt: cached.CreatedAt,
-		ExpireAt:  cached.ExpireAt,
-		IsDeleted: cached.IsDeleted,
+		Code:             code,
+		LongURL:          cached.LongURL,
+		CreatedAt:        cached.CreatedAt,
+		ExpireAt:         cached.ExpireAt,
+		IsDeleted:        cached.IsDeleted,
+		Title:            cached.Title,
+		ShowInterstitial: cached.ShowInterstitial,
+		OGTitle:          cached.OGTitle,
+		OGDescription:    cached.OGDescription,
+		OGImage:          cached.OGImage,
+		Status:           status,
 	}, nil
 }
 
@@ -89,10 +114,16 @@ func (c *RedisCache) Set(ctx context.Context, code string, url *models.ShortURL)
 	key := fmt.Sprintf("url:%s", code)
 	
 	cached := CachedURL{
-		LongURL:   url.LongURL,
-		ExpireAt:  url.ExpireAt,
-		IsDeleted: url.IsDeleted,
-		CreatedAt: url.CreatedAt,
+		LongURL:          url.LongURL,
+		ExpireAt:         url.ExpireAt,
+		IsDeleted:        url.IsDeleted,
+		CreatedAt:        url.CreatedAt,
+		Title:            url.Title,
+		ShowInterstitial: url.ShowInterstitial,
+		OGTitle:          url.OGTitle,
+		OGDescription:    url.OGDescription,
+		OGImage:          url.OGImage,
+		Status:           url.Status,
 	}
 
 	data, err := json.Marshal(cached)
@@ -210,6 +241,6 @@ func (c *RedisCache) Flush(ctx context.Context) error {
 // Custom errors
 var (
 	ErrCacheMiss   = fmt.Errorf("cache miss")
-	ErrURLDeleted  = fmt.Errorf("URL is deleted")
-	ErrURLExpired  = fmt.Errorf("URL has expired")
+	ErrURLDeleted  = errors.ErrURLDeleted
+	ErrURLExpired  = errors.ErrURLExpired
 )
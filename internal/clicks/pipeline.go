@@ -0,0 +1,270 @@
+package clicks
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+// DropPolicy decides what happens to a click when the queue is full
+type DropPolicy string
+
+const (
+	// DropNewest discards the incoming click
+	DropNewest DropPolicy = "drop_newest"
+	// DropOldest discards the oldest queued click to make room
+	DropOldest DropPolicy = "drop_oldest"
+	// Block waits up to BlockTimeout for room, then discards the click
+	Block DropPolicy = "block"
+)
+
+// Drop reasons reported to Metrics
+const (
+	ReasonQueueFull = "queue_full"
+	ReasonEvicted   = "evicted"
+	ReasonTimeout   = "timeout"
+	ReasonClosed    = "closed"
+	ReasonWrite     = "write_failed"
+)
+
+// Config holds pipeline configuration
+type Config struct {
+	QueueSize     int
+	Workers       int
+	BatchSize     int
+	FlushInterval time.Duration
+	DropPolicy    DropPolicy
+	BlockTimeout  time.Duration
+	WriteTimeout  time.Duration
+}
+
+// Store persists batches of click events. Events with a StreamID that has
+// already been recorded are skipped. When the events themselves are at
+// fault, such as a value out of range, the error wraps ErrRejectedClicks.
+type Store interface {
+	RecordClicks(ctx context.Context, events []*models.ClickEvent) error
+}
+
+// Metrics receives pipeline instrumentation
+type Metrics interface {
+	SetClickQueueDepth(depth int)
+	RecordClicksDropped(reason string, count int)
+	ObserveClickBatch(size int, duration time.Duration, err error)
+}
+
+// ErrorHandler is notified when a batch could not be written
+type ErrorHandler func(count int, err error)
+
+// Pipeline buffers click events in a bounded queue and writes them in
+// batches from a fixed pool of workers
+type Pipeline struct {
+	store   Store
+	config  Config
+	metrics Metrics
+	onError ErrorHandler
+	events  chan *models.ClickEvent
+	mu      sync.RWMutex
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+// NewPipeline creates a new click pipeline. It fails on an unknown drop
+// policy rather than guess at one.
+func NewPipeline(store Store, config Config, metrics Metrics, onError ErrorHandler) (*Pipeline, error) {
+	if config.QueueSize <= 0 {
+		config.QueueSize = 10000
+	}
+	if config.Workers <= 0 {
+		config.Workers = 2
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+	if config.FlushInterval <= 0 {
+		config.FlushInterval = time.Second
+	}
+	switch config.DropPolicy {
+	case "":
+		config.DropPolicy = DropNewest
+	case DropNewest, DropOldest, Block:
+	default:
+		return nil, fmt.Errorf("%w %q: must be one of %s, %s, %s", ErrUnknownDropPolicy, config.DropPolicy, DropNewest, DropOldest, Block)
+	}
+	if config.BlockTimeout <= 0 {
+		config.BlockTimeout = 10 * time.Millisecond
+	}
+	if config.WriteTimeout <= 0 {
+		config.WriteTimeout = 10 * time.Second
+	}
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
+	if onError == nil {
+		onError = func(int, error) {}
+	}
+
+	return &Pipeline{
+		store:   store,
+		config:  config,
+		metrics: metrics,
+		onError: onError,
+		events:  make(chan *models.ClickEvent, config.QueueSize),
+	}, nil
+}
+
+// Start launches the batching workers
+func (p *Pipeline) Start() {
+	for i := 0; i < p.config.Workers; i++ {
+		p.wg.Add(1)
+		go p.work()
+	}
+}
+
+// Submit queues a click event without waiting for it to be written. It
+// reports false if the event was dropped.
+func (p *Pipeline) Submit(event *models.ClickEvent) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.closed {
+		p.metrics.RecordClicksDropped(ReasonClosed, 1)
+		return false
+	}
+
+	accepted := p.enqueue(event)
+	p.metrics.SetClickQueueDepth(len(p.events))
+	return accepted
+}
+
+// enqueue applies the drop policy when the queue is full
+func (p *Pipeline) enqueue(event *models.ClickEvent) bool {
+	select {
+	case p.events <- event:
+		return true
+	default:
+	}
+
+	switch p.config.DropPolicy {
+	case DropOldest:
+		// Workers may race us for the freed slot, so retry a few times
+		for i := 0; i < 3; i++ {
+			select {
+			case <-p.events:
+				p.metrics.RecordClicksDropped(ReasonEvicted, 1)
+			default:
+			}
+			select {
+			case p.events <- event:
+				return true
+			default:
+			}
+		}
+		p.metrics.RecordClicksDropped(ReasonQueueFull, 1)
+		return false
+
+	case Block:
+		timer := time.NewTimer(p.config.BlockTimeout)
+		defer timer.Stop()
+		select {
+		case p.events <- event:
+			return true
+		case <-timer.C:
+			p.metrics.RecordClicksDropped(ReasonTimeout, 1)
+			return false
+		}
+
+	default:
+		p.metrics.RecordClicksDropped(ReasonQueueFull, 1)
+		return false
+	}
+}
+
+// Close stops accepting events and flushes everything already queued. It
+// returns an error if ctx expires before the workers finish.
+func (p *Pipeline) Close(ctx context.Context) error {
+	p.mu.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.events)
+	}
+	p.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("click pipeline did not drain: %w", ctx.Err())
+	}
+}
+
+// work collects events into batches, flushing when a batch is full or the
+// flush interval elapses
+func (p *Pipeline) work() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.ClickEvent, 0, p.config.BatchSize)
+	for {
+		select {
+		case event, ok := <-p.events:
+			if !ok {
+				p.flush(batch)
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= p.config.BatchSize {
+				p.flush(batch)
+				batch = make([]*models.ClickEvent, 0, p.config.BatchSize)
+			}
+
+		case <-ticker.C:
+			if len(batch) > 0 {
+				p.flush(batch)
+				batch = make([]*models.ClickEvent, 0, p.config.BatchSize)
+			}
+		}
+	}
+}
+
+// flush writes a batch to the store
+func (p *Pipeline) flush(batch []*models.ClickEvent) {
+	if len(batch) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.WriteTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := p.store.RecordClicks(ctx, batch)
+	p.metrics.ObserveClickBatch(len(batch), time.Since(start), err)
+	p.metrics.SetClickQueueDepth(len(p.events))
+
+	if err != nil {
+		p.metrics.RecordClicksDropped(ReasonWrite, len(batch))
+		p.onError(len(batch), err)
+	}
+}
+
+// noopMetrics is used when no metrics are configured
+type noopMetrics struct{}
+
+func (noopMetrics) SetClickQueueDepth(int)                      {}
+func (noopMetrics) RecordClicksDropped(string, int)             {}
+func (noopMetrics) ObserveClickBatch(int, time.Duration, error) {}
+
+// Custom errors
+var (
+	ErrUnknownDropPolicy = fmt.Errorf("unknown click drop policy")
+)
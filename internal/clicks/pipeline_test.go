@@ -0,0 +1,235 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+// fakeStore records the batches it receives, optionally blocking writes
+type fakeStore struct {
+	mu      sync.Mutex
+	batches [][]*models.ClickEvent
+	gate    chan struct{}
+	err     error
+}
+
+func (s *fakeStore) RecordClicks(ctx context.Context, events []*models.ClickEvent) error {
+	if s.gate != nil {
+		<-s.gate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.batches = append(s.batches, events)
+	return s.err
+}
+
+func (s *fakeStore) total() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, batch := range s.batches {
+		n += len(batch)
+	}
+	return n
+}
+
+// fakeMetrics counts dropped events by reason
+type fakeMetrics struct {
+	mu      sync.Mutex
+	dropped map[string]int
+}
+
+func newFakeMetrics() *fakeMetrics {
+	return &fakeMetrics{dropped: make(map[string]int)}
+}
+
+func (m *fakeMetrics) SetClickQueueDepth(int) {}
+
+func (m *fakeMetrics) RecordClicksDropped(reason string, count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped[reason] += count
+}
+
+func (m *fakeMetrics) ObserveClickBatch(int, time.Duration, error) {}
+
+func (m *fakeMetrics) droppedFor(reason string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.dropped[reason]
+}
+
+func click(code string) *models.ClickEvent {
+	return &models.ClickEvent{Code: code, Timestamp: time.Now()}
+}
+
+func TestPipelineBatchesBySize(t *testing.T) {
+	store := &fakeStore{}
+	p, _ := NewPipeline(store, Config{Workers: 1, BatchSize: 3, FlushInterval: time.Hour}, nil, nil)
+	p.Start()
+
+	for i := 0; i < 7; i++ {
+		if !p.Submit(click("abc")) {
+			t.Fatalf("click %d was dropped", i)
+		}
+	}
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+
+	if len(store.batches) != 3 {
+		t.Fatalf("expected 3 batches, got %d", len(store.batches))
+	}
+	if len(store.batches[0]) != 3 || len(store.batches[2]) != 1 {
+		t.Errorf("unexpected batch sizes: %d, %d, %d",
+			len(store.batches[0]), len(store.batches[1]), len(store.batches[2]))
+	}
+}
+
+func TestPipelineFlushesOnInterval(t *testing.T) {
+	store := &fakeStore{}
+	p, _ := NewPipeline(store, Config{Workers: 1, BatchSize: 100, FlushInterval: 20 * time.Millisecond}, nil, nil)
+	p.Start()
+	defer p.Close(context.Background())
+
+	p.Submit(click("abc"))
+
+	deadline := time.Now().Add(time.Second)
+	for store.total() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected partial batch to be flushed on interval")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestPipelineRejectsUnknownDropPolicy(t *testing.T) {
+	if _, err := NewPipeline(&fakeStore{}, Config{DropPolicy: "drop-oldest"}, nil, nil); !errors.Is(err, ErrUnknownDropPolicy) {
+		t.Errorf("expected ErrUnknownDropPolicy, got %v", err)
+	}
+
+	p, err := NewPipeline(&fakeStore{}, Config{}, nil, nil)
+	if err != nil || p.config.DropPolicy != DropNewest {
+		t.Errorf("expected the default policy to be drop_newest, got %v", err)
+	}
+}
+
+func TestPipelineDropNewest(t *testing.T) {
+	metrics := newFakeMetrics()
+	p, _ := NewPipeline(&fakeStore{}, Config{QueueSize: 2, DropPolicy: DropNewest}, metrics, nil)
+
+	// Workers are not started, so the queue fills up
+	p.Submit(click("a"))
+	p.Submit(click("b"))
+	if p.Submit(click("c")) {
+		t.Error("expected submit to a full queue to fail")
+	}
+	if got := metrics.droppedFor(ReasonQueueFull); got != 1 {
+		t.Errorf("expected 1 queue_full drop, got %d", got)
+	}
+}
+
+func TestPipelineDropOldest(t *testing.T) {
+	metrics := newFakeMetrics()
+	store := &fakeStore{}
+	p, _ := NewPipeline(store, Config{QueueSize: 2, DropPolicy: DropOldest}, metrics, nil)
+
+	p.Submit(click("a"))
+	p.Submit(click("b"))
+	if !p.Submit(click("c")) {
+		t.Fatal("expected newest click to be accepted")
+	}
+	if got := metrics.droppedFor(ReasonEvicted); got != 1 {
+		t.Errorf("expected 1 evicted drop, got %d", got)
+	}
+
+	p.Start()
+	p.Close(context.Background())
+
+	var codes []string
+	for _, batch := range store.batches {
+		for _, event := range batch {
+			codes = append(codes, event.Code)
+		}
+	}
+	if len(codes) != 2 || codes[0] != "b" || codes[1] != "c" {
+		t.Errorf("expected [b c] to be written, got %v", codes)
+	}
+}
+
+func TestPipelineBlockTimesOut(t *testing.T) {
+	metrics := newFakeMetrics()
+	p, _ := NewPipeline(&fakeStore{}, Config{QueueSize: 1, DropPolicy: Block, BlockTimeout: 10 * time.Millisecond}, metrics, nil)
+
+	p.Submit(click("a"))
+
+	start := time.Now()
+	if p.Submit(click("b")) {
+		t.Error("expected blocked submit to time out")
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("expected submit to wait for the block timeout, waited %v", elapsed)
+	}
+	if got := metrics.droppedFor(ReasonTimeout); got != 1 {
+		t.Errorf("expected 1 timeout drop, got %d", got)
+	}
+}
+
+func TestPipelineRejectsAfterClose(t *testing.T) {
+	metrics := newFakeMetrics()
+	p, _ := NewPipeline(&fakeStore{}, Config{}, metrics, nil)
+	p.Start()
+
+	if err := p.Close(context.Background()); err != nil {
+		t.Fatalf("unexpected close error: %v", err)
+	}
+	if p.Submit(click("a")) {
+		t.Error("expected submit after close to fail")
+	}
+	if got := metrics.droppedFor(ReasonClosed); got != 1 {
+		t.Errorf("expected 1 closed drop, got %d", got)
+	}
+}
+
+func TestPipelineCloseHonoursContext(t *testing.T) {
+	store := &fakeStore{gate: make(chan struct{})}
+	defer close(store.gate)
+
+	p, _ := NewPipeline(store, Config{Workers: 1}, nil, nil)
+	p.Start()
+	p.Submit(click("a"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := p.Close(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestPipelineReportsWriteErrors(t *testing.T) {
+	metrics := newFakeMetrics()
+	store := &fakeStore{err: errors.New("database unavailable")}
+
+	var failed int
+	p, _ := NewPipeline(store, Config{Workers: 1}, metrics, func(count int, err error) {
+		failed += count
+	})
+	p.Start()
+	p.Submit(click("a"))
+	p.Submit(click("b"))
+	p.Close(context.Background())
+
+	if failed != 2 {
+		t.Errorf("expected error handler to see 2 clicks, got %d", failed)
+	}
+	if got := metrics.droppedFor(ReasonWrite); got != 2 {
+		t.Errorf("expected 2 write_failed drops, got %d", got)
+	}
+}
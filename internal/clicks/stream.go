@@ -0,0 +1,572 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/urlshortener/internal/models"
+)
+
+// Drop reasons specific to the stream buffer
+const (
+	ReasonStreamUnavailable = "stream_unavailable"
+	ReasonMalformed         = "malformed"
+	ReasonDeadLetter        = "dead_letter"
+	ReasonTrimmed           = "trimmed"
+)
+
+// Stream entry field names
+const (
+	fieldCode       = "code"
+	fieldTimestamp  = "ts"
+	fieldUserAgent  = "ua"
+	fieldIPAddress  = "ip"
+	fieldIPHash     = "iph"
+	fieldReferer    = "ref"
+	fieldCountry    = "country"
+	fieldDeviceType = "device"
+	fieldBrowser    = "browser"
+	fieldOS         = "os"
+	fieldBot        = "bot"
+)
+
+// StreamConfig holds Redis Stream buffer configuration
+type StreamConfig struct {
+	Stream        string
+	Group         string
+	Consumer      string
+	MaxLen        int64         // approximate cap on stream length, reached only if writes stall
+	AddTimeout    time.Duration // how long the redirect path waits for XADD
+	BatchSize     int
+	ReadBlock     time.Duration // how long XREADGROUP waits for new entries
+	ClaimInterval time.Duration
+	ClaimMinIdle  time.Duration // pending entries idle this long are reclaimed
+	MaxDeliveries int64         // entries delivered this often are discarded
+	WriteTimeout  time.Duration
+}
+
+// withDefaults fills in unset configuration values
+func (c StreamConfig) withDefaults() StreamConfig {
+	if c.Stream == "" {
+		c.Stream = "clicks"
+	}
+	if c.Group == "" {
+		c.Group = "click-writers"
+	}
+	if c.Consumer == "" {
+		c.Consumer = "default"
+	}
+	if c.MaxLen <= 0 {
+		c.MaxLen = 1000000
+	}
+	if c.AddTimeout <= 0 {
+		c.AddTimeout = 50 * time.Millisecond
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = 500
+	}
+	if c.ReadBlock <= 0 {
+		c.ReadBlock = 2 * time.Second
+	}
+	if c.ClaimInterval <= 0 {
+		c.ClaimInterval = 30 * time.Second
+	}
+	if c.ClaimMinIdle <= 0 {
+		c.ClaimMinIdle = time.Minute
+	}
+	if c.MaxDeliveries <= 0 {
+		c.MaxDeliveries = 10
+	}
+	if c.WriteTimeout <= 0 {
+		c.WriteTimeout = 10 * time.Second
+	}
+	return c
+}
+
+// Sink accepts click events
+type Sink interface {
+	Submit(event *models.ClickEvent) bool
+}
+
+// StreamProducer appends click events to a Redis Stream
+type StreamProducer struct {
+	client   redis.Cmdable
+	config   StreamConfig
+	fallback Sink
+	metrics  Metrics
+}
+
+// NewStreamProducer creates a producer. Events that cannot be appended to the
+// stream are handed to fallback, or dropped if fallback is nil.
+func NewStreamProducer(client redis.Cmdable, config StreamConfig, fallback Sink, metrics Metrics) *StreamProducer {
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
+
+	return &StreamProducer{
+		client:   client,
+		config:   config.withDefaults(),
+		fallback: fallback,
+		metrics:  metrics,
+	}
+}
+
+// Submit appends a click event to the stream. It reports false if the event
+// was dropped. The stream is capped at MaxLen as a last resort against
+// running Redis out of memory; consumers trim written entries long before
+// that, so the cap only discards entries while writes are failing, and
+// consumers report those as dropped.
+func (p *StreamProducer) Submit(event *models.ClickEvent) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), p.config.AddTimeout)
+	defer cancel()
+
+	err := p.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: p.config.Stream,
+		MaxLen: p.config.MaxLen,
+		Approx: true,
+		Values: encodeEvent(event),
+	}).Err()
+	if err == nil {
+		return true
+	}
+
+	if p.fallback != nil {
+		return p.fallback.Submit(event)
+	}
+
+	p.metrics.RecordClicksDropped(ReasonStreamUnavailable, 1)
+	return false
+}
+
+// StreamConsumer drains a Redis Stream into the store as a member of a
+// consumer group. Entries are acknowledged only after the batch containing
+// them has been committed, so a crash leaves them pending for another
+// consumer to reclaim.
+type StreamConsumer struct {
+	client  redis.Cmdable
+	store   Store
+	config  StreamConfig
+	metrics Metrics
+	onError ErrorHandler
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewStreamConsumer creates a new stream consumer
+func NewStreamConsumer(client redis.Cmdable, store Store, config StreamConfig, metrics Metrics, onError ErrorHandler) *StreamConsumer {
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
+	if onError == nil {
+		onError = func(int, error) {}
+	}
+
+	return &StreamConsumer{
+		client:  client,
+		store:   store,
+		config:  config.withDefaults(),
+		metrics: metrics,
+		onError: onError,
+	}
+}
+
+// Start creates the consumer group if needed and launches the consumer loop
+func (c *StreamConsumer) Start(ctx context.Context) error {
+	err := c.client.XGroupCreateMkStream(ctx, c.config.Stream, c.config.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	ctx, c.cancel = context.WithCancel(context.Background())
+	c.wg.Add(1)
+	go c.run(ctx)
+
+	return nil
+}
+
+// Stop stops the consumer loop after the current batch. Entries that were
+// read but not yet written stay pending and are reclaimed later.
+func (c *StreamConsumer) Stop() {
+	if c.cancel != nil {
+		c.cancel()
+	}
+	c.wg.Wait()
+}
+
+// run reads new entries and periodically reclaims stale pending ones
+func (c *StreamConsumer) run(ctx context.Context) {
+	defer c.wg.Done()
+
+	// Pick up anything left pending by a previous run before reading new entries
+	c.reclaim(ctx)
+	lastClaim := time.Now()
+
+	for ctx.Err() == nil {
+		if time.Since(lastClaim) >= c.config.ClaimInterval {
+			c.reclaim(ctx)
+			lastClaim = time.Now()
+		}
+
+		streams, err := c.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    c.config.Group,
+			Consumer: c.config.Consumer,
+			Streams:  []string{c.config.Stream, ">"},
+			Count:    int64(c.config.BatchSize),
+			Block:    c.config.ReadBlock,
+		}).Result()
+		if err != nil {
+			if err == redis.Nil || ctx.Err() != nil {
+				continue
+			}
+			c.onError(0, fmt.Errorf("failed to read click stream: %w", err))
+			c.sleep(ctx, time.Second)
+			continue
+		}
+
+		for _, stream := range streams {
+			c.process(stream.Messages)
+		}
+	}
+}
+
+// reclaim takes over entries that have been pending longer than
+// ClaimMinIdle, typically because their consumer died mid-batch
+func (c *StreamConsumer) reclaim(ctx context.Context) {
+	c.trim(ctx)
+	c.discardDeadLetters(ctx)
+
+	start := "0-0"
+	for ctx.Err() == nil {
+		messages, next, err := c.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+			Stream:   c.config.Stream,
+			Group:    c.config.Group,
+			Consumer: c.config.Consumer,
+			MinIdle:  c.config.ClaimMinIdle,
+			Start:    start,
+			Count:    int64(c.config.BatchSize),
+		}).Result()
+		if err != nil {
+			c.onError(0, fmt.Errorf("failed to reclaim pending clicks: %w", err))
+			return
+		}
+
+		c.process(messages)
+
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+// discardDeadLetters acknowledges pending entries that have failed too many
+// times, so one bad entry cannot block its batch forever
+func (c *StreamConsumer) discardDeadLetters(ctx context.Context) {
+	pending, err := c.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: c.config.Stream,
+		Group:  c.config.Group,
+		Idle:   c.config.ClaimMinIdle,
+		Start:  "-",
+		End:    "+",
+		Count:  int64(c.config.BatchSize),
+	}).Result()
+	if err != nil {
+		c.onError(0, fmt.Errorf("failed to inspect pending clicks: %w", err))
+		return
+	}
+
+	var dead []string
+	for _, entry := range pending {
+		if entry.RetryCount >= c.config.MaxDeliveries {
+			dead = append(dead, entry.ID)
+		}
+	}
+	if len(dead) == 0 {
+		return
+	}
+
+	if err := c.client.XAck(ctx, c.config.Stream, c.config.Group, dead...).Err(); err != nil {
+		c.onError(len(dead), fmt.Errorf("failed to discard dead clicks: %w", err))
+		return
+	}
+	c.metrics.RecordClicksDropped(ReasonDeadLetter, len(dead))
+}
+
+// lastDeliveredScript returns the ID of the last entry delivered to a
+// consumer group. go-redis parses XINFO GROUPS replies of Redis 6 only.
+var lastDeliveredScript = redis.NewScript(`
+for _, group in ipairs(redis.call('XINFO', 'GROUPS', KEYS[1])) do
+  local fields = {}
+  for i = 1, #group, 2 do
+    fields[group[i]] = group[i + 1]
+  end
+  if fields['name'] == ARGV[1] then
+    return fields['last-delivered-id']
+  end
+end
+return false
+`)
+
+// trim removes entries the group has written, so the MaxLen cap is kept
+// for entries that are still pending or undelivered. Entries the cap has
+// trimmed before they were written are reported as dropped.
+func (c *StreamConsumer) trim(ctx context.Context) {
+	lastDelivered, err := lastDeliveredScript.Run(ctx, c.client, []string{c.config.Stream}, c.config.Group).Text()
+	if err == redis.Nil {
+		return
+	}
+	if err != nil {
+		c.onError(0, fmt.Errorf("failed to inspect click stream: %w", err))
+		return
+	}
+
+	pending, err := c.client.XPending(ctx, c.config.Stream, c.config.Group).Result()
+	if err != nil {
+		c.onError(0, fmt.Errorf("failed to inspect pending clicks: %w", err))
+		return
+	}
+	first, err := c.client.XRangeN(ctx, c.config.Stream, "-", "+", 1).Result()
+	if err != nil {
+		c.onError(0, fmt.Errorf("failed to read click stream: %w", err))
+		return
+	}
+
+	// Written entries are trimmed by MINID, so the stream only starts past
+	// the next undelivered entry if MaxLen trimmed it
+	next := nextStreamID(lastDelivered)
+	if len(first) > 0 && lastDelivered != "0-0" && compareStreamIDs(first[0].ID, next) > 0 {
+		c.onError(0, fmt.Errorf("%w: undelivered clicks before %s", ErrClicksTrimmed, first[0].ID))
+	}
+	if pending.Count > 0 && (len(first) == 0 || compareStreamIDs(pending.Lower, first[0].ID) < 0) {
+		end := "+"
+		if len(first) > 0 {
+			end = "(" + first[0].ID
+		}
+		c.discardTrimmed(ctx, end)
+	}
+
+	// Entries below the oldest pending one, or up to the last delivered one
+	// when nothing is pending, have been written
+	minID := next
+	if pending.Count > 0 {
+		minID = pending.Lower
+	}
+	if err := c.client.XTrimMinIDApprox(ctx, c.config.Stream, minID, 0).Err(); err != nil {
+		c.onError(0, fmt.Errorf("failed to trim click stream: %w", err))
+	}
+}
+
+// discardTrimmed acknowledges pending entries up to end that are no longer
+// in the stream because the MaxLen cap trimmed them
+func (c *StreamConsumer) discardTrimmed(ctx context.Context, end string) {
+	lost, err := c.client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: c.config.Stream,
+		Group:  c.config.Group,
+		Start:  "-",
+		End:    end,
+		Count:  int64(c.config.BatchSize),
+	}).Result()
+	if err != nil {
+		c.onError(0, fmt.Errorf("failed to inspect pending clicks: %w", err))
+		return
+	}
+	if len(lost) == 0 {
+		return
+	}
+
+	ids := make([]string, len(lost))
+	for i, entry := range lost {
+		ids[i] = entry.ID
+	}
+	if err := c.client.XAck(ctx, c.config.Stream, c.config.Group, ids...).Err(); err != nil {
+		c.onError(len(ids), fmt.Errorf("failed to discard trimmed clicks: %w", err))
+		return
+	}
+	c.metrics.RecordClicksDropped(ReasonTrimmed, len(ids))
+	c.onError(len(ids), fmt.Errorf("%w: %d pending clicks", ErrClicksTrimmed, len(ids)))
+}
+
+// process writes a batch of stream entries and acknowledges the entries
+// that were written. Each event carries its entry ID, so entries delivered
+// again after a failed acknowledgement are not recorded twice.
+func (c *StreamConsumer) process(messages []redis.XMessage) {
+	if len(messages) == 0 {
+		return
+	}
+
+	var ids []string
+	events := make([]*models.ClickEvent, 0, len(messages))
+	for _, msg := range messages {
+		event, err := decodeEvent(msg.Values)
+		if err != nil {
+			c.metrics.RecordClicksDropped(ReasonMalformed, 1)
+			ids = append(ids, msg.ID)
+			continue
+		}
+		id := msg.ID
+		event.StreamID = &id
+		events = append(events, event)
+	}
+
+	// Writes and acks use their own context so that shutdown does not
+	// abandon a batch that has already been read
+	ctx, cancel := context.WithTimeout(context.Background(), c.config.WriteTimeout)
+	defer cancel()
+
+	for _, event := range c.write(ctx, events) {
+		ids = append(ids, *event.StreamID)
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	if err := c.client.XAck(ctx, c.config.Stream, c.config.Group, ids...).Err(); err != nil {
+		// The entries are written but will be delivered again
+		c.onError(len(ids), fmt.Errorf("failed to acknowledge clicks: %w", err))
+	}
+}
+
+// write records events and returns those that were written. A batch the
+// store rejected is split until the rejected events are isolated, so only
+// they stay pending and are dead-lettered after MaxDeliveries.
+func (c *StreamConsumer) write(ctx context.Context, events []*models.ClickEvent) []*models.ClickEvent {
+	if len(events) == 0 {
+		return nil
+	}
+
+	start := time.Now()
+	err := c.store.RecordClicks(ctx, events)
+	c.metrics.ObserveClickBatch(len(events), time.Since(start), err)
+	if err == nil {
+		return events
+	}
+	if len(events) == 1 || !errors.Is(err, ErrRejectedClicks) {
+		// Leave the entries pending; they are retried when reclaimed
+		c.onError(len(events), err)
+		return nil
+	}
+
+	half := len(events) / 2
+	return append(c.write(ctx, events[:half]), c.write(ctx, events[half:])...)
+}
+
+// sleep waits for d or until ctx is cancelled
+func (c *StreamConsumer) sleep(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-timer.C:
+	}
+}
+
+// encodeEvent converts a click event into stream entry fields, omitting
+// empty optional values
+func encodeEvent(event *models.ClickEvent) map[string]interface{} {
+	ts := event.Timestamp
+	if ts.IsZero() {
+		ts = time.Now()
+	}
+
+	values := map[string]interface{}{
+		fieldCode:      event.Code,
+		fieldTimestamp: ts.UTC().Format(time.RFC3339Nano),
+	}
+	optional := map[string]*string{
+		fieldUserAgent:  event.UserAgent,
+		fieldIPAddress:  event.IPAddress,
+		fieldIPHash:     event.IPHash,
+		fieldReferer:    event.Referer,
+		fieldCountry:    event.Country,
+		fieldDeviceType: event.DeviceType,
+		fieldBrowser:    event.Browser,
+		fieldOS:         event.OS,
+	}
+	for field, value := range optional {
+		if value != nil && *value != "" {
+			values[field] = *value
+		}
+	}
+
+	if event.IsBot {
+		values[fieldBot] = "1"
+	}
+
+	return values
+}
+
+// decodeEvent converts stream entry fields back into a click event
+func decodeEvent(values map[string]interface{}) (*models.ClickEvent, error) {
+	code, _ := values[fieldCode].(string)
+	if code == "" {
+		return nil, fmt.Errorf("click entry has no code")
+	}
+
+	raw, _ := values[fieldTimestamp].(string)
+	ts, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return nil, fmt.Errorf("click entry has invalid timestamp: %w", err)
+	}
+
+	field := func(name string) *string {
+		if value, ok := values[name].(string); ok && value != "" {
+			return &value
+		}
+		return nil
+	}
+
+	return &models.ClickEvent{
+		Code:       code,
+		Timestamp:  ts,
+		UserAgent:  field(fieldUserAgent),
+		IPAddress:  field(fieldIPAddress),
+		IPHash:     field(fieldIPHash),
+		Referer:    field(fieldReferer),
+		Country:    field(fieldCountry),
+		DeviceType: field(fieldDeviceType),
+		Browser:    field(fieldBrowser),
+		OS:         field(fieldOS),
+		IsBot:      values[fieldBot] == "1",
+	}, nil
+}
+
+// parseStreamID splits a stream entry ID into its time and sequence parts
+func parseStreamID(id string) (ms, seq uint64) {
+	msPart, seqPart, _ := strings.Cut(id, "-")
+	ms, _ = strconv.ParseUint(msPart, 10, 64)
+	seq, _ = strconv.ParseUint(seqPart, 10, 64)
+	return ms, seq
+}
+
+// compareStreamIDs returns -1, 0 or 1 as stream entry ID a sorts before,
+// with or after b
+func compareStreamIDs(a, b string) int {
+	aMS, aSeq := parseStreamID(a)
+	bMS, bSeq := parseStreamID(b)
+	switch {
+	case aMS < bMS || (aMS == bMS && aSeq < bSeq):
+		return -1
+	case aMS == bMS && aSeq == bSeq:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// nextStreamID returns the smallest stream entry ID after id
+func nextStreamID(id string) string {
+	ms, seq := parseStreamID(id)
+	return fmt.Sprintf("%d-%d", ms, seq+1)
+}
+
+// Custom errors
+var (
+	ErrClicksTrimmed  = fmt.Errorf("clicks were trimmed from the stream before they were written")
+	ErrRejectedClicks = fmt.Errorf("clicks rejected by the store")
+)
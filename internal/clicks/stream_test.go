@@ -0,0 +1,276 @@
+package clicks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/urlshortener/internal/models"
+)
+
+func TestEncodeDecodeEvent(t *testing.T) {
+	ua := "Mozilla/5.0"
+	ip := "203.0.113.7"
+	os := "Linux"
+	empty := ""
+	event := &models.ClickEvent{
+		Code:      "abc123",
+		Timestamp: time.Date(2024, 5, 1, 12, 30, 0, 123456789, time.UTC),
+		UserAgent: &ua,
+		IPAddress: &ip,
+		Referer:   &empty,
+		OS:        &os,
+		IsBot:     true,
+	}
+
+	// Redis returns field values as strings
+	values := make(map[string]interface{})
+	for k, v := range encodeEvent(event) {
+		values[k] = v.(string)
+	}
+	if _, ok := values[fieldReferer]; ok {
+		t.Error("expected empty referer to be omitted")
+	}
+
+	decoded, err := decodeEvent(values)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if decoded.Code != event.Code {
+		t.Errorf("expected code %q, got %q", event.Code, decoded.Code)
+	}
+	if !decoded.Timestamp.Equal(event.Timestamp) {
+		t.Errorf("expected timestamp %v, got %v", event.Timestamp, decoded.Timestamp)
+	}
+	if decoded.UserAgent == nil || *decoded.UserAgent != ua {
+		t.Errorf("expected user agent %q, got %v", ua, decoded.UserAgent)
+	}
+	if decoded.IPAddress == nil || *decoded.IPAddress != ip {
+		t.Errorf("expected IP %q, got %v", ip, decoded.IPAddress)
+	}
+	if decoded.OS == nil || *decoded.OS != os {
+		t.Errorf("expected OS %q, got %v", os, decoded.OS)
+	}
+	if !decoded.IsBot {
+		t.Error("expected bot flag to survive the round trip")
+	}
+	if decoded.Referer != nil || decoded.Country != nil || decoded.DeviceType != nil {
+		t.Error("expected missing fields to decode as nil")
+	}
+}
+
+func TestDecodeEventRejectsMalformedEntries(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+	}{
+		{"missing code", map[string]interface{}{fieldTimestamp: time.Now().Format(time.RFC3339Nano)}},
+		{"missing timestamp", map[string]interface{}{fieldCode: "abc"}},
+		{"invalid timestamp", map[string]interface{}{fieldCode: "abc", fieldTimestamp: "yesterday"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeEvent(tt.values); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// fakeSink records submitted events
+type fakeSink struct {
+	events []*models.ClickEvent
+}
+
+func (s *fakeSink) Submit(event *models.ClickEvent) bool {
+	s.events = append(s.events, event)
+	return true
+}
+
+func TestStreamProducerFallsBackWhenRedisIsDown(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+
+	fallback := &fakeSink{}
+	producer := NewStreamProducer(client, StreamConfig{AddTimeout: 100 * time.Millisecond}, fallback, nil)
+
+	if !producer.Submit(click("abc")) {
+		t.Error("expected fallback to accept the click")
+	}
+	if len(fallback.events) != 1 {
+		t.Errorf("expected click to reach the fallback sink, got %d events", len(fallback.events))
+	}
+}
+
+func TestStreamProducerDropsWithoutFallback(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1", MaxRetries: -1})
+	defer client.Close()
+
+	metrics := newFakeMetrics()
+	producer := NewStreamProducer(client, StreamConfig{AddTimeout: 100 * time.Millisecond}, nil, metrics)
+
+	if producer.Submit(click("abc")) {
+		t.Error("expected click to be dropped")
+	}
+	if got := metrics.droppedFor(ReasonStreamUnavailable); got != 1 {
+		t.Errorf("expected 1 stream_unavailable drop, got %d", got)
+	}
+}
+
+func TestStreamConsumerTrimsWrittenClicksAndReportsLostOnes(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	config := StreamConfig{Stream: "clicks", Group: "writers", Consumer: "test", BatchSize: 10}
+	metrics := newFakeMetrics()
+	var errs []error
+	consumer := NewStreamConsumer(client, &fakeStore{}, config, metrics, func(count int, err error) {
+		errs = append(errs, err)
+	})
+	if err := client.XGroupCreateMkStream(ctx, "clicks", "writers", "0").Err(); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		id, err := client.XAdd(ctx, &redis.XAddArgs{Stream: "clicks", Values: encodeEvent(click("abc"))}).Result()
+		if err != nil {
+			t.Fatalf("failed to add click: %v", err)
+		}
+		ids = append(ids, id)
+	}
+
+	// Deliver three clicks and write only the first, as during a database outage
+	if err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "writers", Consumer: "test", Streams: []string{"clicks", ">"}, Count: 3,
+	}).Err(); err != nil {
+		t.Fatalf("failed to read clicks: %v", err)
+	}
+	client.XAck(ctx, "clicks", "writers", ids[0])
+
+	consumer.trim(ctx)
+	if first, _ := client.XRangeN(ctx, "clicks", "-", "+", 1).Result(); len(first) != 1 || first[0].ID != ids[1] {
+		t.Errorf("expected only the written click to be trimmed, stream starts at %v", first)
+	}
+	if len(errs) != 0 || metrics.droppedFor(ReasonTrimmed) != 0 {
+		t.Errorf("expected no clicks to be reported lost, got %v", errs)
+	}
+
+	// The length cap trims an undelivered click. Redis also keeps the two
+	// trimmed pending clicks pending, which miniredis does not model.
+	client.XTrimMaxLen(ctx, "clicks", 1)
+	consumer.trim(ctx)
+	if len(errs) != 1 || !errors.Is(errs[0], ErrClicksTrimmed) {
+		t.Errorf("expected the undelivered click to be reported, got %v", errs)
+	}
+
+	// Once the consumer has caught up nothing more is reported
+	client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "writers", Consumer: "test", Streams: []string{"clicks", ">"},
+	})
+	consumer.trim(ctx)
+	if len(errs) != 1 {
+		t.Errorf("expected no further reports, got %v", errs)
+	}
+}
+
+// rejectingStore records batches but rejects any batch containing a click
+// for the code "bad"
+type rejectingStore struct {
+	fakeStore
+}
+
+func (s *rejectingStore) RecordClicks(ctx context.Context, events []*models.ClickEvent) error {
+	for _, event := range events {
+		if event.Code == "bad" {
+			return fmt.Errorf("failed to copy click: %w: value out of range", ErrRejectedClicks)
+		}
+	}
+	return s.fakeStore.RecordClicks(ctx, events)
+}
+
+func TestStreamConsumerIsolatesRejectedClicks(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	store := &rejectingStore{}
+	var failed int
+	config := StreamConfig{Stream: "clicks", Group: "writers", Consumer: "test", BatchSize: 10, WriteTimeout: time.Second}
+	consumer := NewStreamConsumer(client, store, config, nil, func(count int, err error) {
+		failed += count
+	})
+	if err := client.XGroupCreateMkStream(ctx, "clicks", "writers", "0").Err(); err != nil {
+		t.Fatalf("failed to create group: %v", err)
+	}
+
+	var badID string
+	for _, code := range []string{"a", "b", "bad", "c", "d"} {
+		id := client.XAdd(ctx, &redis.XAddArgs{Stream: "clicks", Values: encodeEvent(click(code))}).Val()
+		if code == "bad" {
+			badID = id
+		}
+	}
+	streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "writers", Consumer: "test", Streams: []string{"clicks", ">"},
+	}).Result()
+	if err != nil {
+		t.Fatalf("failed to read clicks: %v", err)
+	}
+	consumer.process(streams[0].Messages)
+
+	if store.total() != 4 || failed != 1 {
+		t.Errorf("expected 4 clicks written and 1 rejected, got %d and %d", store.total(), failed)
+	}
+	for _, batch := range store.batches {
+		for _, event := range batch {
+			if event.StreamID == nil {
+				t.Errorf("expected click %s to carry its stream entry ID", event.Code)
+			}
+		}
+	}
+
+	// Only the rejected click is left pending
+	pending, err := client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: "clicks", Group: "writers", Start: "-", End: "+", Count: 10,
+	}).Result()
+	if err != nil || len(pending) != 1 || pending[0].ID != badID {
+		t.Errorf("expected only %s to be pending, got %v, %v", badID, pending, err)
+	}
+}
+
+func TestStreamConsumerKeepsBatchWhenStoreIsDown(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	store := &fakeStore{err: errors.New("connection refused")}
+	config := StreamConfig{Stream: "clicks", Group: "writers", Consumer: "test", BatchSize: 10, WriteTimeout: time.Second}
+	consumer := NewStreamConsumer(client, store, config, nil, nil)
+	client.XGroupCreateMkStream(ctx, "clicks", "writers", "0")
+	for i := 0; i < 4; i++ {
+		client.XAdd(ctx, &redis.XAddArgs{Stream: "clicks", Values: encodeEvent(click("abc"))})
+	}
+	streams, _ := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group: "writers", Consumer: "test", Streams: []string{"clicks", ">"},
+	}).Result()
+	consumer.process(streams[0].Messages)
+
+	// Failures that are not caused by the clicks are not retried click by click
+	if len(store.batches) != 1 {
+		t.Errorf("expected one write attempt, got %d", len(store.batches))
+	}
+	if pending, _ := client.XPending(ctx, "clicks", "writers").Result(); pending.Count != 4 {
+		t.Errorf("expected the batch to stay pending, got %d pending", pending.Count)
+	}
+}
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
@@ -14,10 +15,22 @@ type Config struct {
 	RateLimit RateLimitConfig `mapstructure:"rate_limit"`
 	Security SecurityConfig `mapstructure:"security"`
 	Logging  LoggingConfig  `mapstructure:"logging"`
+	Unfurl   UnfurlConfig   `mapstructure:"unfurl"`
+	Clicks   ClicksConfig   `mapstructure:"clicks"`
+	Analytics AnalyticsConfig `mapstructure:"analytics"`
+	Geo      GeoConfig      `mapstructure:"geo"`
+	Privacy  PrivacyConfig  `mapstructure:"privacy"`
+	Webhooks WebhooksConfig `mapstructure:"webhooks"`
+	Live     LiveConfig     `mapstructure:"live"`
+	Abuse    AbuseConfig    `mapstructure:"abuse"`
+	Safety   SafetyConfig   `mapstructure:"safety"`
 }
 
 type ServerConfig struct {
 	Port            string        `mapstructure:"port"`
+	// BaseURL is the public address short links are served from. Its host
+	// is also rejected as a destination.
+	BaseURL         string        `mapstructure:"base_url"`
 	ReadTimeout     time.Duration `mapstructure:"read_timeout"`
 	WriteTimeout    time.Duration `mapstructure:"write_timeout"`
 	IdleTimeout     time.Duration `mapstructure:"idle_timeout"`
@@ -53,6 +66,37 @@ type RateLimitConfig struct {
 	PerIPRPS     int           `mapstructure:"per_ip_rps"`
 	BurstSize    int           `mapstructure:"burst_size"`
 	WindowSize   time.Duration `mapstructure:"window_size"`
+	Backend      string        `mapstructure:"backend"`
+	RedisTimeout time.Duration `mapstructure:"redis_timeout"`
+	IdleTimeout  time.Duration `mapstructure:"idle_timeout"`
+	MaxKeys      int           `mapstructure:"max_keys"`
+	// Policies override the limits above for the routes they list
+	Policies        []RateLimitPolicyConfig        `mapstructure:"policies"`
+	Plans           map[string]RateLimitPlanConfig `mapstructure:"plans"`
+	DefaultPlan     string                         `mapstructure:"default_plan"`
+	APIKeys         []RateLimitAPIKeyConfig        `mapstructure:"api_keys"`
+	TrustUserHeader bool                           `mapstructure:"trust_user_header"`
+	ExemptPaths     []string                       `mapstructure:"exempt_paths"`
+}
+
+type RateLimitPolicyConfig struct {
+	Name      string   `mapstructure:"name"`
+	Routes    []string `mapstructure:"routes"`
+	GlobalRPS int      `mapstructure:"global_rps"`
+	PerIPRPS  int      `mapstructure:"per_ip_rps"`
+	BurstSize int      `mapstructure:"burst_size"`
+}
+
+type RateLimitPlanConfig struct {
+	RPS        int   `mapstructure:"rps"`
+	BurstSize  int   `mapstructure:"burst_size"`
+	DailyQuota int64 `mapstructure:"daily_quota"`
+}
+
+type RateLimitAPIKeyConfig struct {
+	Key       string `mapstructure:"key"`
+	Principal string `mapstructure:"principal"`
+	Plan      string `mapstructure:"plan"`
 }
 
 type SecurityConfig struct {
@@ -60,6 +104,127 @@ type SecurityConfig struct {
 	AllowedOrigins []string `mapstructure:"allowed_origins"`
 	AllowedHosts []string `mapstructure:"allowed_hosts"`
 	BlockedDomains []string `mapstructure:"blocked_domains"`
+	// ShortenerDomains lists other URL shorteners whose links are rejected
+	// as destinations, so redirects cannot be chained into loops
+	ShortenerDomains []string `mapstructure:"shortener_domains"`
+	// TrustedProxies lists the addresses or CIDR blocks of reverse proxies
+	// whose forwarding headers are believed
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+type UnfurlConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	MaxBodyBytes int64         `mapstructure:"max_body_bytes"`
+	MaxRedirects int           `mapstructure:"max_redirects"`
+	UserAgent    string        `mapstructure:"user_agent"`
+	Workers      int           `mapstructure:"workers"`
+	QueueSize    int           `mapstructure:"queue_size"`
+}
+
+type ClicksConfig struct {
+	QueueSize     int               `mapstructure:"queue_size"`
+	Workers       int               `mapstructure:"workers"`
+	BatchSize     int               `mapstructure:"batch_size"`
+	FlushInterval time.Duration     `mapstructure:"flush_interval"`
+	DropPolicy    string            `mapstructure:"drop_policy"`
+	BlockTimeout  time.Duration     `mapstructure:"block_timeout"`
+	Stream        ClickStreamConfig `mapstructure:"stream"`
+}
+
+type ClickStreamConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`
+	Name          string        `mapstructure:"name"`
+	Group         string        `mapstructure:"group"`
+	Consumer      string        `mapstructure:"consumer"`
+	MaxLen        int64         `mapstructure:"max_len"`
+	AddTimeout    time.Duration `mapstructure:"add_timeout"`
+	ClaimInterval time.Duration `mapstructure:"claim_interval"`
+	ClaimMinIdle  time.Duration `mapstructure:"claim_min_idle"`
+	MaxDeliveries int64         `mapstructure:"max_deliveries"`
+}
+
+type AnalyticsConfig struct {
+	RollupInterval time.Duration `mapstructure:"rollup_interval"`
+	RollupLookback time.Duration `mapstructure:"rollup_lookback"`
+	RollupSettle   time.Duration `mapstructure:"rollup_settle"`
+	UniqueVisitors bool          `mapstructure:"unique_visitors"`
+	VisitorFlush   time.Duration `mapstructure:"visitor_flush_interval"`
+}
+
+type GeoConfig struct {
+	DatabasePath   string        `mapstructure:"database_path"`
+	ReloadInterval time.Duration `mapstructure:"reload_interval"`
+}
+
+type PrivacyConfig struct {
+	IPMode          string          `mapstructure:"ip_mode"`
+	HonorDoNotTrack bool            `mapstructure:"honor_do_not_track"`
+	Retention       RetentionConfig `mapstructure:"retention"`
+}
+
+type RetentionConfig struct {
+	Days      int           `mapstructure:"days"`
+	Action    string        `mapstructure:"action"`
+	Interval  time.Duration `mapstructure:"interval"`
+	BatchSize int           `mapstructure:"batch_size"`
+}
+
+type WebhooksConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Interval     time.Duration `mapstructure:"interval"`
+	BatchSize    int           `mapstructure:"batch_size"`
+	Concurrency  int           `mapstructure:"concurrency"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	BaseBackoff  time.Duration `mapstructure:"base_backoff"`
+	MaxBackoff   time.Duration `mapstructure:"max_backoff"`
+	LogRetention time.Duration `mapstructure:"log_retention"`
+}
+
+type LiveConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`
+	MaxStreams        int           `mapstructure:"max_streams"`
+	MaxStreamsPerLink int           `mapstructure:"max_streams_per_link"`
+	SubscriberBuffer  int           `mapstructure:"subscriber_buffer"`
+	Heartbeat         time.Duration `mapstructure:"heartbeat"`
+	MaxDuration       time.Duration `mapstructure:"max_duration"`
+}
+
+// AbuseConfig holds abuse report thresholds. Reports count from the last
+// moderator decision on a link; zero disables a threshold.
+type AbuseConfig struct {
+	ReviewThreshold  int `mapstructure:"review_threshold"`
+	SuspendThreshold int `mapstructure:"suspend_threshold"`
+	// ReporterSecret keys the hashes that identify reporters; it defaults
+	// to security.admin_secret
+	ReporterSecret string `mapstructure:"reporter_secret"`
+}
+
+// SafetyConfig holds destination safety checks. Links are checked against
+// security.blocked_domains and these lists when created, and again every
+// rescan interval.
+type SafetyConfig struct {
+	DomainLists     []SafetyListConfig `mapstructure:"domain_lists"`
+	HashLists       []SafetyListConfig `mapstructure:"hash_lists"`
+	Rules           []SafetyRuleConfig `mapstructure:"rules"`
+	ReloadInterval  time.Duration      `mapstructure:"reload_interval"`
+	RescanInterval  time.Duration      `mapstructure:"rescan_interval"` // zero disables rescans
+	RescanBatchSize int                `mapstructure:"rescan_batch_size"`
+}
+
+// SafetyListConfig is a list file. Domain lists hold one domain per line;
+// hash lists hold raw Safe Browsing hash prefixes of prefix_size bytes,
+// full 32-byte hashes by default.
+type SafetyListConfig struct {
+	Path       string `mapstructure:"path"`
+	Threat     string `mapstructure:"threat"`
+	PrefixSize int    `mapstructure:"prefix_size"`
+}
+
+type SafetyRuleConfig struct {
+	Pattern string `mapstructure:"pattern"`
+	Threat  string `mapstructure:"threat"`
 }
 
 type LoggingConfig struct {
@@ -94,6 +259,79 @@ func Load() (*Config, error) {
 	viper.SetDefault("rate_limit.per_ip_rps", 10)
 	viper.SetDefault("rate_limit.burst_size", 20)
 	viper.SetDefault("rate_limit.window_size", "1s")
+	viper.SetDefault("rate_limit.backend", "local")
+	viper.SetDefault("rate_limit.redis_timeout", "50ms")
+	viper.SetDefault("rate_limit.idle_timeout", "10m")
+	viper.SetDefault("rate_limit.max_keys", 100000)
+	viper.SetDefault("rate_limit.trust_user_header", false)
+	viper.SetDefault("rate_limit.exempt_paths", []string{"/api/v1/healthz", "/api/v1/readyz", "/metrics"})
+
+	viper.SetDefault("security.trusted_proxies", []string{})
+	viper.SetDefault("security.shortener_domains", []string{
+		"bit.ly", "tinyurl.com", "t.co", "goo.gl", "ow.ly", "is.gd", "buff.ly", "rebrand.ly", "cutt.ly", "tiny.cc",
+	})
+
+	viper.SetDefault("unfurl.enabled", true)
+	viper.SetDefault("unfurl.timeout", "5s")
+	viper.SetDefault("unfurl.max_body_bytes", 512*1024)
+	viper.SetDefault("unfurl.max_redirects", 5)
+	viper.SetDefault("unfurl.workers", 4)
+	viper.SetDefault("unfurl.queue_size", 1000)
+
+	viper.SetDefault("clicks.queue_size", 10000)
+	viper.SetDefault("clicks.workers", 2)
+	viper.SetDefault("clicks.batch_size", 500)
+	viper.SetDefault("clicks.flush_interval", "1s")
+	viper.SetDefault("clicks.drop_policy", "drop_newest")
+	viper.SetDefault("clicks.block_timeout", "10ms")
+	viper.SetDefault("clicks.stream.enabled", false)
+	viper.SetDefault("clicks.stream.name", "clicks")
+	viper.SetDefault("clicks.stream.group", "click-writers")
+	viper.SetDefault("clicks.stream.max_len", 1000000)
+	viper.SetDefault("clicks.stream.add_timeout", "50ms")
+	viper.SetDefault("clicks.stream.claim_interval", "30s")
+	viper.SetDefault("clicks.stream.claim_min_idle", "1m")
+	viper.SetDefault("clicks.stream.max_deliveries", 10)
+
+	viper.SetDefault("analytics.rollup_interval", "5m")
+	viper.SetDefault("analytics.rollup_lookback", "2h")
+	viper.SetDefault("analytics.rollup_settle", "2m")
+	viper.SetDefault("analytics.unique_visitors", true)
+	viper.SetDefault("analytics.visitor_flush_interval", "5m")
+
+	viper.SetDefault("geo.database_path", "")
+	viper.SetDefault("geo.reload_interval", "1m")
+
+	viper.SetDefault("privacy.ip_mode", "off")
+	viper.SetDefault("privacy.honor_do_not_track", true)
+	viper.SetDefault("privacy.retention.days", 0)
+	viper.SetDefault("privacy.retention.action", "delete")
+	viper.SetDefault("privacy.retention.interval", "1h")
+	viper.SetDefault("privacy.retention.batch_size", 10000)
+
+	viper.SetDefault("webhooks.enabled", true)
+	viper.SetDefault("webhooks.interval", "5s")
+	viper.SetDefault("webhooks.batch_size", 100)
+	viper.SetDefault("webhooks.concurrency", 4)
+	viper.SetDefault("webhooks.timeout", "10s")
+	viper.SetDefault("webhooks.max_attempts", 8)
+	viper.SetDefault("webhooks.base_backoff", "30s")
+	viper.SetDefault("webhooks.max_backoff", "6h")
+	viper.SetDefault("webhooks.log_retention", "168h")
+
+	viper.SetDefault("live.enabled", true)
+	viper.SetDefault("live.max_streams", 1000)
+	viper.SetDefault("live.max_streams_per_link", 50)
+	viper.SetDefault("live.subscriber_buffer", 64)
+	viper.SetDefault("live.heartbeat", "15s")
+	viper.SetDefault("live.max_duration", "1h")
+
+	viper.SetDefault("abuse.review_threshold", 3)
+	viper.SetDefault("abuse.suspend_threshold", 10)
+
+	viper.SetDefault("safety.reload_interval", "1m")
+	viper.SetDefault("safety.rescan_interval", "6h")
+	viper.SetDefault("safety.rescan_batch_size", 500)
 
 	viper.SetDefault("logging.level", "info")
 	viper.SetDefault("logging.format", "json")
@@ -126,6 +364,15 @@ func (c *Config) GetDSN() string {
 		c.Database.DBName, c.Database.SSLMode)
 }
 
+// GetBaseURL returns the public base URL of short links, defaulting to the
+// local server
+func (c *Config) GetBaseURL() string {
+	if c.Server.BaseURL == "" {
+		return fmt.Sprintf("http://localhost:%s", c.Server.Port)
+	}
+	return strings.TrimSuffix(c.Server.BaseURL, "/")
+}
+
 func (c *Config) GetRedisAddr() string {
 	return fmt.Sprintf("%s:%d", c.Redis.Host, c.Redis.Port)
 }
@@ -0,0 +1,139 @@
+// Package errors defines the domain errors shared by the repository, cache,
+// service and HTTP layers. Every domain error has a stable code, reported to
+// API clients, and a kind, which decides the HTTP status it maps to. Callers
+// match kinds and individual errors with Is and extract codes with As.
+//
+// The package also forwards the standard library helpers, so it can be
+// imported in place of the standard errors package.
+package errors
+
+import (
+	stderrors "errors"
+	"fmt"
+)
+
+// Kinds of domain errors. Every Error matches exactly one of them with Is.
+var (
+	ErrNotFound   = fmt.Errorf("not found")
+	ErrExpired    = fmt.Errorf("expired")
+	ErrDeleted    = fmt.Errorf("deleted")
+	ErrConflict   = fmt.Errorf("conflict")
+	ErrValidation = fmt.Errorf("validation failed")
+	ErrForbidden  = fmt.Errorf("forbidden")
+)
+
+// URL lifecycle errors, shared by the cache and the repository so that a
+// link is reported the same way wherever its state was found
+var (
+	ErrURLNotFound = NotFound("url_not_found", "URL not found")
+	ErrURLExpired  = Expired("url_expired", "URL has expired")
+	ErrURLDeleted  = Deleted("url_deleted", "URL is deleted")
+)
+
+// Error is a domain error
+type Error struct {
+	kind error
+	// Code identifies the error to API clients and never changes
+	Code string
+	// Message describes the error
+	Message string
+	// Fields describes the invalid input fields of a validation error
+	Fields []FieldError
+}
+
+// FieldError describes why an input field is invalid
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+// Error returns the error message
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the error's kind
+func (e *Error) Unwrap() error {
+	return e.kind
+}
+
+// Kind returns the error's kind
+func (e *Error) Kind() error {
+	return e.kind
+}
+
+// Is reports whether target is a domain error with the same kind and code,
+// so copies made by WithFields match the error they were made from
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.kind == e.kind && t.Code == e.Code
+}
+
+// WithFields returns a copy of the error that also names the given fields
+func (e *Error) WithFields(fields ...FieldError) *Error {
+	copied := *e
+	copied.Fields = append(append([]FieldError(nil), e.Fields...), fields...)
+	return &copied
+}
+
+// NotFound returns an error for a resource that does not exist
+func NotFound(code, message string) *Error {
+	return &Error{kind: ErrNotFound, Code: code, Message: message}
+}
+
+// Expired returns an error for a resource that is past its expiry
+func Expired(code, message string) *Error {
+	return &Error{kind: ErrExpired, Code: code, Message: message}
+}
+
+// Deleted returns an error for a resource that has been deleted
+func Deleted(code, message string) *Error {
+	return &Error{kind: ErrDeleted, Code: code, Message: message}
+}
+
+// Conflict returns an error for a resource that already exists or is in a
+// conflicting state
+func Conflict(code, message string) *Error {
+	return &Error{kind: ErrConflict, Code: code, Message: message}
+}
+
+// Validation returns an error for invalid input, optionally naming the
+// fields at fault
+func Validation(code, message string, fields ...FieldError) *Error {
+	return &Error{kind: ErrValidation, Code: code, Message: message, Fields: fields}
+}
+
+// Forbidden returns an error for an operation the caller may not perform
+func Forbidden(code, message string) *Error {
+	return &Error{kind: ErrForbidden, Code: code, Message: message}
+}
+
+// Field returns a field error, for Validation
+func Field(field, reason string) FieldError {
+	return FieldError{Field: field, Reason: reason}
+}
+
+// Code returns the code of the domain error in err's chain, or "" if there
+// is none
+func Code(err error) string {
+	var domainErr *Error
+	if stderrors.As(err, &domainErr) {
+		return domainErr.Code
+	}
+	return ""
+}
+
+// New returns an error with the given text, as errors.New does
+func New(text string) error {
+	return stderrors.New(text)
+}
+
+// Is reports whether any error in err's chain matches target, as errors.Is does
+func Is(err, target error) bool {
+	return stderrors.Is(err, target)
+}
+
+// As finds the first error in err's chain that matches target, as errors.As does
+func As(err error, target any) bool {
+	return stderrors.As(err, target)
+}
@@ -0,0 +1,78 @@
+package errors
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestKinds(t *testing.T) {
+	tests := []struct {
+		err  *Error
+		kind error
+	}{
+		{NotFound("thing_not_found", "thing not found"), ErrNotFound},
+		{Expired("thing_expired", "thing has expired"), ErrExpired},
+		{Deleted("thing_deleted", "thing is deleted"), ErrDeleted},
+		{Conflict("thing_exists", "thing already exists"), ErrConflict},
+		{Validation("invalid_thing", "invalid thing"), ErrValidation},
+		{Forbidden("thing_forbidden", "thing belongs to someone else"), ErrForbidden},
+	}
+
+	kinds := []error{ErrNotFound, ErrExpired, ErrDeleted, ErrConflict, ErrValidation, ErrForbidden}
+	for _, tt := range tests {
+		wrapped := fmt.Errorf("failed to get thing: %w", tt.err)
+		for _, kind := range kinds {
+			if got := Is(wrapped, kind); got != (kind == tt.kind) {
+				t.Errorf("%s: Is(%v) = %v", tt.err.Code, kind, got)
+			}
+		}
+		if tt.err.Kind() != tt.kind {
+			t.Errorf("%s: expected kind %v, got %v", tt.err.Code, tt.kind, tt.err.Kind())
+		}
+	}
+}
+
+func TestErrorsMatchByCode(t *testing.T) {
+	err := NotFound("thing_not_found", "thing not found")
+	if !Is(fmt.Errorf("%w: thing 42", err), err) {
+		t.Error("a wrapped error should match itself")
+	}
+	if !Is(NotFound("thing_not_found", "no such thing"), err) {
+		t.Error("errors with the same kind and code should match")
+	}
+	if Is(NotFound("other_not_found", "thing not found"), err) {
+		t.Error("errors with different codes should not match")
+	}
+	if Is(Deleted("thing_not_found", "thing not found"), err) {
+		t.Error("errors of different kinds should not match")
+	}
+}
+
+func TestWithFields(t *testing.T) {
+	base := Validation("invalid_thing", "invalid thing")
+	err := base.WithFields(Field("name", "is required"))
+	if !Is(err, base) {
+		t.Error("a copy with fields should match the original")
+	}
+	if len(base.Fields) != 0 || len(err.Fields) != 1 {
+		t.Errorf("expected only the copy to have fields, got %v and %v", base.Fields, err.Fields)
+	}
+}
+
+func TestCode(t *testing.T) {
+	err := fmt.Errorf("%w: tag is too long", Validation("invalid_tag", "invalid tag", Field("tags", "too long")))
+	if code := Code(err); code != "invalid_tag" {
+		t.Errorf("expected invalid_tag, got %q", code)
+	}
+	if code := Code(New("connection refused")); code != "" {
+		t.Errorf("expected no code for a plain error, got %q", code)
+	}
+
+	var domainErr *Error
+	if !As(err, &domainErr) || len(domainErr.Fields) != 1 || domainErr.Fields[0].Field != "tags" {
+		t.Errorf("expected the field details, got %+v", domainErr)
+	}
+	if err.Error() != "invalid tag: tag is too long" {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+}
@@ -0,0 +1,188 @@
+package export
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+// Format is a click export file format
+type Format string
+
+// Supported export formats
+const (
+	FormatCSV     Format = "csv"
+	FormatNDJSON  Format = "ndjson"
+	FormatParquet Format = "parquet"
+)
+
+// ParseFormat validates an export format, defaulting to CSV
+func ParseFormat(value string) (Format, error) {
+	switch Format(value) {
+	case "", FormatCSV:
+		return FormatCSV, nil
+	case FormatNDJSON, "jsonl":
+		return FormatNDJSON, nil
+	case FormatParquet:
+		return FormatParquet, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnsupportedFormat, value)
+	}
+}
+
+// ContentType returns the MIME type of the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatParquet:
+		return "application/vnd.apache.parquet"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// Extension returns the file name extension of the format
+func (f Format) Extension() string {
+	if f == FormatNDJSON {
+		return "jsonl"
+	}
+	return string(f)
+}
+
+// optionalColumns are the nullable click_events columns, in export order
+var optionalColumns = []string{
+	"user_agent", "ip_address", "ip_hash", "referer", "country", "device_type", "browser", "os",
+}
+
+// optionalField returns the value of a nullable column
+func optionalField(event *models.ClickEvent, name string) *string {
+	switch name {
+	case "user_agent":
+		return event.UserAgent
+	case "ip_address":
+		return event.IPAddress
+	case "ip_hash":
+		return event.IPHash
+	case "referer":
+		return event.Referer
+	case "country":
+		return event.Country
+	case "device_type":
+		return event.DeviceType
+	case "browser":
+		return event.Browser
+	case "os":
+		return event.OS
+	default:
+		return nil
+	}
+}
+
+// Writer encodes a stream of click events
+type Writer interface {
+	Write(event *models.ClickEvent) error
+	Close() error
+}
+
+// NewWriter creates a writer for the format. Close must be called to
+// complete the output.
+func NewWriter(format Format, w io.Writer) Writer {
+	switch format {
+	case FormatNDJSON:
+		return newNDJSONWriter(w)
+	case FormatParquet:
+		return NewParquetWriter(w)
+	default:
+		return newCSVWriter(w)
+	}
+}
+
+// csvWriter writes click events as CSV with a header row. Null columns are
+// written as empty fields.
+type csvWriter struct {
+	w         *csv.Writer
+	wroteHead bool
+	record    []string
+}
+
+// newCSVWriter creates a CSV writer
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+// Write writes one click event
+func (c *csvWriter) Write(event *models.ClickEvent) error {
+	if !c.wroteHead {
+		if err := c.writeHeader(); err != nil {
+			return err
+		}
+	}
+
+	c.record = append(c.record[:0],
+		strconv.FormatInt(event.ID, 10),
+		event.Code,
+		event.Timestamp.UTC().Format(time.RFC3339Nano),
+	)
+	for _, name := range optionalColumns {
+		value := ""
+		if v := optionalField(event, name); v != nil {
+			value = *v
+		}
+		c.record = append(c.record, value)
+	}
+	c.record = append(c.record, strconv.FormatBool(event.IsBot))
+
+	return c.w.Write(c.record)
+}
+
+// Close writes the header if no rows were written and flushes the output
+func (c *csvWriter) Close() error {
+	if !c.wroteHead {
+		if err := c.writeHeader(); err != nil {
+			return err
+		}
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// writeHeader writes the column names
+func (c *csvWriter) writeHeader() error {
+	c.wroteHead = true
+	header := append([]string{"id", "code", "ts"}, optionalColumns...)
+	return c.w.Write(append(header, "is_bot"))
+}
+
+// ndjsonWriter writes one JSON object per line
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+// newNDJSONWriter creates an NDJSON writer
+func newNDJSONWriter(w io.Writer) *ndjsonWriter {
+	buffered := bufio.NewWriter(w)
+	return &ndjsonWriter{w: buffered, enc: json.NewEncoder(buffered)}
+}
+
+// Write writes one click event
+func (n *ndjsonWriter) Write(event *models.ClickEvent) error {
+	return n.enc.Encode(event)
+}
+
+// Close flushes the output
+func (n *ndjsonWriter) Close() error {
+	return n.w.Flush()
+}
+
+// Custom errors
+var (
+	ErrUnsupportedFormat = fmt.Errorf("unsupported export format")
+)
@@ -0,0 +1,320 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+func testEvents(n int) []*models.ClickEvent {
+	events := make([]*models.ClickEvent, n)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i := range events {
+		country := "DE"
+		ua := fmt.Sprintf("agent, \"%d\"", i)
+		events[i] = &models.ClickEvent{
+			ID:        int64(i + 1),
+			Code:      "abc123",
+			Timestamp: base.Add(time.Duration(i) * time.Second),
+			IsBot:     i%3 == 0,
+		}
+		// Alternate nulls so definition levels have runs of both values
+		if i%4 < 2 {
+			events[i].Country = &country
+		}
+		if i%5 != 0 {
+			events[i].UserAgent = &ua
+		}
+	}
+	return events
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := map[string]Format{"": FormatCSV, "csv": FormatCSV, "ndjson": FormatNDJSON, "jsonl": FormatNDJSON, "parquet": FormatParquet}
+	for value, expected := range tests {
+		if got, err := ParseFormat(value); err != nil || got != expected {
+			t.Errorf("ParseFormat(%q): expected %s, got %s (%v)", value, expected, got, err)
+		}
+	}
+	if _, err := ParseFormat("xlsx"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(FormatCSV, &buf)
+	events := testEvents(3)
+	for _, event := range events {
+		if err := w.Write(event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("invalid CSV: %v", err)
+	}
+	if len(records) != 4 {
+		t.Fatalf("expected header and 3 rows, got %d records", len(records))
+	}
+	if records[0][0] != "id" || records[0][len(records[0])-1] != "is_bot" {
+		t.Errorf("unexpected header %v", records[0])
+	}
+	if records[2][3] != `agent, "1"` || records[1][3] != "" {
+		t.Errorf("unexpected user agents %q and %q", records[2][3], records[1][3])
+	}
+	if records[1][2] != "2024-05-01T12:00:00Z" || records[1][len(records[1])-1] != "true" {
+		t.Errorf("unexpected row %v", records[1])
+	}
+
+	// An empty export still has a header
+	buf.Reset()
+	w = NewWriter(FormatCSV, &buf)
+	w.Close()
+	if lines := bytes.Count(buf.Bytes(), []byte("\n")); lines != 1 {
+		t.Errorf("expected only a header, got %d lines", lines)
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(FormatNDJSON, &buf)
+	for _, event := range testEvents(5) {
+		w.Write(event)
+	}
+	w.Close()
+
+	scanner := bufio.NewScanner(&buf)
+	lines := 0
+	for scanner.Scan() {
+		var event models.ClickEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid line %q: %v", scanner.Text(), err)
+		}
+		lines++
+		if event.ID != int64(lines) {
+			t.Errorf("expected id %d, got %d", lines, event.ID)
+		}
+	}
+	if lines != 5 {
+		t.Errorf("expected 5 lines, got %d", lines)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	events := testEvents(25)
+
+	var buf bytes.Buffer
+	w := NewParquetWriter(&buf)
+	w.rowGroupSize = 10
+	for _, event := range events {
+		if err := w.Write(event); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	file := buf.Bytes()
+	if string(file[:4]) != parquetMagic || string(file[len(file)-4:]) != parquetMagic {
+		t.Fatal("missing Parquet magic bytes")
+	}
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := &thriftReader{buf: file[len(file)-8-footerLen : len(file)-8]}
+	meta := footer.readStruct()
+	if footer.err != nil {
+		t.Fatalf("invalid footer: %v", footer.err)
+	}
+
+	if meta[3] != int64(25) {
+		t.Errorf("expected 25 rows, got %v", meta[3])
+	}
+	schema := meta[2].([]interface{})
+	if len(schema) != len(w.columns)+1 {
+		t.Fatalf("expected %d schema elements, got %d", len(w.columns)+1, len(schema))
+	}
+
+	// Read every column back and compare with the input
+	rowGroups := meta[4].([]interface{})
+	if len(rowGroups) != 3 {
+		t.Fatalf("expected 3 row groups, got %d", len(rowGroups))
+	}
+	columns := make(map[string][]interface{})
+	for _, rg := range rowGroups {
+		numRows := int(rg.(map[int16]interface{})[3].(int64))
+		for _, chunk := range rg.(map[int16]interface{})[1].([]interface{}) {
+			cm := chunk.(map[int16]interface{})[3].(map[int16]interface{})
+			name := string(cm[3].([]interface{})[0].([]byte))
+			offset := int(cm[9].(int64))
+
+			header := &thriftReader{buf: file[offset:]}
+			page := header.readStruct()
+			if header.err != nil {
+				t.Fatalf("invalid page header for %s: %v", name, header.err)
+			}
+			size := int(page[3].(int64))
+			data := file[offset+header.pos : offset+header.pos+size]
+			columns[name] = append(columns[name], decodeColumn(t, name, data, numRows)...)
+		}
+	}
+
+	for i, event := range events {
+		if columns["id"][i] != event.ID {
+			t.Errorf("row %d: expected id %d, got %v", i, event.ID, columns["id"][i])
+		}
+		if columns["ts"][i] != event.Timestamp.UnixMicro() {
+			t.Errorf("row %d: unexpected timestamp %v", i, columns["ts"][i])
+		}
+		if columns["code"][i] != event.Code {
+			t.Errorf("row %d: unexpected code %v", i, columns["code"][i])
+		}
+		if columns["is_bot"][i] != event.IsBot {
+			t.Errorf("row %d: expected is_bot %v, got %v", i, event.IsBot, columns["is_bot"][i])
+		}
+		for name, expected := range map[string]*string{"country": event.Country, "user_agent": event.UserAgent, "os": nil} {
+			got := columns[name][i]
+			if (expected == nil) != (got == nil) || (expected != nil && got != *expected) {
+				t.Errorf("row %d: unexpected %s %v", i, name, got)
+			}
+		}
+	}
+}
+
+// decodeColumn decodes a PLAIN data page written by ParquetWriter
+func decodeColumn(t *testing.T, name string, data []byte, numRows int) []interface{} {
+	t.Helper()
+
+	optional := name != "id" && name != "code" && name != "ts" && name != "is_bot"
+	defined := make([]bool, numRows)
+	for i := range defined {
+		defined[i] = true
+	}
+	if optional {
+		n := int(binary.LittleEndian.Uint32(data))
+		levels := &thriftReader{buf: data[4 : 4+n]}
+		for row := 0; levels.pos < n; {
+			header := levels.readVarint()
+			if header&1 != 0 {
+				t.Fatalf("%s: unexpected bit-packed run", name)
+			}
+			value := levels.buf[levels.pos]
+			levels.pos++
+			for j := 0; j < int(header>>1); j++ {
+				defined[row] = value == 1
+				row++
+			}
+		}
+		data = data[4+n:]
+	}
+
+	values := make([]interface{}, numRows)
+	for i := range values {
+		if !defined[i] {
+			continue
+		}
+		switch name {
+		case "id", "ts":
+			values[i] = int64(binary.LittleEndian.Uint64(data))
+			data = data[8:]
+		case "is_bot":
+			values[i] = data[i/8]&(1<<uint(i%8)) != 0
+		default:
+			n := int(binary.LittleEndian.Uint32(data))
+			values[i] = string(data[4 : 4+n])
+			data = data[4+n:]
+		}
+	}
+	return values
+}
+
+// thriftReader decodes the Thrift compact protocol into maps keyed by field id
+type thriftReader struct {
+	buf []byte
+	pos int
+	err error
+}
+
+func (r *thriftReader) readVarint() uint64 {
+	v, n := binary.Uvarint(r.buf[r.pos:])
+	if n <= 0 {
+		r.err = errors.New("invalid varint")
+		return 0
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) readInt() int64 {
+	v := r.readVarint()
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for r.err == nil && r.pos < len(r.buf) {
+		b := r.buf[r.pos]
+		r.pos++
+		if b == 0 {
+			return fields
+		}
+		typ := b & 0x0f
+		if delta := int16(b >> 4); delta != 0 {
+			last += delta
+		} else {
+			last = int16(r.readInt())
+		}
+		switch typ {
+		case thriftBoolTrue:
+			fields[last] = true
+		case thriftBoolFalse:
+			fields[last] = false
+		default:
+			fields[last] = r.readValue(typ)
+		}
+	}
+	r.err = errors.New("unterminated struct")
+	return fields
+}
+
+func (r *thriftReader) readValue(typ byte) interface{} {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.readInt()
+	case thriftBinary:
+		n := int(r.readVarint())
+		v := r.buf[r.pos : r.pos+n]
+		r.pos += n
+		return v
+	case thriftStruct:
+		return r.readStruct()
+	case thriftList:
+		b := r.buf[r.pos]
+		r.pos++
+		size := int(b >> 4)
+		if size == 15 {
+			size = int(r.readVarint())
+		}
+		items := make([]interface{}, size)
+		for i := range items {
+			items[i] = r.readValue(b & 0x0f)
+		}
+		return items
+	default:
+		r.err = fmt.Errorf("unsupported type %d", typ)
+		return nil
+	}
+}
@@ -0,0 +1,296 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/urlshortener/internal/models"
+)
+
+// parquetMagic starts and ends every Parquet file
+const parquetMagic = "PAR1"
+
+// defaultRowGroupSize is the number of rows buffered before a row group is
+// written; it bounds the writer's memory use
+const defaultRowGroupSize = 10000
+
+// Parquet physical types
+const (
+	parquetBoolean   int32 = 0
+	parquetInt64     int32 = 2
+	parquetByteArray int32 = 6
+)
+
+// Parquet enum values used by the writer
+const (
+	repetitionRequired   int32 = 0
+	repetitionOptional   int32 = 1
+	encodingPlain        int32 = 0
+	encodingRLE          int32 = 3
+	codecUncompressed    int32 = 0
+	pageTypeData         int32 = 0
+	convertedUTF8        int32 = 0
+	convertedTimestampUs int32 = 10
+)
+
+// parquetColumn describes one column and buffers its current row group
+type parquetColumn struct {
+	name     string
+	physical int32
+	optional bool
+	value    func(event *models.ClickEvent) interface{} // int64, bool or *string
+
+	defLevels []byte
+	bools     []bool
+	values    bytes.Buffer
+}
+
+// ParquetWriter writes click events as a Parquet file with uncompressed,
+// PLAIN-encoded columns. Rows are buffered one row group at a time, so
+// memory use does not grow with the number of rows.
+type ParquetWriter struct {
+	w            *countingWriter
+	columns      []*parquetColumn
+	rowGroupSize int
+	rows         int
+	totalRows    int64
+	rowGroups    []interface{}
+	started      bool
+}
+
+// NewParquetWriter creates a Parquet writer
+func NewParquetWriter(w io.Writer) *ParquetWriter {
+	columns := []*parquetColumn{
+		{name: "id", physical: parquetInt64, value: func(e *models.ClickEvent) interface{} { return e.ID }},
+		{name: "code", physical: parquetByteArray, value: func(e *models.ClickEvent) interface{} { return &e.Code }},
+		{name: "ts", physical: parquetInt64, value: func(e *models.ClickEvent) interface{} { return e.Timestamp.UnixMicro() }},
+	}
+	for _, name := range optionalColumns {
+		name := name
+		columns = append(columns, &parquetColumn{
+			name:     name,
+			physical: parquetByteArray,
+			optional: true,
+			value:    func(e *models.ClickEvent) interface{} { return optionalField(e, name) },
+		})
+	}
+	columns = append(columns, &parquetColumn{
+		name: "is_bot", physical: parquetBoolean, value: func(e *models.ClickEvent) interface{} { return e.IsBot },
+	})
+
+	return &ParquetWriter{
+		w:            &countingWriter{w: w},
+		columns:      columns,
+		rowGroupSize: defaultRowGroupSize,
+	}
+}
+
+// Write buffers a click event, writing a row group when the buffer is full
+func (p *ParquetWriter) Write(event *models.ClickEvent) error {
+	for _, col := range p.columns {
+		switch v := col.value(event).(type) {
+		case int64:
+			binary.Write(&col.values, binary.LittleEndian, v)
+		case bool:
+			col.bools = append(col.bools, v)
+		case *string:
+			if v == nil {
+				col.defLevels = append(col.defLevels, 0)
+				continue
+			}
+			if col.optional {
+				col.defLevels = append(col.defLevels, 1)
+			}
+			binary.Write(&col.values, binary.LittleEndian, uint32(len(*v)))
+			col.values.WriteString(*v)
+		}
+	}
+
+	p.rows++
+	if p.rows >= p.rowGroupSize {
+		return p.flushRowGroup()
+	}
+	return nil
+}
+
+// Close writes any buffered rows and the file footer
+func (p *ParquetWriter) Close() error {
+	if err := p.flushRowGroup(); err != nil {
+		return err
+	}
+	if err := p.writeMagic(); err != nil {
+		return err
+	}
+
+	var footer bytes.Buffer
+	encodeThrift(&footer, tStruct{
+		{1, int32(1)},
+		{2, tList{elem: thriftStruct, items: p.schema()}},
+		{3, p.totalRows},
+		{4, tList{elem: thriftStruct, items: p.rowGroups}},
+		{6, "urlshortener"},
+	})
+	binary.Write(&footer, binary.LittleEndian, uint32(footer.Len()))
+	footer.WriteString(parquetMagic)
+
+	_, err := p.w.Write(footer.Bytes())
+	return err
+}
+
+// writeMagic writes the leading magic bytes before the first row group
+func (p *ParquetWriter) writeMagic() error {
+	if p.started {
+		return nil
+	}
+	p.started = true
+	_, err := io.WriteString(p.w, parquetMagic)
+	return err
+}
+
+// flushRowGroup writes the buffered rows as one row group with a single
+// data page per column
+func (p *ParquetWriter) flushRowGroup() error {
+	if p.rows == 0 {
+		return nil
+	}
+	if err := p.writeMagic(); err != nil {
+		return err
+	}
+
+	var chunks []interface{}
+	var groupSize int64
+	for _, col := range p.columns {
+		var page bytes.Buffer
+		if col.optional {
+			levels := encodeLevels(col.defLevels)
+			binary.Write(&page, binary.LittleEndian, uint32(len(levels)))
+			page.Write(levels)
+		}
+		if col.physical == parquetBoolean {
+			page.Write(packBools(col.bools))
+		} else {
+			page.Write(col.values.Bytes())
+		}
+
+		var header bytes.Buffer
+		encodeThrift(&header, tStruct{
+			{1, pageTypeData},
+			{2, int32(page.Len())},
+			{3, int32(page.Len())},
+			{5, tStruct{
+				{1, int32(p.rows)},
+				{2, encodingPlain},
+				{3, encodingRLE},
+				{4, encodingRLE},
+			}},
+		})
+
+		offset := p.w.n
+		if _, err := p.w.Write(header.Bytes()); err != nil {
+			return err
+		}
+		if _, err := p.w.Write(page.Bytes()); err != nil {
+			return err
+		}
+		size := int64(header.Len() + page.Len())
+		groupSize += size
+
+		chunks = append(chunks, tStruct{
+			{2, offset},
+			{3, tStruct{
+				{1, col.physical},
+				{2, tList{elem: thriftI32, items: []interface{}{encodingPlain, encodingRLE}}},
+				{3, tList{elem: thriftBinary, items: []interface{}{col.name}}},
+				{4, codecUncompressed},
+				{5, int64(p.rows)},
+				{6, size},
+				{7, size},
+				{9, offset},
+			}},
+		})
+
+		col.defLevels = col.defLevels[:0]
+		col.bools = col.bools[:0]
+		col.values.Reset()
+	}
+
+	p.rowGroups = append(p.rowGroups, tStruct{
+		{1, tList{elem: thriftStruct, items: chunks}},
+		{2, groupSize},
+		{3, int64(p.rows)},
+	})
+	p.totalRows += int64(p.rows)
+	p.rows = 0
+
+	return nil
+}
+
+// schema returns the file schema: a root element followed by one element
+// per column
+func (p *ParquetWriter) schema() []interface{} {
+	elements := []interface{}{
+		tStruct{{4, "click_event"}, {5, int32(len(p.columns))}},
+	}
+
+	for _, col := range p.columns {
+		repetition := repetitionRequired
+		if col.optional {
+			repetition = repetitionOptional
+		}
+		element := tStruct{{1, col.physical}, {3, repetition}, {4, col.name}}
+
+		switch {
+		case col.physical == parquetByteArray:
+			element = append(element, tField{6, convertedUTF8}, tField{10, tStruct{{1, tStruct{}}}})
+		case col.name == "ts":
+			// TIMESTAMP(isAdjustedToUTC=true, unit=MICROS)
+			element = append(element, tField{6, convertedTimestampUs},
+				tField{10, tStruct{{8, tStruct{{1, true}, {2, tStruct{{2, tStruct{}}}}}}}})
+		}
+
+		elements = append(elements, element)
+	}
+
+	return elements
+}
+
+// encodeLevels encodes definition levels of bit width 1 with the RLE
+// variant of Parquet's RLE/bit-packing hybrid encoding
+func encodeLevels(levels []byte) []byte {
+	var buf bytes.Buffer
+	for i := 0; i < len(levels); {
+		j := i + 1
+		for j < len(levels) && levels[j] == levels[i] {
+			j++
+		}
+		writeVarint(&buf, uint64(j-i)<<1)
+		buf.WriteByte(levels[i])
+		i = j
+	}
+	return buf.Bytes()
+}
+
+// packBools bit-packs booleans, least significant bit first
+func packBools(values []bool) []byte {
+	packed := make([]byte, (len(values)+7)/8)
+	for i, v := range values {
+		if v {
+			packed[i/8] |= 1 << uint(i%8)
+		}
+	}
+	return packed
+}
+
+// countingWriter tracks the number of bytes written for column offsets
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+// Write writes p and counts the bytes written
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
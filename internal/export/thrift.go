@@ -0,0 +1,122 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Thrift compact protocol type codes
+const (
+	thriftBoolTrue  = 1
+	thriftBoolFalse = 2
+	thriftI32       = 5
+	thriftI64       = 6
+	thriftBinary    = 8
+	thriftList      = 9
+	thriftStruct    = 12
+)
+
+// tField is one field of a Thrift struct. Values may be int32, int64,
+// string, bool, tStruct or tList.
+type tField struct {
+	id    int16
+	value interface{}
+}
+
+// tStruct is a Thrift struct; fields must be in increasing id order
+type tStruct []tField
+
+// tList is a homogeneous Thrift list
+type tList struct {
+	elem  byte
+	items []interface{}
+}
+
+// encodeThrift serializes a struct with the Thrift compact protocol, which
+// Parquet uses for its page headers and file metadata
+func encodeThrift(buf *bytes.Buffer, s tStruct) {
+	var last int16
+	for _, f := range s {
+		// Booleans are encoded in the field header
+		var typ byte
+		if b, ok := f.value.(bool); ok {
+			typ = thriftBoolFalse
+			if b {
+				typ = thriftBoolTrue
+			}
+		} else {
+			typ = thriftType(f.value)
+		}
+
+		if delta := f.id - last; delta > 0 && delta <= 15 {
+			buf.WriteByte(byte(delta)<<4 | typ)
+		} else {
+			buf.WriteByte(typ)
+			writeVarint(buf, zigzag(int64(f.id)))
+		}
+		last = f.id
+
+		if _, ok := f.value.(bool); !ok {
+			encodeThriftValue(buf, f.value)
+		}
+	}
+	buf.WriteByte(0) // stop field
+}
+
+// encodeThriftValue writes a value without a field header
+func encodeThriftValue(buf *bytes.Buffer, value interface{}) {
+	switch v := value.(type) {
+	case int32:
+		writeVarint(buf, zigzag(int64(v)))
+	case int64:
+		writeVarint(buf, zigzag(v))
+	case string:
+		writeVarint(buf, uint64(len(v)))
+		buf.WriteString(v)
+	case tStruct:
+		encodeThrift(buf, v)
+	case tList:
+		if len(v.items) < 15 {
+			buf.WriteByte(byte(len(v.items))<<4 | v.elem)
+		} else {
+			buf.WriteByte(0xf0 | v.elem)
+			writeVarint(buf, uint64(len(v.items)))
+		}
+		for _, item := range v.items {
+			encodeThriftValue(buf, item)
+		}
+	default:
+		panic(fmt.Sprintf("unsupported thrift value %T", value))
+	}
+}
+
+// thriftType returns the compact protocol type of a value
+func thriftType(value interface{}) byte {
+	switch value.(type) {
+	case int32:
+		return thriftI32
+	case int64:
+		return thriftI64
+	case string:
+		return thriftBinary
+	case tStruct:
+		return thriftStruct
+	case tList:
+		return thriftList
+	default:
+		panic(fmt.Sprintf("unsupported thrift value %T", value))
+	}
+}
+
+// zigzag maps signed integers to unsigned so small magnitudes stay short
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+// writeVarint writes an unsigned LEB128 varint
+func writeVarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	buf.Write(tmp[:n])
+}
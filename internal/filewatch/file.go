@@ -0,0 +1,144 @@
+// Package filewatch keeps values loaded from local files, such as databases
+// and blocklists that another process keeps up to date, in sync with the
+// files on disk.
+package filewatch
+
+import (
+	"context"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// File is a value loaded from a file. The file is reloaded when its size or
+// modification time changes. A file that has never loaded has no value; a
+// file that becomes invalid keeps its current value.
+type File[T any] struct {
+	path    string
+	load    func(data []byte) (T, error)
+	onError func(error)
+	current atomic.Pointer[version[T]]
+}
+
+// version is a loaded file
+type version[T any] struct {
+	value   T
+	modTime time.Time
+	size    int64
+}
+
+// New creates a file at path, parsed by load, and loads it. Failures to
+// read or parse the file are reported to onError.
+func New[T any](path string, load func(data []byte) (T, error), onError func(error)) *File[T] {
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	f := &File[T]{
+		path:    path,
+		load:    load,
+		onError: onError,
+	}
+	f.Reload()
+
+	return f
+}
+
+// Value returns the loaded value, or false if the file has never loaded
+func (f *File[T]) Value() (T, bool) {
+	current := f.current.Load()
+	if current == nil {
+		var zero T
+		return zero, false
+	}
+	return current.value, true
+}
+
+// Reload loads the file if it differs from the one in use
+func (f *File[T]) Reload() {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		f.onError(err)
+		return
+	}
+
+	current := f.current.Load()
+	if current != nil && info.ModTime().Equal(current.modTime) && info.Size() == current.size {
+		return
+	}
+
+	data, err := os.ReadFile(f.path)
+	if err != nil {
+		f.onError(err)
+		return
+	}
+	value, err := f.load(data)
+	if err != nil {
+		f.onError(err)
+		return
+	}
+
+	f.current.Store(&version[T]{
+		value:   value,
+		modTime: info.ModTime(),
+		size:    info.Size(),
+	})
+}
+
+// Reloader reloads a value from its source
+type Reloader interface {
+	Reload()
+}
+
+// Poller reloads files at a fixed interval
+type Poller struct {
+	reloaders []Reloader
+	interval  time.Duration
+	cancel    context.CancelFunc
+	wg        sync.WaitGroup
+}
+
+// NewPoller creates a poller that reloads every interval
+func NewPoller(interval time.Duration, reloaders ...Reloader) *Poller {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &Poller{reloaders: reloaders, interval: interval}
+}
+
+// Start polls until Stop is called
+func (p *Poller) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+
+	p.wg.Add(1)
+	go p.poll(ctx)
+}
+
+// Stop stops polling
+func (p *Poller) Stop() {
+	if p.cancel != nil {
+		p.cancel()
+	}
+	p.wg.Wait()
+}
+
+// poll reloads everything on each tick
+func (p *Poller) poll(ctx context.Context) {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, reloader := range p.reloaders {
+				reloader.Reload()
+			}
+		}
+	}
+}
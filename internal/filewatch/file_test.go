@@ -0,0 +1,97 @@
+package filewatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// upper loads a file as its upper-cased contents, rejecting empty files
+func upper(loads *int) func(data []byte) (string, error) {
+	return func(data []byte) (string, error) {
+		*loads++
+		if len(data) == 0 {
+			return "", errors.New("empty file")
+		}
+		return strings.ToUpper(string(data)), nil
+	}
+}
+
+func TestFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "list.txt")
+
+	var errs []error
+	loads := 0
+	file := New(path, upper(&loads), func(err error) { errs = append(errs, err) })
+	if _, ok := file.Value(); ok || len(errs) != 1 {
+		t.Fatalf("expected a missing file to be reported, got errors=%v", errs)
+	}
+
+	if err := os.WriteFile(path, []byte("one"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	file.Reload()
+	if value, ok := file.Value(); !ok || value != "ONE" {
+		t.Errorf("expected ONE once the file appeared, got %q", value)
+	}
+
+	// An unchanged file is not loaded again
+	file.Reload()
+	if loads != 1 {
+		t.Errorf("expected 1 load, got %d", loads)
+	}
+
+	if err := os.WriteFile(path, []byte("two"), 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("failed to touch file: %v", err)
+	}
+	file.Reload()
+	if value, _ := file.Value(); value != "TWO" {
+		t.Errorf("expected TWO after reload, got %q", value)
+	}
+
+	// An invalid replacement keeps the current value
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	errs = nil
+	file.Reload()
+	if value, _ := file.Value(); value != "TWO" || len(errs) != 1 {
+		t.Errorf("expected the previous value to be kept and the error reported, got %q, %v", value, errs)
+	}
+}
+
+type countingReloader struct {
+	reloads atomic.Int32
+}
+
+func (r *countingReloader) Reload() {
+	r.reloads.Add(1)
+}
+
+func TestPollerReloadsUntilStopped(t *testing.T) {
+	a, b := &countingReloader{}, &countingReloader{}
+	poller := NewPoller(5*time.Millisecond, a, b)
+	poller.Start()
+
+	deadline := time.Now().Add(time.Second)
+	for (a.reloads.Load() < 2 || b.reloads.Load() < 2) && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	poller.Stop()
+
+	if a.reloads.Load() < 2 || b.reloads.Load() < 2 {
+		t.Fatalf("expected repeated reloads, got %d and %d", a.reloads.Load(), b.reloads.Load())
+	}
+	stopped := a.reloads.Load()
+	time.Sleep(20 * time.Millisecond)
+	if a.reloads.Load() != stopped {
+		t.Error("expected no reloads after Stop")
+	}
+}
@@ -0,0 +1,125 @@
+package geo
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urlshortener/internal/filewatch"
+)
+
+// maxCachedRecords bounds the per-database cache of decoded country codes.
+// Country databases have a few hundred distinct records; city databases
+// have far more, and those beyond the limit are simply decoded each time.
+const maxCachedRecords = 16384
+
+// Locator resolves IP addresses to ISO country codes from an MMDB file,
+// reloading the file when it changes on disk. A Locator without a loaded
+// database resolves every address to the empty string.
+type Locator struct {
+	file   *filewatch.File[*database]
+	poller *filewatch.Poller
+}
+
+// database is a loaded MMDB file with its decoded country cache
+type database struct {
+	reader *Reader
+	mu     sync.RWMutex
+	cache  map[uint]string
+}
+
+// NewLocator creates a locator for the MMDB file at path. A missing or
+// invalid file is reported to onError and retried on every poll, so lookups
+// start working once a valid file appears.
+func NewLocator(path string, interval time.Duration, onError func(error)) *Locator {
+	file := filewatch.New(path, loadDatabase, onError)
+	return &Locator{
+		file:   file,
+		poller: filewatch.NewPoller(interval, file),
+	}
+}
+
+// loadDatabase parses an MMDB file
+func loadDatabase(data []byte) (*database, error) {
+	reader, err := NewReader(data)
+	if err != nil {
+		return nil, err
+	}
+	return &database{
+		reader: reader,
+		cache:  make(map[uint]string),
+	}, nil
+}
+
+// Start polls the database file for changes until Stop is called
+func (l *Locator) Start() {
+	l.poller.Start()
+}
+
+// Stop stops polling the database file
+func (l *Locator) Stop() {
+	l.poller.Stop()
+}
+
+// Loaded reports whether a database is currently loaded
+func (l *Locator) Loaded() bool {
+	_, ok := l.file.Value()
+	return ok
+}
+
+// Country returns the ISO 3166-1 alpha-2 country code for ip, or the empty
+// string if it cannot be resolved
+func (l *Locator) Country(ip net.IP) string {
+	db, ok := l.file.Value()
+	if !ok || ip == nil {
+		return ""
+	}
+
+	offset, found, err := db.reader.lookupOffset(ip)
+	if err != nil || !found {
+		return ""
+	}
+
+	db.mu.RLock()
+	code, ok := db.cache[offset]
+	db.mu.RUnlock()
+	if ok {
+		return code
+	}
+
+	record, err := db.reader.decodeAt(offset)
+	if err != nil {
+		return ""
+	}
+	code = countryCode(record)
+
+	db.mu.Lock()
+	if len(db.cache) < maxCachedRecords {
+		db.cache[offset] = code
+	}
+	db.mu.Unlock()
+
+	return code
+}
+
+// countryCode extracts the country ISO code from a GeoIP2/GeoLite2 Country
+// or City record, falling back to the registered country
+func countryCode(record interface{}) string {
+	fields, ok := record.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	for _, key := range []string{"country", "registered_country"} {
+		country, ok := fields[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if code, ok := country["iso_code"].(string); ok && len(code) == 2 {
+			return strings.ToUpper(code)
+		}
+	}
+
+	return ""
+}
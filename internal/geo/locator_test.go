@@ -0,0 +1,85 @@
+package geo
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLocatorCountry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	if err := os.WriteFile(path, buildDatabase(t, testNetworks, 6, 24), 0o644); err != nil {
+		t.Fatalf("failed to write database: %v", err)
+	}
+
+	locator := NewLocator(path, time.Hour, func(err error) { t.Errorf("unexpected error: %v", err) })
+	if !locator.Loaded() {
+		t.Fatal("expected database to be loaded")
+	}
+
+	tests := []struct {
+		ip       net.IP
+		expected string
+	}{
+		{net.ParseIP("203.0.113.9"), "AU"},
+		{net.ParseIP("203.0.113.9"), "AU"}, // served from the cache
+		{net.ParseIP("198.51.100.3"), "DE"},
+		{net.ParseIP("2001:db8:1::1"), "JP"},
+		{net.ParseIP("192.0.2.1"), ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := locator.Country(tt.ip); got != tt.expected {
+			t.Errorf("Country(%v): expected %q, got %q", tt.ip, tt.expected, got)
+		}
+	}
+}
+
+func TestLocatorReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+
+	var errs []error
+	locator := NewLocator(path, time.Hour, func(err error) { errs = append(errs, err) })
+	if locator.Loaded() || len(errs) != 1 {
+		t.Fatalf("expected a missing database to be reported, got loaded=%v errors=%v", locator.Loaded(), errs)
+	}
+	if got := locator.Country(net.ParseIP("203.0.113.9")); got != "" {
+		t.Errorf("expected no country without a database, got %q", got)
+	}
+
+	if err := os.WriteFile(path, buildDatabase(t, testNetworks, 6, 24), 0o644); err != nil {
+		t.Fatalf("failed to write database: %v", err)
+	}
+	locator.file.Reload()
+	if got := locator.Country(net.ParseIP("203.0.113.9")); got != "AU" {
+		t.Errorf("expected AU after the database appeared, got %q", got)
+	}
+
+	// Replace the database with one mapping the network elsewhere
+	if err := os.WriteFile(path, buildDatabase(t, map[string]string{"203.0.113.0/24": "JP"}, 6, 28), 0o644); err != nil {
+		t.Fatalf("failed to write database: %v", err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("failed to touch database: %v", err)
+	}
+	locator.file.Reload()
+	if got := locator.Country(net.ParseIP("203.0.113.9")); got != "JP" {
+		t.Errorf("expected JP after reload, got %q", got)
+	}
+
+	// An invalid replacement keeps the current database
+	if err := os.WriteFile(path, []byte("corrupt"), 0o644); err != nil {
+		t.Fatalf("failed to write database: %v", err)
+	}
+	errs = nil
+	locator.file.Reload()
+	if len(errs) != 1 {
+		t.Errorf("expected the invalid database to be reported, got %v", errs)
+	}
+	if got := locator.Country(net.ParseIP("203.0.113.9")); got != "JP" {
+		t.Errorf("expected the previous database to keep serving, got %q", got)
+	}
+}
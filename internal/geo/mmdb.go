@@ -0,0 +1,399 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"os"
+)
+
+// metadataMarker precedes the metadata section at the end of an MMDB file
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// maxMetadataSize bounds how far from the end of the file the marker is searched
+const maxMetadataSize = 128 * 1024
+
+// dataSectionSeparator is the number of zero bytes between the search tree
+// and the data section
+const dataSectionSeparator = 16
+
+// Data field types defined by the MaxMind DB format
+const (
+	typeExtended  = 0
+	typePointer   = 1
+	typeString    = 2
+	typeDouble    = 3
+	typeBytes     = 4
+	typeUint16    = 5
+	typeUint32    = 6
+	typeMap       = 7
+	typeInt32     = 8
+	typeUint64    = 9
+	typeUint128   = 10
+	typeArray     = 11
+	typeContainer = 12
+	typeEndMarker = 13
+	typeBool      = 14
+	typeFloat     = 15
+)
+
+// Metadata describes an MMDB file
+type Metadata struct {
+	DatabaseType string
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+	BuildEpoch   uint64
+}
+
+// Reader looks up IP addresses in a MaxMind DB (MMDB) file held in memory
+type Reader struct {
+	buf        []byte
+	metadata   Metadata
+	treeSize   uint
+	dataStart  uint
+	ipv4Start  uint
+	recordSize uint
+}
+
+// Open reads and validates an MMDB file
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GeoIP database: %w", err)
+	}
+	return NewReader(buf)
+}
+
+// NewReader parses an MMDB file from memory
+func NewReader(buf []byte) (*Reader, error) {
+	searchFrom := 0
+	if len(buf) > maxMetadataSize {
+		searchFrom = len(buf) - maxMetadataSize
+	}
+	idx := bytes.LastIndex(buf[searchFrom:], metadataMarker)
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: metadata marker not found", ErrInvalidDatabase)
+	}
+	metaStart := uint(searchFrom + idx + len(metadataMarker))
+
+	// Metadata pointers are relative to the start of the metadata section
+	meta := decoder{buf: buf[metaStart:]}
+	raw, _, err := meta.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDatabase, err)
+	}
+	fields, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", ErrInvalidDatabase)
+	}
+
+	r := &Reader{buf: buf}
+	r.metadata.DatabaseType, _ = fields["database_type"].(string)
+	r.metadata.NodeCount = uint(toUint64(fields["node_count"]))
+	r.metadata.RecordSize = uint(toUint64(fields["record_size"]))
+	r.metadata.IPVersion = uint(toUint64(fields["ip_version"]))
+	r.metadata.BuildEpoch = toUint64(fields["build_epoch"])
+
+	switch r.metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", ErrInvalidDatabase, r.metadata.RecordSize)
+	}
+	if r.metadata.IPVersion != 4 && r.metadata.IPVersion != 6 {
+		return nil, fmt.Errorf("%w: unsupported IP version %d", ErrInvalidDatabase, r.metadata.IPVersion)
+	}
+
+	r.recordSize = r.metadata.RecordSize
+	r.treeSize = r.metadata.NodeCount * r.recordSize / 4
+	r.dataStart = r.treeSize + dataSectionSeparator
+	if r.dataStart > uint(searchFrom+idx) {
+		return nil, fmt.Errorf("%w: search tree exceeds file size", ErrInvalidDatabase)
+	}
+
+	// IPv4 addresses live under ::/96 in IPv6 databases
+	if r.metadata.IPVersion == 6 {
+		node := uint(0)
+		for i := 0; i < 96 && node < r.metadata.NodeCount; i++ {
+			node = r.readRecord(node, 0)
+		}
+		r.ipv4Start = node
+	}
+
+	return r, nil
+}
+
+// Metadata returns the database metadata
+func (r *Reader) Metadata() Metadata {
+	return r.metadata
+}
+
+// Lookup decodes the record for ip. It returns nil if the address is not in
+// the database.
+func (r *Reader) Lookup(ip net.IP) (interface{}, error) {
+	offset, found, err := r.lookupOffset(ip)
+	if err != nil || !found {
+		return nil, err
+	}
+	return r.decodeAt(offset)
+}
+
+// lookupOffset walks the search tree and returns the data section offset of
+// the record for ip
+func (r *Reader) lookupOffset(ip net.IP) (uint, bool, error) {
+	node := uint(0)
+	bits := ip.To4()
+	if bits != nil {
+		node = r.ipv4Start
+	} else {
+		if r.metadata.IPVersion == 4 {
+			return 0, false, nil
+		}
+		bits = ip.To16()
+		if bits == nil {
+			return 0, false, fmt.Errorf("invalid IP address %v", ip)
+		}
+	}
+
+	nodeCount := r.metadata.NodeCount
+	for i := 0; i < len(bits)*8 && node < nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-uint(i%8))) & 1
+		node = r.readRecord(node, bit)
+	}
+
+	switch {
+	case node == nodeCount:
+		return 0, false, nil
+	case node > nodeCount:
+		offset := node - nodeCount - dataSectionSeparator
+		if r.dataStart+offset >= uint(len(r.buf)) {
+			return 0, false, fmt.Errorf("%w: record points outside the data section", ErrInvalidDatabase)
+		}
+		return offset, true, nil
+	default:
+		return 0, false, fmt.Errorf("%w: search tree ended at an internal node", ErrInvalidDatabase)
+	}
+}
+
+// readRecord reads the left (bit 0) or right (bit 1) record of a node
+func (r *Reader) readRecord(node, bit uint) uint {
+	b := r.buf[node*r.recordSize/4:]
+
+	switch r.recordSize {
+	case 24:
+		off := bit * 3
+		return uint(b[off])<<16 | uint(b[off+1])<<8 | uint(b[off+2])
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xf0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0f)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:]))
+	}
+}
+
+// decodeAt decodes the value at an offset in the data section
+func (r *Reader) decodeAt(offset uint) (interface{}, error) {
+	d := decoder{buf: r.buf[r.dataStart:]}
+	value, _, err := d.decode(offset, 0)
+	return value, err
+}
+
+// maxDecodeDepth guards against maliciously nested data
+const maxDecodeDepth = 32
+
+// decoder reads values from an MMDB data section. Pointers are offsets from
+// the start of buf.
+type decoder struct {
+	buf []byte
+}
+
+// decode reads the value at offset and returns it with the offset that
+// follows it
+func (d decoder) decode(offset uint, depth int) (interface{}, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, fmt.Errorf("data nested too deeply")
+	}
+
+	typ, size, offset, err := d.readControl(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		target, next, err := d.readPointer(size, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decode(target, depth+1)
+		return value, next, err
+	}
+
+	if typ == typeMap {
+		m := make(map[string]interface{})
+		for i := uint(0); i < size; i++ {
+			var key, value interface{}
+			key, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			name, ok := key.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("map key is not a string")
+			}
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[name] = value
+		}
+		return m, offset, nil
+	}
+
+	if typ == typeArray {
+		var a []interface{}
+		for i := uint(0); i < size; i++ {
+			var value interface{}
+			value, offset, err = d.decode(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	}
+
+	if typ == typeBool {
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buf)) {
+		return nil, 0, fmt.Errorf("value extends past the end of the data")
+	}
+	b := d.buf[offset:end]
+
+	switch typ {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes, typeUint128:
+		return append([]byte(nil), b...), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("invalid double size %d", size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("invalid float size %d", size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), end, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, fmt.Errorf("invalid integer size %d", size)
+		}
+		var v uint64
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, end, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, fmt.Errorf("invalid int32 size %d", size)
+		}
+		var v uint32
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		// Only the full four byte form can be negative
+		return int64(int32(v)), end, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+	}
+}
+
+// readControl parses a control byte and any extended type and size bytes
+func (d decoder) readControl(offset uint) (typ, size, next uint, err error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, 0, 0, fmt.Errorf("offset %d is outside the data", offset)
+	}
+	ctrl := d.buf[offset]
+	offset++
+
+	typ = uint(ctrl >> 5)
+	if typ == typeExtended {
+		if offset >= uint(len(d.buf)) {
+			return 0, 0, 0, fmt.Errorf("truncated extended type")
+		}
+		typ = 7 + uint(d.buf[offset])
+		offset++
+	}
+
+	// Pointers encode their size bits differently
+	if typ == typePointer {
+		return typ, uint(ctrl & 0x1f), offset, nil
+	}
+
+	size = uint(ctrl & 0x1f)
+	if size >= 29 {
+		n := size - 28
+		if offset+n > uint(len(d.buf)) {
+			return 0, 0, 0, fmt.Errorf("truncated size")
+		}
+		var extra uint
+		for _, c := range d.buf[offset : offset+n] {
+			extra = extra<<8 | uint(c)
+		}
+		offset += n
+		switch n {
+		case 1:
+			size = 29 + extra
+		case 2:
+			size = 285 + extra
+		default:
+			size = 65821 + extra
+		}
+	}
+
+	return typ, size, offset, nil
+}
+
+// readPointer decodes a pointer whose control bits were sizeBits
+func (d decoder) readPointer(sizeBits, offset uint) (target, next uint, err error) {
+	n := (sizeBits>>3)&0x3 + 1
+	if offset+n > uint(len(d.buf)) {
+		return 0, 0, fmt.Errorf("truncated pointer")
+	}
+
+	var v uint
+	for _, c := range d.buf[offset : offset+n] {
+		v = v<<8 | uint(c)
+	}
+
+	switch n {
+	case 1:
+		target = (sizeBits&0x7)<<8 | v
+	case 2:
+		target = ((sizeBits&0x7)<<16 | v) + 2048
+	case 3:
+		target = ((sizeBits&0x7)<<24 | v) + 526336
+	default:
+		target = v
+	}
+
+	return target, offset + n, nil
+}
+
+// toUint64 converts a decoded unsigned integer
+func toUint64(v interface{}) uint64 {
+	n, _ := v.(uint64)
+	return n
+}
+
+// Custom errors
+var (
+	ErrInvalidDatabase = fmt.Errorf("invalid GeoIP database")
+)
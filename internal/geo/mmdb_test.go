@@ -0,0 +1,288 @@
+package geo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"testing"
+)
+
+// testNetworks maps CIDRs to country codes for the generated databases
+var testNetworks = map[string]string{
+	"203.0.113.0/24":  "AU",
+	"198.51.100.0/25": "DE",
+	"2001:db8::/32":   "JP",
+}
+
+// encodeData appends an MMDB data section encoding of v
+func encodeData(buf *bytes.Buffer, v interface{}) {
+	writeControl := func(typ int, size int) {
+		var ctrl byte
+		if typ > 7 {
+			ctrl = 0
+		} else {
+			ctrl = byte(typ << 5)
+		}
+		switch {
+		case size < 29:
+			buf.WriteByte(ctrl | byte(size))
+			if typ > 7 {
+				buf.WriteByte(byte(typ - 7))
+			}
+		case size < 285:
+			buf.WriteByte(ctrl | 29)
+			if typ > 7 {
+				buf.WriteByte(byte(typ - 7))
+			}
+			buf.WriteByte(byte(size - 29))
+		default:
+			buf.WriteByte(ctrl | 30)
+			if typ > 7 {
+				buf.WriteByte(byte(typ - 7))
+			}
+			binary.Write(buf, binary.BigEndian, uint16(size-285))
+		}
+	}
+
+	switch val := v.(type) {
+	case string:
+		writeControl(typeString, len(val))
+		buf.WriteString(val)
+	case uint16:
+		writeControl(typeUint16, 2)
+		binary.Write(buf, binary.BigEndian, val)
+	case uint32:
+		writeControl(typeUint32, 4)
+		binary.Write(buf, binary.BigEndian, val)
+	case uint64:
+		writeControl(typeUint64, 8)
+		binary.Write(buf, binary.BigEndian, val)
+	case bool:
+		size := 0
+		if val {
+			size = 1
+		}
+		writeControl(typeBool, size)
+	case []interface{}:
+		writeControl(typeArray, len(val))
+		for _, item := range val {
+			encodeData(buf, item)
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		writeControl(typeMap, len(keys))
+		for _, k := range keys {
+			encodeData(buf, k)
+			encodeData(buf, val[k])
+		}
+	default:
+		panic(fmt.Sprintf("unsupported test value %T", v))
+	}
+}
+
+// trieNode is a node of the search tree being built; children are node
+// indexes, -1 for empty or -2-n for data record n
+type trieNode [2]int
+
+// buildDatabase writes an MMDB file containing networks
+func buildDatabase(t *testing.T, networks map[string]string, ipVersion, recordSize int) []byte {
+	t.Helper()
+
+	nodes := []trieNode{{-1, -1}}
+	var data bytes.Buffer
+	offsets := map[string]int{}
+
+	// Encode each country once, with a pointer from a second record to
+	// exercise pointer decoding
+	for _, code := range []string{"AU", "DE", "JP"} {
+		offsets[code] = data.Len()
+		encodeData(&data, map[string]interface{}{
+			"country": map[string]interface{}{
+				"iso_code": code,
+				"names":    map[string]interface{}{"en": "Country " + code},
+			},
+			"is_anycast": false,
+		})
+	}
+	pointerOffset := data.Len()
+	data.WriteByte(typePointer<<5 | 0) // pointer with 11-bit target
+	data.WriteByte(byte(offsets["DE"]))
+	offsets["DE-pointer"] = pointerOffset
+
+	for cidr, code := range networks {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			t.Fatalf("invalid CIDR %q: %v", cidr, err)
+		}
+		ones, _ := network.Mask.Size()
+		ip := network.IP.To16()
+		if v4 := network.IP.To4(); v4 != nil {
+			if ipVersion == 4 {
+				ip = v4
+			} else {
+				ip = append(make(net.IP, 12), v4...)
+				ones += 96
+			}
+		}
+
+		record := offsets[code]
+		if code == "DE" {
+			record = offsets["DE-pointer"]
+		}
+
+		node := 0
+		for i := 0; i < ones; i++ {
+			bit := int(ip[i/8]>>(7-uint(i%8))) & 1
+			if i == ones-1 {
+				nodes[node][bit] = -2 - record
+				break
+			}
+			if nodes[node][bit] < 0 {
+				nodes = append(nodes, trieNode{-1, -1})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+
+	nodeCount := len(nodes)
+	var out bytes.Buffer
+	recordValue := func(child int) uint32 {
+		switch {
+		case child == -1:
+			return uint32(nodeCount)
+		case child < -1:
+			return uint32(nodeCount + dataSectionSeparator + (-2 - child))
+		default:
+			return uint32(child)
+		}
+	}
+	for _, n := range nodes {
+		left, right := recordValue(n[0]), recordValue(n[1])
+		switch recordSize {
+		case 24:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)})
+		case 28:
+			out.Write([]byte{byte(left >> 16), byte(left >> 8), byte(left),
+				byte((left>>20)&0xf0) | byte((right>>24)&0x0f),
+				byte(right >> 16), byte(right >> 8), byte(right)})
+		case 32:
+			binary.Write(&out, binary.BigEndian, left)
+			binary.Write(&out, binary.BigEndian, right)
+		}
+	}
+	out.Write(make([]byte, dataSectionSeparator))
+	out.Write(data.Bytes())
+
+	out.Write(metadataMarker)
+	encodeData(&out, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(1700000000),
+		"database_type":               "Test-Country",
+		"description":                 map[string]interface{}{"en": "Test database"},
+		"ip_version":                  uint16(ipVersion),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(recordSize),
+	})
+
+	return out.Bytes()
+}
+
+func TestReaderLookup(t *testing.T) {
+	for _, recordSize := range []int{24, 28, 32} {
+		for _, ipVersion := range []int{4, 6} {
+			networks := testNetworks
+			if ipVersion == 4 {
+				networks = map[string]string{"203.0.113.0/24": "AU", "198.51.100.0/25": "DE"}
+			}
+
+			t.Run(fmt.Sprintf("ipv%d/%d-bit", ipVersion, recordSize), func(t *testing.T) {
+				reader, err := NewReader(buildDatabase(t, networks, ipVersion, recordSize))
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				meta := reader.Metadata()
+				if meta.DatabaseType != "Test-Country" || meta.BuildEpoch != 1700000000 {
+					t.Errorf("unexpected metadata: %+v", meta)
+				}
+
+				tests := []struct {
+					ip       string
+					expected string
+				}{
+					{"203.0.113.7", "AU"},
+					{"203.0.113.255", "AU"},
+					{"198.51.100.1", "DE"},
+					{"198.51.100.200", ""},
+					{"192.0.2.1", ""},
+				}
+				if ipVersion == 6 {
+					tests = append(tests,
+						struct{ ip, expected string }{"2001:db8::1", "JP"},
+						struct{ ip, expected string }{"2001:db9::1", ""},
+					)
+				}
+
+				for _, tt := range tests {
+					record, err := reader.Lookup(net.ParseIP(tt.ip))
+					if err != nil {
+						t.Fatalf("lookup %s: unexpected error: %v", tt.ip, err)
+					}
+					if got := countryCode(record); got != tt.expected {
+						t.Errorf("lookup %s: expected %q, got %q", tt.ip, tt.expected, got)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestReaderDecodesRecordFields(t *testing.T) {
+	reader, err := NewReader(buildDatabase(t, testNetworks, 6, 24))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	record, err := reader.Lookup(net.ParseIP("2001:db8::1"))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fields := record.(map[string]interface{})
+	if anycast, ok := fields["is_anycast"].(bool); !ok || anycast {
+		t.Errorf("expected is_anycast false, got %v", fields["is_anycast"])
+	}
+	names := fields["country"].(map[string]interface{})["names"].(map[string]interface{})
+	if names["en"] != "Country JP" {
+		t.Errorf("expected English name, got %v", names["en"])
+	}
+}
+
+func TestNewReaderRejectsInvalidData(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"no marker", []byte("not a database")},
+		{"truncated metadata", append(append([]byte{}, metadataMarker...), 0xe9)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewReader(tt.data); !errors.Is(err, ErrInvalidDatabase) {
+				t.Errorf("expected ErrInvalidDatabase, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/netutil"
+)
+
+// ReportURL handles POST /api/v1/urls/:code/report
+func (h *Handler) ReportURL(c *gin.Context) {
+	code := c.Param("code")
+
+	var req models.ReportURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBindError(c, "invalid_request", err)
+		return
+	}
+
+	if err := h.service.ReportURL(c.Request.Context(), code, &req, netutil.ClientIP(c)); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	// The response is the same whether or not the report changed the link
+	c.JSON(http.StatusAccepted, models.ReportURLResponse{
+		Code:    code,
+		Message: "Thank you, the link will be reviewed",
+	})
+}
+
+// SuspendURL handles POST /api/v1/admin/urls/:code/suspend
+func (h *Handler) SuspendURL(c *gin.Context) {
+	req, ok := bindLinkStatusRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.SuspendURL(c.Request.Context(), c.Param("code"), req); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":   c.Param("code"),
+		"status": models.LinkSuspended,
+	})
+}
+
+// UnsuspendURL handles POST /api/v1/admin/urls/:code/unsuspend
+func (h *Handler) UnsuspendURL(c *gin.Context) {
+	req, ok := bindLinkStatusRequest(c)
+	if !ok {
+		return
+	}
+
+	if err := h.service.UnsuspendURL(c.Request.Context(), c.Param("code"), req); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":   c.Param("code"),
+		"status": models.LinkActive,
+	})
+}
+
+// GetURLReports handles GET /api/v1/admin/urls/:code/reports
+func (h *Handler) GetURLReports(c *gin.Context) {
+	page, pageSize := parsePagination(c)
+
+	moderation, err := h.service.GetURLModeration(c.Request.Context(), c.Param("code"), page, pageSize)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, moderation)
+}
+
+// bindLinkStatusRequest reads the optional body of a moderation request
+func bindLinkStatusRequest(c *gin.Context) (*models.LinkStatusRequest, bool) {
+	var req models.LinkStatusRequest
+	if c.Request.ContentLength == 0 {
+		return &req, true
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBindError(c, "invalid_request", err)
+		return nil, false
+	}
+	return &req, true
+}
+
+// renderSuspended serves the warning page of a suspended link in place of
+// the link. The destination is deliberately left out.
+func (h *Handler) renderSuspended(c *gin.Context, code string) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+
+	c.Render(http.StatusForbidden, render.HTML{
+		Template: pageTemplates,
+		Name:     "suspended.html",
+		Data: suspendedPage{
+			Code:     code,
+			ShortURL: h.baseURL + "/" + code,
+		},
+	})
+}
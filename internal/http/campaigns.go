@@ -0,0 +1,180 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/models"
+)
+
+// AddTags handles POST /api/v1/urls/:code/tags
+func (h *Handler) AddTags(c *gin.Context) {
+	code := c.Param("code")
+
+	var req models.TagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBindError(c, "invalid_request", err)
+		return
+	}
+
+	tags, err := h.service.AddTags(c.Request.Context(), code, req.Tags)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": code,
+		"tags": tags,
+	})
+}
+
+// RemoveTag handles DELETE /api/v1/urls/:code/tags/:tag
+func (h *Handler) RemoveTag(c *gin.Context) {
+	code := c.Param("code")
+	tag := c.Param("tag")
+
+	if err := h.service.RemoveTag(c.Request.Context(), code, tag); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Tag removed successfully",
+		"code":    code,
+		"tag":     tag,
+	})
+}
+
+// SetURLCampaign handles PUT /api/v1/urls/:code/campaign
+func (h *Handler) SetURLCampaign(c *gin.Context) {
+	code := c.Param("code")
+
+	var req models.SetCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBindError(c, "invalid_request", err)
+		return
+	}
+
+	if err := h.service.SetURLCampaign(c.Request.Context(), code, req.CampaignID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":        code,
+		"campaign_id": req.CampaignID,
+	})
+}
+
+// CreateCampaign handles POST /api/v1/campaigns
+func (h *Handler) CreateCampaign(c *gin.Context) {
+	user := c.GetHeader("X-User-ID")
+	if user == "" {
+		writeProblem(c, http.StatusBadRequest, "invalid_user", "X-User-ID header is required")
+		return
+	}
+
+	var req models.CreateCampaignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBindError(c, "invalid_request", err)
+		return
+	}
+
+	campaign, err := h.service.CreateCampaign(c.Request.Context(), user, &req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, campaign)
+}
+
+// GetCampaign handles GET /api/v1/campaigns/:id
+func (h *Handler) GetCampaign(c *gin.Context) {
+	id, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+
+	campaign, err := h.service.GetCampaign(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, campaign)
+}
+
+// GetCampaignURLs handles GET /api/v1/campaigns/:id/urls
+func (h *Handler) GetCampaignURLs(c *gin.Context) {
+	id, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+
+	urls, err := h.service.GetCampaignURLs(c.Request.Context(), id, page, pageSize)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, urls)
+}
+
+// GetCampaignStats handles GET /api/v1/campaigns/:id/stats
+func (h *Handler) GetCampaignStats(c *gin.Context) {
+	id, ok := parseCampaignID(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.GetCampaignStats(c.Request.Context(), id)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// GetUserCampaigns handles GET /api/v1/users/:user/campaigns
+func (h *Handler) GetUserCampaigns(c *gin.Context) {
+	campaigns, err := h.service.GetUserCampaigns(c.Request.Context(), c.Param("user"))
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"campaigns": campaigns,
+	})
+}
+
+// parseCampaignID reads the :id path parameter, writing a 400 if it is invalid
+func parseCampaignID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id < 1 {
+		writeProblem(c, http.StatusBadRequest, "invalid_campaign_id", "Campaign ID must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
+
+// parseOptionalCampaignID reads the campaign_id query parameter if present
+func parseOptionalCampaignID(c *gin.Context) (*int64, bool) {
+	raw := c.Query("campaign_id")
+	if raw == "" {
+		return nil, true
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 1 {
+		writeProblem(c, http.StatusBadRequest, "invalid_campaign_id", "Campaign ID must be a positive integer")
+		return nil, false
+	}
+	return &id, true
+}
@@ -0,0 +1,56 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/models"
+)
+
+// ExportURLClicks handles GET /api/v1/urls/:code/clicks/export
+func (h *Handler) ExportURLClicks(c *gin.Context) {
+	h.exportClicks(c, c.Param("code"), "")
+}
+
+// ExportUserClicks handles GET /api/v1/users/:user/clicks/export
+func (h *Handler) ExportUserClicks(c *gin.Context) {
+	user := c.Param("user")
+	if user == "" {
+		writeProblem(c, http.StatusBadRequest, "invalid_user", "User parameter is required")
+		return
+	}
+
+	h.exportClicks(c, "", user)
+}
+
+// exportClicks streams the click events of a link or a user's links in the
+// requested format
+func (h *Handler) exportClicks(c *gin.Context, code, user string) {
+	var query models.ExportQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		writeBindError(c, "invalid_export_query", err)
+		return
+	}
+
+	export, err := h.service.NewClickExport(c.Request.Context(), code, user, query)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", export.Format.ContentType())
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.Filename))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+
+	// Large exports outlast the server's write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	// The status is already sent, so a failure can only cut the download
+	// short; Parquet files are left without a footer and fail to open
+	if err := export.Stream(c.Request.Context(), c.Writer); err != nil {
+		c.Error(err)
+	}
+}
@@ -1,20 +1,30 @@
 package http
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
 	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/netutil"
+	"github.com/urlshortener/internal/privacy"
+	"github.com/urlshortener/internal/qrcode"
 	"github.com/urlshortener/internal/service"
+	"github.com/urlshortener/internal/ua"
 )
 
+// qrCacheSize is the number of rendered QR images kept in memory
+const qrCacheSize = 512
+
 // Handler provides HTTP handlers for the URL shortener API
 type Handler struct {
 	service *service.ShortenerService
 	baseURL string
+	qr      *qrcode.Generator
 }
 
 // NewHandler creates a new HTTP handler
@@ -22,6 +32,7 @@ func NewHandler(service *service.ShortenerService, baseURL string) *Handler {
 	return &Handler{
 		service: service,
 		baseURL: baseURL,
+		qr:      qrcode.NewGenerator(qrCacheSize),
 	}
 }
 
@@ -29,10 +40,7 @@ func NewHandler(service *service.ShortenerService, baseURL string) *Handler {
 func (h *Handler) CreateShortURL(c *gin.Context) {
 	var req models.CreateURLRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_request",
-			Message: "Invalid request body: " + err.Error(),
-		})
+		writeBindError(c, "invalid_request", err)
 		return
 	}
 
@@ -44,27 +52,7 @@ func (h *Handler) CreateShortURL(c *gin.Context) {
 	// Create short URL
 	response, err := h.service.CreateShortURL(c.Request.Context(), &req)
 	if err != nil {
-		status := http.StatusInternalServerError
-		errorCode := "internal_error"
-		
-		if strings.Contains(err.Error(), "custom alias already exists") {
-			status = http.StatusConflict
-			errorCode = "alias_exists"
-		} else if strings.Contains(err.Error(), "invalid URL") {
-			status = http.StatusBadRequest
-			errorCode = "invalid_url"
-		} else if strings.Contains(err.Error(), "URL too long") {
-			status = http.StatusBadRequest
-			errorCode = "url_too_long"
-		} else if strings.Contains(err.Error(), "blocked") {
-			status = http.StatusForbidden
-			errorCode = "url_blocked"
-		}
-
-		c.JSON(status, models.ErrorResponse{
-			Error:   errorCode,
-			Message: err.Error(),
-		})
+		writeError(c, err)
 		return
 	}
 
@@ -75,83 +63,216 @@ func (h *Handler) CreateShortURL(c *gin.Context) {
 func (h *Handler) RedirectToLongURL(c *gin.Context) {
 	code := c.Param("code")
 	if code == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_code",
-			Message: "URL code is required",
-		})
+		writeProblem(c, http.StatusBadRequest, "invalid_code", "URL code is required")
+		return
+	}
+
+	// A trailing "+" or ?preview=1 shows where the link goes without following it
+	if strings.HasSuffix(code, "+") || c.Query("preview") == "1" {
+		h.PreviewURL(c, strings.TrimSuffix(code, "+"))
 		return
 	}
 
 	// Extract request information for analytics
 	userAgent := c.GetHeader("User-Agent")
-	ipAddress := c.ClientIP()
+	ipAddress := netutil.ClientIP(c)
 	referer := c.GetHeader("Referer")
+	doNotTrack := privacy.DoNotTrack(c.Request.Header)
 
-	// Get long URL
-	url, err := h.service.GetLongURL(c.Request.Context(), code, userAgent, ipAddress, referer)
+	// Social crawlers get the link's own Open Graph card instead of following
+	// the redirect, and their hits are not counted as clicks
+	if ua.IsSocialCrawler(userAgent) {
+		h.renderOpenGraph(c, code)
+		return
+	}
+
+	url, err := h.service.LookupURL(c.Request.Context(), code)
 	if err != nil {
-		status := http.StatusNotFound
-		errorCode := "url_not_found"
-		
-		if strings.Contains(err.Error(), "expired") {
-			status = http.StatusGone
-			errorCode = "url_expired"
-		} else if strings.Contains(err.Error(), "deleted") {
-			status = http.StatusGone
-			errorCode = "url_deleted"
-		}
+		writeError(c, err)
+		return
+	}
+
+	if url.Status == models.LinkSuspended {
+		h.renderSuspended(c, code)
+		return
+	}
+
+	// Links flagged for an interstitial, or reported and awaiting review,
+	// always stop at the preview page. Visitors may leave at the warning, so
+	// only redirects are counted as clicks.
+	if url.ShowInterstitial || url.Status == models.LinkUnderReview {
+		h.renderPreview(c, url, true)
+		return
+	}
+
+	h.service.RecordClick(c.Request.Context(), code, userAgent, ipAddress, referer, doNotTrack)
+
+	// A link's status can change, so browsers must come back for every click
+	// rather than cache the redirect
+	c.Header("Cache-Control", "private, no-cache")
+	c.Redirect(http.StatusFound, url.LongURL)
+}
+
+// PreviewURL renders the preview page for GET /:code+ without recording a click
+func (h *Handler) PreviewURL(c *gin.Context, code string) {
+	url, err := h.service.LookupURL(c.Request.Context(), code)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	if url.Status == models.LinkSuspended {
+		h.renderSuspended(c, code)
+		return
+	}
+
+	h.renderPreview(c, url, false)
+}
 
-		c.JSON(status, models.ErrorResponse{
-			Error:   errorCode,
-			Message: "URL not found or no longer available",
-		})
+// renderOpenGraph serves a crawler an HTML card with the link's Open Graph tags
+// and, unless the link stops at the preview page, a meta refresh to the
+// destination
+func (h *Handler) renderOpenGraph(c *gin.Context, code string) {
+	metadata, err := h.service.GetURLMetadata(c.Request.Context(), code)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	// Crawlers must not unfurl a card pointing at a suspended destination
+	if metadata.Status == models.LinkSuspended {
+		h.renderSuspended(c, code)
 		return
 	}
 
-	// Redirect to long URL
-	c.Redirect(http.StatusMovedPermanently, url.LongURL)
+	if metadata.ShowInterstitial || metadata.Status == models.LinkUnderReview {
+		c.Header("Cache-Control", "no-store")
+	} else {
+		c.Header("Cache-Control", "public, max-age=300")
+	}
+
+	c.Render(http.StatusOK, render.HTML{
+		Template: pageTemplates,
+		Name:     "opengraph.html",
+		Data:     h.newOpenGraphPage(metadata),
+	})
+}
+
+// renderPreview writes the HTML preview page for a short URL
+func (h *Handler) renderPreview(c *gin.Context, url *models.ShortURL, interstitial bool) {
+	// Previews must never be cached as the redirect itself
+	c.Header("Cache-Control", "no-store")
+	c.Header("X-Robots-Tag", "noindex, nofollow")
+
+	c.Render(http.StatusOK, render.HTML{
+		Template: pageTemplates,
+		Name:     "preview.html",
+		Data:     h.newPreviewPage(url, interstitial),
+	})
 }
 
 // GetURLMetadata handles GET /api/v1/urls/:code
 func (h *Handler) GetURLMetadata(c *gin.Context) {
 	code := c.Param("code")
 	if code == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_code",
-			Message: "URL code is required",
-		})
+		writeProblem(c, http.StatusBadRequest, "invalid_code", "URL code is required")
 		return
 	}
 
 	// Get metadata
 	metadata, err := h.service.GetURLMetadata(c.Request.Context(), code)
 	if err != nil {
-		status := http.StatusNotFound
-		errorCode := "url_not_found"
-		
-		if strings.Contains(err.Error(), "expired") {
-			status = http.StatusGone
-			errorCode = "url_expired"
-		}
-
-		c.JSON(status, models.ErrorResponse{
-			Error:   errorCode,
-			Message: "URL not found or no longer available",
-		})
+		writeError(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, metadata)
 }
 
+// GetQRCode handles GET /api/v1/urls/:code/qr
+func (h *Handler) GetQRCode(c *gin.Context) {
+	code := c.Param("code")
+	if code == "" {
+		writeProblem(c, http.StatusBadRequest, "invalid_code", "URL code is required")
+		return
+	}
+
+	opts, err := parseQROptions(c)
+	if err != nil {
+		writeProblem(c, http.StatusBadRequest, "invalid_qr_options", err.Error())
+		return
+	}
+
+	// Only render codes for links that still resolve
+	url, err := h.service.LookupURL(c.Request.Context(), code)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+	if url.Status == models.LinkSuspended {
+		writeProblem(c, http.StatusForbidden, "url_suspended", "URL has been suspended")
+		return
+	}
+
+	image, err := h.qr.Generate(h.baseURL+"/"+code, opts)
+	if err != nil {
+		writeProblem(c, http.StatusInternalServerError, "qr_generation_failed", "Failed to generate QR code")
+		return
+	}
+
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, opts.ContentType(), image)
+}
+
+// parseQROptions reads QR rendering options from the query string
+func parseQROptions(c *gin.Context) (qrcode.Options, error) {
+	opts := qrcode.DefaultOptions()
+
+	if format := c.Query("format"); format != "" {
+		opts.Format = qrcode.Format(strings.ToLower(format))
+	}
+	if level := c.Query("level"); level != "" {
+		opts.Level = strings.ToUpper(level)
+	}
+
+	if size := c.Query("size"); size != "" {
+		value, err := strconv.Atoi(size)
+		if err != nil {
+			return opts, fmt.Errorf("size must be an integer")
+		}
+		opts.Size = value
+	}
+	if margin := c.Query("margin"); margin != "" {
+		value, err := strconv.Atoi(margin)
+		if err != nil {
+			return opts, fmt.Errorf("margin must be an integer")
+		}
+		opts.Margin = value
+	}
+
+	if fg := c.Query("fg"); fg != "" {
+		value, err := qrcode.ParseColor(fg)
+		if err != nil {
+			return opts, err
+		}
+		opts.Foreground = value
+	}
+	if bg := c.Query("bg"); bg != "" {
+		value, err := qrcode.ParseColor(bg)
+		if err != nil {
+			return opts, err
+		}
+		opts.Background = value
+	}
+
+	return opts, opts.Validate()
+}
+
 // DeleteURL handles DELETE /api/v1/urls/:code
 func (h *Handler) DeleteURL(c *gin.Context) {
 	code := c.Param("code")
 	if code == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_code",
-			Message: "URL code is required",
-		})
+		writeProblem(c, http.StatusBadRequest, "invalid_code", "URL code is required")
 		return
 	}
 
@@ -160,17 +281,7 @@ func (h *Handler) DeleteURL(c *gin.Context) {
 
 	// Delete URL
 	if err := h.service.DeleteURL(c.Request.Context(), code); err != nil {
-		status := http.StatusNotFound
-		errorCode := "url_not_found"
-		
-		if strings.Contains(err.Error(), "not found") {
-			status = http.StatusNotFound
-		}
-
-		c.JSON(status, models.ErrorResponse{
-			Error:   errorCode,
-			Message: err.Error(),
-		})
+		writeError(c, err)
 		return
 	}
 
@@ -213,14 +324,33 @@ func (h *Handler) ReadinessCheck(c *gin.Context) {
 func (h *Handler) GetUserURLs(c *gin.Context) {
 	user := c.Param("user")
 	if user == "" {
-		c.JSON(http.StatusBadRequest, models.ErrorResponse{
-			Error:   "invalid_user",
-			Message: "User parameter is required",
-		})
+		writeProblem(c, http.StatusBadRequest, "invalid_user", "User parameter is required")
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+
+	// Optional filters: ?tag=a&tag=b requires every tag, ?campaign_id=N a campaign
+	campaignID, ok := parseOptionalCampaignID(c)
+	if !ok {
+		return
+	}
+	filter := models.URLFilter{
+		Tags:       c.QueryArray("tag"),
+		CampaignID: campaignID,
+	}
+
+	urls, err := h.service.GetUserURLs(c.Request.Context(), user, filter, page, pageSize)
+	if err != nil {
+		writeError(c, err)
 		return
 	}
 
-	// Parse pagination parameters
+	c.JSON(http.StatusOK, urls)
+}
+
+// parsePagination reads the page and page_size query parameters
+func parsePagination(c *gin.Context) (int, int) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", "20"))
 
@@ -231,24 +361,13 @@ func (h *Handler) GetUserURLs(c *gin.Context) {
 		pageSize = 20
 	}
 
-	// Get URLs for user (this would need to be added to the service interface)
-	c.JSON(http.StatusNotImplemented, models.ErrorResponse{
-		Error:   "not_implemented",
-		Message: "User URL listing not yet implemented",
-	})
-	return
+	return page, pageSize
 }
 
 // CleanupExpired handles POST /api/v1/admin/cleanup (admin only)
 func (h *Handler) CleanupExpired(c *gin.Context) {
-	// TODO: Add admin authentication
-	// For now, allow the operation (in production, verify admin privileges)
-
 	if err := h.service.CleanupExpiredURLs(c.Request.Context()); err != nil {
-		c.JSON(http.StatusInternalServerError, models.ErrorResponse{
-			Error:   "cleanup_failed",
-			Message: "Failed to cleanup expired URLs: " + err.Error(),
-		})
+		writeProblem(c, http.StatusInternalServerError, "cleanup_failed", "Failed to cleanup expired URLs")
 		return
 	}
 
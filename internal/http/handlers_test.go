@@ -0,0 +1,364 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/cache"
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/repo"
+	"github.com/urlshortener/internal/service"
+)
+
+// fakeRepo stores links in memory. Methods the tests do not use are left to
+// the embedded interface and panic if called.
+type fakeRepo struct {
+	repo.URLRepository
+	urls   map[string]*models.ShortURL
+	tags   map[string]map[string]bool
+	clicks []*models.ClickEvent
+}
+
+func newFakeRepo(urls ...*models.ShortURL) *fakeRepo {
+	r := &fakeRepo{urls: make(map[string]*models.ShortURL), tags: make(map[string]map[string]bool)}
+	for _, url := range urls {
+		r.urls[url.Code] = url
+	}
+	return r
+}
+
+func (r *fakeRepo) CreateURL(ctx context.Context, url *models.ShortURL, tags []string) error {
+	r.urls[url.Code] = url
+	return nil
+}
+
+func (r *fakeRepo) GetURLByCode(ctx context.Context, code string) (*models.ShortURL, error) {
+	url, ok := r.urls[code]
+	if !ok {
+		return nil, repo.ErrURLNotFound
+	}
+	if url.IsDeleted {
+		return nil, errors.ErrURLDeleted
+	}
+	return url, nil
+}
+
+func (r *fakeRepo) GetURLMetadata(ctx context.Context, code string) (*models.URLMetadata, error) {
+	url, err := r.GetURLByCode(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+	return &models.URLMetadata{
+		Code:             url.Code,
+		LongURL:          url.LongURL,
+		ShowInterstitial: url.ShowInterstitial,
+		OGTitle:          url.OGTitle,
+		OGDescription:    url.OGDescription,
+		OGImage:          url.OGImage,
+		Status:           url.Status,
+	}, nil
+}
+
+func (r *fakeRepo) AddTags(ctx context.Context, code string, tags []string, maxTags int) error {
+	if _, err := r.GetURLByCode(ctx, code); err != nil {
+		return err
+	}
+	merged := make(map[string]bool)
+	for tag := range r.tags[code] {
+		merged[tag] = true
+	}
+	for _, tag := range tags {
+		merged[tag] = true
+	}
+	if len(merged) > maxTags {
+		return repo.ErrTooManyTags
+	}
+	r.tags[code] = merged
+	return nil
+}
+
+func (r *fakeRepo) RecordClick(ctx context.Context, event *models.ClickEvent) error {
+	r.clicks = append(r.clicks, event)
+	return nil
+}
+
+// fakeCache is a cache that never holds anything
+type fakeCache struct {
+	cache.Cache
+}
+
+func (fakeCache) Get(ctx context.Context, code string) (*models.ShortURL, error) {
+	return nil, cache.ErrCacheMiss
+}
+
+func (fakeCache) Set(ctx context.Context, code string, url *models.ShortURL) error { return nil }
+
+func (fakeCache) SetNegative(ctx context.Context, code string) error { return nil }
+
+// staticResolver resolves every host name to one address
+type staticResolver string
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: net.ParseIP(string(r))}}, nil
+}
+
+// newTestRouter serves the handlers used by the tests on top of a fake
+// repository
+func newTestRouter(r *fakeRepo, opts ...service.Option) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	opts = append([]service.Option{service.WithResolver(staticResolver("93.184.216.34"))}, opts...)
+	svc := service.NewShortenerService(r, fakeCache{}, service.Config{
+		BaseURL:      "https://sho.rt",
+		CodeLength:   7,
+		MaxURLLength: 2048,
+	}, opts...)
+	handler := NewHandler(svc, "https://sho.rt")
+
+	router := gin.New()
+	router.POST("/api/v1/shorten", handler.CreateShortURL)
+	router.GET("/api/v1/urls/:code", handler.GetURLMetadata)
+	router.POST("/api/v1/urls/:code/tags", handler.AddTags)
+	router.GET("/:code", handler.RedirectToLongURL)
+	return router
+}
+
+// serve sends a request to the router and returns the response
+func serve(router http.Handler, method, target, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// decodeProblem checks that a response is a problem document and decodes it
+func decodeProblem(t *testing.T, w *httptest.ResponseRecorder) models.Problem {
+	t.Helper()
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, models.ProblemContentType) {
+		t.Fatalf("expected %s, got %q", models.ProblemContentType, ct)
+	}
+	var problem models.Problem
+	if err := json.Unmarshal(w.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("failed to decode problem: %v", err)
+	}
+	if problem.Status != w.Code {
+		t.Errorf("expected status %d in the body, got %d", w.Code, problem.Status)
+	}
+	return problem
+}
+
+func TestWriteErrorMapsKindsToStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{"not found", errors.NotFound("thing_not_found", "Thing not found"), http.StatusNotFound, "thing_not_found", "Thing not found"},
+		{"expired", errors.ErrURLExpired, http.StatusGone, "url_expired", "URL has expired"},
+		{"deleted", errors.ErrURLDeleted, http.StatusGone, "url_deleted", "URL is deleted"},
+		{"conflict", errors.Conflict("code_taken", "Code taken"), http.StatusConflict, "code_taken", "Code taken"},
+		{"validation", errors.Validation("bad_input", "Bad input"), http.StatusBadRequest, "bad_input", "Bad input"},
+		{"forbidden", errors.Forbidden("not_owner", "Not yours"), http.StatusForbidden, "not_owner", "Not yours"},
+		// Context wrapped around a domain error is not reported
+		{"wrapped", fmt.Errorf("failed to load link from 10.0.0.5: %w", errors.ErrURLNotFound), http.StatusNotFound, "url_not_found", "URL not found"},
+		{"internal", fmt.Errorf("failed to query postgres at 10.0.0.5: timeout"), http.StatusInternalServerError, "internal_error", "An internal error occurred"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/things/1", nil)
+
+			writeError(c, tt.err)
+
+			if w.Code != tt.status {
+				t.Errorf("expected status %d, got %d", tt.status, w.Code)
+			}
+			problem := decodeProblem(t, w)
+			if problem.Code != tt.code || problem.Detail != tt.detail || problem.Instance != "/api/v1/things/1" {
+				t.Errorf("unexpected problem %+v", problem)
+			}
+			if strings.Contains(w.Body.String(), "10.0.0.5") {
+				t.Errorf("expected internal context to stay private, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateShortURLProblems(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   string
+		params []models.InvalidParam
+	}{
+		{"malformed body", `{"url":`, http.StatusBadRequest, "invalid_request", nil},
+		{"missing url", `{}`, http.StatusBadRequest, "invalid_request", []models.InvalidParam{{Name: "url", Reason: "failed the required check"}}},
+		{"unsupported scheme", `{"url":"ftp://example.com/file"}`, http.StatusBadRequest, "invalid_url", []models.InvalidParam{{Name: "url", Reason: "must use http or https"}}},
+		{"private address", `{"url":"http://10.0.0.1/admin"}`, http.StatusBadRequest, "url_private_address", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(newTestRouter(newFakeRepo()), http.MethodPost, "/api/v1/shorten", tt.body, nil)
+			if w.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			problem := decodeProblem(t, w)
+			if problem.Code != tt.code {
+				t.Errorf("expected code %s, got %s", tt.code, problem.Code)
+			}
+			if tt.params != nil && fmt.Sprint(problem.InvalidParams) != fmt.Sprint(tt.params) {
+				t.Errorf("expected invalid params %v, got %v", tt.params, problem.InvalidParams)
+			}
+		})
+	}
+
+	// Hosts resolving to private addresses are rejected without naming the address
+	router := newTestRouter(newFakeRepo(), service.WithResolver(staticResolver("10.20.30.40")))
+	w := serve(router, http.MethodPost, "/api/v1/shorten", `{"url":"https://intranet.example/"}`, nil)
+	if problem := decodeProblem(t, w); w.Code != http.StatusBadRequest || problem.Code != "url_private_address" {
+		t.Errorf("expected url_private_address, got %d %+v", w.Code, problem)
+	}
+	if strings.Contains(w.Body.String(), "10.20.30.40") {
+		t.Errorf("expected the resolved address to stay private, got %s", w.Body.String())
+	}
+
+	w = serve(newTestRouter(newFakeRepo()), http.MethodPost, "/api/v1/shorten", `{"url":"https://example.com/page"}`, nil)
+	if w.Code != http.StatusCreated {
+		t.Errorf("expected the link to be created, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestLinkLookupProblems(t *testing.T) {
+	router := newTestRouter(newFakeRepo(
+		&models.ShortURL{Code: "gone", LongURL: "https://example.com/", IsDeleted: true},
+	))
+
+	for _, target := range []string{"/missing", "/api/v1/urls/missing"} {
+		w := serve(router, http.MethodGet, target, "", nil)
+		if problem := decodeProblem(t, w); w.Code != http.StatusNotFound || problem.Code != "url_not_found" {
+			t.Errorf("%s: expected url_not_found, got %d %+v", target, w.Code, problem)
+		}
+	}
+
+	w := serve(router, http.MethodGet, "/gone", "", nil)
+	if problem := decodeProblem(t, w); w.Code != http.StatusGone || problem.Code != "url_deleted" {
+		t.Errorf("expected url_deleted, got %d %+v", w.Code, problem)
+	}
+}
+
+func TestRedirectIsNotCached(t *testing.T) {
+	r := newFakeRepo(&models.ShortURL{Code: "abc123", LongURL: "https://example.com/page", Status: models.LinkActive})
+	router := newTestRouter(r)
+
+	w := serve(router, http.MethodGet, "/abc123", "", nil)
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/page" {
+		t.Fatalf("expected a temporary redirect, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != "private, no-cache" {
+		t.Errorf("expected the redirect not to be cached, got %q", cc)
+	}
+	if len(r.clicks) != 1 {
+		t.Errorf("expected the click to be recorded, got %d", len(r.clicks))
+	}
+}
+
+func TestInterstitialViewsAreNotCounted(t *testing.T) {
+	r := newFakeRepo(
+		&models.ShortURL{Code: "warned", LongURL: "https://example.com/page", Status: models.LinkActive, ShowInterstitial: true},
+		&models.ShortURL{Code: "reported", LongURL: "https://example.com/page", Status: models.LinkUnderReview},
+		&models.ShortURL{Code: "gone", LongURL: "https://example.com/page", Status: models.LinkSuspended},
+	)
+	router := newTestRouter(r)
+
+	for _, code := range []string{"warned", "reported", "gone"} {
+		if w := serve(router, http.MethodGet, "/"+code, "", nil); w.Code == http.StatusFound {
+			t.Errorf("%s: expected no redirect, got %d", code, w.Code)
+		}
+	}
+	if len(r.clicks) != 0 {
+		t.Errorf("expected views that stop short of the destination not to be counted, got %d", len(r.clicks))
+	}
+}
+
+func TestOpenGraphCardForFlaggedLinks(t *testing.T) {
+	title := "Claim your prize"
+	r := newFakeRepo(
+		&models.ShortURL{Code: "ok", LongURL: "https://example.com/page", Status: models.LinkActive, OGTitle: &title},
+		&models.ShortURL{Code: "warned", LongURL: "https://example.com/page", Status: models.LinkActive, ShowInterstitial: true, OGTitle: &title},
+		&models.ShortURL{Code: "reported", LongURL: "https://example.com/page", Status: models.LinkUnderReview, OGTitle: &title},
+	)
+	router := newTestRouter(r)
+	crawler := http.Header{"User-Agent": {"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)"}}
+
+	w := serve(router, http.MethodGet, "/ok", "", crawler)
+	if !strings.Contains(w.Body.String(), `http-equiv="refresh"`) || !strings.Contains(w.Body.String(), title) {
+		t.Errorf("expected a card redirecting to the destination, got %s", w.Body.String())
+	}
+
+	for _, code := range []string{"warned", "reported"} {
+		w := serve(router, http.MethodGet, "/"+code, "", crawler)
+		body := w.Body.String()
+		if w.Code != http.StatusOK || strings.Contains(body, "refresh") || strings.Contains(body, "example.com") || strings.Contains(body, title) {
+			t.Errorf("%s: expected a card without the destination, got %s", code, body)
+		}
+		if !strings.Contains(body, "https://sho.rt/"+code+"?preview=1") {
+			t.Errorf("%s: expected the card to link to the preview page, got %s", code, body)
+		}
+		if cc := w.Header().Get("Cache-Control"); cc != "no-store" {
+			t.Errorf("%s: expected the card not to be cached, got %q", code, cc)
+		}
+	}
+	if len(r.clicks) != 0 {
+		t.Errorf("expected crawler hits not to be counted, got %d", len(r.clicks))
+	}
+}
+
+func TestAddTagsLimitsTagsPerURL(t *testing.T) {
+	r := newFakeRepo(&models.ShortURL{Code: "abc123", LongURL: "https://example.com/", Status: models.LinkActive})
+	router := newTestRouter(r)
+
+	tags := func(from, to int) string {
+		names := make([]string, 0, to-from)
+		for i := from; i < to; i++ {
+			names = append(names, fmt.Sprintf("%q", fmt.Sprintf("tag-%d", i)))
+		}
+		return `{"tags":[` + strings.Join(names, ",") + `]}`
+	}
+
+	for _, body := range []string{tags(0, 10), tags(10, 20), tags(5, 15)} {
+		if w := serve(router, http.MethodPost, "/api/v1/urls/abc123/tags", body, nil); w.Code != http.StatusOK {
+			t.Fatalf("expected tags up to the limit to be added, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	// Each request is within the limit, but the URL would exceed it
+	w := serve(router, http.MethodPost, "/api/v1/urls/abc123/tags", tags(20, 21), nil)
+	if problem := decodeProblem(t, w); w.Code != http.StatusBadRequest || problem.Code != "too_many_tags" || len(problem.InvalidParams) != 1 {
+		t.Errorf("expected too_many_tags, got %d %+v", w.Code, problem)
+	}
+	if len(r.tags["abc123"]) != 20 {
+		t.Errorf("expected 20 tags, got %d", len(r.tags["abc123"]))
+	}
+}
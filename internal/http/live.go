@@ -0,0 +1,38 @@
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/live"
+	"github.com/urlshortener/internal/service"
+)
+
+// StreamLiveClicks handles GET /api/v1/urls/:code/live
+func (h *Handler) StreamLiveClicks(c *gin.Context) {
+	sub, err := h.service.SubscribeLiveClicks(c.Request.Context(), c.Param("code"))
+	if err != nil {
+		switch {
+		case errors.Is(err, live.ErrTooManyLinkStreams):
+			c.Header("Retry-After", "30")
+			writeProblem(c, http.StatusTooManyRequests, "too_many_streams", "Too many live streams are open for this URL")
+		case errors.Is(err, live.ErrTooManyStreams), errors.Is(err, service.ErrLiveUnavailable):
+			c.Header("Retry-After", "30")
+			writeProblem(c, http.StatusServiceUnavailable, "live_unavailable", "Live click streams are currently unavailable")
+		default:
+			writeError(c, err)
+		}
+		return
+	}
+	defer sub.Close()
+
+	// Streams outlast the server's write timeout
+	http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	if err := sub.Serve(c.Request.Context(), c.Writer); err != nil {
+		// The client went away; there is nothing left to send
+		c.Error(err)
+	}
+}
@@ -0,0 +1,100 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/models"
+)
+
+// writeProblem writes a problem response and aborts the request
+func writeProblem(c *gin.Context, status int, code, detail string, params ...models.InvalidParam) {
+	problem := models.NewProblem(status, code, detail)
+	problem.Instance = c.Request.URL.Path
+	problem.InvalidParams = params
+
+	c.Header("Content-Type", models.ProblemContentType)
+	c.AbortWithStatusJSON(status, problem)
+}
+
+// writeError writes the problem response for an error returned by the
+// service. Domain errors keep their code and map to a status by kind; only
+// their message and fields are reported, never the context they were
+// wrapped in. Any other error is reported as an internal error.
+func writeError(c *gin.Context, err error) {
+	var domainErr *errors.Error
+	if !errors.As(err, &domainErr) {
+		writeProblem(c, http.StatusInternalServerError, "internal_error", "An internal error occurred")
+		return
+	}
+
+	var params []models.InvalidParam
+	for _, field := range domainErr.Fields {
+		params = append(params, models.InvalidParam{Name: field.Field, Reason: field.Reason})
+	}
+
+	writeProblem(c, problemStatus(domainErr), domainErr.Code, domainErr.Message, params...)
+}
+
+// problemStatus returns the HTTP status for a domain error's kind
+func problemStatus(err *errors.Error) int {
+	switch err.Kind() {
+	case errors.ErrNotFound:
+		return http.StatusNotFound
+	case errors.ErrExpired, errors.ErrDeleted:
+		return http.StatusGone
+	case errors.ErrConflict:
+		return http.StatusConflict
+	case errors.ErrValidation:
+		return http.StatusBadRequest
+	case errors.ErrForbidden:
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// writeBindError reports a request body or query string that could not be
+// bound, naming the fields that failed validation
+func writeBindError(c *gin.Context, code string, err error) {
+	var validationErrs validator.ValidationErrors
+	if !errors.As(err, &validationErrs) {
+		writeProblem(c, http.StatusBadRequest, code, "Invalid request: "+err.Error())
+		return
+	}
+
+	params := make([]models.InvalidParam, 0, len(validationErrs))
+	for _, fieldErr := range validationErrs {
+		reason := "failed the " + fieldErr.Tag() + " check"
+		if fieldErr.Param() != "" {
+			reason += " (" + fieldErr.Param() + ")"
+		}
+		params = append(params, models.InvalidParam{Name: fieldName(fieldErr), Reason: reason})
+	}
+
+	writeProblem(c, http.StatusBadRequest, code, "Invalid request: some fields failed validation", params...)
+}
+
+// fieldName returns the snake_case name of a field that failed validation,
+// matching its JSON and query names: CampaignID becomes campaign_id
+func fieldName(fieldErr validator.FieldError) string {
+	name := []rune(fieldErr.Field())
+
+	var b strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			// Start a word after a lowercase letter or digit, and at the
+			// last capital of an acronym that is followed by a word
+			if i > 0 && (!unicode.IsUpper(name[i-1]) || i+1 < len(name) && unicode.IsLower(name[i+1])) {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
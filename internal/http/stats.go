@@ -0,0 +1,27 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/models"
+)
+
+// GetClickStats handles GET /api/v1/urls/:code/stats
+func (h *Handler) GetClickStats(c *gin.Context) {
+	code := c.Param("code")
+
+	var query models.StatsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		writeBindError(c, "invalid_stats_query", err)
+		return
+	}
+
+	stats, err := h.service.GetClickStats(c.Request.Context(), code, query)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
@@ -0,0 +1,121 @@
+package http
+
+import (
+	"embed"
+	"html/template"
+	"net/url"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+//go:embed templates/*.html
+var templateFS embed.FS
+
+// pageTemplates holds the HTML pages served outside the JSON API
+var pageTemplates = template.Must(template.ParseFS(templateFS, "templates/*.html"))
+
+// previewPage is the view model for templates/preview.html
+type previewPage struct {
+	Code         string
+	ShortURL     string
+	LongURL      string
+	Host         string
+	Title        string
+	CreatedAt    time.Time
+	Insecure     bool
+	Interstitial bool
+	UnderReview  bool
+}
+
+// suspendedPage is the view model for templates/suspended.html
+type suspendedPage struct {
+	Code     string
+	ShortURL string
+}
+
+// newPreviewPage builds the preview view model for a short URL
+func (h *Handler) newPreviewPage(shortURL *models.ShortURL, interstitial bool) previewPage {
+	page := previewPage{
+		Code:         shortURL.Code,
+		ShortURL:     h.baseURL + "/" + shortURL.Code,
+		LongURL:      shortURL.LongURL,
+		Host:         shortURL.LongURL,
+		CreatedAt:    shortURL.CreatedAt,
+		Interstitial: interstitial,
+		UnderReview:  shortURL.Status == models.LinkUnderReview,
+	}
+
+	if shortURL.Title != nil {
+		page.Title = *shortURL.Title
+	}
+
+	if parsed, err := url.Parse(shortURL.LongURL); err == nil && parsed.Host != "" {
+		page.Host = parsed.Hostname()
+		page.Insecure = parsed.Scheme != "https"
+	}
+
+	return page
+}
+
+// openGraphPage is the view model for templates/opengraph.html
+type openGraphPage struct {
+	ShortURL    string
+	LongURL     string // empty for links that stop at the preview page
+	PreviewURL  string
+	Title       string
+	Description string
+	ImageURL    string
+}
+
+// newOpenGraphPage builds the crawler card for a link. Per-link overrides win,
+// then metadata unfurled from the destination, then the owner's title. Links
+// that stop at the preview page get a bare card pointing at the preview, so a
+// crawler user agent cannot be used to skip it.
+func (h *Handler) newOpenGraphPage(metadata *models.URLMetadata) openGraphPage {
+	page := openGraphPage{
+		ShortURL:   h.baseURL + "/" + metadata.Code,
+		PreviewURL: h.baseURL + "/" + metadata.Code + "?preview=1",
+	}
+	if metadata.ShowInterstitial || metadata.Status == models.LinkUnderReview {
+		page.Title = page.ShortURL
+		return page
+	}
+	page.LongURL = metadata.LongURL
+
+	var preview models.LinkPreview
+	if metadata.Preview != nil {
+		preview = *metadata.Preview
+	}
+
+	page.Title = firstNonEmpty(deref(metadata.OGTitle), preview.Title, deref(metadata.Title))
+	page.Description = firstNonEmpty(deref(metadata.OGDescription), preview.Description)
+	page.ImageURL = firstNonEmpty(deref(metadata.OGImage), preview.ImageURL)
+
+	if page.Title == "" {
+		page.Title = metadata.LongURL
+		if parsed, err := url.Parse(metadata.LongURL); err == nil && parsed.Host != "" {
+			page.Title = parsed.Hostname()
+		}
+	}
+
+	return page
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// deref returns the value of an optional string
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,136 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/models"
+)
+
+// CreateWebhook handles POST /api/v1/webhooks
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	owner, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeBindError(c, "invalid_request", err)
+		return
+	}
+
+	webhook, err := h.service.CreateWebhook(c.Request.Context(), owner, &req)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// GetWebhooks handles GET /api/v1/webhooks
+func (h *Handler) GetWebhooks(c *gin.Context) {
+	owner, ok := requireUser(c)
+	if !ok {
+		return
+	}
+
+	webhooks, err := h.service.GetWebhooks(c.Request.Context(), owner)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"webhooks": webhooks,
+	})
+}
+
+// DeleteWebhook handles DELETE /api/v1/webhooks/:id
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	owner, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteWebhook(c.Request.Context(), owner, id); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries handles GET /api/v1/webhooks/:id/deliveries
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	owner, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+
+	deliveries, err := h.service.GetWebhookDeliveries(c.Request.Context(), owner, id, c.Query("status"), page, pageSize)
+	if err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RetryWebhookDelivery handles POST /api/v1/webhooks/:id/deliveries/:delivery/retry
+func (h *Handler) RetryWebhookDelivery(c *gin.Context) {
+	owner, ok := requireUser(c)
+	if !ok {
+		return
+	}
+	id, ok := parseWebhookID(c, "id")
+	if !ok {
+		return
+	}
+	deliveryID, ok := parseWebhookID(c, "delivery")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RetryWebhookDelivery(c.Request.Context(), owner, id, deliveryID); err != nil {
+		writeError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"id":     deliveryID,
+		"status": models.DeliveryPending,
+	})
+}
+
+// requireUser reads the X-User-ID header, writing a 400 if it is missing
+func requireUser(c *gin.Context) (string, bool) {
+	user := c.GetHeader("X-User-ID")
+	if user == "" {
+		writeProblem(c, http.StatusBadRequest, "invalid_user", "X-User-ID header is required")
+		return "", false
+	}
+	return user, true
+}
+
+// parseWebhookID reads a numeric path parameter, writing a 400 if it is invalid
+func parseWebhookID(c *gin.Context, param string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(param), 10, 64)
+	if err != nil || id < 1 {
+		writeProblem(c, http.StatusBadRequest, "invalid_"+param+"_id", "ID must be a positive integer")
+		return 0, false
+	}
+	return id, true
+}
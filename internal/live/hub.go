@@ -0,0 +1,376 @@
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/urlshortener/internal/models"
+)
+
+// publishBatchSize is the number of clicks published per Redis round trip
+const publishBatchSize = 100
+
+// Config holds live click stream configuration
+type Config struct {
+	ChannelPrefix     string        // Redis channel prefix; the link code is appended
+	PublishBuffer     int           // clicks queued for publishing before new ones are dropped
+	PublishTimeout    time.Duration // time limit for publishing one batch
+	SubscriberBuffer  int           // clicks queued per stream before new ones are dropped
+	MaxStreams        int           // concurrent streams per instance
+	MaxStreamsPerLink int           // concurrent streams per link and instance
+	Heartbeat         time.Duration // interval of keep-alive comments on idle streams
+	MaxDuration       time.Duration // streams are closed after this long; clients reconnect
+	RetryDelay        time.Duration // reconnect delay suggested to clients
+}
+
+// subscriber is the part of a Redis pub/sub connection the hub uses to
+// follow link channels
+type subscriber interface {
+	Subscribe(ctx context.Context, channels ...string) error
+	Unsubscribe(ctx context.Context, channels ...string) error
+}
+
+// channelState tracks a link's channel subscription. Its lock serializes
+// the Redis calls for the link, which are made outside the hub's lock.
+type channelState struct {
+	mu         sync.Mutex
+	subscribed bool
+	syncing    int // calls waiting for or holding mu, guarded by the hub's lock
+}
+
+// published is a click waiting to be published
+type published struct {
+	code    string
+	payload []byte
+}
+
+// Hub fans clicks out to live streams. Clicks are published to one Redis
+// channel per link, so a stream sees clicks recorded by every instance.
+// An instance only subscribes to a link's channel while it has a stream
+// open for that link.
+type Hub struct {
+	client  redis.UniversalClient
+	config  Config
+	onError func(error)
+	queue   chan published
+
+	mu       sync.RWMutex
+	conn     *redis.PubSub
+	pubsub   subscriber
+	streams  map[string]map[*Subscription]struct{}
+	channels map[string]*channelState
+	total    int
+
+	ctx    context.Context // bounds subscription calls; canceled by Stop
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewHub creates a live click hub
+func NewHub(client redis.UniversalClient, config Config, onError func(error)) *Hub {
+	if config.ChannelPrefix == "" {
+		config.ChannelPrefix = "clicks:live:"
+	}
+	if config.PublishBuffer <= 0 {
+		config.PublishBuffer = 4096
+	}
+	if config.PublishTimeout <= 0 {
+		config.PublishTimeout = time.Second
+	}
+	if config.SubscriberBuffer <= 0 {
+		config.SubscriberBuffer = 64
+	}
+	if config.MaxStreams <= 0 {
+		config.MaxStreams = 1000
+	}
+	if config.MaxStreamsPerLink <= 0 {
+		config.MaxStreamsPerLink = 50
+	}
+	if config.Heartbeat <= 0 {
+		config.Heartbeat = 15 * time.Second
+	}
+	if config.MaxDuration <= 0 {
+		config.MaxDuration = time.Hour
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = 3 * time.Second
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	return &Hub{
+		client:   client,
+		config:   config,
+		onError:  onError,
+		queue:    make(chan published, config.PublishBuffer),
+		streams:  make(map[string]map[*Subscription]struct{}),
+		channels: make(map[string]*channelState),
+		ctx:      context.Background(),
+	}
+}
+
+// Start connects to Redis pub/sub and publishes queued clicks in the
+// background until Stop is called
+func (h *Hub) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	h.cancel = cancel
+
+	// The connection is opened lazily and re-subscribes to every followed
+	// channel after a reconnect
+	pubsub := h.client.Subscribe(ctx)
+	h.mu.Lock()
+	h.conn, h.pubsub, h.ctx = pubsub, pubsub, ctx
+	h.mu.Unlock()
+
+	h.wg.Add(2)
+	go h.publishLoop(ctx)
+	go h.receiveLoop(ctx, pubsub)
+}
+
+// Stop ends every open stream and waits for the hub to exit
+func (h *Hub) Stop() {
+	if h.cancel != nil {
+		h.cancel()
+	}
+
+	h.mu.Lock()
+	if h.conn != nil {
+		h.conn.Close()
+	}
+	h.conn, h.pubsub = nil, nil
+	for _, streams := range h.streams {
+		for sub := range streams {
+			close(sub.events)
+		}
+	}
+	h.streams = make(map[string]map[*Subscription]struct{})
+	h.channels = make(map[string]*channelState)
+	h.total = 0
+	h.mu.Unlock()
+
+	h.wg.Wait()
+}
+
+// Publish queues a click for live streams. It never blocks; when the queue
+// is full the click is left out of the live stream.
+func (h *Hub) Publish(event *models.ClickEvent) {
+	payload, err := json.Marshal(NewClick(event))
+	if err != nil {
+		return
+	}
+
+	select {
+	case h.queue <- published{code: event.Code, payload: payload}:
+	default:
+	}
+}
+
+// Subscribe opens a stream of a link's clicks. The subscription must be
+// closed when the client goes away.
+func (h *Hub) Subscribe(ctx context.Context, code string) (*Subscription, error) {
+	h.mu.Lock()
+	if h.total >= h.config.MaxStreams {
+		h.mu.Unlock()
+		return nil, ErrTooManyStreams
+	}
+	streams := h.streams[code]
+	if len(streams) >= h.config.MaxStreamsPerLink {
+		h.mu.Unlock()
+		return nil, ErrTooManyLinkStreams
+	}
+
+	first := streams == nil
+	if first {
+		streams = make(map[*Subscription]struct{})
+		h.streams[code] = streams
+	}
+
+	sub := &Subscription{
+		hub:    h,
+		code:   code,
+		events: make(chan []byte, h.config.SubscriberBuffer),
+	}
+	streams[sub] = struct{}{}
+	h.total++
+	h.mu.Unlock()
+
+	if first {
+		h.syncChannel(code)
+	}
+	return sub, nil
+}
+
+// unsubscribe removes a stream, leaving the link's channel once its last
+// stream is gone
+func (h *Hub) unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	streams := h.streams[sub.code]
+	if _, ok := streams[sub]; !ok {
+		h.mu.Unlock()
+		return
+	}
+	delete(streams, sub)
+	h.total--
+
+	last := len(streams) == 0
+	if last {
+		delete(h.streams, sub.code)
+	}
+	h.mu.Unlock()
+
+	if last {
+		h.syncChannel(sub.code)
+	}
+}
+
+// syncChannel subscribes to or leaves a link's channel so that it is
+// followed exactly while the link has streams. Redis is called outside the
+// hub's lock, so a slow Redis does not hold up dispatch or other links.
+// Calls for one link are serialized and each acts on the latest state, so
+// a subscribe and unsubscribe that race still leave the channel right.
+func (h *Hub) syncChannel(code string) {
+	h.mu.Lock()
+	state := h.channels[code]
+	if state == nil {
+		state = &channelState{}
+		h.channels[code] = state
+	}
+	state.syncing++
+	h.mu.Unlock()
+
+	state.mu.Lock()
+	h.mu.RLock()
+	_, wanted := h.streams[code]
+	pubsub, ctx := h.pubsub, h.ctx
+	h.mu.RUnlock()
+
+	if pubsub != nil && wanted != state.subscribed {
+		// Either way the connection's channel set is updated, so a failed
+		// subscribe is retried when the connection is re-established
+		if wanted {
+			if err := pubsub.Subscribe(ctx, h.channel(code)); err != nil {
+				h.onError(fmt.Errorf("failed to subscribe to live clicks: %w", err))
+			}
+		} else if err := pubsub.Unsubscribe(ctx, h.channel(code)); err != nil {
+			h.onError(fmt.Errorf("failed to unsubscribe from live clicks: %w", err))
+		}
+		state.subscribed = wanted
+	}
+	state.mu.Unlock()
+
+	h.mu.Lock()
+	state.syncing--
+	if state.syncing == 0 && !state.subscribed && h.channels[code] == state {
+		delete(h.channels, code)
+	}
+	h.mu.Unlock()
+}
+
+// dispatch delivers a published click to the link's local streams. Streams
+// that fall behind skip clicks rather than slowing down the others.
+func (h *Hub) dispatch(code string, payload []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for sub := range h.streams[code] {
+		select {
+		case sub.events <- payload:
+		default:
+			atomic.AddInt64(&sub.dropped, 1)
+		}
+	}
+}
+
+// publishLoop publishes queued clicks in pipelined batches
+func (h *Hub) publishLoop(ctx context.Context) {
+	defer h.wg.Done()
+
+	batch := make([]published, 0, publishBatchSize)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-h.queue:
+			batch = append(batch[:0], p)
+		}
+
+	fill:
+		for len(batch) < publishBatchSize {
+			select {
+			case p := <-h.queue:
+				batch = append(batch, p)
+			default:
+				break fill
+			}
+		}
+
+		publishCtx, cancel := context.WithTimeout(ctx, h.config.PublishTimeout)
+		_, err := h.client.Pipelined(publishCtx, func(pipe redis.Pipeliner) error {
+			for _, p := range batch {
+				pipe.Publish(publishCtx, h.channel(p.code), p.payload)
+			}
+			return nil
+		})
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			h.onError(fmt.Errorf("failed to publish live clicks: %w", err))
+		}
+	}
+}
+
+// receiveLoop hands clicks received from Redis to dispatch
+func (h *Hub) receiveLoop(ctx context.Context, pubsub *redis.PubSub) {
+	defer h.wg.Done()
+
+	messages := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			h.dispatch(strings.TrimPrefix(msg.Channel, h.config.ChannelPrefix), []byte(msg.Payload))
+		}
+	}
+}
+
+// channel returns the Redis channel of a link
+func (h *Hub) channel(code string) string {
+	return h.config.ChannelPrefix + code
+}
+
+// NewClick reduces a click event to the fields shown on live dashboards
+func NewClick(event *models.ClickEvent) models.LiveClick {
+	click := models.LiveClick{
+		Timestamp: event.Timestamp.UTC(),
+		IsBot:     event.IsBot,
+	}
+	if event.Country != nil {
+		click.Country = *event.Country
+	}
+	if event.DeviceType != nil {
+		click.Device = *event.DeviceType
+	}
+	if event.Referer != nil {
+		if u, err := url.Parse(*event.Referer); err == nil {
+			click.RefererHost = strings.ToLower(u.Hostname())
+		}
+	}
+	return click
+}
+
+// Custom errors
+var (
+	ErrTooManyStreams     = fmt.Errorf("too many live streams")
+	ErrTooManyLinkStreams = fmt.Errorf("too many live streams for this link")
+)
@@ -0,0 +1,214 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+// fakeSubscriber records the channels the hub follows
+type fakeSubscriber struct {
+	channels map[string]bool
+	calls    int
+}
+
+func (f *fakeSubscriber) Subscribe(ctx context.Context, channels ...string) error {
+	f.calls++
+	for _, channel := range channels {
+		f.channels[channel] = true
+	}
+	return nil
+}
+
+func (f *fakeSubscriber) Unsubscribe(ctx context.Context, channels ...string) error {
+	f.calls++
+	for _, channel := range channels {
+		delete(f.channels, channel)
+	}
+	return nil
+}
+
+func newTestHub(config Config) (*Hub, *fakeSubscriber) {
+	pubsub := &fakeSubscriber{channels: make(map[string]bool)}
+	hub := NewHub(nil, config, nil)
+	hub.pubsub = pubsub
+	return hub, pubsub
+}
+
+func TestSubscribeFollowsLinkChannels(t *testing.T) {
+	hub, pubsub := newTestHub(Config{})
+	ctx := context.Background()
+
+	first, _ := hub.Subscribe(ctx, "abc")
+	second, _ := hub.Subscribe(ctx, "abc")
+	other, _ := hub.Subscribe(ctx, "xyz")
+
+	if !pubsub.channels["clicks:live:abc"] || !pubsub.channels["clicks:live:xyz"] || pubsub.calls != 2 {
+		t.Fatalf("expected one subscribe per link, got %v after %d calls", pubsub.channels, pubsub.calls)
+	}
+
+	first.Close()
+	first.Close()
+	if !pubsub.channels["clicks:live:abc"] {
+		t.Error("expected the channel to stay subscribed while a stream is open")
+	}
+	second.Close()
+	if pubsub.channels["clicks:live:abc"] {
+		t.Error("expected the channel to be left after the last stream closed")
+	}
+	other.Close()
+	if hub.total != 0 || len(hub.streams) != 0 {
+		t.Errorf("expected no streams, got %d", hub.total)
+	}
+}
+
+func TestSubscribeLimits(t *testing.T) {
+	hub, _ := newTestHub(Config{MaxStreams: 3, MaxStreamsPerLink: 2})
+	ctx := context.Background()
+
+	a1, _ := hub.Subscribe(ctx, "a")
+	hub.Subscribe(ctx, "a")
+	if _, err := hub.Subscribe(ctx, "a"); !errors.Is(err, ErrTooManyLinkStreams) {
+		t.Errorf("expected ErrTooManyLinkStreams, got %v", err)
+	}
+
+	hub.Subscribe(ctx, "b")
+	if _, err := hub.Subscribe(ctx, "c"); !errors.Is(err, ErrTooManyStreams) {
+		t.Errorf("expected ErrTooManyStreams, got %v", err)
+	}
+
+	// Closing a stream frees its slot
+	a1.Close()
+	if _, err := hub.Subscribe(ctx, "c"); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+// slowSubscriber blocks subscribe calls until released
+type slowSubscriber struct {
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (s *slowSubscriber) Subscribe(ctx context.Context, channels ...string) error {
+	s.entered <- struct{}{}
+	<-s.release
+	return nil
+}
+
+func (s *slowSubscriber) Unsubscribe(ctx context.Context, channels ...string) error {
+	return nil
+}
+
+func TestSlowSubscribeDoesNotBlockDispatch(t *testing.T) {
+	hub := NewHub(nil, Config{}, nil)
+	ctx := context.Background()
+	open, _ := hub.Subscribe(ctx, "abc")
+
+	// Another link's first stream waits for Redis to confirm its subscribe
+	pubsub := &slowSubscriber{entered: make(chan struct{}), release: make(chan struct{})}
+	hub.pubsub = pubsub
+	go hub.Subscribe(ctx, "xyz")
+	<-pubsub.entered
+
+	done := make(chan struct{})
+	go func() {
+		hub.dispatch("abc", []byte("click"))
+		hub.Subscribe(ctx, "abc")
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected dispatch and other streams not to wait for the subscribe")
+	}
+	close(pubsub.release)
+
+	if got := string(<-open.events); got != "click" {
+		t.Errorf("expected the click to be dispatched, got %q", got)
+	}
+}
+
+func TestServeStreamsClicks(t *testing.T) {
+	hub, _ := newTestHub(Config{SubscriberBuffer: 2, Heartbeat: 10 * time.Millisecond, MaxDuration: 200 * time.Millisecond})
+	sub, _ := hub.Subscribe(context.Background(), "abc")
+	defer sub.Close()
+
+	// The third click overflows the buffer and is reported as lag
+	hub.dispatch("abc", []byte(`{"n":1}`))
+	hub.dispatch("abc", []byte(`{"n":2}`))
+	hub.dispatch("abc", []byte(`{"n":3}`))
+	hub.dispatch("other", []byte(`{"n":4}`))
+
+	rec := httptest.NewRecorder()
+	if err := sub.Serve(context.Background(), rec); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	body := rec.Body.String()
+	expected := "retry: 3000\n\n" +
+		"event: lag\ndata: {\"dropped\":1}\n\n" +
+		"event: click\ndata: {\"n\":1}\n\n" +
+		"event: click\ndata: {\"n\":2}\n\n"
+	if !strings.HasPrefix(body, expected) {
+		t.Errorf("unexpected stream start:\n%s", body)
+	}
+	if !strings.Contains(body, ": ping\n\n") {
+		t.Error("expected heartbeats while the stream is idle")
+	}
+	if strings.Contains(body, `"n":4`) {
+		t.Error("received a click for another link")
+	}
+}
+
+func TestServeEndsWhenHubStops(t *testing.T) {
+	hub, _ := newTestHub(Config{})
+	sub, _ := hub.Subscribe(context.Background(), "abc")
+
+	done := make(chan error)
+	go func() {
+		done <- sub.Serve(context.Background(), httptest.NewRecorder())
+	}()
+
+	hub.Stop()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("stream did not end after the hub stopped")
+	}
+	sub.Close()
+}
+
+func TestNewClick(t *testing.T) {
+	country, device, referer := "DE", "mobile", "https://News.Example.com:8443/story?id=1"
+	click := NewClick(&models.ClickEvent{
+		Code:       "abc",
+		Timestamp:  time.Date(2024, 5, 1, 14, 0, 0, 0, time.FixedZone("CEST", 2*3600)),
+		Country:    &country,
+		DeviceType: &device,
+		Referer:    &referer,
+	})
+
+	if !click.Timestamp.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) || click.Timestamp.Location() != time.UTC {
+		t.Errorf("expected a UTC timestamp, got %v", click.Timestamp)
+	}
+	if click.Country != "DE" || click.Device != "mobile" || click.RefererHost != "news.example.com" {
+		t.Errorf("unexpected click %+v", click)
+	}
+
+	if click := NewClick(&models.ClickEvent{Code: "abc", IsBot: true}); click.RefererHost != "" || !click.IsBot {
+		t.Errorf("unexpected click %+v", click)
+	}
+}
@@ -0,0 +1,89 @@
+package live
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Subscription is one open stream of a link's clicks
+type Subscription struct {
+	hub     *Hub
+	code    string
+	events  chan []byte
+	dropped int64
+	once    sync.Once
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.unsubscribe(s)
+	})
+}
+
+// Serve writes the subscription's clicks to w as Server-Sent Events until
+// ctx is done, the hub stops or the stream reaches its maximum duration.
+// Idle streams get a comment line every heartbeat interval, which keeps
+// proxies from timing out the connection and detects clients that left.
+// When the stream fell behind and skipped clicks, a "lag" event with the
+// number skipped precedes the next click.
+func (s *Subscription) Serve(ctx context.Context, w http.ResponseWriter) error {
+	config := s.hub.config
+	flusher, _ := w.(http.Flusher)
+
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Disable response buffering in nginx
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	write := func(format string, args ...interface{}) error {
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	if err := write("retry: %d\n\n", config.RetryDelay.Milliseconds()); err != nil {
+		return err
+	}
+
+	heartbeat := time.NewTicker(config.Heartbeat)
+	defer heartbeat.Stop()
+	deadline := time.NewTimer(config.MaxDuration)
+	defer deadline.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-deadline.C:
+			return nil
+		case <-heartbeat.C:
+			if err := write(": ping\n\n"); err != nil {
+				return err
+			}
+		case payload, ok := <-s.events:
+			if !ok {
+				return nil
+			}
+			if dropped := atomic.SwapInt64(&s.dropped, 0); dropped > 0 {
+				if err := write("event: lag\ndata: {\"dropped\":%d}\n\n", dropped); err != nil {
+					return err
+				}
+			}
+			if err := write("event: click\ndata: %s\n\n", payload); err != nil {
+				return err
+			}
+		}
+	}
+}
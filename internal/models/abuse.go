@@ -0,0 +1,64 @@
+package models
+
+import (
+	"time"
+)
+
+// Link statuses
+const (
+	LinkActive      = "active"       // redirected normally
+	LinkUnderReview = "under_review" // redirected through a warning page
+	LinkSuspended   = "suspended"    // not redirected
+)
+
+// Abuse report reasons
+const (
+	ReportPhishing = "phishing"
+	ReportMalware  = "malware"
+	ReportSpam     = "spam"
+	ReportOther    = "other"
+)
+
+// ReportReasons lists the reasons a link can be reported for
+var ReportReasons = []string{ReportPhishing, ReportMalware, ReportSpam, ReportOther}
+
+// LinkReport is an abuse report made by a visitor
+type LinkReport struct {
+	ID        int64     `json:"id" db:"id"`
+	Code      string    `json:"code" db:"code"`
+	Reason    string    `json:"reason" db:"reason"`
+	Details   *string   `json:"details,omitempty" db:"details"`
+	Reporter  string    `json:"-" db:"reporter"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// ReportURLRequest represents the request to report a link
+type ReportURLRequest struct {
+	Reason  string  `json:"reason" binding:"required"`
+	Details *string `json:"details,omitempty" binding:"omitempty,max=2000"`
+}
+
+// ReportURLResponse acknowledges a report without revealing its effect
+type ReportURLResponse struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// LinkStatusRequest represents a moderator's request to change a link's status
+type LinkStatusRequest struct {
+	Reason string `json:"reason" binding:"max=1000"`
+}
+
+// LinkModeration is a link's moderation state together with its reports
+type LinkModeration struct {
+	Code            string       `json:"code"`
+	Status          string       `json:"status"`
+	StatusReason    *string      `json:"status_reason,omitempty"`
+	StatusChangedAt *time.Time   `json:"status_changed_at,omitempty"`
+	ReviewedAt      *time.Time   `json:"reviewed_at,omitempty"`
+	PendingReports  int64        `json:"pending_reports"`
+	Reports         []LinkReport `json:"reports"`
+	Total           int64        `json:"total"`
+	Page            int          `json:"page"`
+	PageSize        int          `json:"page_size"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+// Campaign groups links belonging to one owner
+type Campaign struct {
+	ID          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	CreatedBy   string    `json:"created_by" db:"created_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateCampaignRequest represents the request to create a campaign
+type CreateCampaignRequest struct {
+	Name        string  `json:"name" binding:"required,max=255"`
+	Description *string `json:"description,omitempty"`
+}
+
+// CampaignStats aggregates click statistics over a campaign's links
+type CampaignStats struct {
+	CampaignID   int64        `json:"campaign_id"`
+	Name         string       `json:"name"`
+	TotalLinks   int64        `json:"total_links"`
+	TotalClicks  int64        `json:"total_clicks"`
+	LastAccessAt *time.Time   `json:"last_access_at,omitempty"`
+	TopLinks     []LinkClicks `json:"top_links"`
+}
+
+// LinkClicks is the click total of a single link
+type LinkClicks struct {
+	Code        string `json:"code"`
+	TotalClicks int64  `json:"total_clicks"`
+}
+
+// TagsRequest represents the request to attach tags to a link
+type TagsRequest struct {
+	Tags []string `json:"tags" binding:"required,min=1,max=20"`
+}
+
+// SetCampaignRequest assigns a link to a campaign, or removes it when null
+type SetCampaignRequest struct {
+	CampaignID *int64 `json:"campaign_id"`
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+)
+
+// StatsQuery represents the query parameters of the click statistics endpoint
+type StatsQuery struct {
+	From     string `form:"from"`     // RFC 3339 timestamp or YYYY-MM-DD date
+	To       string `form:"to"`       // RFC 3339 timestamp or YYYY-MM-DD date, exclusive
+	Interval string `form:"interval"` // hour, day or week
+	Timezone string `form:"tz"`       // IANA time zone name
+	Top      int    `form:"top"`      // number of entries in each breakdown
+}
+
+// ExportQuery represents the query parameters of the click export endpoints
+type ExportQuery struct {
+	From   string `form:"from"`   // RFC 3339 timestamp or YYYY-MM-DD date
+	To     string `form:"to"`     // RFC 3339 timestamp or YYYY-MM-DD date, exclusive
+	Format string `form:"format"` // csv, ndjson or parquet
+}
+
+// ClickExportFilter selects the click events to export: those of one link,
+// or of every link created by a user
+type ClickExportFilter struct {
+	Code string
+	User string
+	From time.Time
+	To   time.Time
+}
+
+// LiveClick is a click as streamed to live dashboards
+type LiveClick struct {
+	Timestamp   time.Time `json:"ts"`
+	Country     string    `json:"country,omitempty"`
+	Device      string    `json:"device,omitempty"`
+	RefererHost string    `json:"referer_host,omitempty"`
+	IsBot       bool      `json:"is_bot"`
+}
+
+// ClickStats is a time series of clicks with breakdowns for a short URL.
+// UniqueVisitors is the sum of the daily unique visitors in the range.
+type ClickStats struct {
+	Code           string           `json:"code"`
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	Interval       string           `json:"interval"`
+	Timezone       string           `json:"timezone"`
+	TotalClicks    int64            `json:"total_clicks"`
+	BotClicks      int64            `json:"bot_clicks"`
+	UniqueVisitors int64            `json:"unique_visitors"`
+	Series         []ClickBucket    `json:"series"`
+	TopReferrers   []DimensionCount `json:"top_referrers"`
+	TopCountries   []DimensionCount `json:"top_countries"`
+	TopDevices     []DimensionCount `json:"top_devices"`
+	TopBrowsers    []DimensionCount `json:"top_browsers"`
+}
+
+// ClickBucket is the number of clicks in one interval starting at Start.
+// Unique visitors are only counted for day and week buckets.
+type ClickBucket struct {
+	Start          time.Time `json:"start"`
+	Clicks         int64     `json:"clicks"`
+	BotClicks      int64     `json:"bot_clicks"`
+	UniqueVisitors *int64    `json:"unique_visitors,omitempty"`
+}
+
+// DailyVisitors is the estimated number of unique visitors to a URL on a UTC day
+type DailyVisitors struct {
+	Day      time.Time `json:"day"`
+	Visitors int64     `json:"visitors"`
+}
+
+// DimensionCount is the number of clicks sharing one value of a dimension
+type DimensionCount struct {
+	Value  string `json:"value"`
+	Clicks int64  `json:"clicks"`
+}
@@ -6,56 +6,103 @@ import (
 
 // ShortURL represents a shortened URL in the database
 type ShortURL struct {
-	ID          int64      `json:"id" db:"id"`
-	Code        string     `json:"code" db:"code"`
-	LongURL     string     `json:"long_url" db:"long_url"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
-	ExpireAt    *time.Time `json:"expire_at,omitempty" db:"expire_at"`
-	IsDeleted   bool       `json:"is_deleted" db:"is_deleted"`
-	CustomAlias bool       `json:"custom_alias" db:"custom_alias"`
-	CreatedBy   *string    `json:"created_by,omitempty" db:"created_by"`
-	Metadata    *string    `json:"metadata,omitempty" db:"metadata"`
+	ID               int64      `json:"id" db:"id"`
+	Code             string     `json:"code" db:"code"`
+	LongURL          string     `json:"long_url" db:"long_url"`
+	OriginalURL      *string    `json:"original_url,omitempty" db:"original_url"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	ExpireAt         *time.Time `json:"expire_at,omitempty" db:"expire_at"`
+	IsDeleted        bool       `json:"is_deleted" db:"is_deleted"`
+	CustomAlias      bool       `json:"custom_alias" db:"custom_alias"`
+	CreatedBy        *string    `json:"created_by,omitempty" db:"created_by"`
+	Metadata         *string    `json:"metadata,omitempty" db:"metadata"`
+	Title            *string    `json:"title,omitempty" db:"title"`
+	ShowInterstitial bool       `json:"show_interstitial" db:"show_interstitial"`
+	OGTitle          *string    `json:"og_title,omitempty" db:"og_title"`
+	OGDescription    *string    `json:"og_description,omitempty" db:"og_description"`
+	OGImage          *string    `json:"og_image,omitempty" db:"og_image"`
+	CampaignID       *int64     `json:"campaign_id,omitempty" db:"campaign_id"`
+	Status           string     `json:"status" db:"status"`
+	StatusReason     *string    `json:"status_reason,omitempty" db:"status_reason"`
+	ReviewedAt       *time.Time `json:"reviewed_at,omitempty" db:"reviewed_at"`
 }
 
 // CreateURLRequest represents the request to create a short URL
 type CreateURLRequest struct {
-	URL         string     `json:"url" binding:"required,url"`
-	CustomAlias *string    `json:"custom_alias,omitempty"`
-	ExpireAt    *time.Time `json:"expire_at,omitempty"`
-	CreatedBy   *string    `json:"created_by,omitempty"`
-	Metadata    *string    `json:"metadata,omitempty"`
+	URL              string     `json:"url" binding:"required,url"`
+	CustomAlias      *string    `json:"custom_alias,omitempty"`
+	ExpireAt         *time.Time `json:"expire_at,omitempty"`
+	CreatedBy        *string    `json:"created_by,omitempty"`
+	Metadata         *string    `json:"metadata,omitempty"`
+	Title            *string    `json:"title,omitempty" binding:"omitempty,max=255"`
+	ShowInterstitial bool       `json:"show_interstitial,omitempty"`
+	OGTitle          *string    `json:"og_title,omitempty" binding:"omitempty,max=255"`
+	OGDescription    *string    `json:"og_description,omitempty" binding:"omitempty,max=1024"`
+	OGImage          *string    `json:"og_image,omitempty" binding:"omitempty,url"`
+	Tags             []string   `json:"tags,omitempty" binding:"omitempty,max=20"`
+	CampaignID       *int64     `json:"campaign_id,omitempty"`
 }
 
 // CreateURLResponse represents the response after creating a short URL
 type CreateURLResponse struct {
-	Code      string     `json:"code"`
-	ShortURL  string     `json:"short_url"`
-	LongURL   string     `json:"long_url"`
-	ExpireAt  *time.Time `json:"expire_at,omitempty"`
-	CreatedAt time.Time  `json:"created_at"`
+	Code        string     `json:"code"`
+	ShortURL    string     `json:"short_url"`
+	LongURL     string     `json:"long_url"`
+	OriginalURL string     `json:"original_url,omitempty"`
+	ExpireAt    *time.Time `json:"expire_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `json:"status"`
 }
 
-// URLMetadata represents the metadata for a short URL
+// URLMetadata represents the metadata for a short URL. UniqueVisitors is
+// the sum of the daily unique visitor estimates: visitor IDs are salted per
+// day, so a visitor returning on another day is counted again.
 type URLMetadata struct {
-	Code         string     `json:"code"`
-	LongURL      string     `json:"long_url"`
-	CreatedAt    time.Time  `json:"created_at"`
-	ExpireAt     *time.Time `json:"expire_at,omitempty"`
-	TotalClicks  int64      `json:"total_clicks"`
-	LastAccessAt *time.Time `json:"last_access_at,omitempty"`
-	IsDeleted    bool       `json:"is_deleted"`
+	Code             string       `json:"code"`
+	LongURL          string       `json:"long_url"`
+	OriginalURL      *string      `json:"original_url,omitempty"`
+	CreatedAt        time.Time    `json:"created_at"`
+	ExpireAt         *time.Time   `json:"expire_at,omitempty"`
+	TotalClicks      int64        `json:"total_clicks"`
+	BotClicks        int64        `json:"bot_clicks"`
+	UniqueVisitors   int64        `json:"unique_visitors"`
+	LastAccessAt     *time.Time   `json:"last_access_at,omitempty"`
+	IsDeleted        bool         `json:"is_deleted"`
+	Title            *string      `json:"title,omitempty"`
+	ShowInterstitial bool         `json:"show_interstitial"`
+	OGTitle          *string      `json:"og_title,omitempty"`
+	OGDescription    *string      `json:"og_description,omitempty"`
+	OGImage          *string      `json:"og_image,omitempty"`
+	Tags             []string     `json:"tags,omitempty"`
+	CampaignID       *int64       `json:"campaign_id,omitempty"`
+	Status           string       `json:"status"`
+	Preview          *LinkPreview `json:"preview,omitempty"`
+}
+
+// LinkPreview holds metadata unfurled from the destination page
+type LinkPreview struct {
+	Title       string    `json:"title,omitempty" db:"title"`
+	Description string    `json:"description,omitempty" db:"description"`
+	ImageURL    string    `json:"image_url,omitempty" db:"image_url"`
+	FaviconURL  string    `json:"favicon_url,omitempty" db:"favicon_url"`
+	FetchedAt   time.Time `json:"fetched_at" db:"fetched_at"`
 }
 
 // ClickEvent represents a click event for analytics
 type ClickEvent struct {
-	ID         int64      `json:"id" db:"id"`
-	Code       string     `json:"code" db:"code"`
-	Timestamp  time.Time  `json:"timestamp" db:"ts"`
-	UserAgent  *string    `json:"user_agent,omitempty" db:"user_agent"`
-	IPAddress  *string    `json:"ip_address,omitempty" db:"ip_address"`
-	Referer    *string    `json:"referer,omitempty" db:"referer"`
-	Country    *string    `json:"country,omitempty" db:"country"`
-	DeviceType *string    `json:"device_type,omitempty" db:"device_type"`
+	ID         int64     `json:"id" db:"id"`
+	Code       string    `json:"code" db:"code"`
+	Timestamp  time.Time `json:"timestamp" db:"ts"`
+	UserAgent  *string   `json:"user_agent,omitempty" db:"user_agent"`
+	IPAddress  *string   `json:"ip_address,omitempty" db:"ip_address"`
+	IPHash     *string   `json:"ip_hash,omitempty" db:"ip_hash"`
+	Referer    *string   `json:"referer,omitempty" db:"referer"`
+	Country    *string   `json:"country,omitempty" db:"country"`
+	DeviceType *string   `json:"device_type,omitempty" db:"device_type"`
+	Browser    *string   `json:"browser,omitempty" db:"browser"`
+	OS         *string   `json:"os,omitempty" db:"os"`
+	IsBot      bool      `json:"is_bot" db:"is_bot"`
+	StreamID   *string   `json:"-" db:"stream_id"` // the Redis stream entry the click was read from
 }
 
 // HealthResponse represents the health check response
@@ -65,19 +112,19 @@ type HealthResponse struct {
 	Services  map[string]string `json:"services"`
 }
 
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error   string `json:"error"`
-	Code    string `json:"code,omitempty"`
-	Message string `json:"message"`
-}
-
 // Pagination represents pagination parameters
 type Pagination struct {
 	Page     int `json:"page" form:"page"`
 	PageSize int `json:"page_size" form:"page_size"`
 }
 
+// URLFilter narrows a URL listing
+type URLFilter struct {
+	// Tags requires a link to carry every listed tag
+	Tags       []string
+	CampaignID *int64
+}
+
 // URLListResponse represents a paginated list of URLs
 type URLListResponse struct {
 	URLs       []URLMetadata `json:"urls"`
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// WebhookSubscription is an endpoint notified of events on an owner's links
+type WebhookSubscription struct {
+	ID              int64     `json:"id" db:"id"`
+	Owner           string    `json:"owner" db:"owner"`
+	URL             string    `json:"url" db:"url"`
+	Secret          string    `json:"secret,omitempty" db:"secret"`
+	Events          []string  `json:"events" db:"events"`
+	ClickThresholds []int64   `json:"click_thresholds" db:"click_thresholds"`
+	Active          bool      `json:"active" db:"active"`
+	CreatedAt       time.Time `json:"created_at" db:"created_at"`
+}
+
+// CreateWebhookRequest represents the request to register a webhook
+type CreateWebhookRequest struct {
+	URL             string   `json:"url" binding:"required,max=2048"`
+	Events          []string `json:"events" binding:"required,min=1"`
+	ClickThresholds []int64  `json:"click_thresholds,omitempty" binding:"max=20"`
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"   // waiting for its first or next attempt
+	DeliverySucceeded = "succeeded" // the endpoint accepted the event
+	DeliveryDead      = "dead"      // every attempt failed; retried only on request
+)
+
+// WebhookDelivery is one event sent, or to be sent, to one subscription
+type WebhookDelivery struct {
+	ID             int64           `json:"id" db:"id"`
+	SubscriptionID int64           `json:"subscription_id" db:"subscription_id"`
+	EventID        int64           `json:"event_id" db:"event_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at,omitempty" db:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code,omitempty" db:"last_status_code"`
+	LastError      *string         `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	CompletedAt    *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+
+	// Endpoint details, loaded when a delivery is claimed for sending
+	URL    string `json:"-"`
+	Secret string `json:"-"`
+}
+
+// WebhookAttempt is the outcome of one delivery attempt
+type WebhookAttempt struct {
+	DeliveryID int64
+	StatusCode int        // zero if no response was received
+	Error      string     // empty on success
+	Succeeded  bool
+	RetryAt    *time.Time // nil when a failed delivery is dead-lettered
+}
+
+// WebhookDeliveryList is a page of a subscription's delivery log
+type WebhookDeliveryList struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	Pagination Pagination        `json:"pagination"`
+	Total      int64             `json:"total"`
+}
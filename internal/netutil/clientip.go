@@ -0,0 +1,167 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// clientIPKey is the gin context key of the resolved client address
+const clientIPKey = "netutil.client_ip"
+
+// ProxyResolver determines the client address of requests that may have
+// passed through reverse proxies. Forwarding headers are only believed when
+// the connection comes from a trusted proxy, and are read from the right so
+// addresses a client prepends itself are ignored.
+type ProxyResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewProxyResolver creates a resolver that trusts the given proxies, each
+// an IP address or CIDR block. With none, forwarding headers are ignored.
+func NewProxyResolver(trustedProxies []string) (*ProxyResolver, error) {
+	r := &ProxyResolver{}
+	for _, proxy := range trustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if !strings.Contains(proxy, "/") {
+			ip := net.ParseIP(proxy)
+			if ip == nil {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidProxy, proxy)
+			}
+			bits := 128
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 32
+			}
+			r.trusted = append(r.trusted, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, block, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidProxy, proxy)
+		}
+		r.trusted = append(r.trusted, block)
+	}
+	return r, nil
+}
+
+// ClientIP returns the address of the client that made a request. If the
+// peer is a trusted proxy, the hops listed by the Forwarded header, or else
+// X-Forwarded-For or X-Real-IP, are walked from the right and the first
+// address that is not a trusted proxy is returned.
+func (r *ProxyResolver) ClientIP(req *http.Request) string {
+	peer := parseHost(req.RemoteAddr)
+	if peer == nil {
+		return req.RemoteAddr
+	}
+	if !r.isTrusted(peer) {
+		return peer.String()
+	}
+
+	client := peer
+	hops := forwardedHops(req.Header)
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseHost(hops[i])
+		if ip == nil {
+			// Unknown or obfuscated hops end the chain of trust
+			break
+		}
+		client = ip
+		if !r.isTrusted(ip) {
+			break
+		}
+	}
+	return client.String()
+}
+
+// Middleware creates a Gin middleware that resolves the client address
+// once per request, for ClientIP
+func (r *ProxyResolver) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(clientIPKey, r.ClientIP(c.Request))
+		c.Next()
+	}
+}
+
+// isTrusted reports whether ip is a trusted proxy
+func (r *ProxyResolver) isTrusted(ip net.IP) bool {
+	for _, block := range r.trusted {
+		if block.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the client address resolved by ProxyResolver.Middleware,
+// or the peer address if the middleware did not run
+func ClientIP(c *gin.Context) string {
+	if ip := c.GetString(clientIPKey); ip != "" {
+		return ip
+	}
+	if ip := parseHost(c.Request.RemoteAddr); ip != nil {
+		return ip.String()
+	}
+	return c.Request.RemoteAddr
+}
+
+// forwardedHops returns the addresses a request was forwarded for, from
+// the original client to the last proxy. The standard Forwarded header
+// (RFC 7239) takes precedence over X-Forwarded-For and X-Real-IP.
+func forwardedHops(header http.Header) []string {
+	if values := header.Values("Forwarded"); len(values) > 0 {
+		var hops []string
+		for _, element := range splitList(values) {
+			hop := ""
+			for _, pair := range strings.Split(element, ";") {
+				name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(name, "for") {
+					hop = strings.Trim(value, `"`)
+				}
+			}
+			// Elements without "for" still count as a hop
+			hops = append(hops, hop)
+		}
+		return hops
+	}
+	if values := header.Values("X-Forwarded-For"); len(values) > 0 {
+		return splitList(values)
+	}
+	if realIP := strings.TrimSpace(header.Get("X-Real-IP")); realIP != "" {
+		return []string{realIP}
+	}
+	return nil
+}
+
+// splitList splits comma-separated header values, which may be repeated
+func splitList(values []string) []string {
+	var items []string
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			items = append(items, strings.TrimSpace(item))
+		}
+	}
+	return items
+}
+
+// parseHost parses an address with an optional port, such as "192.0.2.1",
+// "192.0.2.1:8080", "2001:db8::1" or "[2001:db8::1]:8080"
+func parseHost(addr string) net.IP {
+	if ip := net.ParseIP(addr); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+}
+
+// Custom errors
+var (
+	ErrInvalidProxy = fmt.Errorf("invalid trusted proxy")
+)
@@ -0,0 +1,119 @@
+package netutil
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxyResolverClientIP(t *testing.T) {
+	resolver, err := NewProxyResolver([]string{"10.0.0.0/8", "2001:db8::1"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string][]string
+		expected   string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.7:4321",
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "spoofed header from untrusted peer",
+			remoteAddr: "203.0.113.7:4321",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			expected:   "203.0.113.7",
+		},
+		{
+			name:       "trusted proxy",
+			remoteAddr: "10.0.0.2:4321",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "client prepends a fake hop",
+			remoteAddr: "10.0.0.2:4321",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.2.3.4, 198.51.100.1, 10.0.0.3"}},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "repeated headers",
+			remoteAddr: "10.0.0.2:4321",
+			headers:    map[string][]string{"X-Forwarded-For": {"1.2.3.4", "198.51.100.1"}},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "only trusted hops",
+			remoteAddr: "10.0.0.2:4321",
+			headers:    map[string][]string{"X-Forwarded-For": {"10.0.0.4, 10.0.0.3"}},
+			expected:   "10.0.0.4",
+		},
+		{
+			name:       "invalid hop",
+			remoteAddr: "10.0.0.2:4321",
+			headers:    map[string][]string{"X-Forwarded-For": {"198.51.100.1, garbage"}},
+			expected:   "10.0.0.2",
+		},
+		{
+			name:       "X-Real-IP",
+			remoteAddr: "10.0.0.2:4321",
+			headers:    map[string][]string{"X-Real-Ip": {"198.51.100.1"}},
+			expected:   "198.51.100.1",
+		},
+		{
+			name:       "Forwarded takes precedence",
+			remoteAddr: "[2001:db8::1]:443",
+			headers: map[string][]string{
+				"Forwarded":       {`for=1.2.3.4, for="[2001:db8:cafe::17]:4711";proto=https, for=10.0.0.3:80;by=10.0.0.2`},
+				"X-Forwarded-For": {"192.0.2.9"},
+			},
+			expected: "2001:db8:cafe::17",
+		},
+		{
+			name:       "obfuscated Forwarded hop",
+			remoteAddr: "10.0.0.2:4321",
+			headers:    map[string][]string{"Forwarded": {`for=198.51.100.1, for=_hidden`}},
+			expected:   "10.0.0.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/abc", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for name, values := range tt.headers {
+				req.Header[name] = values
+			}
+
+			if ip := resolver.ClientIP(req); ip != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, ip)
+			}
+		})
+	}
+}
+
+func TestProxyResolverWithoutTrustedProxies(t *testing.T) {
+	resolver, _ := NewProxyResolver(nil)
+
+	req := httptest.NewRequest("GET", "/abc", nil)
+	req.RemoteAddr = "127.0.0.1:4321"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Set("Forwarded", "for=198.51.100.1")
+
+	if ip := resolver.ClientIP(req); ip != "127.0.0.1" {
+		t.Errorf("expected headers to be ignored, got %s", ip)
+	}
+}
+
+func TestNewProxyResolverRejectsInvalidProxies(t *testing.T) {
+	for _, proxy := range []string{"10.0.0.0/33", "proxy.internal"} {
+		if _, err := NewProxyResolver([]string{proxy}); !errors.Is(err, ErrInvalidProxy) {
+			t.Errorf("%s: expected ErrInvalidProxy, got %v", proxy, err)
+		}
+	}
+}
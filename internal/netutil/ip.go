@@ -0,0 +1,62 @@
+package netutil
+
+import (
+	"net"
+)
+
+// nonPublicRanges lists address blocks that are never reachable on the public
+// internet but are not covered by the net.IP classification helpers
+var nonPublicRanges = mustParseCIDRs(
+	"0.0.0.0/8",          // "this" network
+	"100.64.0.0/10",      // carrier-grade NAT
+	"192.0.0.0/24",       // IETF protocol assignments
+	"192.0.2.0/24",       // TEST-NET-1
+	"198.18.0.0/15",      // benchmarking
+	"198.51.100.0/24",    // TEST-NET-2
+	"203.0.113.0/24",     // TEST-NET-3
+	"240.0.0.0/4",        // reserved
+	"255.255.255.255/32", // broadcast
+	"64:ff9b::/96",       // NAT64, which could reach any IPv4 address
+	"64:ff9b:1::/48",     // local-use IPv4/IPv6 translation
+	"2001:db8::/32",      // documentation
+)
+
+// IsPublicIP reports whether ip is a globally routable unicast address.
+// Loopback, private, link-local (including cloud metadata endpoints),
+// multicast and reserved ranges are all treated as non-public.
+func IsPublicIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+
+	for _, block := range nonPublicRanges {
+		if block.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// mustParseCIDRs parses a static list of CIDR blocks
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	blocks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, block, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic("netutil: invalid CIDR " + cidr)
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks
+}
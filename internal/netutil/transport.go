@@ -0,0 +1,59 @@
+package netutil
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// TransportConfig holds the settings of a transport for user-supplied URLs
+type TransportConfig struct {
+	Timeout      time.Duration // dial, TLS handshake and response header timeout
+	MaxIdleConns int
+	// AllowPrivateNetworks disables SSRF protection; only meant for tests
+	AllowPrivateNetworks bool
+}
+
+// NewTransport creates an HTTP transport for requests to URLs supplied by
+// users, such as link destinations and webhook endpoints. Connections to
+// non-public addresses fail with ErrPrivateAddress.
+func NewTransport(config TransportConfig) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: config.Timeout,
+	}
+	if !config.AllowPrivateNetworks {
+		// Checked after DNS resolution so rebinding cannot sneak past it
+		dialer.Control = blockPrivateAddresses
+	}
+
+	return &http.Transport{
+		// Never route through an environment proxy, it would bypass the dial check
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   config.Timeout,
+		ResponseHeaderTimeout: config.Timeout,
+		MaxIdleConns:          config.MaxIdleConns,
+		IdleConnTimeout:       30 * time.Second,
+	}
+}
+
+// blockPrivateAddresses rejects connections to non-public IP addresses
+func blockPrivateAddresses(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid dial address %q: %w", address, err)
+	}
+
+	if !IsPublicIP(net.ParseIP(host)) {
+		return fmt.Errorf("%w: %s", ErrPrivateAddress, host)
+	}
+
+	return nil
+}
+
+// Custom errors
+var (
+	ErrPrivateAddress = fmt.Errorf("host resolves to a non-public address")
+)
@@ -1,9 +1,12 @@
 package obs
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/netutil"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -53,7 +56,7 @@ func LoggingMiddleware(logger *Logger) gin.HandlerFunc {
 			"path", c.Request.URL.Path,
 			"status", c.Writer.Status(),
 			"latency", time.Since(start),
-			"client_ip", c.ClientIP(),
+			"client_ip", netutil.ClientIP(c),
 			"user_agent", c.Request.UserAgent(),
 		)
 	}
@@ -68,13 +71,12 @@ func RecoveryMiddleware(logger *Logger) gin.HandlerFunc {
 					"panic", err,
 					"path", c.Request.URL.Path,
 					"method", c.Request.Method,
-					"client_ip", c.ClientIP(),
+					"client_ip", netutil.ClientIP(c),
 				)
 
-				c.JSON(500, gin.H{
-					"error":   "internal_error",
-					"message": "Internal server error",
-				})
+				c.Header("Content-Type", models.ProblemContentType)
+				c.AbortWithStatusJSON(http.StatusInternalServerError,
+					models.NewProblem(http.StatusInternalServerError, "internal_error", "Internal server error"))
 			}
 		}()
 		
@@ -18,6 +18,10 @@ type Metrics struct {
 	cacheMisses        prometheus.Counter
 	databaseOperations *prometheus.HistogramVec
 	activeConnections  prometheus.Gauge
+	clickQueueDepth    prometheus.Gauge
+	clicksDropped      *prometheus.CounterVec
+	clickBatchSize     prometheus.Histogram
+	clickBatchDuration *prometheus.HistogramVec
 }
 
 // NewMetrics creates a new metrics instance
@@ -71,6 +75,34 @@ func NewMetrics() *Metrics {
 				Help: "Current number of active connections",
 			},
 		),
+		clickQueueDepth: prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "click_queue_depth",
+				Help: "Current number of click events waiting to be written",
+			},
+		),
+		clicksDropped: prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "clicks_dropped_total",
+				Help: "Total number of click events dropped before being written",
+			},
+			[]string{"reason"},
+		),
+		clickBatchSize: prometheus.NewHistogram(
+			prometheus.HistogramOpts{
+				Name:    "click_batch_size",
+				Help:    "Number of click events per batch write",
+				Buckets: prometheus.ExponentialBuckets(1, 2, 12),
+			},
+		),
+		clickBatchDuration: prometheus.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "click_batch_duration_seconds",
+				Help:    "Click batch write duration in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"status"},
+		),
 	}
 
 	// Register metrics
@@ -82,6 +114,10 @@ func NewMetrics() *Metrics {
 		m.cacheMisses,
 		m.databaseOperations,
 		m.activeConnections,
+		m.clickQueueDepth,
+		m.clicksDropped,
+		m.clickBatchSize,
+		m.clickBatchDuration,
 	)
 
 	return m
@@ -136,3 +172,23 @@ func (m *Metrics) RecordDatabaseOperation(operation, table string, duration time
 func (m *Metrics) SetActiveConnections(count int) {
 	m.activeConnections.Set(float64(count))
 }
+
+// SetClickQueueDepth sets the click queue depth gauge
+func (m *Metrics) SetClickQueueDepth(depth int) {
+	m.clickQueueDepth.Set(float64(depth))
+}
+
+// RecordClicksDropped counts click events dropped for the given reason
+func (m *Metrics) RecordClicksDropped(reason string, count int) {
+	m.clicksDropped.WithLabelValues(reason).Add(float64(count))
+}
+
+// ObserveClickBatch records the size and duration of a click batch write
+func (m *Metrics) ObserveClickBatch(size int, duration time.Duration, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+	}
+	m.clickBatchSize.Observe(float64(size))
+	m.clickBatchDuration.WithLabelValues(status).Observe(duration.Seconds())
+}
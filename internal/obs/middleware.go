@@ -1,11 +1,13 @@
 package obs
 
 import (
+	"crypto/subtle"
 	"net/http"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/models"
 )
 
 // CORSMiddleware creates a CORS middleware
@@ -32,7 +34,8 @@ func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
 		}
 		
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-User-ID")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-User-ID, X-API-Key")
+		c.Header("Access-Control-Expose-Headers", "RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset, Retry-After")
 		c.Header("Access-Control-Allow-Credentials", "true")
 		
 		// Handle preflight requests
@@ -59,6 +62,26 @@ func SecurityMiddleware() gin.HandlerFunc {
 	}
 }
 
+// AdminAuthMiddleware requires the admin secret as a bearer token or in the
+// X-Admin-Secret header. Without a configured secret, every request is rejected.
+func AdminAuthMiddleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		provided := c.GetHeader("X-Admin-Secret")
+		if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+			provided = strings.TrimPrefix(auth, "Bearer ")
+		}
+
+		if secret == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(secret)) != 1 {
+			c.Header("Content-Type", models.ProblemContentType)
+			c.AbortWithStatusJSON(http.StatusUnauthorized,
+				models.NewProblem(http.StatusUnauthorized, "unauthorized", "Admin credentials are required"))
+			return
+		}
+
+		c.Next()
+	}
+}
+
 // RequestIDMiddleware adds a unique request ID to each request
 func RequestIDMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -0,0 +1,235 @@
+package privacy
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+// Mode selects how client IP addresses are stored
+type Mode string
+
+// IP storage modes
+const (
+	ModeOff      Mode = "off"      // store the full address
+	ModeTruncate Mode = "truncate" // store the /24 (IPv4) or /48 (IPv6) network
+	ModeHash     Mode = "hash"     // store a salted hash that changes daily
+)
+
+// Network prefixes kept in truncate mode
+const (
+	ipv4PrefixBits = 24
+	ipv6PrefixBits = 48
+)
+
+// saltTimeout bounds how long a click waits for the day's salt
+const saltTimeout = 250 * time.Millisecond
+
+// saltRetryDelay is how long clicks go without a salt after fetching it
+// failed, rather than each waiting for the salt source
+const saltRetryDelay = 10 * time.Second
+
+// ParseMode validates an IP storage mode, treating the empty string as off
+func ParseMode(value string) (Mode, error) {
+	switch Mode(value) {
+	case "", ModeOff:
+		return ModeOff, nil
+	case ModeTruncate, ModeHash:
+		return Mode(value), nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidMode, value)
+	}
+}
+
+// Anonymizer removes identifying detail from click events before they are
+// stored
+type Anonymizer struct {
+	mode  Mode
+	salts SaltSource
+	now   func() time.Time
+
+	mu         sync.Mutex
+	day        string
+	salt       []byte
+	fetching   chan struct{} // closed when the fetch in progress completes
+	retryAfter time.Time     // no fetch before this time after a failure
+}
+
+// NewAnonymizer creates an anonymizer. Hash mode requires a salt source.
+func NewAnonymizer(mode Mode, salts SaltSource) (*Anonymizer, error) {
+	if mode == ModeHash && salts == nil {
+		return nil, fmt.Errorf("%w: hash mode requires a salt source", ErrInvalidMode)
+	}
+
+	return &Anonymizer{
+		mode:  mode,
+		salts: salts,
+		now:   time.Now,
+	}, nil
+}
+
+// Anonymize rewrites the event's IP address according to the configured
+// mode. If the day's salt is unavailable the address is dropped rather than
+// stored unhashed.
+func (a *Anonymizer) Anonymize(ctx context.Context, event *models.ClickEvent) {
+	if event.IPAddress == nil || a.mode == ModeOff {
+		return
+	}
+
+	ip := net.ParseIP(*event.IPAddress)
+	if ip == nil {
+		event.IPAddress = nil
+		return
+	}
+
+	switch a.mode {
+	case ModeTruncate:
+		network := TruncateIP(ip).String()
+		event.IPAddress = &network
+	case ModeHash:
+		event.IPAddress = nil
+		if hash, err := a.hash(ctx, ip); err == nil {
+			event.IPHash = &hash
+		}
+	}
+}
+
+// VisitorID derives a pseudonymous visitor identifier from the client IP
+// address and user agent. It is keyed with the daily salt, so the same
+// visitor gets a new identifier every day and identifiers cannot be reversed
+// once the salt has expired.
+func (a *Anonymizer) VisitorID(ctx context.Context, ipAddress, userAgent string) (string, error) {
+	if a.salts == nil {
+		return "", fmt.Errorf("visitor IDs require a salt source")
+	}
+
+	salt, err := a.currentSalt(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte("visitor\x00"))
+	mac.Write([]byte(ipAddress))
+	mac.Write([]byte{0})
+	mac.Write([]byte(userAgent))
+	return hex.EncodeToString(mac.Sum(nil)[:16]), nil
+}
+
+// hash returns the hex HMAC-SHA256 of ip keyed with today's salt
+func (a *Anonymizer) hash(ctx context.Context, ip net.IP) (string, error) {
+	salt, err := a.currentSalt(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	if v4 := ip.To4(); v4 != nil {
+		ip = v4
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ip)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// currentSalt returns the salt for the current UTC day, fetching it once per
+// day from the salt source. Only one fetch runs at a time, outside the lock,
+// and after a failure the salt is reported unavailable until saltRetryDelay
+// has passed.
+func (a *Anonymizer) currentSalt(ctx context.Context) ([]byte, error) {
+	now := a.now()
+	day := now.UTC().Format("2006-01-02")
+
+	a.mu.Lock()
+	if a.day == day {
+		salt := a.salt
+		a.mu.Unlock()
+		return salt, nil
+	}
+	if now.Before(a.retryAfter) {
+		a.mu.Unlock()
+		return nil, ErrSaltUnavailable
+	}
+	if fetching := a.fetching; fetching != nil {
+		a.mu.Unlock()
+		select {
+		case <-fetching:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		a.mu.Lock()
+		defer a.mu.Unlock()
+		if a.day != day {
+			return nil, ErrSaltUnavailable
+		}
+		return a.salt, nil
+	}
+	fetching := make(chan struct{})
+	a.fetching = fetching
+	a.mu.Unlock()
+
+	// Salts are fetched on the redirect path, so don't wait long for them
+	fetchCtx, cancel := context.WithTimeout(ctx, saltTimeout)
+	defer cancel()
+	salt, err := a.salts.Salt(fetchCtx, day)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.fetching = nil
+	close(fetching)
+	if err != nil {
+		a.retryAfter = now.Add(saltRetryDelay)
+		return nil, fmt.Errorf("%w: %v", ErrSaltUnavailable, err)
+	}
+	a.day, a.salt = day, salt
+
+	return salt, nil
+}
+
+// TruncateIP zeroes the host bits of ip, keeping its /24 (IPv4) or /48
+// (IPv6) network
+func TruncateIP(ip net.IP) net.IP {
+	if v4 := ip.To4(); v4 != nil {
+		return v4.Mask(net.CIDRMask(ipv4PrefixBits, 32))
+	}
+	return ip.Mask(net.CIDRMask(ipv6PrefixBits, 128))
+}
+
+// StripVisitor removes everything that identifies an individual visitor from
+// a click event, keeping only what feeds aggregate statistics. Referrers are
+// reduced to their origin.
+func StripVisitor(event *models.ClickEvent) {
+	event.IPAddress = nil
+	event.IPHash = nil
+	event.UserAgent = nil
+
+	if event.Referer != nil {
+		if u, err := url.Parse(*event.Referer); err == nil && u.Scheme != "" && u.Host != "" {
+			origin := u.Scheme + "://" + u.Host
+			event.Referer = &origin
+		} else {
+			event.Referer = nil
+		}
+	}
+}
+
+// DoNotTrack reports whether a request carries a Do Not Track or Global
+// Privacy Control opt-out
+func DoNotTrack(header http.Header) bool {
+	return header.Get("DNT") == "1" || header.Get("Sec-GPC") == "1"
+}
+
+// Custom errors
+var (
+	ErrInvalidMode     = fmt.Errorf("invalid IP anonymization mode")
+	ErrSaltUnavailable = fmt.Errorf("IP hashing salt unavailable")
+)
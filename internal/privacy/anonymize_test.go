@@ -0,0 +1,232 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+func TestTruncateIP(t *testing.T) {
+	tests := []struct {
+		ip       string
+		expected string
+	}{
+		{"203.0.113.77", "203.0.113.0"},
+		{"::ffff:203.0.113.77", "203.0.113.0"},
+		{"2001:db8:abcd:1234::1", "2001:db8:abcd::"},
+	}
+
+	for _, tt := range tests {
+		if got := TruncateIP(net.ParseIP(tt.ip)).String(); got != tt.expected {
+			t.Errorf("TruncateIP(%s): expected %s, got %s", tt.ip, tt.expected, got)
+		}
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for _, value := range []string{"", "off", "truncate", "hash"} {
+		if _, err := ParseMode(value); err != nil {
+			t.Errorf("ParseMode(%q): unexpected error: %v", value, err)
+		}
+	}
+	if _, err := ParseMode("encrypt"); !errors.Is(err, ErrInvalidMode) {
+		t.Errorf("expected ErrInvalidMode, got %v", err)
+	}
+	if _, err := NewAnonymizer(ModeHash, nil); !errors.Is(err, ErrInvalidMode) {
+		t.Errorf("expected hash mode without salts to fail, got %v", err)
+	}
+}
+
+func clickFrom(ip string) *models.ClickEvent {
+	return &models.ClickEvent{Code: "abc123", IPAddress: &ip}
+}
+
+func TestAnonymizeTruncate(t *testing.T) {
+	a, _ := NewAnonymizer(ModeTruncate, nil)
+
+	event := clickFrom("198.51.100.23")
+	a.Anonymize(context.Background(), event)
+	if event.IPAddress == nil || *event.IPAddress != "198.51.100.0" {
+		t.Errorf("expected truncated address, got %v", event.IPAddress)
+	}
+
+	event = clickFrom("not-an-ip")
+	a.Anonymize(context.Background(), event)
+	if event.IPAddress != nil {
+		t.Errorf("expected unparseable address to be dropped, got %q", *event.IPAddress)
+	}
+}
+
+// failingSalts is a salt source that is always unavailable
+type failingSalts struct{}
+
+func (failingSalts) Salt(ctx context.Context, day string) ([]byte, error) {
+	return nil, errors.New("unavailable")
+}
+
+func TestAnonymizeHash(t *testing.T) {
+	a, _ := NewAnonymizer(ModeHash, NewLocalSalts())
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	hash := func(ip string) string {
+		event := clickFrom(ip)
+		a.Anonymize(context.Background(), event)
+		if event.IPAddress != nil {
+			t.Fatalf("expected address to be removed, got %q", *event.IPAddress)
+		}
+		if event.IPHash == nil || len(*event.IPHash) != 64 {
+			t.Fatalf("expected a hex SHA-256 hash, got %v", event.IPHash)
+		}
+		return *event.IPHash
+	}
+
+	first := hash("203.0.113.7")
+	if hash("::ffff:203.0.113.7") != first {
+		t.Error("expected IPv4-mapped addresses to hash like IPv4")
+	}
+	if hash("203.0.113.8") == first {
+		t.Error("expected different addresses to hash differently")
+	}
+
+	now = now.Add(24 * time.Hour)
+	if hash("203.0.113.7") == first {
+		t.Error("expected the hash to change with the daily salt")
+	}
+
+	// Without a salt the address is dropped, never stored in the clear
+	a, _ = NewAnonymizer(ModeHash, failingSalts{})
+	event := clickFrom("203.0.113.7")
+	a.Anonymize(context.Background(), event)
+	if event.IPAddress != nil || event.IPHash != nil {
+		t.Errorf("expected no address or hash, got %v and %v", event.IPAddress, event.IPHash)
+	}
+}
+
+// countingSalts fails while err is set and counts fetches
+type countingSalts struct {
+	err     error
+	fetches int
+}
+
+func (s *countingSalts) Salt(ctx context.Context, day string) ([]byte, error) {
+	s.fetches++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []byte("salt-" + day), nil
+}
+
+func TestCurrentSaltBacksOffAfterFailure(t *testing.T) {
+	salts := &countingSalts{err: errors.New("unavailable")}
+	a, _ := NewAnonymizer(ModeHash, salts)
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if _, err := a.currentSalt(context.Background()); !errors.Is(err, ErrSaltUnavailable) {
+			t.Fatalf("expected ErrSaltUnavailable, got %v", err)
+		}
+	}
+	if salts.fetches != 1 {
+		t.Errorf("expected one fetch during the retry delay, got %d", salts.fetches)
+	}
+
+	salts.err = nil
+	now = now.Add(saltRetryDelay)
+	if salt, err := a.currentSalt(context.Background()); err != nil || string(salt) != "salt-2024-05-01" {
+		t.Errorf("expected the salt once the retry delay passed, got %q, %v", salt, err)
+	}
+	if _, err := a.currentSalt(context.Background()); err != nil || salts.fetches != 2 {
+		t.Errorf("expected the salt to be cached, got %d fetches, %v", salts.fetches, err)
+	}
+}
+
+func TestStripVisitor(t *testing.T) {
+	ua := "Mozilla/5.0"
+	referer := "https://news.example.com/article?id=42"
+	event := clickFrom("203.0.113.7")
+	event.UserAgent = &ua
+	event.Referer = &referer
+
+	StripVisitor(event)
+
+	if event.IPAddress != nil || event.IPHash != nil || event.UserAgent != nil {
+		t.Error("expected visitor identifiers to be removed")
+	}
+	if event.Referer == nil || *event.Referer != "https://news.example.com" {
+		t.Errorf("expected referrer reduced to its origin, got %v", event.Referer)
+	}
+
+	app := "android-app://com.example"
+	event.Referer = &app
+	StripVisitor(event)
+	if event.Referer == nil || *event.Referer != "android-app://com.example" {
+		t.Errorf("unexpected referrer %v", event.Referer)
+	}
+
+	relative := "/path"
+	event.Referer = &relative
+	StripVisitor(event)
+	if event.Referer != nil {
+		t.Errorf("expected referrer without an origin to be dropped, got %q", *event.Referer)
+	}
+}
+
+func TestDoNotTrack(t *testing.T) {
+	tests := []struct {
+		header   http.Header
+		expected bool
+	}{
+		{http.Header{}, false},
+		{http.Header{"Dnt": {"1"}}, true},
+		{http.Header{"Dnt": {"0"}}, false},
+		{http.Header{"Sec-Gpc": {"1"}}, true},
+	}
+
+	for _, tt := range tests {
+		if got := DoNotTrack(tt.header); got != tt.expected {
+			t.Errorf("DoNotTrack(%v): expected %v, got %v", tt.header, tt.expected, got)
+		}
+	}
+}
+
+func TestVisitorID(t *testing.T) {
+	a, _ := NewAnonymizer(ModeOff, NewLocalSalts())
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	a.now = func() time.Time { return now }
+
+	id := func(ip, ua string) string {
+		visitor, err := a.VisitorID(context.Background(), ip, ua)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return visitor
+	}
+
+	first := id("203.0.113.7", "Mozilla/5.0")
+	if id("203.0.113.7", "Mozilla/5.0") != first {
+		t.Error("expected the same visitor to keep its ID within a day")
+	}
+	if id("203.0.113.7", "curl/8.0") == first {
+		t.Error("expected a different user agent to change the ID")
+	}
+	// The separator keeps shifted boundaries from colliding
+	if id("203.0.113.7M", "ozilla/5.0") == first {
+		t.Error("expected the IP and user agent to be hashed separately")
+	}
+
+	now = now.Add(24 * time.Hour)
+	if id("203.0.113.7", "Mozilla/5.0") == first {
+		t.Error("expected the ID to change with the daily salt")
+	}
+
+	if _, err := (&Anonymizer{mode: ModeOff}).VisitorID(context.Background(), "203.0.113.7", ""); err == nil {
+		t.Error("expected an error without a salt source")
+	}
+}
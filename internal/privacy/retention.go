@@ -0,0 +1,192 @@
+package privacy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RetentionAction selects what happens to click events past the retention
+// period
+type RetentionAction string
+
+// Retention actions
+const (
+	// RetentionDelete removes old click events; their counts survive in the
+	// hourly rollups and click_stats
+	RetentionDelete RetentionAction = "delete"
+	// RetentionAnonymize keeps old click events but clears their IP address,
+	// IP hash and user agent
+	RetentionAnonymize RetentionAction = "anonymize"
+)
+
+// ParseRetentionAction validates a retention action, defaulting to delete
+func ParseRetentionAction(value string) (RetentionAction, error) {
+	switch RetentionAction(value) {
+	case "", RetentionDelete:
+		return RetentionDelete, nil
+	case RetentionAnonymize:
+		return RetentionAnonymize, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrInvalidRetentionAction, value)
+	}
+}
+
+// RetentionStore removes per-visitor click data
+type RetentionStore interface {
+	GetRollupWatermark(ctx context.Context) (time.Time, error)
+	DeleteClickEvents(ctx context.Context, before time.Time, limit int) (int64, error)
+	AnonymizeClickEvents(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// RetentionConfig holds retention worker configuration
+type RetentionConfig struct {
+	MaxAge         time.Duration   // click events older than this are purged
+	Action         RetentionAction // delete or anonymize
+	Interval       time.Duration   // how often the worker runs
+	BatchSize      int             // rows changed per statement
+	RollupLookback time.Duration   // range the rollup aggregator recomputes
+	Timeout        time.Duration   // time limit for a single run
+}
+
+// Retention periodically purges click events past the retention period
+type Retention struct {
+	store   RetentionStore
+	config  RetentionConfig
+	onError func(error)
+	onPurge func(count int64)
+	now     func() time.Time
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewRetention creates a retention worker. onPurge is called with the number
+// of events purged by each run that purged any.
+func NewRetention(store RetentionStore, config RetentionConfig, onError func(error), onPurge func(count int64)) *Retention {
+	if config.Action == "" {
+		config.Action = RetentionDelete
+	}
+	if config.Interval <= 0 {
+		config.Interval = time.Hour
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 10000
+	}
+	if config.RollupLookback < 0 {
+		config.RollupLookback = 0
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Minute
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+	if onPurge == nil {
+		onPurge = func(int64) {}
+	}
+
+	return &Retention{
+		store:   store,
+		config:  config,
+		onError: onError,
+		onPurge: onPurge,
+		now:     time.Now,
+	}
+}
+
+// Start runs the worker in the background until Stop is called
+func (r *Retention) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.cancel = cancel
+
+	r.wg.Add(1)
+	go r.run(ctx)
+}
+
+// Stop cancels any in-flight run and waits for the worker to exit
+func (r *Retention) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+	r.wg.Wait()
+}
+
+// run purges old events on every tick
+func (r *Retention) run(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(r.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.runWithTimeout(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runWithTimeout performs one run, reporting its outcome
+func (r *Retention) runWithTimeout(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, r.config.Timeout)
+	defer cancel()
+
+	count, err := r.RunOnce(ctx)
+	if count > 0 {
+		r.onPurge(count)
+	}
+	if err != nil && ctx.Err() == nil {
+		r.onError(err)
+	}
+}
+
+// RunOnce purges click events older than the retention period in batches
+// and returns how many were purged. Deletion never reaches past the range
+// the rollup aggregator may still recompute, so stats are unaffected.
+func (r *Retention) RunOnce(ctx context.Context) (int64, error) {
+	if r.config.MaxAge <= 0 {
+		return 0, nil
+	}
+
+	cutoff := r.now().Add(-r.config.MaxAge)
+	purge := r.store.AnonymizeClickEvents
+
+	if r.config.Action == RetentionDelete {
+		watermark, err := r.store.GetRollupWatermark(ctx)
+		if err != nil {
+			return 0, err
+		}
+		if watermark.IsZero() {
+			// Nothing has been rolled up, so deleting would lose clicks
+			return 0, nil
+		}
+		if safe := watermark.Add(-r.config.RollupLookback); safe.Before(cutoff) {
+			cutoff = safe
+		}
+		purge = r.store.DeleteClickEvents
+	}
+
+	var total int64
+	for {
+		count, err := purge(ctx, cutoff, r.config.BatchSize)
+		total += count
+		if err != nil {
+			return total, err
+		}
+		if count < int64(r.config.BatchSize) {
+			return total, nil
+		}
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+	}
+}
+
+// Custom errors
+var (
+	ErrInvalidRetentionAction = fmt.Errorf("invalid retention action")
+)
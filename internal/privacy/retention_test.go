@@ -0,0 +1,147 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeRetentionStore records purge calls against a fixed number of old events
+type fakeRetentionStore struct {
+	watermark  time.Time
+	remaining  int64
+	action     string
+	cutoffs    []time.Time
+	failPurges bool
+}
+
+func (s *fakeRetentionStore) GetRollupWatermark(ctx context.Context) (time.Time, error) {
+	return s.watermark, nil
+}
+
+func (s *fakeRetentionStore) purge(action string, before time.Time, limit int) (int64, error) {
+	if s.failPurges {
+		return 0, errors.New("database unavailable")
+	}
+	s.action = action
+	s.cutoffs = append(s.cutoffs, before)
+	n := s.remaining
+	if n > int64(limit) {
+		n = int64(limit)
+	}
+	s.remaining -= n
+	return n, nil
+}
+
+func (s *fakeRetentionStore) DeleteClickEvents(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return s.purge("delete", before, limit)
+}
+
+func (s *fakeRetentionStore) AnonymizeClickEvents(ctx context.Context, before time.Time, limit int) (int64, error) {
+	return s.purge("anonymize", before, limit)
+}
+
+func TestRetentionRunOnce(t *testing.T) {
+	now := time.Date(2024, 5, 31, 12, 0, 0, 0, time.UTC)
+	maxAge := 30 * 24 * time.Hour
+
+	tests := []struct {
+		name           string
+		action         RetentionAction
+		watermark      time.Time
+		expectedAction string
+		expectedCutoff time.Time
+		expectedCount  int64
+	}{
+		{
+			name:           "delete up to the retention cutoff",
+			action:         RetentionDelete,
+			watermark:      now.Add(-time.Hour),
+			expectedAction: "delete",
+			expectedCutoff: now.Add(-maxAge),
+			expectedCount:  25,
+		},
+		{
+			name:           "delete stops short of the rollup lookback",
+			action:         RetentionDelete,
+			watermark:      now.Add(-maxAge),
+			expectedAction: "delete",
+			expectedCutoff: now.Add(-maxAge - 2*time.Hour),
+			expectedCount:  25,
+		},
+		{
+			name:          "delete waits for the first rollup",
+			action:        RetentionDelete,
+			expectedCount: 0,
+		},
+		{
+			name:           "anonymize ignores rollups",
+			action:         RetentionAnonymize,
+			expectedAction: "anonymize",
+			expectedCutoff: now.Add(-maxAge),
+			expectedCount:  25,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeRetentionStore{watermark: tt.watermark, remaining: 25}
+			r := NewRetention(store, RetentionConfig{
+				MaxAge:         maxAge,
+				Action:         tt.action,
+				BatchSize:      10,
+				RollupLookback: 2 * time.Hour,
+			}, nil, nil)
+			r.now = func() time.Time { return now }
+
+			count, err := r.RunOnce(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if count != tt.expectedCount {
+				t.Errorf("expected %d events purged, got %d", tt.expectedCount, count)
+			}
+			if tt.expectedCount == 0 {
+				if len(store.cutoffs) != 0 {
+					t.Errorf("expected no purges, got %d", len(store.cutoffs))
+				}
+				return
+			}
+
+			if store.action != tt.expectedAction {
+				t.Errorf("expected %s, got %s", tt.expectedAction, store.action)
+			}
+			// 25 events in batches of 10 take three statements
+			if len(store.cutoffs) != 3 {
+				t.Errorf("expected 3 batches, got %d", len(store.cutoffs))
+			}
+			for _, cutoff := range store.cutoffs {
+				if !cutoff.Equal(tt.expectedCutoff) {
+					t.Errorf("expected cutoff %v, got %v", tt.expectedCutoff, cutoff)
+				}
+			}
+		})
+	}
+}
+
+func TestRetentionDisabled(t *testing.T) {
+	store := &fakeRetentionStore{watermark: time.Now(), remaining: 5}
+	r := NewRetention(store, RetentionConfig{}, nil, nil)
+
+	if count, err := r.RunOnce(context.Background()); err != nil || count != 0 {
+		t.Errorf("expected no-op, got %d, %v", count, err)
+	}
+}
+
+func TestRetentionReportsErrors(t *testing.T) {
+	store := &fakeRetentionStore{watermark: time.Now(), failPurges: true}
+	r := NewRetention(store, RetentionConfig{MaxAge: time.Hour}, nil, nil)
+
+	if _, err := r.RunOnce(context.Background()); err == nil {
+		t.Error("expected purge error to be returned")
+	}
+	if _, err := ParseRetentionAction("archive"); !errors.Is(err, ErrInvalidRetentionAction) {
+		t.Errorf("expected ErrInvalidRetentionAction, got %v", err)
+	}
+}
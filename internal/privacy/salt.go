@@ -0,0 +1,99 @@
+package privacy
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// saltSize is the length of generated salts in bytes
+const saltSize = 32
+
+// saltTTL keeps a day's salt just long enough to cover clock skew between
+// instances; once it expires the day's hashes can no longer be linked to
+// addresses
+const saltTTL = 48 * time.Hour
+
+// SaltSource provides the secret salt for a UTC day (YYYY-MM-DD). Every
+// instance must see the same salt for a day so hashes are comparable.
+type SaltSource interface {
+	Salt(ctx context.Context, day string) ([]byte, error)
+}
+
+// RedisSalts shares daily salts between instances through Redis. The first
+// instance to ask for a day generates its salt; salts expire and are never
+// persisted elsewhere.
+type RedisSalts struct {
+	client redis.Cmdable
+	prefix string
+}
+
+// NewRedisSalts creates a Redis-backed salt source
+func NewRedisSalts(client redis.Cmdable) *RedisSalts {
+	return &RedisSalts{
+		client: client,
+		prefix: "privacy:salt:",
+	}
+}
+
+// Salt returns the salt for day, creating it if no instance has yet
+func (s *RedisSalts) Salt(ctx context.Context, day string) ([]byte, error) {
+	salt, err := newSalt()
+	if err != nil {
+		return nil, err
+	}
+
+	key := s.prefix + day
+	if err := s.client.SetNX(ctx, key, salt, saltTTL).Err(); err != nil {
+		return nil, fmt.Errorf("failed to store salt: %w", err)
+	}
+
+	stored, err := s.client.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get salt: %w", err)
+	}
+
+	return stored, nil
+}
+
+// LocalSalts generates daily salts in memory, for single-instance
+// deployments. Salts are lost on restart.
+type LocalSalts struct {
+	mu   sync.Mutex
+	day  string
+	salt []byte
+}
+
+// NewLocalSalts creates an in-memory salt source
+func NewLocalSalts() *LocalSalts {
+	return &LocalSalts{}
+}
+
+// Salt returns the salt for day, replacing the previous day's salt
+func (s *LocalSalts) Salt(ctx context.Context, day string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.day != day {
+		salt, err := newSalt()
+		if err != nil {
+			return nil, err
+		}
+		s.day, s.salt = day, salt
+	}
+
+	return s.salt, nil
+}
+
+// newSalt returns a random salt
+func newSalt() ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	return salt, nil
+}
@@ -0,0 +1,331 @@
+package qrcode
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/boombuler/barcode/qr"
+)
+
+// Format is the output image format
+type Format string
+
+const (
+	FormatPNG Format = "png"
+	FormatSVG Format = "svg"
+)
+
+const (
+	DefaultSize   = 256
+	MinSize       = 64
+	MaxSize       = 2048
+	DefaultMargin = 4
+	MaxMargin     = 16
+)
+
+// Options controls how a QR code is rendered
+type Options struct {
+	Format     Format
+	Size       int    // image width and height in pixels
+	Level      string // error correction level: L, M, Q or H
+	Margin     int    // quiet zone width in modules
+	Foreground color.NRGBA
+	Background color.NRGBA
+}
+
+// DefaultOptions returns black-on-white PNG options
+func DefaultOptions() Options {
+	return Options{
+		Format:     FormatPNG,
+		Size:       DefaultSize,
+		Level:      "M",
+		Margin:     DefaultMargin,
+		Foreground: color.NRGBA{A: 0xff},
+		Background: color.NRGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+// Validate checks that options are within supported bounds
+func (o Options) Validate() error {
+	if o.Format != FormatPNG && o.Format != FormatSVG {
+		return fmt.Errorf("%w: unsupported format %q", ErrInvalidOptions, o.Format)
+	}
+	if o.Size < MinSize || o.Size > MaxSize {
+		return fmt.Errorf("%w: size must be between %d and %d", ErrInvalidOptions, MinSize, MaxSize)
+	}
+	if _, err := parseLevel(o.Level); err != nil {
+		return err
+	}
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return fmt.Errorf("%w: margin must be between 0 and %d", ErrInvalidOptions, MaxMargin)
+	}
+	return nil
+}
+
+// ContentType returns the MIME type of the rendered image
+func (o Options) ContentType() string {
+	if o.Format == FormatSVG {
+		return "image/svg+xml"
+	}
+	return "image/png"
+}
+
+// cacheKey identifies a rendered image
+func (o Options) cacheKey(content string) string {
+	return fmt.Sprintf("%s|%d|%s|%d|%s|%s|%s",
+		o.Format, o.Size, o.Level, o.Margin, hexColor(o.Foreground), hexColor(o.Background), content)
+}
+
+// Generator renders QR codes and keeps recently generated images in memory
+type Generator struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List
+}
+
+// cacheEntry is a rendered image held in the LRU cache
+type cacheEntry struct {
+	key   string
+	image []byte
+}
+
+// NewGenerator creates a generator caching up to maxEntries images
+func NewGenerator(maxEntries int) *Generator {
+	if maxEntries <= 0 {
+		maxEntries = 256
+	}
+
+	return &Generator{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Generate renders content as a QR code, serving repeated requests from cache
+func (g *Generator) Generate(content string, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	key := opts.cacheKey(content)
+	if image, ok := g.get(key); ok {
+		return image, nil
+	}
+
+	image, err := Render(content, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	g.put(key, image)
+	return image, nil
+}
+
+// get returns a cached image and marks it as recently used
+func (g *Generator) get(key string) ([]byte, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	elem, ok := g.entries[key]
+	if !ok {
+		return nil, false
+	}
+
+	g.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).image, true
+}
+
+// put stores an image, evicting the least recently used entry when full
+func (g *Generator) put(key string, image []byte) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if elem, ok := g.entries[key]; ok {
+		g.order.MoveToFront(elem)
+		return
+	}
+
+	g.entries[key] = g.order.PushFront(&cacheEntry{key: key, image: image})
+
+	for g.order.Len() > g.maxEntries {
+		oldest := g.order.Back()
+		g.order.Remove(oldest)
+		delete(g.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Render encodes content and draws it in the requested format
+func Render(content string, opts Options) ([]byte, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	modules, err := encode(content, opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Format == FormatSVG {
+		return renderSVG(modules, opts), nil
+	}
+	return renderPNG(modules, opts)
+}
+
+// encode returns the module matrix for content, true meaning dark
+func encode(content, level string) ([][]bool, error) {
+	ecLevel, err := parseLevel(level)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := qr.Encode(content, ecLevel, qr.Auto)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %w", err)
+	}
+
+	size := code.Bounds().Dx()
+	modules := make([][]bool, size)
+	for y := 0; y < size; y++ {
+		modules[y] = make([]bool, size)
+		for x := 0; x < size; x++ {
+			r, g, b, _ := code.At(x, y).RGBA()
+			modules[y][x] = r+g+b < 3*0x8000
+		}
+	}
+
+	return modules, nil
+}
+
+// renderPNG draws the modules into a size x size paletted PNG, centring the
+// code when the size is not an exact multiple of the module count
+func renderPNG(modules [][]bool, opts Options) ([]byte, error) {
+	total := len(modules) + 2*opts.Margin
+	scale := opts.Size / total
+	if scale < 1 {
+		return nil, fmt.Errorf("%w: size %d is too small for %d modules", ErrInvalidOptions, opts.Size, total)
+	}
+	offset := (opts.Size-scale*total)/2 + opts.Margin*scale
+
+	img := image.NewPaletted(image.Rect(0, 0, opts.Size, opts.Size),
+		color.Palette{opts.Background, opts.Foreground})
+
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					img.SetColorIndex(offset+x*scale+px, offset+y*scale+py, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode PNG: %w", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// renderSVG draws the modules as a single path, merging horizontal runs
+func renderSVG(modules [][]bool, opts Options) []byte {
+	total := len(modules) + 2*opts.Margin
+
+	var path strings.Builder
+	for y, row := range modules {
+		for x := 0; x < len(row); x++ {
+			if !row[x] {
+				continue
+			}
+			start := x
+			for x < len(row) && row[x] {
+				x++
+			}
+			fmt.Fprintf(&path, "M%d %dh%dv1h-%dz", start+opts.Margin, y+opts.Margin, x-start, x-start)
+		}
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, `<?xml version="1.0" encoding="UTF-8"?>`+"\n")
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		opts.Size, opts.Size, total, total)
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" %s/>`, svgFill(opts.Background))
+	fmt.Fprintf(&buf, `<path d="%s" %s/>`, path.String(), svgFill(opts.Foreground))
+	buf.WriteString("</svg>\n")
+
+	return buf.Bytes()
+}
+
+// svgFill returns fill attributes for a color, including opacity if needed
+func svgFill(c color.NRGBA) string {
+	fill := fmt.Sprintf(`fill="#%02x%02x%02x"`, c.R, c.G, c.B)
+	if c.A != 0xff {
+		fill += fmt.Sprintf(` fill-opacity="%s"`, strconv.FormatFloat(float64(c.A)/255, 'f', 3, 64))
+	}
+	return fill
+}
+
+// parseLevel maps a level name to the encoder's error correction level
+func parseLevel(level string) (qr.ErrorCorrectionLevel, error) {
+	switch strings.ToUpper(level) {
+	case "L":
+		return qr.L, nil
+	case "M":
+		return qr.M, nil
+	case "Q":
+		return qr.Q, nil
+	case "H":
+		return qr.H, nil
+	}
+	return qr.M, fmt.Errorf("%w: error correction level must be one of L, M, Q, H", ErrInvalidOptions)
+}
+
+// ParseColor parses a hex color in #RGB, #RRGGBB or #RRGGBBAA form. The
+// alpha channel is straight, as in CSS, so the color is non-premultiplied.
+func ParseColor(s string) (color.NRGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) == 6 {
+		hex += "ff"
+	}
+	if len(hex) != 8 {
+		return color.NRGBA{}, fmt.Errorf("%w: invalid color %q", ErrInvalidOptions, s)
+	}
+
+	value, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.NRGBA{}, fmt.Errorf("%w: invalid color %q", ErrInvalidOptions, s)
+	}
+
+	return color.NRGBA{
+		R: uint8(value >> 24),
+		G: uint8(value >> 16),
+		B: uint8(value >> 8),
+		A: uint8(value),
+	}, nil
+}
+
+// hexColor formats a color as RRGGBBAA
+func hexColor(c color.NRGBA) string {
+	return fmt.Sprintf("%02x%02x%02x%02x", c.R, c.G, c.B, c.A)
+}
+
+// Custom errors
+var (
+	ErrInvalidOptions = fmt.Errorf("invalid QR code options")
+)
@@ -0,0 +1,164 @@
+package qrcode
+
+import (
+	"bytes"
+	"errors"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected color.NRGBA
+		wantErr  bool
+	}{
+		{"#000000", color.NRGBA{0, 0, 0, 255}, false},
+		{"ff8800", color.NRGBA{255, 136, 0, 255}, false},
+		{"#f80", color.NRGBA{255, 136, 0, 255}, false},
+		{"#ffffff00", color.NRGBA{255, 255, 255, 0}, false},
+		{"#ff880080", color.NRGBA{255, 136, 0, 128}, false},
+		{"#12345", color.NRGBA{}, true},
+		{"#gggggg", color.NRGBA{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseColor(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseColor(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.expected {
+				t.Errorf("ParseColor(%q) = %v, want %v", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Options)
+	}{
+		{"unknown format", func(o *Options) { o.Format = "gif" }},
+		{"too small", func(o *Options) { o.Size = MinSize - 1 }},
+		{"too large", func(o *Options) { o.Size = MaxSize + 1 }},
+		{"bad level", func(o *Options) { o.Level = "X" }},
+		{"negative margin", func(o *Options) { o.Margin = -1 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultOptions()
+			tt.modify(&opts)
+			if err := opts.Validate(); !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("expected ErrInvalidOptions, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRenderPNG(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Size = 300
+	opts.Foreground = color.NRGBA{R: 0x11, G: 0x22, B: 0x33, A: 0xff}
+
+	data, err := Render("http://localhost:8080/abc12345", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("output is not a valid PNG: %v", err)
+	}
+	if img.Bounds().Dx() != 300 || img.Bounds().Dy() != 300 {
+		t.Errorf("expected 300x300 image, got %v", img.Bounds())
+	}
+
+	// The top-left corner lies in the quiet zone
+	if got := color.NRGBAModel.Convert(img.At(0, 0)); got != opts.Background {
+		t.Errorf("expected background in quiet zone, got %v", got)
+	}
+
+	// The first module of the finder pattern is dark
+	modules, _ := encode("http://localhost:8080/abc12345", opts.Level)
+	total := len(modules) + 2*opts.Margin
+	scale := opts.Size / total
+	corner := (opts.Size-scale*total)/2 + opts.Margin*scale
+	if got := color.NRGBAModel.Convert(img.At(corner, corner)); got != opts.Foreground {
+		t.Errorf("expected foreground at finder pattern, got %v", got)
+	}
+}
+
+func TestRenderPNGTranslucentColors(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Foreground, _ = ParseColor("#ff880080")
+	opts.Background, _ = ParseColor("#ffffff40")
+
+	data, err := Render("http://localhost:8080/abc12345", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("output is not a valid PNG: %v", err)
+	}
+
+	if got := color.NRGBAModel.Convert(img.At(0, 0)); got != opts.Background {
+		t.Errorf("expected background %v, got %v", opts.Background, got)
+	}
+	modules, _ := encode("http://localhost:8080/abc12345", opts.Level)
+	total := len(modules) + 2*opts.Margin
+	scale := opts.Size / total
+	corner := (opts.Size-scale*total)/2 + opts.Margin*scale
+	if got := color.NRGBAModel.Convert(img.At(corner, corner)); got != opts.Foreground {
+		t.Errorf("expected foreground %v, got %v", opts.Foreground, got)
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Format = FormatSVG
+	opts.Background = color.NRGBA{R: 0xff, G: 0xff, B: 0xff}
+
+	data, err := Render("http://localhost:8080/abc12345", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	svg := string(data)
+	if !strings.Contains(svg, "<svg") || !strings.Contains(svg, "<path d=\"M") {
+		t.Errorf("expected an SVG with a path, got %s", svg)
+	}
+	if !strings.Contains(svg, `fill-opacity="0.000"`) {
+		t.Errorf("expected transparent background, got %s", svg)
+	}
+}
+
+func TestGeneratorCachesByOptions(t *testing.T) {
+	gen := NewGenerator(2)
+	opts := DefaultOptions()
+
+	first, err := gen.Generate("http://localhost:8080/a", opts)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	second, _ := gen.Generate("http://localhost:8080/a", opts)
+	if &first[0] != &second[0] {
+		t.Error("expected identical request to be served from cache")
+	}
+
+	opts.Level = "H"
+	third, _ := gen.Generate("http://localhost:8080/a", opts)
+	if bytes.Equal(first, third) {
+		t.Error("expected different error correction level to produce a different image")
+	}
+
+	gen.Generate("http://localhost:8080/b", opts)
+	if gen.order.Len() != 2 {
+		t.Errorf("expected cache to be bounded at 2 entries, got %d", gen.order.Len())
+	}
+}
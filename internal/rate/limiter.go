@@ -3,21 +3,50 @@ package rate
 import (
 	"context"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/netutil"
 	"golang.org/x/time/rate"
 )
 
-// Limiter provides rate limiting functionality
+// RateLimiter decides whether a client may make a request. Limiter keeps
+// its buckets in process memory; RedisLimiter shares them between instances.
+type RateLimiter interface {
+	// Allow reports whether a request from ip may proceed now
+	Allow(ip string) bool
+	// Take is Allow, also reporting the state of the client's buckets
+	Take(ip string) Decision
+	// Wait blocks until a request from ip may proceed or ctx is done
+	Wait(ctx context.Context, ip string) error
+	// Close releases background resources
+	Close()
+}
+
+// Decision is the outcome of a rate limit check. Limit, Remaining and
+// Reset describe the bucket closest to running out; Limit is zero when no
+// limit applies.
+type Decision struct {
+	Allowed    bool
+	Limit      int           // requests allowed in a burst
+	Remaining  int           // requests left in the burst
+	Reset      time.Duration // until the bucket is full again
+	RetryAfter time.Duration // until a rejected request would be allowed
+}
+
+// Limiter provides process-local rate limiting
 type Limiter struct {
 	globalLimiter *rate.Limiter
-	ipLimiters    map[string]*rate.Limiter
-	mu            sync.RWMutex
-	cleanupTicker *time.Ticker
+	ipLimiters    *keyStore
 	config        Config
+	now           func() time.Time
+	done          chan struct{}
+	closeOnce     sync.Once
 }
 
 // Config holds rate limiting configuration
@@ -26,74 +55,134 @@ type Config struct {
 	PerIPRPS   int           `json:"per_ip_rps"`
 	BurstSize  int           `json:"burst_size"`
 	WindowSize time.Duration `json:"window_size"`
+	// IdleTimeout is how long a per-IP bucket is kept after its last
+	// request. It is raised to the time a bucket takes to refill, so
+	// evicting a bucket never resets a client's limit.
+	IdleTimeout time.Duration `json:"idle_timeout"`
+	// MaxKeys caps the number of per-IP buckets; the least recently used
+	// are evicted first
+	MaxKeys int `json:"max_keys"`
 }
 
 // NewLimiter creates a new rate limiter
 func NewLimiter(config Config) *Limiter {
+	if config.BurstSize <= 0 {
+		config.BurstSize = 1
+	}
+	if config.IdleTimeout <= 0 {
+		config.IdleTimeout = 10 * time.Minute
+	}
+	if config.PerIPRPS > 0 {
+		refill := time.Duration(float64(config.BurstSize) / float64(config.PerIPRPS) * float64(time.Second))
+		if config.IdleTimeout < refill {
+			config.IdleTimeout = refill
+		}
+	}
+	if config.MaxKeys <= 0 {
+		config.MaxKeys = 100000
+	}
+
 	limiter := &Limiter{
-		globalLimiter: rate.NewLimiter(rate.Limit(config.GlobalRPS), config.BurstSize),
-		ipLimiters:    make(map[string]*rate.Limiter),
+		globalLimiter: rate.NewLimiter(limit(config.GlobalRPS), config.BurstSize),
 		config:        config,
+		now:           time.Now,
+		done:          make(chan struct{}),
 	}
+	limiter.ipLimiters = newKeyStore(config.MaxKeys, func() *rate.Limiter {
+		return rate.NewLimiter(limit(config.PerIPRPS), config.BurstSize)
+	})
 
 	// Start cleanup goroutine
-	limiter.cleanupTicker = time.NewTicker(time.Minute)
 	go limiter.cleanup()
 
 	return limiter
 }
 
-// cleanup removes old IP limiters to prevent memory leaks
+// cleanup periodically removes idle IP limiters to bound memory use
 func (l *Limiter) cleanup() {
-	for range l.cleanupTicker.C {
-		l.mu.Lock()
-			// Remove limiters that haven't been used in the last 10 minutes
-	cutoff := time.Now().Add(-10 * time.Minute)
-	for ip := range l.ipLimiters {
-		// This is a simplified cleanup - in production you might want to track last access
-		if time.Since(cutoff) > 0 {
-			delete(l.ipLimiters, ip)
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.done:
+			return
+		case <-ticker.C:
+			l.evictIdle()
 		}
 	}
-		l.mu.Unlock()
-	}
+}
+
+// evictIdle removes IP limiters that have not been used for the idle timeout
+func (l *Limiter) evictIdle() int {
+	return l.ipLimiters.evictIdle(l.now().Add(-l.config.IdleTimeout))
 }
 
 // getIPLimiter gets or creates a rate limiter for a specific IP
 func (l *Limiter) getIPLimiter(ip string) *rate.Limiter {
-	l.mu.RLock()
-	limiter, exists := l.ipLimiters[ip]
-	l.mu.RUnlock()
+	return l.ipLimiters.get(ip, l.now())
+}
 
-	if exists {
-		return limiter
+// limit converts a configured rate; zero or less means unlimited
+func limit(rps int) rate.Limit {
+	if rps <= 0 {
+		return rate.Inf
 	}
+	return rate.Limit(rps)
+}
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+// Allow checks if a request is allowed
+func (l *Limiter) Allow(ip string) bool {
+	return l.Take(ip).Allowed
+}
 
-	// Double-check after acquiring write lock
-	if limiter, exists = l.ipLimiters[ip]; exists {
-		return limiter
+// Take checks if a request is allowed. A request is only counted against
+// the global and per-IP limits if both allow it.
+func (l *Limiter) Take(ip string) Decision {
+	limiters := make([]*rate.Limiter, 0, 2)
+	if l.config.GlobalRPS > 0 {
+		limiters = append(limiters, l.globalLimiter)
 	}
-
-	// Create new limiter for this IP
-	limiter = rate.NewLimiter(rate.Limit(l.config.PerIPRPS), l.config.BurstSize)
-	l.ipLimiters[ip] = limiter
-
-	return limiter
+	if l.config.PerIPRPS > 0 {
+		limiters = append(limiters, l.getIPLimiter(ip))
+	}
+	return take(l.now(), limiters)
 }
 
-// Allow checks if a request is allowed
-func (l *Limiter) Allow(ip string) bool {
-	// Check global rate limit first
-	if !l.globalLimiter.Allow() {
-		return false
+// take reserves a token from every limiter, cancelling the reservations
+// unless all of them are available now
+func take(now time.Time, limiters []*rate.Limiter) Decision {
+	decision := Decision{Allowed: true}
+
+	reservations := make([]*rate.Reservation, 0, len(limiters))
+	for _, limiter := range limiters {
+		reservation := limiter.ReserveN(now, 1)
+		reservations = append(reservations, reservation)
+		if delay := reservation.DelayFrom(now); !reservation.OK() || delay > 0 {
+			decision.Allowed = false
+			if delay > decision.RetryAfter {
+				decision.RetryAfter = delay
+			}
+		}
+	}
+	if !decision.Allowed {
+		for _, reservation := range reservations {
+			reservation.CancelAt(now)
+		}
 	}
 
-	// Check per-IP rate limit
-	ipLimiter := l.getIPLimiter(ip)
-	return ipLimiter.Allow()
+	for i, limiter := range limiters {
+		tokens := limiter.TokensAt(now)
+		remaining := int(math.Max(0, math.Floor(tokens)))
+		if i > 0 && remaining >= decision.Remaining {
+			continue
+		}
+		decision.Limit = limiter.Burst()
+		decision.Remaining = remaining
+		decision.Reset = time.Duration((float64(limiter.Burst()) - tokens) / float64(limiter.Limit()) * float64(time.Second))
+	}
+
+	return decision
 }
 
 // Wait waits for a request to be allowed
@@ -104,6 +193,9 @@ func (l *Limiter) Wait(ctx context.Context, ip string) error {
 	}
 
 	// Wait for per-IP rate limit
+	if l.config.PerIPRPS <= 0 {
+		return nil
+	}
 	ipLimiter := l.getIPLimiter(ip)
 	if err := ipLimiter.Wait(ctx); err != nil {
 		return fmt.Errorf("per-IP rate limit wait failed: %w", err)
@@ -114,23 +206,23 @@ func (l *Limiter) Wait(ctx context.Context, ip string) error {
 
 // Close stops the cleanup goroutine
 func (l *Limiter) Close() {
-	if l.cleanupTicker != nil {
-		l.cleanupTicker.Stop()
-	}
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
 }
 
 // RateLimitMiddleware creates a Gin middleware for rate limiting
-func RateLimitMiddleware(limiter *Limiter) gin.HandlerFunc {
+func RateLimitMiddleware(limiter RateLimiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := getClientIP(c)
+		ip := netutil.ClientIP(c)
 
 		// Check if request is allowed
-		if !limiter.Allow(ip) {
-			c.JSON(http.StatusTooManyRequests, gin.H{
-				"error":   "rate_limit_exceeded",
-				"message": "Too many requests, please try again later",
-			})
-			c.Abort()
+		decision := limiter.Take(ip)
+		writeHeaders(c, decision)
+		if !decision.Allowed {
+			c.Header("Content-Type", models.ProblemContentType)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests,
+				models.NewProblem(http.StatusTooManyRequests, "rate_limit_exceeded", "Too many requests, please try again later"))
 			return
 		}
 
@@ -139,17 +231,15 @@ func RateLimitMiddleware(limiter *Limiter) gin.HandlerFunc {
 }
 
 // RateLimitWaitMiddleware creates a Gin middleware that waits for rate limits
-func RateLimitWaitMiddleware(limiter *Limiter) gin.HandlerFunc {
+func RateLimitWaitMiddleware(limiter RateLimiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := getClientIP(c)
+		ip := netutil.ClientIP(c)
 
 		// Wait for rate limit
 		if err := limiter.Wait(c.Request.Context(), ip); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "rate_limit_error",
-				"message": "Rate limiting error occurred",
-			})
-			c.Abort()
+			c.Header("Content-Type", models.ProblemContentType)
+			c.AbortWithStatusJSON(http.StatusInternalServerError,
+				models.NewProblem(http.StatusInternalServerError, "rate_limit_error", "Rate limiting error occurred"))
 			return
 		}
 
@@ -157,39 +247,39 @@ func RateLimitWaitMiddleware(limiter *Limiter) gin.HandlerFunc {
 	}
 }
 
-// getClientIP extracts the client IP address from the request
-func getClientIP(c *gin.Context) string {
-	// Check X-Forwarded-For header first (for proxy scenarios)
-	if forwardedFor := c.GetHeader("X-Forwarded-For"); forwardedFor != "" {
-		// Take the first IP in the chain
-		if idx := len(forwardedFor); idx > 0 {
-			if commaIdx := len(forwardedFor); commaIdx > 0 {
-				forwardedFor = forwardedFor[:commaIdx]
-			}
-		}
-		return forwardedFor
+// writeHeaders sets the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers of the IETF rate limit headers draft, and
+// Retry-After on rejected requests. Times are in whole seconds, rounded up.
+func writeHeaders(c *gin.Context, decision Decision) {
+	if decision.Limit > 0 {
+		c.Header("RateLimit-Limit", strconv.Itoa(decision.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+		c.Header("RateLimit-Reset", strconv.FormatInt(seconds(decision.Reset), 10))
 	}
-
-	// Check X-Real-IP header
-	if realIP := c.GetHeader("X-Real-IP"); realIP != "" {
-		return realIP
+	if !decision.Allowed {
+		retryAfter := seconds(decision.RetryAfter)
+		if retryAfter < 1 {
+			retryAfter = 1
+		}
+		c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
 	}
+}
 
-	// Fallback to remote address
-	return c.ClientIP()
+// seconds rounds a duration up to whole seconds
+func seconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
 }
 
 // GetStats returns rate limiting statistics
 func (l *Limiter) GetStats() map[string]interface{} {
-	l.mu.RLock()
-	defer l.mu.RUnlock()
-
 	stats := make(map[string]interface{})
 	stats["global_rps"] = l.config.GlobalRPS
 	stats["per_ip_rps"] = l.config.PerIPRPS
 	stats["burst_size"] = l.config.BurstSize
 	stats["window_size"] = l.config.WindowSize
-	stats["active_ip_limiters"] = len(l.ipLimiters)
+	stats["idle_timeout"] = l.config.IdleTimeout
+	stats["max_keys"] = l.config.MaxKeys
+	stats["active_ip_limiters"] = l.ipLimiters.len()
 
 	return stats
 }
@@ -0,0 +1,129 @@
+package rate
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestLimiterTakeReportsRemaining(t *testing.T) {
+	limiter := NewLimiter(Config{GlobalRPS: 1, PerIPRPS: 1, BurstSize: 3})
+	defer limiter.Close()
+
+	for i, remaining := range []int{2, 1, 0} {
+		decision := limiter.Take("203.0.113.7")
+		if !decision.Allowed || decision.Limit != 3 || decision.Remaining != remaining {
+			t.Errorf("request %d: unexpected decision %+v", i, decision)
+		}
+	}
+
+	decision := limiter.Take("203.0.113.7")
+	if decision.Allowed || decision.Remaining != 0 {
+		t.Errorf("expected the request to be rejected, got %+v", decision)
+	}
+	if decision.RetryAfter <= 0 || decision.RetryAfter > time.Second {
+		t.Errorf("expected to retry within a second, got %v", decision.RetryAfter)
+	}
+	if decision.Reset <= 2*time.Second || decision.Reset > 3*time.Second {
+		t.Errorf("expected the bucket to refill within 3 seconds, got %v", decision.Reset)
+	}
+}
+
+func TestLimiterTakeOnlyCountsAllowedRequests(t *testing.T) {
+	limiter := NewLimiter(Config{GlobalRPS: 1, PerIPRPS: 1, BurstSize: 2})
+	defer limiter.Close()
+
+	limiter.Take("203.0.113.7")
+	limiter.Take("198.51.100.1")
+	// Rejected by the global limit, so the per-IP token is returned
+	if limiter.Take("203.0.113.7").Allowed {
+		t.Fatal("expected the request to be rejected")
+	}
+
+	if tokens := limiter.getIPLimiter("203.0.113.7").Tokens(); tokens < 1 {
+		t.Errorf("expected the rejected request not to be counted, got %.2f tokens left", tokens)
+	}
+}
+
+func TestLimiterWithoutLimits(t *testing.T) {
+	limiter := NewLimiter(Config{})
+	defer limiter.Close()
+
+	for i := 0; i < 100; i++ {
+		if decision := limiter.Take("203.0.113.7"); !decision.Allowed || decision.Limit != 0 {
+			t.Fatalf("expected unlimited requests, got %+v", decision)
+		}
+	}
+}
+
+func TestLimiterKeepsActiveBucketsOnCleanup(t *testing.T) {
+	limiter := NewLimiter(Config{PerIPRPS: 1, BurstSize: 3, IdleTimeout: time.Minute})
+	defer limiter.Close()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		limiter.Take("203.0.113.7")
+	}
+	limiter.Take("198.51.100.1")
+
+	now = now.Add(30 * time.Second)
+	limiter.Take("203.0.113.7")
+	now = now.Add(45 * time.Second)
+
+	// Only the client idle for longer than the timeout is evicted
+	if evicted := limiter.evictIdle(); evicted != 1 {
+		t.Errorf("expected 1 idle bucket to be evicted, got %d", evicted)
+	}
+	if n := limiter.ipLimiters.len(); n != 1 {
+		t.Errorf("expected 1 bucket to be kept, got %d", n)
+	}
+
+	// The kept bucket was not reset: it refilled for 45s from empty
+	if decision := limiter.Take("203.0.113.7"); decision.Remaining != 2 {
+		t.Errorf("expected the bucket to be kept, got %+v", decision)
+	}
+}
+
+func TestLimiterIdleTimeoutCoversRefill(t *testing.T) {
+	limiter := NewLimiter(Config{PerIPRPS: 1, BurstSize: 120, IdleTimeout: time.Minute})
+	defer limiter.Close()
+
+	if limiter.config.IdleTimeout != 2*time.Minute {
+		t.Errorf("expected the idle timeout to be raised to the refill time, got %v", limiter.config.IdleTimeout)
+	}
+}
+
+func TestKeyStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := newKeyStore(2*storeShards, func() *rate.Limiter { return rate.NewLimiter(1, 1) })
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	// Find three keys that share a shard, which holds two
+	var keys []string
+	for i := 0; len(keys) < 3; i++ {
+		key := fmt.Sprintf("198.51.100.%d", i)
+		if len(keys) == 0 || store.shard(key) == store.shard(keys[0]) {
+			keys = append(keys, key)
+		}
+	}
+
+	first := store.get(keys[0], now)
+	store.get(keys[1], now)
+	if store.get(keys[0], now) != first {
+		t.Fatal("expected the same bucket for a key")
+	}
+	store.get(keys[2], now)
+
+	shard := store.shard(keys[0])
+	if _, ok := shard.entries[keys[1]]; ok {
+		t.Error("expected the least recently used key to be evicted")
+	}
+	if _, ok := shard.entries[keys[0]]; !ok {
+		t.Error("expected the recently used key to be kept")
+	}
+	if n := store.len(); n != 2 {
+		t.Errorf("expected 2 keys, got %d", n)
+	}
+}
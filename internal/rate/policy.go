@@ -0,0 +1,367 @@
+package rate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/netutil"
+)
+
+// Policy limits a group of routes
+type Policy struct {
+	Name string
+	// Routes lists "[METHOD ]path" patterns, where path is a route as
+	// registered with gin ("/:code") or a prefix ending in "/*"
+	Routes    []string
+	GlobalRPS int // all anonymous clients together; principals with a plan share another budget
+	PerIPRPS  int // each anonymous client IP
+	BurstSize int
+}
+
+// Plan limits an authenticated principal across all routes
+type Plan struct {
+	RPS        int
+	BurstSize  int
+	DailyQuota int64 // requests per UTC day; zero or less is unlimited
+}
+
+// Principal is an authenticated client
+type Principal struct {
+	ID   string
+	Plan string
+}
+
+// PolicyConfig holds rate limit policy configuration
+type PolicyConfig struct {
+	Default     Policy   // applies to routes no other policy matches
+	Policies    []Policy // the first policy with a matching route applies
+	Plans       map[string]Plan
+	DefaultPlan string   // plan of principals without one
+	ExemptPaths []string // request paths that are never limited
+}
+
+// Identifier returns the principal that made a request, if any
+type Identifier func(c *gin.Context) (Principal, bool)
+
+// LimiterFactory creates the limiter of a policy or plan. The name is
+// unique per limiter, so it can be used to namespace shared buckets.
+type LimiterFactory func(name string, config Config) RateLimiter
+
+// route is a compiled route pattern
+type route struct {
+	method string
+	path   string
+	prefix bool
+}
+
+// policyLimiter holds the limiters of one policy. The client limiter holds
+// both the global and the per-client buckets, so a request only counts
+// against them if both allow it; the global limiter applies the global
+// limit to principals with a plan.
+type policyLimiter struct {
+	name   string
+	routes []route
+	global RateLimiter
+	client RateLimiter
+}
+
+// planLimiter holds the limiter of one plan
+type planLimiter struct {
+	plan    Plan
+	limiter RateLimiter
+}
+
+// PolicyLimiter applies per-route policies to anonymous clients, keyed by
+// IP, and plan limits and daily quotas to authenticated principals. A
+// route's global limit applies to both, with separate budgets, so a flood
+// of anonymous requests does not lock out principals.
+type PolicyLimiter struct {
+	defaultPolicy *policyLimiter
+	policies      []*policyLimiter
+	plans         map[string]*planLimiter
+	defaultPlan   string
+	exempt        map[string]bool
+	quotas        QuotaCounter
+	identify      Identifier
+	now           func() time.Time
+}
+
+// NewPolicyLimiter creates a policy limiter
+func NewPolicyLimiter(config PolicyConfig, newLimiter LimiterFactory, quotas QuotaCounter, identify Identifier) (*PolicyLimiter, error) {
+	if identify == nil {
+		identify = func(*gin.Context) (Principal, bool) { return Principal{}, false }
+	}
+
+	p := &PolicyLimiter{
+		plans:       make(map[string]*planLimiter),
+		defaultPlan: config.DefaultPlan,
+		exempt:      make(map[string]bool),
+		quotas:      quotas,
+		identify:    identify,
+		now:         time.Now,
+	}
+
+	for _, path := range config.ExemptPaths {
+		p.exempt[path] = true
+	}
+
+	config.Default.Name = "default"
+	p.defaultPolicy = newPolicyLimiter(config.Default, newLimiter)
+	names := map[string]bool{"default": true}
+	for _, policy := range config.Policies {
+		if policy.Name == "" || names[policy.Name] {
+			return nil, fmt.Errorf("%w: policy name %q is empty or not unique", ErrInvalidPolicy, policy.Name)
+		}
+		names[policy.Name] = true
+
+		compiled := newPolicyLimiter(policy, newLimiter)
+		for _, pattern := range policy.Routes {
+			r, err := parseRoute(pattern)
+			if err != nil {
+				return nil, err
+			}
+			compiled.routes = append(compiled.routes, r)
+		}
+		p.policies = append(p.policies, compiled)
+	}
+
+	for name, plan := range config.Plans {
+		if plan.BurstSize <= 0 {
+			plan.BurstSize = plan.RPS
+		}
+		p.plans[name] = &planLimiter{
+			plan:    plan,
+			limiter: newLimiter("plan:"+name, Config{PerIPRPS: plan.RPS, BurstSize: plan.BurstSize}),
+		}
+	}
+	if p.defaultPlan != "" && p.plans[p.defaultPlan] == nil {
+		return nil, fmt.Errorf("%w: default plan %q is not defined", ErrInvalidPolicy, p.defaultPlan)
+	}
+
+	return p, nil
+}
+
+// newPolicyLimiter creates the limiters of a policy
+func newPolicyLimiter(policy Policy, newLimiter LimiterFactory) *policyLimiter {
+	name := "policy:" + policy.Name
+	return &policyLimiter{
+		name:   policy.Name,
+		global: newLimiter(name+":global", Config{GlobalRPS: policy.GlobalRPS, BurstSize: policy.BurstSize}),
+		client: newLimiter(name, Config{GlobalRPS: policy.GlobalRPS, PerIPRPS: policy.PerIPRPS, BurstSize: policy.BurstSize}),
+	}
+}
+
+// parseRoute compiles a "[METHOD ]path" pattern
+func parseRoute(pattern string) (route, error) {
+	var r route
+	fields := strings.Fields(pattern)
+	switch len(fields) {
+	case 1:
+		r.path = fields[0]
+	case 2:
+		r.method, r.path = strings.ToUpper(fields[0]), fields[1]
+	default:
+		return r, fmt.Errorf("%w: invalid route %q", ErrInvalidPolicy, pattern)
+	}
+	if !strings.HasPrefix(r.path, "/") {
+		return r, fmt.Errorf("%w: route %q must start with /", ErrInvalidPolicy, pattern)
+	}
+	if strings.HasSuffix(r.path, "/*") {
+		r.path, r.prefix = strings.TrimSuffix(r.path, "*"), true
+	}
+	return r, nil
+}
+
+// match reports whether the route pattern matches a request
+func (r route) match(method, path string) bool {
+	if r.method != "" && r.method != method {
+		return false
+	}
+	if r.prefix {
+		return strings.HasPrefix(path, r.path)
+	}
+	return path == r.path
+}
+
+// policyFor returns the policy of a request. Routes are matched against
+// the route pattern, so "/:code" covers every redirect; requests that match
+// no registered route are matched by their path.
+func (p *PolicyLimiter) policyFor(c *gin.Context) *policyLimiter {
+	path := c.FullPath()
+	if path == "" {
+		path = c.Request.URL.Path
+	}
+	for _, policy := range p.policies {
+		for _, r := range policy.routes {
+			if r.match(c.Request.Method, path) {
+				return policy
+			}
+		}
+	}
+	return p.defaultPolicy
+}
+
+// check applies the request's policy and plan. The decision describes the
+// limit closest to running out; quota reports whether a rejected request
+// exceeded its daily quota rather than a rate limit. A rejected request
+// does not count against the limits or quota that allowed it, except that
+// a principal over its plan's rate still counts against the global limit.
+func (p *PolicyLimiter) check(c *gin.Context) (decision Decision, quota bool) {
+	policy := p.policyFor(c)
+
+	principal, authenticated := p.identify(c)
+	var plan *planLimiter
+	if authenticated {
+		plan = p.plans[principal.Plan]
+		if plan == nil {
+			plan = p.plans[p.defaultPlan]
+		}
+	}
+	if plan == nil {
+		key := netutil.ClientIP(c)
+		if authenticated {
+			// Without plans, principals get the route's per-client limit
+			key = "principal:" + principal.ID
+		}
+		return policy.client.Take(key), false
+	}
+
+	hasQuota := plan.plan.DailyQuota > 0 && p.quotas != nil
+	now := p.now().UTC()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	resetAt := day.AddDate(0, 0, 1)
+	quotaKey := principal.ID + ":" + day.Format("2006-01-02")
+	exceeded := Decision{
+		Limit:      int(plan.plan.DailyQuota),
+		Reset:      resetAt.Sub(now),
+		RetryAfter: resetAt.Sub(now),
+	}
+
+	// Requests over the quota are rejected before they take any tokens.
+	// Quotas fail open; the rate limits still apply.
+	if hasQuota {
+		if count, err := p.quotas.Count(c.Request.Context(), quotaKey); err == nil && count >= plan.plan.DailyQuota {
+			return exceeded, true
+		}
+	}
+
+	decision = policy.global.Take("")
+	if !decision.Allowed {
+		return decision, false
+	}
+	decision = tighter(decision, plan.limiter.Take(principal.ID))
+	if !decision.Allowed || !hasQuota {
+		return decision, false
+	}
+
+	count, err := p.quotas.Increment(c.Request.Context(), quotaKey, resetAt)
+	if err != nil {
+		return decision, false
+	}
+
+	remaining := plan.plan.DailyQuota - count
+	if remaining < 0 {
+		// Concurrent requests raced past the check above
+		return exceeded, true
+	}
+	return tighter(decision, Decision{
+		Allowed:   true,
+		Limit:     int(plan.plan.DailyQuota),
+		Remaining: int(remaining),
+		Reset:     resetAt.Sub(now),
+	}), false
+}
+
+// tighter combines two decisions, describing the limit with fewer requests
+// remaining. The request is only allowed if both allow it.
+func tighter(a, b Decision) Decision {
+	result := a
+	if b.Limit > 0 && (a.Limit == 0 || b.Remaining < a.Remaining) {
+		result.Limit, result.Remaining, result.Reset = b.Limit, b.Remaining, b.Reset
+	}
+	result.Allowed = a.Allowed && b.Allowed
+	if b.RetryAfter > result.RetryAfter {
+		result.RetryAfter = b.RetryAfter
+	}
+	return result
+}
+
+// Middleware creates a Gin middleware that applies the policies and sets
+// rate limit headers on every response
+func (p *PolicyLimiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p.exempt[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		decision, quota := p.check(c)
+		writeHeaders(c, decision)
+		if !decision.Allowed {
+			code, message := "rate_limit_exceeded", "Too many requests, please try again later"
+			if quota {
+				code, message = "quota_exceeded", "Daily request quota exceeded"
+			}
+			c.Header("Content-Type", models.ProblemContentType)
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, models.NewProblem(http.StatusTooManyRequests, code, message))
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Close releases every policy and plan limiter
+func (p *PolicyLimiter) Close() {
+	p.defaultPolicy.global.Close()
+	p.defaultPolicy.client.Close()
+	for _, policy := range p.policies {
+		policy.global.Close()
+		policy.client.Close()
+	}
+	for _, plan := range p.plans {
+		plan.limiter.Close()
+	}
+}
+
+// APIKeyIdentifier identifies principals by the X-API-Key header. With
+// trustUserHeader set, requests without a key are identified by X-User-ID
+// and get the default plan; only enable it behind a gateway that sets the
+// header after authenticating the user.
+func APIKeyIdentifier(keys map[string]Principal, trustUserHeader bool) Identifier {
+	// Keys are looked up by hash so lookups do not leak key prefixes through timing
+	hashed := make(map[string]Principal, len(keys))
+	for key, principal := range keys {
+		hashed[hashKey(key)] = principal
+	}
+
+	return func(c *gin.Context) (Principal, bool) {
+		if key := c.GetHeader("X-API-Key"); key != "" {
+			principal, ok := hashed[hashKey(key)]
+			return principal, ok
+		}
+		if trustUserHeader {
+			if user := c.GetHeader("X-User-ID"); user != "" {
+				return Principal{ID: "user:" + user}, true
+			}
+		}
+		return Principal{}, false
+	}
+}
+
+// hashKey returns the SHA-256 of an API key
+func hashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Custom errors
+var (
+	ErrInvalidPolicy = fmt.Errorf("invalid rate limit policy")
+)
@@ -0,0 +1,298 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// countingLimiter allows a fixed number of requests per key, or any number
+// if allow is negative
+type countingLimiter struct {
+	allow int
+	seen  map[string]int
+}
+
+func (l *countingLimiter) Allow(key string) bool {
+	return l.Take(key).Allowed
+}
+
+func (l *countingLimiter) Take(key string) Decision {
+	l.seen[key]++
+	if l.allow < 0 {
+		return Decision{Allowed: true}
+	}
+	remaining := l.allow - l.seen[key]
+	if remaining < 0 {
+		return Decision{Limit: l.allow, Reset: time.Second, RetryAfter: 1500 * time.Millisecond}
+	}
+	return Decision{Allowed: true, Limit: l.allow, Remaining: remaining, Reset: time.Second}
+}
+
+func (l *countingLimiter) Wait(ctx context.Context, key string) error { return nil }
+
+func (l *countingLimiter) Close() {}
+
+// newTestPolicyLimiter creates a policy limiter whose limiters allow as many
+// requests per key as their configured burst
+func newTestPolicyLimiter(t *testing.T, config PolicyConfig, identify Identifier) (*PolicyLimiter, map[string]*countingLimiter) {
+	limiters := make(map[string]*countingLimiter)
+	factory := func(name string, config Config) RateLimiter {
+		limiter := &countingLimiter{allow: config.BurstSize, seen: make(map[string]int)}
+		if config.GlobalRPS <= 0 && config.PerIPRPS <= 0 {
+			limiter.allow = -1
+		}
+		limiters[name] = limiter
+		return limiter
+	}
+
+	policies, err := NewPolicyLimiter(config, factory, NewMemoryQuota(), identify)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return policies, limiters
+}
+
+func newTestRouter(policies *PolicyLimiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(policies.Middleware())
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/api/v1/healthz", ok)
+	router.POST("/api/v1/shorten", ok)
+	router.POST("/api/v1/admin/cleanup", ok)
+	router.GET("/:code", ok)
+	return router
+}
+
+func request(router *gin.Engine, method, path string, header http.Header) int {
+	return serve(router, method, path, header).Code
+}
+
+func serve(router *gin.Engine, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = "203.0.113.7:4321"
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestPolicyLimiterAppliesRoutePolicies(t *testing.T) {
+	policies, limiters := newTestPolicyLimiter(t, PolicyConfig{
+		Default: Policy{PerIPRPS: 1, BurstSize: 1},
+		Policies: []Policy{
+			{Name: "redirect", Routes: []string{"GET /:code"}, PerIPRPS: 1, BurstSize: 3},
+			{Name: "admin", Routes: []string{"/api/v1/admin/*"}, PerIPRPS: 1, BurstSize: 2},
+		},
+		ExemptPaths: []string{"/api/v1/healthz"},
+	}, nil)
+	router := newTestRouter(policies)
+
+	for i, expected := range []int{200, 200, 200, 429} {
+		if code := request(router, "GET", "/abc"+string(rune('a'+i)), nil); code != expected {
+			t.Errorf("redirect %d: expected %d, got %d", i, expected, code)
+		}
+	}
+	for i, expected := range []int{200, 200, 429} {
+		if code := request(router, "POST", "/api/v1/admin/cleanup", nil); code != expected {
+			t.Errorf("admin request %d: expected %d, got %d", i, expected, code)
+		}
+	}
+	for i, expected := range []int{200, 429} {
+		if code := request(router, "POST", "/api/v1/shorten", nil); code != expected {
+			t.Errorf("create request %d: expected %d, got %d", i, expected, code)
+		}
+	}
+	// Unknown paths fall under the default policy, which is used up
+	if code := request(router, "GET", "/api/v1/unknown/path", nil); code != http.StatusTooManyRequests {
+		t.Errorf("expected the default policy to apply, got %d", code)
+	}
+
+	for i := 0; i < 5; i++ {
+		if code := request(router, "GET", "/api/v1/healthz", nil); code != http.StatusOK {
+			t.Errorf("expected exempt path to be allowed, got %d", code)
+		}
+	}
+
+	if limiters["policy:redirect"].seen["203.0.113.7"] != 4 {
+		t.Errorf("expected redirects to be keyed by client IP, got %v", limiters["policy:redirect"].seen)
+	}
+}
+
+func TestPolicyLimiterKeysPrincipalsByPlan(t *testing.T) {
+	identify := APIKeyIdentifier(map[string]Principal{
+		"secret-pro": {ID: "acme", Plan: "pro"},
+	}, true)
+	policies, limiters := newTestPolicyLimiter(t, PolicyConfig{
+		Default: Policy{GlobalRPS: 100, PerIPRPS: 1, BurstSize: 100},
+		Plans: map[string]Plan{
+			"free": {RPS: 1, BurstSize: 1},
+			"pro":  {RPS: 10, BurstSize: 3},
+		},
+		DefaultPlan: "free",
+	}, identify)
+	router := newTestRouter(policies)
+
+	pro := http.Header{"X-Api-Key": {"secret-pro"}}
+	for i, expected := range []int{200, 200, 200, 429} {
+		if code := request(router, "POST", "/api/v1/shorten", pro); code != expected {
+			t.Errorf("pro request %d: expected %d, got %d", i, expected, code)
+		}
+	}
+
+	// Users identified by a trusted header get the default plan
+	user := http.Header{"X-User-Id": {"alice"}}
+	for i, expected := range []int{200, 429} {
+		if code := request(router, "POST", "/api/v1/shorten", user); code != expected {
+			t.Errorf("user request %d: expected %d, got %d", i, expected, code)
+		}
+	}
+
+	// An unknown key is anonymous
+	if code := request(router, "POST", "/api/v1/shorten", http.Header{"X-Api-Key": {"wrong"}}); code != http.StatusOK {
+		t.Errorf("expected an anonymous request to be allowed, got %d", code)
+	}
+
+	if limiters["plan:pro"].seen["acme"] != 4 || limiters["plan:free"].seen["user:alice"] != 2 {
+		t.Errorf("expected plan limits keyed by principal, got %v and %v", limiters["plan:pro"].seen, limiters["plan:free"].seen)
+	}
+	if limiters["policy:default"].seen["203.0.113.7"] != 1 {
+		t.Errorf("expected only the anonymous request to be keyed by IP, got %v", limiters["policy:default"].seen)
+	}
+}
+
+func TestPolicyLimiterEnforcesDailyQuota(t *testing.T) {
+	identify := APIKeyIdentifier(map[string]Principal{"key": {ID: "acme", Plan: "free"}}, false)
+	policies, _ := newTestPolicyLimiter(t, PolicyConfig{
+		Default: Policy{PerIPRPS: 100, BurstSize: 100},
+		Plans:   map[string]Plan{"free": {RPS: 100, BurstSize: 100, DailyQuota: 2}},
+	}, identify)
+	now := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+	policies.now = func() time.Time { return now }
+	policies.quotas.(*MemoryQuota).now = policies.now
+	router := newTestRouter(policies)
+
+	header := http.Header{"X-Api-Key": {"key"}}
+	for i, expected := range []int{200, 200, 429} {
+		if code := request(router, "GET", "/abc", header); code != expected {
+			t.Errorf("request %d: expected %d, got %d", i, expected, code)
+		}
+	}
+
+	// The quota resets at midnight UTC
+	now = now.Add(2 * time.Minute)
+	if code := request(router, "GET", "/abc", header); code != http.StatusOK {
+		t.Errorf("expected the quota to reset, got %d", code)
+	}
+}
+
+func TestPolicyLimiterOnlyCountsAllowedRequests(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	factory := func(name string, config Config) RateLimiter {
+		limiter := NewLimiter(config)
+		limiter.now = func() time.Time { return now }
+		t.Cleanup(limiter.Close)
+		return limiter
+	}
+	policies, err := NewPolicyLimiter(PolicyConfig{
+		Default: Policy{GlobalRPS: 10, PerIPRPS: 1, BurstSize: 2},
+	}, factory, NewMemoryQuota(), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	router := newTestRouter(policies)
+
+	other := func() int {
+		req := httptest.NewRequest("GET", "/abc", nil)
+		req.RemoteAddr = "198.51.100.9:1234"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Another client uses up the global limit
+	for i, expected := range []int{200, 200} {
+		if code := other(); code != expected {
+			t.Errorf("other client request %d: expected %d, got %d", i, expected, code)
+		}
+	}
+	for i := 0; i < 3; i++ {
+		if code := request(router, "GET", "/abc", nil); code != http.StatusTooManyRequests {
+			t.Errorf("request %d: expected the global limit to apply, got %d", i, code)
+		}
+	}
+
+	// The global bucket refills long before a client's would, and this
+	// client's own burst is still intact
+	now = now.Add(200 * time.Millisecond)
+	for i, expected := range []int{200, 200} {
+		if code := request(router, "GET", "/abc", nil); code != expected {
+			t.Errorf("request %d after refill: expected %d, got %d", i, expected, code)
+		}
+	}
+}
+
+func TestPolicyLimiterSetsHeaders(t *testing.T) {
+	identify := APIKeyIdentifier(map[string]Principal{"key": {ID: "acme", Plan: "free"}}, false)
+	policies, _ := newTestPolicyLimiter(t, PolicyConfig{
+		Default:     Policy{PerIPRPS: 1, BurstSize: 2},
+		Plans:       map[string]Plan{"free": {RPS: 100, BurstSize: 100, DailyQuota: 3}},
+		ExemptPaths: []string{"/api/v1/healthz"},
+	}, identify)
+	now := time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC)
+	policies.now = func() time.Time { return now }
+	policies.quotas.(*MemoryQuota).now = policies.now
+	router := newTestRouter(policies)
+
+	expectHeaders := func(rec *httptest.ResponseRecorder, limit, remaining, reset, retryAfter string) {
+		t.Helper()
+		header := rec.Header()
+		if header.Get("RateLimit-Limit") != limit || header.Get("RateLimit-Remaining") != remaining ||
+			header.Get("RateLimit-Reset") != reset || header.Get("Retry-After") != retryAfter {
+			t.Errorf("unexpected headers %v", header)
+		}
+	}
+
+	expectHeaders(serve(router, "GET", "/abc", nil), "2", "1", "1", "")
+	expectHeaders(serve(router, "GET", "/abc", nil), "2", "0", "1", "")
+	expectHeaders(serve(router, "GET", "/abc", nil), "2", "0", "1", "2")
+
+	// The daily quota is reported once it is closer to running out
+	key := http.Header{"X-Api-Key": {"key"}}
+	expectHeaders(serve(router, "GET", "/abc", key), "3", "2", "3600", "")
+	serve(router, "GET", "/abc", key)
+	serve(router, "GET", "/abc", key)
+	rec := serve(router, "GET", "/abc", key)
+	expectHeaders(rec, "3", "0", "3600", "3600")
+	if !strings.Contains(rec.Body.String(), "quota_exceeded") {
+		t.Errorf("expected a quota error, got %s", rec.Body.String())
+	}
+
+	expectHeaders(serve(router, "GET", "/api/v1/healthz", nil), "", "", "", "")
+}
+
+func TestNewPolicyLimiterValidatesConfig(t *testing.T) {
+	factory := func(string, Config) RateLimiter { return &countingLimiter{seen: map[string]int{}} }
+
+	configs := []PolicyConfig{
+		{Policies: []Policy{{Routes: []string{"/a"}}}},
+		{Policies: []Policy{{Name: "a"}, {Name: "a"}}},
+		{Policies: []Policy{{Name: "a", Routes: []string{"api/v1"}}}},
+		{Policies: []Policy{{Name: "a", Routes: []string{"GET /a extra"}}}},
+		{DefaultPlan: "missing"},
+	}
+	for i, config := range configs {
+		if _, err := NewPolicyLimiter(config, factory, nil, nil); !errors.Is(err, ErrInvalidPolicy) {
+			t.Errorf("config %d: expected ErrInvalidPolicy, got %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// QuotaCounter counts requests against a quota
+type QuotaCounter interface {
+	// Increment adds one to the count of key, which is reset at expireAt,
+	// and returns the new count
+	Increment(ctx context.Context, key string, expireAt time.Time) (int64, error)
+	// Count returns the current count of key
+	Count(ctx context.Context, key string) (int64, error)
+}
+
+// quotaEntry is a process-local quota count
+type quotaEntry struct {
+	count    int64
+	expireAt time.Time
+}
+
+// MemoryQuota counts quotas in process memory
+type MemoryQuota struct {
+	mu        sync.Mutex
+	counts    map[string]*quotaEntry
+	nextSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryQuota creates a process-local quota counter
+func NewMemoryQuota() *MemoryQuota {
+	return &MemoryQuota{
+		counts: make(map[string]*quotaEntry),
+		now:    time.Now,
+	}
+}
+
+// Increment adds one to the count of key
+func (q *MemoryQuota) Increment(ctx context.Context, key string, expireAt time.Time) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	if !now.Before(q.nextSweep) {
+		// Drop expired counts at most once a minute
+		for k, entry := range q.counts {
+			if !now.Before(entry.expireAt) {
+				delete(q.counts, k)
+			}
+		}
+		q.nextSweep = now.Add(time.Minute)
+	}
+
+	entry, ok := q.counts[key]
+	if !ok || !now.Before(entry.expireAt) {
+		entry = &quotaEntry{expireAt: expireAt}
+		q.counts[key] = entry
+	}
+	entry.count++
+
+	return entry.count, nil
+}
+
+// Count returns the current count of key
+func (q *MemoryQuota) Count(ctx context.Context, key string) (int64, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	entry, ok := q.counts[key]
+	if !ok || !q.now().Before(entry.expireAt) {
+		return 0, nil
+	}
+	return entry.count, nil
+}
+
+// RedisQuota counts quotas in Redis so they are shared by every instance.
+// While Redis is unavailable, counts go to a local fallback counter.
+type RedisQuota struct {
+	client   redis.Cmdable
+	redis    RedisConfig
+	fallback QuotaCounter
+	now      func() time.Time
+
+	// fallbackUntil is the Unix time in nanoseconds until which Redis is skipped
+	fallbackUntil int64
+}
+
+// NewRedisQuota creates a Redis-backed quota counter
+func NewRedisQuota(client redis.Cmdable, redisConfig RedisConfig, fallback QuotaCounter) *RedisQuota {
+	if redisConfig.Prefix == "" {
+		redisConfig.Prefix = "ratelimit:quota:"
+	}
+	if redisConfig.Timeout <= 0 {
+		redisConfig.Timeout = 50 * time.Millisecond
+	}
+	if redisConfig.Cooldown <= 0 {
+		redisConfig.Cooldown = 5 * time.Second
+	}
+
+	return &RedisQuota{
+		client:   client,
+		redis:    redisConfig,
+		fallback: fallback,
+		now:      time.Now,
+	}
+}
+
+// Increment adds one to the count of key
+func (q *RedisQuota) Increment(ctx context.Context, key string, expireAt time.Time) (int64, error) {
+	if q.now().UnixNano() < atomic.LoadInt64(&q.fallbackUntil) {
+		return q.fallback.Increment(ctx, key, expireAt)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, q.redis.Timeout)
+	defer cancel()
+
+	var incr *redis.IntCmd
+	_, err := q.client.TxPipelined(runCtx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(runCtx, q.redis.Prefix+key)
+		pipe.ExpireAt(runCtx, q.redis.Prefix+key, expireAt)
+		return nil
+	})
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		atomic.StoreInt64(&q.fallbackUntil, q.now().Add(q.redis.Cooldown).UnixNano())
+		count, fallbackErr := q.fallback.Increment(ctx, key, expireAt)
+		if fallbackErr != nil {
+			return 0, fmt.Errorf("%w: %v", ErrRedisUnavailable, err)
+		}
+		return count, nil
+	}
+
+	return incr.Val(), nil
+}
+
+// Count returns the current count of key
+func (q *RedisQuota) Count(ctx context.Context, key string) (int64, error) {
+	if q.now().UnixNano() < atomic.LoadInt64(&q.fallbackUntil) {
+		return q.fallback.Count(ctx, key)
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, q.redis.Timeout)
+	defer cancel()
+
+	count, err := q.client.Get(runCtx, q.redis.Prefix+key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, ctx.Err()
+		}
+		atomic.StoreInt64(&q.fallbackUntil, q.now().Add(q.redis.Cooldown).UnixNano())
+		count, fallbackErr := q.fallback.Count(ctx, key)
+		if fallbackErr != nil {
+			return 0, fmt.Errorf("%w: %v", ErrRedisUnavailable, err)
+		}
+		return count, nil
+	}
+
+	return count, nil
+}
@@ -0,0 +1,209 @@
+package rate
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// gcraScript applies the generic cell rate algorithm to every key in KEYS
+// and only consumes from the buckets if all of them allow the request. Each
+// key stores its theoretical arrival time (TAT) in microseconds; ARGV holds
+// the emission interval in microseconds and the burst size for each key.
+// Redis time is used so instances with skewed clocks agree.
+// Returns {allowed, retry_after_us, remaining, reset_after_us}, where the
+// last two describe the key with the fewest requests remaining.
+var gcraScript = redis.NewScript(`
+redis.replicate_commands()
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000000 + tonumber(t[2])
+
+local allowed = 1
+local retry_after = 0
+local remaining = -1
+local reset_after = 0
+local tats = {}
+
+for i, key in ipairs(KEYS) do
+	local interval = tonumber(ARGV[2 * i - 1])
+	local burst = tonumber(ARGV[2 * i])
+
+	local tat = tonumber(redis.call('GET', key)) or now
+	if tat < now then
+		tat = now
+	end
+
+	local new_tat = tat + interval
+	local allow_at = new_tat - interval * burst
+	local key_remaining = 0
+	local key_reset = tat - now
+	if now < allow_at then
+		allowed = 0
+		retry_after = math.max(retry_after, allow_at - now)
+	else
+		key_remaining = math.floor((now - allow_at) / interval)
+		key_reset = new_tat - now
+	end
+	if remaining < 0 or key_remaining < remaining then
+		remaining = key_remaining
+		reset_after = key_reset
+	end
+	tats[i] = new_tat
+end
+
+if allowed == 1 then
+	for i, key in ipairs(KEYS) do
+		-- Format explicitly; Lua would print large numbers in exponent form
+		redis.call('SET', key, string.format('%d', tats[i]), 'PX', math.ceil((tats[i] - now) / 1000))
+	end
+end
+
+return {allowed, retry_after, remaining, reset_after}
+`)
+
+// RedisConfig holds Redis-backed limiter configuration
+type RedisConfig struct {
+	Prefix   string        // key prefix for limiter buckets
+	Timeout  time.Duration // how long a check waits for Redis before falling back
+	Cooldown time.Duration // how long to use the fallback after Redis fails
+}
+
+// RedisLimiter enforces limits shared by every instance, so N replicas
+// together allow the configured rate rather than N times it. Buckets are
+// kept in Redis and updated atomically by a Lua script. While Redis is
+// unavailable, checks go to a local fallback limiter.
+type RedisLimiter struct {
+	client   redis.Scripter
+	config   Config
+	redis    RedisConfig
+	fallback RateLimiter
+	now      func() time.Time
+
+	// fallbackUntil is the Unix time in nanoseconds until which Redis is skipped
+	fallbackUntil int64
+}
+
+// NewRedisLimiter creates a Redis-backed limiter
+func NewRedisLimiter(client redis.Scripter, config Config, redisConfig RedisConfig, fallback RateLimiter) *RedisLimiter {
+	if redisConfig.Prefix == "" {
+		redisConfig.Prefix = "ratelimit:"
+	}
+	if redisConfig.Timeout <= 0 {
+		redisConfig.Timeout = 50 * time.Millisecond
+	}
+	if redisConfig.Cooldown <= 0 {
+		redisConfig.Cooldown = 5 * time.Second
+	}
+	if config.BurstSize <= 0 {
+		config.BurstSize = 1
+	}
+
+	return &RedisLimiter{
+		client:   client,
+		config:   config,
+		redis:    redisConfig,
+		fallback: fallback,
+		now:      time.Now,
+	}
+}
+
+// Allow checks if a request is allowed
+func (l *RedisLimiter) Allow(ip string) bool {
+	return l.Take(ip).Allowed
+}
+
+// Take checks if a request is allowed
+func (l *RedisLimiter) Take(ip string) Decision {
+	decision, err := l.take(context.Background(), ip)
+	if err != nil {
+		return l.fallback.Take(ip)
+	}
+	return decision
+}
+
+// Wait waits for a request to be allowed
+func (l *RedisLimiter) Wait(ctx context.Context, ip string) error {
+	for {
+		decision, err := l.take(ctx, ip)
+		if err != nil {
+			return l.fallback.Wait(ctx, ip)
+		}
+		if decision.Allowed {
+			return nil
+		}
+
+		timer := time.NewTimer(decision.RetryAfter)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("rate limit wait failed: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Close releases the fallback limiter
+func (l *RedisLimiter) Close() {
+	l.fallback.Close()
+}
+
+// take runs the GCRA script against the global and per-IP buckets. It
+// returns an error, without contacting Redis, during the cooldown after a
+// failure so an outage does not add the timeout to every request.
+func (l *RedisLimiter) take(ctx context.Context, ip string) (Decision, error) {
+	if l.now().UnixNano() < atomic.LoadInt64(&l.fallbackUntil) {
+		return Decision{}, ErrRedisUnavailable
+	}
+
+	var keys []string
+	var args []interface{}
+	if l.config.GlobalRPS > 0 {
+		keys = append(keys, l.redis.Prefix+"global")
+		args = append(args, emissionInterval(l.config.GlobalRPS), l.config.BurstSize)
+	}
+	if l.config.PerIPRPS > 0 {
+		keys = append(keys, l.redis.Prefix+"ip:"+ip)
+		args = append(args, emissionInterval(l.config.PerIPRPS), l.config.BurstSize)
+	}
+	if len(keys) == 0 {
+		return Decision{Allowed: true}, nil
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, l.redis.Timeout)
+	defer cancel()
+
+	values, err := gcraScript.Run(runCtx, l.client, keys, args...).Int64Slice()
+	if err == nil && len(values) != 4 {
+		err = fmt.Errorf("unexpected rate limit script result %v", values)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			// The caller gave up; Redis is not to blame
+			return Decision{}, ctx.Err()
+		}
+		atomic.StoreInt64(&l.fallbackUntil, l.now().Add(l.redis.Cooldown).UnixNano())
+		return Decision{}, fmt.Errorf("%w: %v", ErrRedisUnavailable, err)
+	}
+
+	return Decision{
+		Allowed:    values[0] == 1,
+		Limit:      l.config.BurstSize,
+		Remaining:  int(values[2]),
+		Reset:      time.Duration(values[3]) * time.Microsecond,
+		RetryAfter: time.Duration(values[1]) * time.Microsecond,
+	}, nil
+}
+
+// emissionInterval returns the time between requests at rps, in microseconds
+func emissionInterval(rps int) int64 {
+	return int64(math.Ceil(1e6 / float64(rps)))
+}
+
+// Custom errors
+var (
+	ErrRedisUnavailable = fmt.Errorf("rate limit store unavailable")
+)
@@ -0,0 +1,227 @@
+package rate
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// fakeScripter answers script calls with a fixed result and records the
+// keys and arguments it was called with
+type fakeScripter struct {
+	result []interface{}
+	err    error
+	calls  int
+	keys   []string
+	args   []interface{}
+}
+
+func (f *fakeScripter) Eval(ctx context.Context, script string, keys []string, args ...interface{}) *redis.Cmd {
+	return f.EvalSha(ctx, "", keys, args...)
+}
+
+func (f *fakeScripter) EvalSha(ctx context.Context, sha1 string, keys []string, args ...interface{}) *redis.Cmd {
+	f.calls++
+	f.keys, f.args = keys, args
+	return redis.NewCmdResult(f.result, f.err)
+}
+
+func (f *fakeScripter) ScriptExists(ctx context.Context, hashes ...string) *redis.BoolSliceCmd {
+	return redis.NewBoolSliceResult(nil, nil)
+}
+
+func (f *fakeScripter) ScriptLoad(ctx context.Context, script string) *redis.StringCmd {
+	return redis.NewStringResult("", nil)
+}
+
+// fakeFallback counts the checks it receives
+type fakeFallback struct {
+	allows int
+	waits  int
+}
+
+func (f *fakeFallback) Allow(ip string) bool {
+	return f.Take(ip).Allowed
+}
+
+func (f *fakeFallback) Take(ip string) Decision {
+	f.allows++
+	return Decision{Allowed: true}
+}
+
+func (f *fakeFallback) Wait(ctx context.Context, ip string) error {
+	f.waits++
+	return nil
+}
+
+func (f *fakeFallback) Close() {}
+
+func TestRedisLimiterChecksGlobalAndPerIPBuckets(t *testing.T) {
+	client := &fakeScripter{result: []interface{}{int64(1), int64(0), int64(4), int64(333334)}}
+	limiter := NewRedisLimiter(client, Config{GlobalRPS: 100, PerIPRPS: 3, BurstSize: 5}, RedisConfig{}, &fakeFallback{})
+
+	decision := limiter.Take("203.0.113.7")
+	if !decision.Allowed || decision.Limit != 5 || decision.Remaining != 4 || decision.Reset != 333334*time.Microsecond {
+		t.Errorf("unexpected decision %+v", decision)
+	}
+	if len(client.keys) != 2 || client.keys[0] != "ratelimit:global" || client.keys[1] != "ratelimit:ip:203.0.113.7" {
+		t.Errorf("unexpected keys %v", client.keys)
+	}
+	expected := []interface{}{int64(10000), 5, int64(333334), 5}
+	for i, arg := range expected {
+		if client.args[i] != arg {
+			t.Errorf("argument %d: expected %v, got %v", i, arg, client.args[i])
+		}
+	}
+
+	client.result = []interface{}{int64(0), int64(250000), int64(0), int64(1583334)}
+	decision = limiter.Take("203.0.113.7")
+	if decision.Allowed || decision.RetryAfter != 250*time.Millisecond || decision.Remaining != 0 {
+		t.Errorf("unexpected decision %+v", decision)
+	}
+}
+
+func TestRedisLimiterWaitsForRetryAfter(t *testing.T) {
+	client := &fakeScripter{result: []interface{}{int64(0), int64(20000), int64(0), int64(20000)}}
+	limiter := NewRedisLimiter(client, Config{PerIPRPS: 1}, RedisConfig{}, &fakeFallback{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := limiter.Wait(ctx, "203.0.113.7"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected deadline error, got %v", err)
+	}
+	// Checks are spaced by the retry-after reported by Redis
+	if client.calls < 2 || client.calls > 4 || time.Since(start) < 40*time.Millisecond {
+		t.Errorf("expected 2 to 4 checks over 50ms, got %d", client.calls)
+	}
+}
+
+func TestRedisLimiterFallsBackWhileRedisIsDown(t *testing.T) {
+	client := &fakeScripter{err: errors.New("connection refused")}
+	fallback := &fakeFallback{}
+	limiter := NewRedisLimiter(client, Config{GlobalRPS: 10, PerIPRPS: 1}, RedisConfig{Cooldown: time.Minute}, fallback)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("203.0.113.7") {
+			t.Error("expected the fallback to allow the request")
+		}
+	}
+	if fallback.allows != 3 {
+		t.Errorf("expected 3 fallback checks, got %d", fallback.allows)
+	}
+	if client.calls != 1 {
+		t.Errorf("expected Redis to be skipped during the cooldown, got %d calls", client.calls)
+	}
+
+	// Redis is tried again once the cooldown has passed
+	client.err = nil
+	client.result = []interface{}{int64(0), int64(1000), int64(0), int64(1000)}
+	now = now.Add(time.Minute)
+	if limiter.Allow("203.0.113.7") {
+		t.Error("expected Redis to reject the request")
+	}
+	if client.calls != 2 || fallback.allows != 3 {
+		t.Errorf("expected the check to go to Redis, got %d calls", client.calls)
+	}
+}
+
+func TestRedisLimiterWithoutLimits(t *testing.T) {
+	client := &fakeScripter{}
+	limiter := NewRedisLimiter(client, Config{}, RedisConfig{}, &fakeFallback{})
+
+	if !limiter.Allow("203.0.113.7") || client.calls != 0 {
+		t.Errorf("expected unlimited requests without calling Redis, got %d calls", client.calls)
+	}
+}
+
+// newMiniredisLimiter creates a limiter backed by an in-process Redis that
+// runs the Lua script, with Redis time frozen at now
+func newMiniredisLimiter(t *testing.T, config Config, now time.Time) (*RedisLimiter, *miniredis.Miniredis) {
+	server := miniredis.RunT(t)
+	server.SetTime(now)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	// A generous timeout so a slow test machine does not trigger the fallback
+	limiter := NewRedisLimiter(client, config, RedisConfig{Timeout: time.Second}, &fakeFallback{})
+	return limiter, server
+}
+
+func TestGCRAScriptAllowsBurstThenRefills(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter, server := newMiniredisLimiter(t, Config{PerIPRPS: 2, BurstSize: 3}, now)
+
+	for i, remaining := range []int{2, 1, 0} {
+		decision := limiter.Take("203.0.113.7")
+		reset := time.Duration(i+1) * 500 * time.Millisecond
+		if !decision.Allowed || decision.Remaining != remaining || decision.Reset != reset {
+			t.Errorf("request %d: unexpected decision %+v", i, decision)
+		}
+	}
+
+	decision := limiter.Take("203.0.113.7")
+	if decision.Allowed || decision.Remaining != 0 || decision.RetryAfter != 500*time.Millisecond {
+		t.Errorf("expected the burst to be used up, got %+v", decision)
+	}
+
+	// The bucket expires once it would be full again
+	if ttl := server.TTL("ratelimit:ip:203.0.113.7"); ttl != 1500*time.Millisecond {
+		t.Errorf("expected the bucket to expire in 1.5s, got %v", ttl)
+	}
+
+	// One emission interval later a single request is allowed again
+	server.SetTime(now.Add(500 * time.Millisecond))
+	if decision := limiter.Take("203.0.113.7"); !decision.Allowed || decision.Remaining != 0 {
+		t.Errorf("expected one request after refilling, got %+v", decision)
+	}
+	if decision := limiter.Take("203.0.113.7"); decision.Allowed {
+		t.Errorf("expected the refilled request to be used up, got %+v", decision)
+	}
+
+	// Other clients have their own bucket
+	if decision := limiter.Take("198.51.100.9"); !decision.Allowed || decision.Remaining != 2 {
+		t.Errorf("expected a full bucket for another client, got %+v", decision)
+	}
+}
+
+func TestGCRAScriptOnlyConsumesWhenEveryKeyAllows(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	limiter, server := newMiniredisLimiter(t, Config{GlobalRPS: 10, PerIPRPS: 1, BurstSize: 2}, now)
+
+	for i := 0; i < 2; i++ {
+		if !limiter.Allow("203.0.113.7") {
+			t.Fatalf("request %d: expected the burst to be allowed", i)
+		}
+	}
+
+	global, _ := server.Get("ratelimit:global")
+	client, _ := server.Get("ratelimit:ip:203.0.113.7")
+
+	// The per-IP bucket is empty; the global bucket must not be charged
+	if decision := limiter.Take("203.0.113.7"); decision.Allowed || decision.RetryAfter != time.Second {
+		t.Errorf("expected the per-IP limit to reject the request, got %+v", decision)
+	}
+	if got, _ := server.Get("ratelimit:global"); got != global {
+		t.Errorf("expected the global bucket to be unchanged, got %s, was %s", got, global)
+	}
+	if got, _ := server.Get("ratelimit:ip:203.0.113.7"); got != client {
+		t.Errorf("expected the per-IP bucket to be unchanged, got %s, was %s", got, client)
+	}
+
+	// Once the global bucket refills, another client is allowed its full burst
+	server.SetTime(now.Add(200 * time.Millisecond))
+	for i := 0; i < 2; i++ {
+		if !limiter.Allow("198.51.100.9") {
+			t.Errorf("request %d: expected another client to be allowed", i)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package rate
+
+import (
+	"container/list"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// storeShards is the number of independently locked parts of a keyStore
+const storeShards = 32
+
+// keyEntry is the bucket of one key
+type keyEntry struct {
+	key      string
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// keyShard is one locked part of a keyStore. Its list is ordered by last
+// access, most recent first.
+type keyShard struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+}
+
+// keyStore holds per-key buckets. Keys are spread over shards by hash so
+// requests from different clients rarely contend for the same lock. Each
+// shard evicts its least recently used key when it is full.
+type keyStore struct {
+	shards   [storeShards]keyShard
+	shardCap int
+	newLimit func() *rate.Limiter
+}
+
+// newKeyStore creates a store of at most maxKeys buckets
+func newKeyStore(maxKeys int, newLimit func() *rate.Limiter) *keyStore {
+	shardCap := (maxKeys + storeShards - 1) / storeShards
+	if shardCap < 1 {
+		shardCap = 1
+	}
+
+	s := &keyStore{shardCap: shardCap, newLimit: newLimit}
+	for i := range s.shards {
+		s.shards[i].entries = make(map[string]*list.Element)
+		s.shards[i].lru = list.New()
+	}
+	return s
+}
+
+// get returns the bucket of key, creating it if needed, and marks the key
+// as seen at now
+func (s *keyStore) get(key string, now time.Time) *rate.Limiter {
+	shard := s.shard(key)
+	shard.mu.Lock()
+	defer shard.mu.Unlock()
+
+	if elem, ok := shard.entries[key]; ok {
+		entry := elem.Value.(*keyEntry)
+		entry.lastSeen = now
+		shard.lru.MoveToFront(elem)
+		return entry.limiter
+	}
+
+	entry := &keyEntry{key: key, limiter: s.newLimit(), lastSeen: now}
+	shard.entries[key] = shard.lru.PushFront(entry)
+	if shard.lru.Len() > s.shardCap {
+		oldest := shard.lru.Back()
+		shard.lru.Remove(oldest)
+		delete(shard.entries, oldest.Value.(*keyEntry).key)
+	}
+	return entry.limiter
+}
+
+// evictIdle removes keys last seen before cutoff and returns how many
+func (s *keyStore) evictIdle(cutoff time.Time) int {
+	evicted := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		for elem := shard.lru.Back(); elem != nil; elem = shard.lru.Back() {
+			entry := elem.Value.(*keyEntry)
+			if !entry.lastSeen.Before(cutoff) {
+				break
+			}
+			shard.lru.Remove(elem)
+			delete(shard.entries, entry.key)
+			evicted++
+		}
+		shard.mu.Unlock()
+	}
+	return evicted
+}
+
+// len returns the number of tracked keys
+func (s *keyStore) len() int {
+	total := 0
+	for i := range s.shards {
+		shard := &s.shards[i]
+		shard.mu.Lock()
+		total += shard.lru.Len()
+		shard.mu.Unlock()
+	}
+	return total
+}
+
+// shard returns the shard of key
+func (s *keyStore) shard(key string) *keyShard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return &s.shards[h.Sum32()%storeShards]
+}
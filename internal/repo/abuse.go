@@ -0,0 +1,227 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+// ReportURL records an abuse report and escalates the link's status once
+// enough reports have been made since the last moderator review: to
+// under_review at reviewThreshold and to suspended at suspendThreshold. A
+// threshold of zero or less disables that step. Repeated reports by the
+// same reporter are ignored until the next review. It returns the link's
+// status after the report.
+func (r *PostgresRepo) ReportURL(ctx context.Context, report *models.LinkReport, reviewThreshold, suspendThreshold int) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the link so concurrent reports are counted one at a time
+	var (
+		status     string
+		reviewedAt *time.Time
+	)
+	err = tx.QueryRowContext(ctx,
+		`SELECT status, reviewed_at FROM short_urls WHERE code = $1 AND is_deleted = false FOR UPDATE`, report.Code,
+	).Scan(&status, &reviewedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrURLNotFound
+		}
+		return "", fmt.Errorf("failed to get URL status: %w", err)
+	}
+
+	// A reporter's earlier report is replaced if it predates the last
+	// review, so they can report the link again once a moderator has
+	// decided on it
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO link_reports (code, reason, details, reporter)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (code, reporter) DO UPDATE
+		SET reason = EXCLUDED.reason, details = EXCLUDED.details, created_at = NOW()
+		WHERE $5::timestamptz IS NOT NULL AND link_reports.created_at <= $5
+		RETURNING id, created_at`,
+		report.Code, report.Reason, report.Details, report.Reporter, reviewedAt,
+	).Scan(&report.ID, &report.CreatedAt)
+	if err == sql.ErrNoRows {
+		// Already reported by this reporter since the last review
+		return status, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to create report: %w", err)
+	}
+
+	if status != models.LinkSuspended {
+		var count int
+		err = tx.QueryRowContext(ctx,
+			`SELECT COUNT(*) FROM link_reports WHERE code = $1 AND ($2::timestamptz IS NULL OR created_at > $2)`,
+			report.Code, reviewedAt,
+		).Scan(&count)
+		if err != nil {
+			return "", fmt.Errorf("failed to count reports: %w", err)
+		}
+
+		next := status
+		if suspendThreshold > 0 && count >= suspendThreshold {
+			next = models.LinkSuspended
+		} else if reviewThreshold > 0 && count >= reviewThreshold {
+			next = models.LinkUnderReview
+		}
+
+		if next != status {
+			reason := fmt.Sprintf("Automatically set to %s after %d reports", next, count)
+			_, err = tx.ExecContext(ctx,
+				`UPDATE short_urls SET status = $2, status_reason = $3, status_changed_at = NOW() WHERE code = $1`,
+				report.Code, next, reason,
+			)
+			if err != nil {
+				return "", fmt.Errorf("failed to update URL status: %w", err)
+			}
+			status = next
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return status, nil
+}
+
+// SetURLStatus records a moderator's decision on a link. Reports made
+// before the decision no longer count towards automatic escalation.
+func (r *PostgresRepo) SetURLStatus(ctx context.Context, code, status string, reason *string) error {
+	query := `
+		UPDATE short_urls
+		SET status = $2, status_reason = $3, status_changed_at = NOW(), reviewed_at = NOW()
+		WHERE code = $1 AND is_deleted = false`
+
+	result, err := r.db.ExecContext(ctx, query, code, status, reason)
+	if err != nil {
+		return fmt.Errorf("failed to update URL status: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+
+	return nil
+}
+
+// GetURLModeration returns a link's moderation state and a page of its
+// reports, newest first
+func (r *PostgresRepo) GetURLModeration(ctx context.Context, code string, page, pageSize int) (*models.LinkModeration, error) {
+	offset := (page - 1) * pageSize
+
+	moderation := &models.LinkModeration{
+		Code:     code,
+		Page:     page,
+		PageSize: pageSize,
+	}
+	err := r.db.QueryRowContext(ctx, `
+		SELECT s.status, s.status_reason, s.status_changed_at, s.reviewed_at,
+			(SELECT COUNT(*) FROM link_reports lr
+				WHERE lr.code = s.code AND (s.reviewed_at IS NULL OR lr.created_at > s.reviewed_at)),
+			(SELECT COUNT(*) FROM link_reports lr WHERE lr.code = s.code)
+		FROM short_urls s
+		WHERE s.code = $1 AND s.is_deleted = false`, code,
+	).Scan(
+		&moderation.Status, &moderation.StatusReason, &moderation.StatusChangedAt, &moderation.ReviewedAt,
+		&moderation.PendingReports, &moderation.Total,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrURLNotFound
+		}
+		return nil, fmt.Errorf("failed to get URL moderation: %w", err)
+	}
+
+	query := `
+		SELECT id, code, reason, details, created_at
+		FROM link_reports
+		WHERE code = $1
+		ORDER BY created_at DESC, id DESC
+		LIMIT $2 OFFSET $3`
+
+	rows, err := r.db.QueryContext(ctx, query, code, pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reports: %w", err)
+	}
+	defer rows.Close()
+
+	moderation.Reports = []models.LinkReport{}
+	for rows.Next() {
+		var report models.LinkReport
+		if err := rows.Scan(&report.ID, &report.Code, &report.Reason, &report.Details, &report.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan report: %w", err)
+		}
+		moderation.Reports = append(moderation.Reports, report)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating reports: %w", err)
+	}
+
+	return moderation, nil
+}
+
+// FlagURL sets a link's status on behalf of an automatic check. Unlike
+// SetURLStatus it does not count as a review. Suspended links, and links a
+// moderator has cleared, are left as they are.
+func (r *PostgresRepo) FlagURL(ctx context.Context, code, status, reason string) error {
+	query := `
+		UPDATE short_urls
+		SET status = $2, status_reason = $3, status_changed_at = NOW()
+		WHERE code = $1 AND is_deleted = false AND status <> 'suspended'
+			AND NOT (status = 'active' AND reviewed_at IS NOT NULL)`
+
+	if _, err := r.db.ExecContext(ctx, query, code, status, reason); err != nil {
+		return fmt.Errorf("failed to flag URL: %w", err)
+	}
+
+	return nil
+}
+
+// GetURLsForScan returns up to limit live links that are not suspended, with
+// IDs above afterID, in ID order
+func (r *PostgresRepo) GetURLsForScan(ctx context.Context, afterID int64, limit int) ([]models.ShortURL, error) {
+	query := `
+		SELECT id, code, long_url, status, reviewed_at
+		FROM short_urls
+		WHERE id > $1 AND is_deleted = false AND status <> 'suspended'
+			AND (expire_at IS NULL OR expire_at > NOW())
+		ORDER BY id
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get URLs for scan: %w", err)
+	}
+	defer rows.Close()
+
+	var urls []models.ShortURL
+	for rows.Next() {
+		var url models.ShortURL
+		if err := rows.Scan(&url.ID, &url.Code, &url.LongURL, &url.Status, &url.ReviewedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan URL: %w", err)
+		}
+		urls = append(urls, url)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating URLs: %w", err)
+	}
+
+	return urls, nil
+}
@@ -0,0 +1,261 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/lib/pq"
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/models"
+)
+
+// campaignTopLinks is the number of links listed in campaign statistics
+const campaignTopLinks = 10
+
+// AddTags attaches tags to a URL, creating tags that do not exist yet. It
+// fails with ErrTooManyTags if the URL would end up with more than maxTags.
+func (r *PostgresRepo) AddTags(ctx context.Context, code string, tags []string, maxTags int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Lock the URL so concurrent calls cannot both pass the limit
+	var locked bool
+	err = tx.QueryRowContext(ctx,
+		`SELECT true FROM short_urls WHERE code = $1 AND is_deleted = false FOR UPDATE`, code,
+	).Scan(&locked)
+	if err == sql.ErrNoRows {
+		return ErrURLNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check URL: %w", err)
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT t.name FROM url_tags ut JOIN tags t ON t.id = ut.tag_id WHERE ut.code = $1
+			UNION
+			SELECT unnest($2::text[])
+		) AS all_tags`, code, pq.Array(tags),
+	).Scan(&count)
+	if err != nil {
+		return fmt.Errorf("failed to count tags: %w", err)
+	}
+	if count > maxTags {
+		return ErrTooManyTags
+	}
+
+	if err := insertTags(ctx, tx, code, tags); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit tags: %w", err)
+	}
+
+	return nil
+}
+
+// insertTags attaches tags to a URL within tx, creating tags that do not
+// exist yet
+func insertTags(ctx context.Context, tx *sql.Tx, code string, tags []string) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO tags (name)
+		SELECT unnest($1::text[])
+		ON CONFLICT (name) DO NOTHING`, pq.Array(tags))
+	if err != nil {
+		return fmt.Errorf("failed to create tags: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO url_tags (code, tag_id)
+		SELECT $1, id FROM tags WHERE name = ANY($2)
+		ON CONFLICT (code, tag_id) DO NOTHING`, code, pq.Array(tags))
+	if err != nil {
+		return fmt.Errorf("failed to tag URL: %w", err)
+	}
+
+	return nil
+}
+
+// RemoveTag detaches a tag from a URL
+func (r *PostgresRepo) RemoveTag(ctx context.Context, code, tag string) error {
+	query := `
+		DELETE FROM url_tags ut
+		USING tags t
+		WHERE ut.tag_id = t.id AND ut.code = $1 AND t.name = $2`
+
+	result, err := r.db.ExecContext(ctx, query, code, tag)
+	if err != nil {
+		return fmt.Errorf("failed to remove tag: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+// CreateCampaign creates a new campaign
+func (r *PostgresRepo) CreateCampaign(ctx context.Context, campaign *models.Campaign) error {
+	query := `
+		INSERT INTO campaigns (name, description, created_by)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		campaign.Name, campaign.Description, campaign.CreatedBy,
+	).Scan(&campaign.ID, &campaign.CreatedAt)
+
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrCampaignExists
+		}
+		return fmt.Errorf("failed to create campaign: %w", err)
+	}
+
+	return nil
+}
+
+// GetCampaign retrieves a campaign by ID
+func (r *PostgresRepo) GetCampaign(ctx context.Context, id int64) (*models.Campaign, error) {
+	query := `
+		SELECT id, name, description, created_by, created_at
+		FROM campaigns
+		WHERE id = $1`
+
+	campaign := &models.Campaign{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&campaign.ID, &campaign.Name, &campaign.Description, &campaign.CreatedBy, &campaign.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("failed to get campaign: %w", err)
+	}
+
+	return campaign, nil
+}
+
+// GetCampaignsByUser lists the campaigns owned by a user
+func (r *PostgresRepo) GetCampaignsByUser(ctx context.Context, user string) ([]models.Campaign, error) {
+	query := `
+		SELECT id, name, description, created_by, created_at
+		FROM campaigns
+		WHERE created_by = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	campaigns := []models.Campaign{}
+	for rows.Next() {
+		var campaign models.Campaign
+		err := rows.Scan(
+			&campaign.ID, &campaign.Name, &campaign.Description, &campaign.CreatedBy, &campaign.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan campaign: %w", err)
+		}
+		campaigns = append(campaigns, campaign)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating campaigns: %w", err)
+	}
+
+	return campaigns, nil
+}
+
+// SetURLCampaign assigns a URL to a campaign, or removes it when campaignID is nil
+func (r *PostgresRepo) SetURLCampaign(ctx context.Context, code string, campaignID *int64) error {
+	query := `UPDATE short_urls SET campaign_id = $2 WHERE code = $1 AND is_deleted = false`
+
+	result, err := r.db.ExecContext(ctx, query, code, campaignID)
+	if err != nil {
+		return fmt.Errorf("failed to set URL campaign: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrURLNotFound
+	}
+
+	return nil
+}
+
+// GetCampaignStats aggregates click statistics over a campaign's URLs
+func (r *PostgresRepo) GetCampaignStats(ctx context.Context, id int64) (*models.CampaignStats, error) {
+	query := `
+		SELECT
+			c.id, c.name,
+			COUNT(s.code) AS total_links,
+			COALESCE(SUM(cs.total_clicks), 0) AS total_clicks,
+			MAX(cs.last_access_at) AS last_access_at
+		FROM campaigns c
+		LEFT JOIN short_urls s ON s.campaign_id = c.id AND s.is_deleted = false
+		LEFT JOIN click_stats cs ON cs.code = s.code
+		WHERE c.id = $1
+		GROUP BY c.id, c.name`
+
+	stats := &models.CampaignStats{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&stats.CampaignID, &stats.Name, &stats.TotalLinks, &stats.TotalClicks, &stats.LastAccessAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrCampaignNotFound
+		}
+		return nil, fmt.Errorf("failed to get campaign stats: %w", err)
+	}
+
+	topQuery := `
+		SELECT s.code, COALESCE(cs.total_clicks, 0) AS total_clicks
+		FROM short_urls s
+		LEFT JOIN click_stats cs ON cs.code = s.code
+		WHERE s.campaign_id = $1 AND s.is_deleted = false
+		ORDER BY total_clicks DESC, s.code
+		LIMIT $2`
+
+	rows, err := r.db.QueryContext(ctx, topQuery, id, campaignTopLinks)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get campaign top links: %w", err)
+	}
+	defer rows.Close()
+
+	stats.TopLinks = []models.LinkClicks{}
+	for rows.Next() {
+		var link models.LinkClicks
+		if err := rows.Scan(&link.Code, &link.TotalClicks); err != nil {
+			return nil, fmt.Errorf("failed to scan campaign link: %w", err)
+		}
+		stats.TopLinks = append(stats.TopLinks, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating campaign links: %w", err)
+	}
+
+	return stats, nil
+}
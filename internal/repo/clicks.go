@@ -0,0 +1,230 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/urlshortener/internal/clicks"
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/models"
+)
+
+// RecordClicks writes a batch of click events with COPY and folds them into
+// click_stats in the same transaction. Events read from a stream entry that
+// has already been recorded are skipped.
+func (r *PostgresRepo) RecordClicks(ctx context.Context, events []*models.ClickEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	events, err = skipRecordedClicks(ctx, tx, events)
+	if err != nil || len(events) == 0 {
+		return err
+	}
+
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("click_events",
+		"code", "ts", "user_agent", "ip_address", "ip_hash", "referer", "country", "device_type", "browser", "os", "is_bot",
+		"stream_id",
+	))
+	if err != nil {
+		return fmt.Errorf("failed to prepare click copy: %w", err)
+	}
+
+	for _, event := range events {
+		if event.Timestamp.IsZero() {
+			event.Timestamp = time.Now()
+		}
+		_, err := stmt.ExecContext(ctx,
+			event.Code, event.Timestamp, event.UserAgent, event.IPAddress, event.IPHash, event.Referer,
+			event.Country, event.DeviceType, event.Browser, event.OS, event.IsBot, event.StreamID,
+		)
+		if err != nil {
+			stmt.Close()
+			return clickError("failed to copy click", err)
+		}
+	}
+
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		stmt.Close()
+		return clickError("failed to flush click copy", err)
+	}
+	if err := stmt.Close(); err != nil {
+		return clickError("failed to close click copy", err)
+	}
+
+	if err := updateClickStats(ctx, tx, events); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit clicks: %w", err)
+	}
+
+	return nil
+}
+
+// skipRecordedClicks drops events whose stream entry has already been
+// recorded. The unique index on stream_id catches a concurrent batch that
+// records the same entry first.
+func skipRecordedClicks(ctx context.Context, tx *sql.Tx, events []*models.ClickEvent) ([]*models.ClickEvent, error) {
+	var ids []string
+	for _, event := range events {
+		if event.StreamID != nil {
+			ids = append(ids, *event.StreamID)
+		}
+	}
+	if len(ids) == 0 {
+		return events, nil
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT stream_id FROM click_events WHERE stream_id = ANY($1)`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up recorded clicks: %w", err)
+	}
+	defer rows.Close()
+
+	recorded := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan recorded click: %w", err)
+		}
+		recorded[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating recorded clicks: %w", err)
+	}
+	if len(recorded) == 0 {
+		return events, nil
+	}
+
+	remaining := make([]*models.ClickEvent, 0, len(events)-len(recorded))
+	for _, event := range events {
+		if event.StreamID == nil || !recorded[*event.StreamID] {
+			remaining = append(remaining, event)
+		}
+	}
+	return remaining, nil
+}
+
+// clickError wraps an error writing clicks. Data exceptions and constraint
+// violations are caused by the events, so they are marked as rejected.
+func clickError(message string, err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && (pqErr.Code.Class() == "22" || pqErr.Code.Class() == "23") {
+		return fmt.Errorf("%s: %w: %v", message, clicks.ErrRejectedClicks, err)
+	}
+	return fmt.Errorf("%s: %w", message, err)
+}
+
+// clickAggregate summarizes a batch of clicks for one code
+type clickAggregate struct {
+	clicks int64
+	bots   int64
+	first  time.Time
+	last   time.Time
+}
+
+// updateClickStats upserts one click_stats row per code in the batch
+func updateClickStats(ctx context.Context, tx execer, events []*models.ClickEvent) error {
+	aggregates := make(map[string]*clickAggregate)
+	for _, event := range events {
+		agg, ok := aggregates[event.Code]
+		if !ok {
+			agg = &clickAggregate{first: event.Timestamp, last: event.Timestamp}
+			aggregates[event.Code] = agg
+		}
+		if event.IsBot {
+			agg.bots++
+		} else {
+			agg.clicks++
+		}
+		if event.Timestamp.Before(agg.first) {
+			agg.first = event.Timestamp
+		}
+		if event.Timestamp.After(agg.last) {
+			agg.last = event.Timestamp
+		}
+	}
+
+	// Lock rows in a stable order so concurrent batches cannot deadlock
+	codes := make([]string, 0, len(aggregates))
+	for code := range aggregates {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	counts := make([]int64, len(codes))
+	bots := make([]int64, len(codes))
+	firsts := make([]string, len(codes))
+	lasts := make([]string, len(codes))
+	for i, code := range codes {
+		agg := aggregates[code]
+		counts[i] = agg.clicks
+		bots[i] = agg.bots
+		firsts[i] = agg.first.UTC().Format(time.RFC3339Nano)
+		lasts[i] = agg.last.UTC().Format(time.RFC3339Nano)
+	}
+
+	query := `
+		INSERT INTO click_stats (code, total_clicks, bot_clicks, first_access_at, last_access_at)
+		SELECT * FROM unnest($1::text[], $2::bigint[], $3::bigint[], $4::timestamptz[], $5::timestamptz[])
+		ON CONFLICT (code) DO UPDATE SET
+			total_clicks = click_stats.total_clicks + EXCLUDED.total_clicks,
+			bot_clicks = click_stats.bot_clicks + EXCLUDED.bot_clicks,
+			first_access_at = LEAST(click_stats.first_access_at, EXCLUDED.first_access_at),
+			last_access_at = GREATEST(click_stats.last_access_at, EXCLUDED.last_access_at)`
+
+	_, err := tx.ExecContext(ctx, query,
+		pq.Array(codes), pq.Array(counts), pq.Array(bots), pq.Array(firsts), pq.Array(lasts),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update click stats: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteClickEvents deletes up to limit click events recorded before a time
+// and returns how many were deleted
+func (r *PostgresRepo) DeleteClickEvents(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		DELETE FROM click_events
+		WHERE id IN (SELECT id FROM click_events WHERE ts < $1 LIMIT $2)`
+
+	result, err := r.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete click events: %w", err)
+	}
+
+	return result.RowsAffected()
+}
+
+// AnonymizeClickEvents clears the IP address, IP hash and user agent of up
+// to limit click events recorded before a time and returns how many changed
+func (r *PostgresRepo) AnonymizeClickEvents(ctx context.Context, before time.Time, limit int) (int64, error) {
+	query := `
+		UPDATE click_events SET ip_address = NULL, ip_hash = NULL, user_agent = NULL
+		WHERE id IN (
+			SELECT id FROM click_events
+			WHERE ts < $1 AND (ip_address IS NOT NULL OR ip_hash IS NOT NULL OR user_agent IS NOT NULL)
+			LIMIT $2
+		)`
+
+	result, err := r.db.ExecContext(ctx, query, before, limit)
+	if err != nil {
+		return 0, fmt.Errorf("failed to anonymize click events: %w", err)
+	}
+
+	return result.RowsAffected()
+}
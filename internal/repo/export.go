@@ -0,0 +1,80 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/urlshortener/internal/models"
+)
+
+// exportFetchSize is the number of rows fetched from the export cursor at a time
+const exportFetchSize = 1000
+
+// ExportClickEvents streams the click events matching filter, oldest first,
+// to fn. Rows are read through a server-side cursor in a read-only
+// transaction, so memory use does not depend on the size of the export.
+func (r *PostgresRepo) ExportClickEvents(ctx context.Context, filter models.ClickExportFilter, fn func(event *models.ClickEvent) error) error {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	query := `
+		DECLARE click_export NO SCROLL CURSOR FOR
+		SELECT e.id, e.code, e.ts, e.user_agent, host(e.ip_address), e.ip_hash, e.referer,
+			e.country, e.device_type, e.browser, e.os, e.is_bot
+		FROM click_events e
+		WHERE e.ts >= $1 AND e.ts < $2`
+	args := []interface{}{filter.From, filter.To}
+
+	if filter.Code != "" {
+		query += ` AND e.code = $3`
+		args = append(args, filter.Code)
+	} else {
+		query += ` AND e.code IN (SELECT code FROM short_urls WHERE created_by = $3)`
+		args = append(args, filter.User)
+	}
+	query += ` ORDER BY e.ts, e.id`
+
+	if _, err := tx.ExecContext(ctx, query, args...); err != nil {
+		return fmt.Errorf("failed to open click export cursor: %w", err)
+	}
+
+	fetch := fmt.Sprintf(`FETCH %d FROM click_export`, exportFetchSize)
+	for {
+		rows, err := tx.QueryContext(ctx, fetch)
+		if err != nil {
+			return fmt.Errorf("failed to fetch click events: %w", err)
+		}
+
+		count := 0
+		for rows.Next() {
+			event := &models.ClickEvent{}
+			err := rows.Scan(
+				&event.ID, &event.Code, &event.Timestamp, &event.UserAgent, &event.IPAddress, &event.IPHash,
+				&event.Referer, &event.Country, &event.DeviceType, &event.Browser, &event.OS, &event.IsBot,
+			)
+			if err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to scan click event: %w", err)
+			}
+			if err := fn(event); err != nil {
+				rows.Close()
+				return err
+			}
+			count++
+		}
+
+		if err := rows.Err(); err != nil {
+			rows.Close()
+			return fmt.Errorf("error iterating click events: %w", err)
+		}
+		rows.Close()
+
+		if count < exportFetchSize {
+			return nil
+		}
+	}
+}
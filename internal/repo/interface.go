@@ -2,14 +2,15 @@ package repo
 
 import (
 	"context"
+	"time"
 
 	"github.com/urlshortener/internal/models"
 )
 
 // URLRepository defines the interface for URL storage operations
 type URLRepository interface {
-	// CreateURL creates a new short URL
-	CreateURL(ctx context.Context, url *models.ShortURL) error
+	// CreateURL creates a new short URL and attaches tags to it
+	CreateURL(ctx context.Context, url *models.ShortURL, tags []string) error
 
 	// GetURLByCode retrieves a URL by its short code
 	GetURLByCode(ctx context.Context, code string) (*models.ShortURL, error)
@@ -23,14 +24,114 @@ type URLRepository interface {
 	// RecordClick records a click event
 	RecordClick(ctx context.Context, event *models.ClickEvent) error
 
+	// RecordClicks records a batch of click events
+	RecordClicks(ctx context.Context, events []*models.ClickEvent) error
+
 	// GetExpiredURLs gets URLs that have expired
 	GetExpiredURLs(ctx context.Context, limit int) ([]string, error)
 
 	// MarkURLsAsDeleted marks multiple URLs as deleted
 	MarkURLsAsDeleted(ctx context.Context, codes []string) error
 
-	// GetURLsByUser gets URLs created by a specific user
-	GetURLsByUser(ctx context.Context, user string, page, pageSize int) (*models.URLListResponse, error)
+	// UpdateLinkPreview stores metadata unfurled from the destination page
+	UpdateLinkPreview(ctx context.Context, code string, preview *models.LinkPreview) error
+
+	// GetURLsByUser gets URLs created by a specific user, optionally narrowed by tags or campaign
+	GetURLsByUser(ctx context.Context, user string, filter models.URLFilter, page, pageSize int) (*models.URLListResponse, error)
+
+	// AddTags attaches tags to a URL, creating tags that do not exist yet, as
+	// long as the URL ends up with at most maxTags
+	AddTags(ctx context.Context, code string, tags []string, maxTags int) error
+
+	// RemoveTag detaches a tag from a URL
+	RemoveTag(ctx context.Context, code, tag string) error
+
+	// CreateCampaign creates a new campaign
+	CreateCampaign(ctx context.Context, campaign *models.Campaign) error
+
+	// GetCampaign retrieves a campaign by ID
+	GetCampaign(ctx context.Context, id int64) (*models.Campaign, error)
+
+	// GetCampaignsByUser lists the campaigns owned by a user
+	GetCampaignsByUser(ctx context.Context, user string) ([]models.Campaign, error)
+
+	// SetURLCampaign assigns a URL to a campaign, or removes it when campaignID is nil
+	SetURLCampaign(ctx context.Context, code string, campaignID *int64) error
+
+	// GetCampaignStats aggregates click statistics over a campaign's URLs
+	GetCampaignStats(ctx context.Context, id int64) (*models.CampaignStats, error)
+
+	// GetClickSeries counts clicks on a URL in [from, to), bucketed by interval in a time zone
+	GetClickSeries(ctx context.Context, code string, from, to time.Time, interval, timezone string) ([]models.ClickBucket, error)
+
+	// GetTopDimension returns the most frequent values of a click dimension for a URL
+	GetTopDimension(ctx context.Context, code, dimension string, from, to time.Time, limit int) ([]models.DimensionCount, error)
+
+	// GetRollupWatermark returns the time before which click rollups are complete
+	GetRollupWatermark(ctx context.Context) (time.Time, error)
+
+	// RollupClicks recomputes the hourly click rollups for [from, to)
+	RollupClicks(ctx context.Context, from, to time.Time) error
+
+	// GetDailyVisitors returns the unique visitor estimates for a URL on the UTC days in [from, to)
+	GetDailyVisitors(ctx context.Context, code string, from, to time.Time) ([]models.DailyVisitors, error)
+
+	// SaveDailyVisitors stores unique visitor estimates for one UTC day
+	SaveDailyVisitors(ctx context.Context, day time.Time, visitors map[string]int64) error
+
+	// ExportClickEvents streams the click events matching a filter, oldest first
+	ExportClickEvents(ctx context.Context, filter models.ClickExportFilter, fn func(event *models.ClickEvent) error) error
+
+	// DeleteClickEvents deletes up to limit click events recorded before a time
+	DeleteClickEvents(ctx context.Context, before time.Time, limit int) (int64, error)
+
+	// AnonymizeClickEvents clears visitor details from up to limit click events recorded before a time
+	AnonymizeClickEvents(ctx context.Context, before time.Time, limit int) (int64, error)
+
+	// CreateWebhook registers a webhook subscription
+	CreateWebhook(ctx context.Context, webhook *models.WebhookSubscription) error
+
+	// GetWebhook retrieves a webhook subscription by ID, without its secret
+	GetWebhook(ctx context.Context, id int64) (*models.WebhookSubscription, error)
+
+	// GetWebhooksByOwner lists the webhook subscriptions of an owner, without their secrets
+	GetWebhooksByOwner(ctx context.Context, owner string) ([]models.WebhookSubscription, error)
+
+	// DeleteWebhook removes a webhook subscription together with its delivery log
+	DeleteWebhook(ctx context.Context, id int64) error
+
+	// GetWebhookDeliveries returns a page of a subscription's delivery log, optionally filtered by status
+	GetWebhookDeliveries(ctx context.Context, subscriptionID int64, status string, page, pageSize int) (*models.WebhookDeliveryList, error)
+
+	// RetryWebhookDelivery requeues a dead-lettered delivery
+	RetryWebhookDelivery(ctx context.Context, subscriptionID, deliveryID int64) error
+
+	// DispatchWebhookEvents fans queued outbox events out to deliveries
+	DispatchWebhookEvents(ctx context.Context, limit int) (int, error)
+
+	// ClaimWebhookDeliveries leases due deliveries to a worker
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+
+	// RecordWebhookAttempt stores the outcome of a delivery attempt
+	RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt) error
+
+	// PruneWebhookDeliveries deletes finished deliveries completed before a time
+	PruneWebhookDeliveries(ctx context.Context, before time.Time) (int64, error)
+
+	// ReportURL records an abuse report and escalates the link's status when report thresholds are reached
+	ReportURL(ctx context.Context, report *models.LinkReport, reviewThreshold, suspendThreshold int) (string, error)
+
+	// SetURLStatus records a moderator's decision on a link
+	SetURLStatus(ctx context.Context, code, status string, reason *string) error
+
+	// GetURLModeration returns a link's moderation state and a page of its reports
+	GetURLModeration(ctx context.Context, code string, page, pageSize int) (*models.LinkModeration, error)
+
+	// FlagURL sets a link's status on behalf of an automatic check
+	FlagURL(ctx context.Context, code, status, reason string) error
+
+	// GetURLsForScan returns a page of live links that are not suspended, in ID order
+	GetURLsForScan(ctx context.Context, afterID int64, limit int) ([]models.ShortURL, error)
 
 	// Close closes the repository connection
 	Close() error
@@ -4,10 +4,12 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/lib/pq"
+	"github.com/urlshortener/internal/errors"
 	"github.com/urlshortener/internal/models"
-	_ "github.com/lib/pq"
 )
 
 // PostgresRepo implements the URL repository interface
@@ -15,6 +17,11 @@ type PostgresRepo struct {
 	db *sql.DB
 }
 
+// execer is satisfied by both *sql.DB and *sql.Tx
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
 // NewPostgresRepo creates a new PostgreSQL repository
 func NewPostgresRepo(dsn string) (*PostgresRepo, error) {
 	db, err := sql.Open("postgres", dsn)
@@ -35,28 +42,50 @@ func (r *PostgresRepo) Close() error {
 	return r.db.Close()
 }
 
-// CreateURL creates a new short URL
-func (r *PostgresRepo) CreateURL(ctx context.Context, url *models.ShortURL) error {
-	query := `
-		INSERT INTO short_urls (code, long_url, expire_at, custom_alias, created_by, metadata)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, created_at`
+// CreateURL creates a new short URL and attaches tags to it, creating tags
+// that do not exist yet. The link is only created if tagging succeeds.
+func (r *PostgresRepo) CreateURL(ctx context.Context, url *models.ShortURL, tags []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
 
-	err := r.db.QueryRowContext(ctx, query,
-		url.Code, url.LongURL, url.ExpireAt, url.CustomAlias, url.CreatedBy, url.Metadata,
-	).Scan(&url.ID, &url.CreatedAt)
+	query := `
+		INSERT INTO short_urls (code, long_url, original_url, expire_at, custom_alias, created_by, metadata, title,
+			show_interstitial, og_title, og_description, og_image, campaign_id, status, status_reason, status_changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, COALESCE(NULLIF($14, ''), 'active'), $15,
+			CASE WHEN $15::text IS NULL THEN NULL ELSE NOW() END)
+		RETURNING id, created_at, status`
+
+	err = tx.QueryRowContext(ctx, query,
+		url.Code, url.LongURL, url.OriginalURL, url.ExpireAt, url.CustomAlias, url.CreatedBy, url.Metadata,
+		url.Title, url.ShowInterstitial, url.OGTitle, url.OGDescription, url.OGImage, url.CampaignID,
+		url.Status, url.StatusReason,
+	).Scan(&url.ID, &url.CreatedAt, &url.Status)
 
 	if err != nil {
 		return fmt.Errorf("failed to create URL: %w", err)
 	}
 
+	if len(tags) > 0 {
+		if err := insertTags(ctx, tx, url.Code, tags); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit URL: %w", err)
+	}
+
 	return nil
 }
 
 // GetURLByCode retrieves a URL by its short code
 func (r *PostgresRepo) GetURLByCode(ctx context.Context, code string) (*models.ShortURL, error) {
 	query := `
-		SELECT id, code, long_url, created_at, expire_at, is_deleted, custom_alias, created_by, metadata
+		SELECT id, code, long_url, created_at, expire_at, is_deleted, custom_alias, created_by, metadata,
+			title, show_interstitial, og_title, og_description, og_image, campaign_id, status
 		FROM short_urls
 		WHERE code = $1 AND is_deleted = false`
 
@@ -64,6 +93,8 @@ func (r *PostgresRepo) GetURLByCode(ctx context.Context, code string) (*models.S
 	err := r.db.QueryRowContext(ctx, query, code).Scan(
 		&url.ID, &url.Code, &url.LongURL, &url.CreatedAt, &url.ExpireAt,
 		&url.IsDeleted, &url.CustomAlias, &url.CreatedBy, &url.Metadata,
+		&url.Title, &url.ShowInterstitial, &url.OGTitle, &url.OGDescription, &url.OGImage,
+		&url.CampaignID, &url.Status,
 	)
 
 	if err != nil {
@@ -85,17 +116,36 @@ func (r *PostgresRepo) GetURLByCode(ctx context.Context, code string) (*models.S
 func (r *PostgresRepo) GetURLMetadata(ctx context.Context, code string) (*models.URLMetadata, error) {
 	query := `
 		SELECT 
-			s.code, s.long_url, s.created_at, s.expire_at, s.is_deleted,
-			COALESCE(cs.total_clicks, 0) as total_clicks,
-			cs.last_access_at
+			s.code, s.long_url, s.original_url, s.created_at, s.expire_at, s.is_deleted,
+			COALESCE(cs.total_clicks, 0) as total_clicks, COALESCE(cs.bot_clicks, 0) as bot_clicks,
+			(SELECT COALESCE(SUM(v.visitors), 0) FROM daily_unique_visitors v WHERE v.code = s.code) AS unique_visitors,
+			cs.last_access_at, s.title, s.show_interstitial,
+			s.og_title, s.og_description, s.og_image, s.campaign_id, s.status,
+			ARRAY(
+				SELECT t.name FROM url_tags ut
+				JOIN tags t ON t.id = ut.tag_id
+				WHERE ut.code = s.code
+				ORDER BY t.name
+			) AS tags,
+			p.title, p.description, p.image_url, p.favicon_url, p.fetched_at
 		FROM short_urls s
 		LEFT JOIN click_stats cs ON s.code = cs.code
+		LEFT JOIN link_previews p ON s.code = p.code
 		WHERE s.code = $1 AND s.is_deleted = false`
 
 	metadata := &models.URLMetadata{}
+	var (
+		previewTitle, previewDescription sql.NullString
+		previewImage, previewFavicon     sql.NullString
+		previewFetchedAt                 sql.NullTime
+	)
 	err := r.db.QueryRowContext(ctx, query, code).Scan(
-		&metadata.Code, &metadata.LongURL, &metadata.CreatedAt, &metadata.ExpireAt,
-		&metadata.IsDeleted, &metadata.TotalClicks, &metadata.LastAccessAt,
+		&metadata.Code, &metadata.LongURL, &metadata.OriginalURL, &metadata.CreatedAt, &metadata.ExpireAt,
+		&metadata.IsDeleted, &metadata.TotalClicks, &metadata.BotClicks, &metadata.UniqueVisitors, &metadata.LastAccessAt,
+		&metadata.Title, &metadata.ShowInterstitial,
+		&metadata.OGTitle, &metadata.OGDescription, &metadata.OGImage, &metadata.CampaignID, &metadata.Status,
+		pq.Array(&metadata.Tags),
+		&previewTitle, &previewDescription, &previewImage, &previewFavicon, &previewFetchedAt,
 	)
 
 	if err != nil {
@@ -105,6 +155,16 @@ func (r *PostgresRepo) GetURLMetadata(ctx context.Context, code string) (*models
 		return nil, fmt.Errorf("failed to get URL metadata: %w", err)
 	}
 
+	if previewFetchedAt.Valid {
+		metadata.Preview = &models.LinkPreview{
+			Title:       previewTitle.String,
+			Description: previewDescription.String,
+			ImageURL:    previewImage.String,
+			FaviconURL:  previewFavicon.String,
+			FetchedAt:   previewFetchedAt.Time,
+		}
+	}
+
 	// Check if URL has expired
 	if metadata.ExpireAt != nil && time.Now().After(*metadata.ExpireAt) {
 		return nil, ErrURLExpired
@@ -134,19 +194,28 @@ func (r *PostgresRepo) DeleteURL(ctx context.Context, code string) error {
 	return nil
 }
 
-// RecordClick records a click event
+// RecordClick records a single click event
 func (r *PostgresRepo) RecordClick(ctx context.Context, event *models.ClickEvent) error {
-	query := `
-		INSERT INTO click_events (code, user_agent, ip_address, referer, country, device_type)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, ts`
-
-	err := r.db.QueryRowContext(ctx, query,
-		event.Code, event.UserAgent, event.IPAddress, event.Referer, event.Country, event.DeviceType,
-	).Scan(&event.ID, &event.Timestamp)
+	return r.RecordClicks(ctx, []*models.ClickEvent{event})
+}
 
+// UpdateLinkPreview stores metadata unfurled from the destination page
+func (r *PostgresRepo) UpdateLinkPreview(ctx context.Context, code string, preview *models.LinkPreview) error {
+	query := `
+		INSERT INTO link_previews (code, title, description, image_url, favicon_url, fetched_at)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6)
+		ON CONFLICT (code) DO UPDATE SET
+			title = EXCLUDED.title,
+			description = EXCLUDED.description,
+			image_url = EXCLUDED.image_url,
+			favicon_url = EXCLUDED.favicon_url,
+			fetched_at = EXCLUDED.fetched_at`
+
+	_, err := r.db.ExecContext(ctx, query,
+		code, preview.Title, preview.Description, preview.ImageURL, preview.FaviconURL, preview.FetchedAt,
+	)
 	if err != nil {
-		return fmt.Errorf("failed to record click: %w", err)
+		return fmt.Errorf("failed to update link preview: %w", err)
 	}
 
 	return nil
@@ -198,42 +267,70 @@ func (r *PostgresRepo) MarkURLsAsDeleted(ctx context.Context, codes []string) er
 	return nil
 }
 
-// GetURLsByUser gets URLs created by a specific user
-func (r *PostgresRepo) GetURLsByUser(ctx context.Context, user string, page, pageSize int) (*models.URLListResponse, error) {
+// GetURLsByUser gets URLs created by a specific user, optionally narrowed by tags or campaign
+func (r *PostgresRepo) GetURLsByUser(ctx context.Context, user string, filter models.URLFilter, page, pageSize int) (*models.URLListResponse, error) {
 	offset := (page - 1) * pageSize
 
+	where := []string{"s.created_by = $1", "s.is_deleted = false"}
+	args := []interface{}{user}
+
+	if filter.CampaignID != nil {
+		args = append(args, *filter.CampaignID)
+		where = append(where, fmt.Sprintf("s.campaign_id = $%d", len(args)))
+	}
+
+	if len(filter.Tags) > 0 {
+		args = append(args, pq.Array(filter.Tags), len(filter.Tags))
+		where = append(where, fmt.Sprintf(`s.code IN (
+			SELECT ut.code FROM url_tags ut
+			JOIN tags t ON t.id = ut.tag_id
+			WHERE t.name = ANY($%d)
+			GROUP BY ut.code
+			HAVING COUNT(*) = $%d)`, len(args)-1, len(args)))
+	}
+
+	conditions := strings.Join(where, " AND ")
+
 	// Get total count
-	countQuery := `SELECT COUNT(*) FROM short_urls WHERE created_by = $1 AND is_deleted = false`
+	countQuery := `SELECT COUNT(*) FROM short_urls s WHERE ` + conditions
 	var total int64
-	err := r.db.QueryRowContext(ctx, countQuery, user).Scan(&total)
+	err := r.db.QueryRowContext(ctx, countQuery, args...).Scan(&total)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get URL count: %w", err)
 	}
 
 	// Get URLs
-	query := `
+	query := fmt.Sprintf(`
 		SELECT 
 			s.code, s.long_url, s.created_at, s.expire_at, s.is_deleted,
-			COALESCE(cs.total_clicks, 0) as total_clicks,
-			cs.last_access_at
+			COALESCE(cs.total_clicks, 0) as total_clicks, COALESCE(cs.bot_clicks, 0) as bot_clicks,
+			cs.last_access_at, s.title, s.show_interstitial, s.campaign_id, s.status,
+			ARRAY(
+				SELECT t.name FROM url_tags ut
+				JOIN tags t ON t.id = ut.tag_id
+				WHERE ut.code = s.code
+				ORDER BY t.name
+			) AS tags
 		FROM short_urls s
 		LEFT JOIN click_stats cs ON s.code = cs.code
-		WHERE s.created_by = $1 AND s.is_deleted = false
+		WHERE %s
 		ORDER BY s.created_at DESC
-		LIMIT $2 OFFSET $3`
+		LIMIT $%d OFFSET $%d`, conditions, len(args)+1, len(args)+2)
 
-	rows, err := r.db.QueryContext(ctx, query, user, pageSize, offset)
+	rows, err := r.db.QueryContext(ctx, query, append(args, pageSize, offset)...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get URLs: %w", err)
 	}
 	defer rows.Close()
 
-	var urls []models.URLMetadata
+	urls := []models.URLMetadata{}
 	for rows.Next() {
 		var url models.URLMetadata
 		err := rows.Scan(
 			&url.Code, &url.LongURL, &url.CreatedAt, &url.ExpireAt,
-			&url.IsDeleted, &url.TotalClicks, &url.LastAccessAt,
+			&url.IsDeleted, &url.TotalClicks, &url.BotClicks, &url.LastAccessAt,
+			&url.Title, &url.ShowInterstitial, &url.CampaignID, &url.Status,
+			pq.Array(&url.Tags),
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan URL: %w", err)
@@ -257,6 +354,12 @@ func (r *PostgresRepo) GetURLsByUser(ctx context.Context, user string, page, pag
 
 // Custom errors
 var (
-	ErrURLNotFound = fmt.Errorf("URL not found")
-	ErrURLExpired  = fmt.Errorf("URL has expired")
+	ErrURLNotFound      = errors.ErrURLNotFound
+	ErrURLExpired       = errors.ErrURLExpired
+	ErrTagNotFound      = errors.NotFound("tag_not_found", "tag not found on URL")
+	ErrTooManyTags      = errors.Validation("too_many_tags", "URL has too many tags")
+	ErrCampaignNotFound = errors.NotFound("campaign_not_found", "campaign not found")
+	ErrCampaignExists   = errors.Conflict("campaign_exists", "campaign already exists")
+	ErrWebhookNotFound  = errors.NotFound("webhook_not_found", "webhook not found")
+	ErrDeliveryNotFound = errors.NotFound("delivery_not_found", "webhook delivery not found")
 )
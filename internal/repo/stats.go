@@ -0,0 +1,257 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/urlshortener/internal/models"
+)
+
+// Click dimensions broken down by the stats API
+const (
+	DimensionReferrer   = "referrer"
+	DimensionCountry    = "country"
+	DimensionDeviceType = "device_type"
+	DimensionBrowser    = "browser"
+)
+
+// clickDimensions maps each dimension to the click_events expression that
+// produces its value. Referrers are reduced to their host name.
+var clickDimensions = map[string]string{
+	DimensionReferrer: `CASE
+		WHEN referer IS NULL OR referer = '' THEN '(direct)'
+		ELSE COALESCE(lower(substring(referer from '^[A-Za-z][A-Za-z0-9+.-]*://([^/:?#]+)')), '(other)')
+	END`,
+	DimensionCountry:    `COALESCE(NULLIF(country, ''), 'unknown')`,
+	DimensionDeviceType: `COALESCE(NULLIF(device_type, ''), 'unknown')`,
+	DimensionBrowser:    `COALESCE(NULLIF(browser, ''), 'unknown')`,
+}
+
+// rollupLockID is the advisory lock key serializing rollup runs across instances
+const rollupLockID = 0x636c69636b73
+
+// GetClickSeries counts clicks on a URL in [from, to), bucketed by interval
+// (hour, day or week) in the given time zone, with bot clicks counted
+// separately. Empty buckets are omitted. Completed hours are read from the
+// rollup tables and the remainder from click_events.
+func (r *PostgresRepo) GetClickSeries(ctx context.Context, code string, from, to time.Time, interval, timezone string) ([]models.ClickBucket, error) {
+	query := `
+		WITH state AS (SELECT rolled_up_until AS cutoff FROM click_rollup_state),
+		hourly AS (
+			SELECT r.bucket, r.clicks, r.bot_clicks
+			FROM click_rollups_hourly r, state
+			WHERE r.code = $1 AND r.bucket >= $2 AND r.bucket < LEAST($3, state.cutoff)
+			UNION ALL
+			SELECT date_trunc('hour', e.ts), COUNT(*) FILTER (WHERE NOT e.is_bot), COUNT(*) FILTER (WHERE e.is_bot)
+			FROM click_events e
+			WHERE e.code = $1 AND e.ts >= GREATEST($2, (SELECT cutoff FROM state)) AND e.ts < $3
+			GROUP BY 1
+		)
+		SELECT date_trunc($4::text, bucket AT TIME ZONE $5::text) AT TIME ZONE $5::text AS start, SUM(clicks), SUM(bot_clicks)
+		FROM hourly
+		GROUP BY 1
+		ORDER BY 1`
+
+	rows, err := r.db.QueryContext(ctx, query, code, from, to, interval, timezone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get click series: %w", err)
+	}
+	defer rows.Close()
+
+	buckets := []models.ClickBucket{}
+	for rows.Next() {
+		var bucket models.ClickBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Clicks, &bucket.BotClicks); err != nil {
+			return nil, fmt.Errorf("failed to scan click bucket: %w", err)
+		}
+		buckets = append(buckets, bucket)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating click buckets: %w", err)
+	}
+
+	return buckets, nil
+}
+
+// GetTopDimension returns the most frequent values of a click dimension for
+// a URL in [from, to), ignoring bot clicks
+func (r *PostgresRepo) GetTopDimension(ctx context.Context, code, dimension string, from, to time.Time, limit int) ([]models.DimensionCount, error) {
+	expr, ok := clickDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unknown click dimension %q", dimension)
+	}
+
+	query := fmt.Sprintf(`
+		WITH state AS (SELECT rolled_up_until AS cutoff FROM click_rollup_state)
+		SELECT value, SUM(clicks) AS clicks
+		FROM (
+			SELECT r.value, r.clicks
+			FROM click_dimension_rollups_hourly r, state
+			WHERE r.code = $1 AND r.dimension = $2 AND r.bucket >= $3 AND r.bucket < LEAST($4, state.cutoff)
+			UNION ALL
+			SELECT %s, COUNT(*)
+			FROM click_events e
+			WHERE e.code = $1 AND e.ts >= GREATEST($3, (SELECT cutoff FROM state)) AND e.ts < $4 AND NOT e.is_bot
+			GROUP BY 1
+		) t
+		GROUP BY value
+		ORDER BY clicks DESC, value
+		LIMIT $5`, expr)
+
+	rows, err := r.db.QueryContext(ctx, query, code, dimension, from, to, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top %s: %w", dimension, err)
+	}
+	defer rows.Close()
+
+	counts := []models.DimensionCount{}
+	for rows.Next() {
+		var count models.DimensionCount
+		if err := rows.Scan(&count.Value, &count.Clicks); err != nil {
+			return nil, fmt.Errorf("failed to scan %s count: %w", dimension, err)
+		}
+		counts = append(counts, count)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating %s counts: %w", dimension, err)
+	}
+
+	return counts, nil
+}
+
+// GetRollupWatermark returns the time before which click rollups are
+// complete, or the zero time if no rollup has run
+func (r *PostgresRepo) GetRollupWatermark(ctx context.Context) (time.Time, error) {
+	var watermark time.Time
+	err := r.db.QueryRowContext(ctx, `SELECT rolled_up_until FROM click_rollup_state`).Scan(&watermark)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to get rollup watermark: %w", err)
+	}
+
+	return watermark, nil
+}
+
+// RollupClicks recomputes the hourly rollups for [from, to) from
+// click_events and advances the watermark to to. Recomputing is idempotent,
+// so overlapping ranges pick up clicks that were written late.
+func (r *PostgresRepo) RollupClicks(ctx context.Context, from, to time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, rollupLockID); err != nil {
+		return fmt.Errorf("failed to lock rollups: %w", err)
+	}
+
+	statements := []string{
+		`DELETE FROM click_rollups_hourly WHERE bucket >= $1 AND bucket < $2`,
+		`INSERT INTO click_rollups_hourly (code, bucket, clicks, bot_clicks)
+		SELECT code, date_trunc('hour', ts), COUNT(*) FILTER (WHERE NOT is_bot), COUNT(*) FILTER (WHERE is_bot)
+		FROM click_events
+		WHERE ts >= $1 AND ts < $2
+		GROUP BY 1, 2`,
+		`DELETE FROM click_dimension_rollups_hourly WHERE bucket >= $1 AND bucket < $2`,
+	}
+	for dimension, expr := range clickDimensions {
+		statements = append(statements, fmt.Sprintf(`
+		INSERT INTO click_dimension_rollups_hourly (code, bucket, dimension, value, clicks)
+		SELECT code, date_trunc('hour', ts), '%s', %s, COUNT(*)
+		FROM click_events
+		WHERE ts >= $1 AND ts < $2 AND NOT is_bot
+		GROUP BY 1, 2, 4`, dimension, expr))
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.ExecContext(ctx, statement, from, to); err != nil {
+			return fmt.Errorf("failed to roll up clicks: %w", err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO click_rollup_state (rolled_up_until) VALUES ($1)
+		ON CONFLICT (id) DO UPDATE SET
+			rolled_up_until = GREATEST(click_rollup_state.rolled_up_until, EXCLUDED.rolled_up_until)`, to)
+	if err != nil {
+		return fmt.Errorf("failed to update rollup watermark: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit rollups: %w", err)
+	}
+
+	return nil
+}
+
+// GetDailyVisitors returns the unique visitor estimates for a URL on the UTC
+// days in [from, to)
+func (r *PostgresRepo) GetDailyVisitors(ctx context.Context, code string, from, to time.Time) ([]models.DailyVisitors, error) {
+	query := `
+		SELECT day, visitors
+		FROM daily_unique_visitors
+		WHERE code = $1 AND day >= ($2::timestamptz AT TIME ZONE 'UTC')::date AND day < $3::timestamptz AT TIME ZONE 'UTC'
+		ORDER BY day`
+
+	rows, err := r.db.QueryContext(ctx, query, code, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get daily visitors: %w", err)
+	}
+	defer rows.Close()
+
+	days := []models.DailyVisitors{}
+	for rows.Next() {
+		var day models.DailyVisitors
+		if err := rows.Scan(&day.Day, &day.Visitors); err != nil {
+			return nil, fmt.Errorf("failed to scan daily visitors: %w", err)
+		}
+		day.Day = day.Day.UTC()
+		days = append(days, day)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating daily visitors: %w", err)
+	}
+
+	return days, nil
+}
+
+// SaveDailyVisitors stores unique visitor estimates for one UTC day. An
+// estimate never lowers a stored value, so a Redis restart cannot erase
+// counts that were already persisted.
+func (r *PostgresRepo) SaveDailyVisitors(ctx context.Context, day time.Time, visitors map[string]int64) error {
+	if len(visitors) == 0 {
+		return nil
+	}
+
+	codes := make([]string, 0, len(visitors))
+	counts := make([]int64, 0, len(visitors))
+	for code, count := range visitors {
+		codes = append(codes, code)
+		counts = append(counts, count)
+	}
+
+	// Links deleted since the click are skipped rather than failing the batch
+	query := `
+		INSERT INTO daily_unique_visitors (code, day, visitors)
+		SELECT v.code, $1::date, v.visitors
+		FROM unnest($2::text[], $3::bigint[]) AS v(code, visitors)
+		JOIN short_urls s ON s.code = v.code
+		ON CONFLICT (code, day) DO UPDATE SET
+			visitors = GREATEST(daily_unique_visitors.visitors, EXCLUDED.visitors)`
+
+	_, err := r.db.ExecContext(ctx, query, day.UTC().Format("2006-01-02"), pq.Array(codes), pq.Array(counts))
+	if err != nil {
+		return fmt.Errorf("failed to save daily visitors: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,317 @@
+package repo
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+	"github.com/urlshortener/internal/models"
+)
+
+// CreateWebhook registers a webhook subscription
+func (r *PostgresRepo) CreateWebhook(ctx context.Context, webhook *models.WebhookSubscription) error {
+	query := `
+		INSERT INTO webhook_subscriptions (owner, url, secret, events, click_thresholds)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, active, created_at`
+
+	err := r.db.QueryRowContext(ctx, query,
+		webhook.Owner, webhook.URL, webhook.Secret,
+		pq.Array(webhook.Events), pq.Array(webhook.ClickThresholds),
+	).Scan(&webhook.ID, &webhook.Active, &webhook.CreatedAt)
+
+	if err != nil {
+		return fmt.Errorf("failed to create webhook: %w", err)
+	}
+
+	return nil
+}
+
+// GetWebhook retrieves a webhook subscription by ID, without its secret
+func (r *PostgresRepo) GetWebhook(ctx context.Context, id int64) (*models.WebhookSubscription, error) {
+	query := `
+		SELECT id, owner, url, events, click_thresholds, active, created_at
+		FROM webhook_subscriptions
+		WHERE id = $1`
+
+	webhook := &models.WebhookSubscription{}
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&webhook.ID, &webhook.Owner, &webhook.URL, pq.Array(&webhook.Events),
+		pq.Array(&webhook.ClickThresholds), &webhook.Active, &webhook.CreatedAt,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("failed to get webhook: %w", err)
+	}
+
+	return webhook, nil
+}
+
+// GetWebhooksByOwner lists the webhook subscriptions of an owner, without their secrets
+func (r *PostgresRepo) GetWebhooksByOwner(ctx context.Context, owner string) ([]models.WebhookSubscription, error) {
+	query := `
+		SELECT id, owner, url, events, click_thresholds, active, created_at
+		FROM webhook_subscriptions
+		WHERE owner = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.QueryContext(ctx, query, owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := []models.WebhookSubscription{}
+	for rows.Next() {
+		var webhook models.WebhookSubscription
+		err := rows.Scan(
+			&webhook.ID, &webhook.Owner, &webhook.URL, pq.Array(&webhook.Events),
+			pq.Array(&webhook.ClickThresholds), &webhook.Active, &webhook.CreatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook: %w", err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook subscription together with its delivery log
+func (r *PostgresRepo) DeleteWebhook(ctx context.Context, id int64) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM webhook_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveries returns a page of a subscription's delivery log, newest first
+func (r *PostgresRepo) GetWebhookDeliveries(ctx context.Context, subscriptionID int64, status string, page, pageSize int) (*models.WebhookDeliveryList, error) {
+	offset := (page - 1) * pageSize
+
+	conditions := `subscription_id = $1 AND ($2 = '' OR status = $2)`
+
+	var total int64
+	err := r.db.QueryRowContext(ctx,
+		`SELECT COUNT(*) FROM webhook_deliveries WHERE `+conditions, subscriptionID, status,
+	).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count webhook deliveries: %w", err)
+	}
+
+	query := `
+		SELECT id, subscription_id, event_id, event_type, payload, status, attempts,
+			CASE WHEN status = 'pending' THEN next_attempt_at END,
+			last_status_code, last_error, created_at, completed_at
+		FROM webhook_deliveries
+		WHERE ` + conditions + `
+		ORDER BY created_at DESC, id DESC
+		LIMIT $3 OFFSET $4`
+
+	rows, err := r.db.QueryContext(ctx, query, subscriptionID, status, pageSize, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		err := rows.Scan(
+			&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+			&d.NextAttemptAt, &d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.CompletedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+
+	return &models.WebhookDeliveryList{
+		Deliveries: deliveries,
+		Pagination: models.Pagination{
+			Page:     page,
+			PageSize: pageSize,
+		},
+		Total: total,
+	}, nil
+}
+
+// RetryWebhookDelivery requeues a dead-lettered delivery with a fresh set of attempts
+func (r *PostgresRepo) RetryWebhookDelivery(ctx context.Context, subscriptionID, deliveryID int64) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET status = 'pending', attempts = 0, next_attempt_at = NOW(), completed_at = NULL
+		WHERE id = $1 AND subscription_id = $2 AND status = 'dead'`
+
+	result, err := r.db.ExecContext(ctx, query, deliveryID, subscriptionID)
+	if err != nil {
+		return fmt.Errorf("failed to retry webhook delivery: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if rowsAffected == 0 {
+		return ErrDeliveryNotFound
+	}
+
+	return nil
+}
+
+// DispatchWebhookEvents moves up to limit outbox events into one delivery per
+// matching active subscription and returns the number of events consumed.
+// Threshold events only go to subscriptions that asked for that threshold.
+func (r *PostgresRepo) DispatchWebhookEvents(ctx context.Context, limit int) (int, error) {
+	query := `
+		WITH events AS (
+			DELETE FROM webhook_outbox
+			WHERE id IN (
+				SELECT id FROM webhook_outbox
+				ORDER BY id
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, owner, event_type, payload, threshold, created_at
+		), deliveries AS (
+			INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload)
+			SELECT s.id, e.id, e.event_type, jsonb_build_object(
+				'id', e.id,
+				'type', e.event_type,
+				'created_at', e.created_at,
+				'data', e.payload
+			)
+			FROM events e
+			JOIN webhook_subscriptions s
+				ON s.owner = e.owner
+				AND s.active
+				AND e.event_type = ANY(s.events)
+				AND (e.threshold IS NULL OR e.threshold = ANY(s.click_thresholds))
+			RETURNING 1
+		)
+		SELECT COUNT(*) FROM events`
+
+	var count int
+	if err := r.db.QueryRowContext(ctx, query, limit).Scan(&count); err != nil {
+		return 0, fmt.Errorf("failed to dispatch webhook events: %w", err)
+	}
+
+	return count, nil
+}
+
+// ClaimWebhookDeliveries returns up to limit due deliveries with their
+// endpoint details and pushes their next attempt past the lease, so other
+// workers skip them while they are being sent
+func (r *PostgresRepo) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	query := `
+		UPDATE webhook_deliveries d
+		SET next_attempt_at = NOW() + make_interval(secs => $2)
+		FROM webhook_subscriptions s
+		WHERE s.id = d.subscription_id
+			AND d.id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'pending' AND next_attempt_at <= NOW()
+				ORDER BY next_attempt_at
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			)
+		RETURNING d.id, d.subscription_id, d.event_id, d.event_type, d.payload,
+			d.status, d.attempts, d.created_at, s.url, s.secret`
+
+	rows, err := r.db.QueryContext(ctx, query, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		var d models.WebhookDelivery
+		err := rows.Scan(
+			&d.ID, &d.SubscriptionID, &d.EventID, &d.EventType, &d.Payload,
+			&d.Status, &d.Attempts, &d.CreatedAt, &d.URL, &d.Secret,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// RecordWebhookAttempt stores the outcome of a delivery attempt. A failed
+// attempt without a retry time dead-letters the delivery.
+func (r *PostgresRepo) RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt) error {
+	query := `
+		UPDATE webhook_deliveries
+		SET attempts = attempts + 1,
+			last_status_code = NULLIF($2, 0),
+			last_error = NULLIF($3, ''),
+			status = CASE
+				WHEN $4 THEN 'succeeded'
+				WHEN $5::timestamptz IS NULL THEN 'dead'
+				ELSE 'pending'
+			END,
+			next_attempt_at = COALESCE($5, next_attempt_at),
+			completed_at = CASE WHEN $4 OR $5::timestamptz IS NULL THEN NOW() END
+		WHERE id = $1`
+
+	_, err := r.db.ExecContext(ctx, query,
+		attempt.DeliveryID, attempt.StatusCode, attempt.Error, attempt.Succeeded, attempt.RetryAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+
+	return nil
+}
+
+// PruneWebhookDeliveries deletes finished deliveries completed before a time
+func (r *PostgresRepo) PruneWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM webhook_deliveries
+		WHERE status IN ('succeeded', 'dead') AND completed_at < $1`
+
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune webhook deliveries: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	return rowsAffected, nil
+}
@@ -0,0 +1,301 @@
+package safety
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// canonicalURL is a URL in the canonical form used for Safe Browsing lookups
+type canonicalURL struct {
+	scheme string
+	host   string
+	path   string
+	query  string // including the leading "?", if any
+	ip     bool
+}
+
+// String returns the canonical URL
+func (u canonicalURL) String() string {
+	return u.scheme + "://" + u.host + u.path + u.query
+}
+
+// canonicalize canonicalizes a URL as specified for Safe Browsing lookups.
+// Whitespace and the fragment are removed and percent-escapes are decoded
+// repeatedly. The host is lowercased, with IPv4 addresses in any notation
+// turned into dotted decimal, and the path is resolved. The result is
+// escaped again. User info and port are dropped.
+func canonicalize(raw string) (canonicalURL, error) {
+	raw = strings.Trim(raw, " \t\r\n\f\v")
+
+	stripped := make([]byte, 0, len(raw))
+	for i := 0; i < len(raw); i++ {
+		if c := raw[i]; c != '\t' && c != '\r' && c != '\n' {
+			stripped = append(stripped, c)
+		}
+	}
+	s := string(stripped)
+	if i := strings.IndexByte(s, '#'); i >= 0 {
+		s = s[:i]
+	}
+
+	for {
+		next := unescape(s)
+		if next == s {
+			break
+		}
+		s = next
+	}
+
+	u := canonicalURL{scheme: "http"}
+	if i := strings.Index(s, "://"); i > 0 && isScheme(s[:i]) {
+		u.scheme, s = strings.ToLower(s[:i]), s[i+3:]
+	}
+
+	end := strings.IndexAny(s, "/?")
+	if end < 0 {
+		end = len(s)
+	}
+	host, path := s[:end], s[end:]
+	if i := strings.IndexByte(path, '?'); i >= 0 {
+		path, u.query = path[:i], path[i:]
+	}
+
+	host, u.ip = canonicalHost(host)
+	if host == "" {
+		return canonicalURL{}, fmt.Errorf("%w: missing host", ErrInvalidURL)
+	}
+
+	u.host = escape(host)
+	u.path = escape(canonicalPath(path))
+	u.query = escape(u.query)
+	return u, nil
+}
+
+// expressions returns the host suffix and path prefix combinations that are
+// looked up for a URL: the host and up to four of its parent domains, each
+// with the exact path, with and without query, and up to four directory
+// prefixes of the path
+func (u canonicalURL) expressions() []string {
+	hosts := []string{u.host}
+	if !u.ip {
+		labels := strings.Split(u.host, ".")
+		start := len(labels) - 5
+		if start < 1 {
+			start = 1
+		}
+		// The top-level domain on its own is never looked up
+		for i := start; i < len(labels)-1; i++ {
+			hosts = append(hosts, strings.Join(labels[i:], "."))
+		}
+	}
+
+	var paths []string
+	if u.query != "" {
+		paths = append(paths, u.path+u.query)
+	}
+	paths = append(paths, u.path)
+	segments := strings.Split(strings.TrimPrefix(u.path, "/"), "/")
+	prefix := "/"
+	for i := 0; i < len(segments) && i < 4; i++ {
+		paths = append(paths, prefix)
+		if i == len(segments)-1 {
+			break
+		}
+		prefix += segments[i] + "/"
+	}
+
+	seen := make(map[string]bool)
+	var expressions []string
+	for _, host := range hosts {
+		for _, path := range paths {
+			if expression := host + path; !seen[expression] {
+				seen[expression] = true
+				expressions = append(expressions, expression)
+			}
+		}
+	}
+	return expressions
+}
+
+// canonicalHost lowercases a host, drops user info, port and stray dots, and
+// normalizes IPv4 addresses. It reports whether the host is an IP address.
+func canonicalHost(host string) (string, bool) {
+	if i := strings.LastIndexByte(host, '@'); i >= 0 {
+		host = host[i+1:]
+	}
+	if strings.HasPrefix(host, "[") {
+		if i := strings.IndexByte(host, ']'); i >= 0 {
+			return asciiLower(host[:i+1]), true
+		}
+	}
+	if i := strings.LastIndexByte(host, ':'); i >= 0 {
+		host = host[:i]
+	}
+
+	var labels []string
+	for _, label := range strings.Split(host, ".") {
+		if label != "" {
+			labels = append(labels, label)
+		}
+	}
+	host = asciiLower(strings.Join(labels, "."))
+
+	if ip := parseIPv4(host); ip != nil {
+		return ip.String(), true
+	}
+	return host, false
+}
+
+// HostIP returns the address a URL host denotes when it is an IP literal,
+// including IPv4 addresses in the octal, hex and shortened notations browsers
+// accept, or nil when the host is a name
+func HostIP(host string) net.IP {
+	host, ip := canonicalHost(host)
+	if !ip {
+		return nil
+	}
+	return net.ParseIP(strings.Trim(host, "[]"))
+}
+
+// parseIPv4 parses an IPv4 address in any notation browsers accept: one to
+// four parts, each decimal, octal with a leading zero or hex with a leading
+// 0x, where the last part fills the remaining bytes
+func parseIPv4(host string) net.IP {
+	parts := strings.Split(host, ".")
+	if len(parts) > 4 {
+		return nil
+	}
+
+	var addr uint64
+	for i, part := range parts {
+		base := 10
+		switch {
+		case len(part) > 2 && (part[:2] == "0x" || part[:2] == "0X"):
+			base, part = 16, part[2:]
+		case len(part) > 1 && part[0] == '0':
+			base, part = 8, part[1:]
+		}
+		value, err := strconv.ParseUint(part, base, 32)
+		if err != nil || part == "" || part[0] == '+' || part[0] == '-' {
+			return nil
+		}
+
+		if i < len(parts)-1 {
+			if value > 0xff {
+				return nil
+			}
+			addr = addr<<8 | value
+			continue
+		}
+		remaining := uint(4 - i)
+		if value >= 1<<(8*remaining) {
+			return nil
+		}
+		addr = addr<<(8*remaining) | value
+	}
+
+	return net.IPv4(byte(addr>>24), byte(addr>>16), byte(addr>>8), byte(addr))
+}
+
+// canonicalPath resolves "." and ".." segments and repeated slashes
+func canonicalPath(path string) string {
+	segments := strings.Split(path, "/")
+	var resolved []string
+	trailing := false
+	for i, segment := range segments {
+		last := i == len(segments)-1
+		switch segment {
+		case "", ".":
+			trailing = last
+		case "..":
+			if len(resolved) > 0 {
+				resolved = resolved[:len(resolved)-1]
+			}
+			trailing = last
+		default:
+			resolved = append(resolved, segment)
+		}
+	}
+
+	result := "/" + strings.Join(resolved, "/")
+	if trailing && len(resolved) > 0 {
+		result += "/"
+	}
+	return result
+}
+
+// unescape decodes percent-escapes once, keeping invalid ones as they are
+func unescape(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]) {
+			b = append(b, unhex(s[i+1])<<4|unhex(s[i+2]))
+			i += 2
+			continue
+		}
+		b = append(b, s[i])
+	}
+	return string(b)
+}
+
+// escape percent-escapes control characters, spaces, non-ASCII bytes, "#"
+// and "%"
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= 0x20 || c >= 0x7f || c == '#' || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
+// asciiLower lowercases ASCII letters and leaves other bytes alone
+func asciiLower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// isScheme reports whether s is a valid URL scheme
+func isScheme(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of a hexadecimal digit
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,62 @@
+package safety
+
+import (
+	"context"
+	"fmt"
+)
+
+// Common threat types
+const (
+	ThreatMalware  = "malware"
+	ThreatPhishing = "phishing"
+	ThreatUnwanted = "unwanted"
+	ThreatBlocked  = "blocked" // listed by the operator without a category
+)
+
+// Verdict describes why a URL was flagged
+type Verdict struct {
+	Threat string `json:"threat"` // the threat type of the list that matched
+	Source string `json:"source"` // the list that matched
+	Rule   string `json:"rule"`   // the list entry or URL expression that matched
+}
+
+// String describes the verdict
+func (v *Verdict) String() string {
+	return fmt.Sprintf("%s (%s matched %s)", v.Threat, v.Source, v.Rule)
+}
+
+// Checker looks URLs up in a list of unsafe destinations
+type Checker interface {
+	// Check returns a verdict if the URL is listed, or nil if it is not
+	Check(ctx context.Context, rawURL string) (*Verdict, error)
+}
+
+// Chain runs checkers in order and returns the first verdict. A checker
+// that fails does not stop the others; its error is only returned if no
+// other checker flags the URL.
+type Chain []Checker
+
+// Check runs every checker until one flags the URL
+func (c Chain) Check(ctx context.Context, rawURL string) (*Verdict, error) {
+	var firstErr error
+	for _, checker := range c {
+		verdict, err := checker.Check(ctx, rawURL)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if verdict != nil {
+			return verdict, nil
+		}
+	}
+	return nil, firstErr
+}
+
+// Custom errors
+var (
+	ErrInvalidURL  = fmt.Errorf("invalid URL")
+	ErrInvalidList = fmt.Errorf("invalid safety list")
+	ErrInvalidRule = fmt.Errorf("invalid safety rule")
+)
@@ -0,0 +1,224 @@
+package safety
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDomainList(t *testing.T) {
+	list := NewDomainList("blocked_domains", ThreatBlocked, []string{"evil.com", "=exact.example", "*.wild.example", "195.127.0.11"})
+
+	tests := []struct {
+		url  string
+		rule string
+	}{
+		{"https://evil.com/login", "evil.com"},
+		{"https://www.EVIL.com./login", "evil.com"},
+		{"https://notevil.com/", ""},
+		{"https://evil.com.example.org/", ""},
+		{"https://exact.example/", "=exact.example"},
+		{"https://www.exact.example/", ""},
+		{"https://wild.example/", "wild.example"},
+		{"https://a.wild.example/", "wild.example"},
+		{"http://3279880203/", "195.127.0.11"},
+	}
+
+	for _, tt := range tests {
+		verdict, err := list.Check(context.Background(), tt.url)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.url, err)
+		}
+		switch {
+		case tt.rule == "" && verdict != nil:
+			t.Errorf("%s: expected no match, got %v", tt.url, verdict)
+		case tt.rule != "" && (verdict == nil || verdict.Rule != tt.rule || verdict.Threat != ThreatBlocked):
+			t.Errorf("%s: expected a match on %s, got %v", tt.url, tt.rule, verdict)
+		}
+	}
+
+	if !list.Contains("WWW.Evil.com") || list.Contains("evil.co") {
+		t.Error("unexpected Contains result")
+	}
+}
+
+func TestParseDomainList(t *testing.T) {
+	list := ParseDomainList("feed.txt", ThreatPhishing, []byte("# phishing domains\n\nphish.example\n  =login.example  \n"))
+	if list.Len() != 2 {
+		t.Errorf("expected 2 entries, got %d", list.Len())
+	}
+}
+
+// hashPrefixes returns the prefixes of the hashes of URL expressions
+func hashPrefixes(size int, expressions ...string) []byte {
+	var data []byte
+	for _, expression := range expressions {
+		sum := sha256.Sum256([]byte(expression))
+		data = append(data, sum[:size]...)
+	}
+	return data
+}
+
+func TestHashList(t *testing.T) {
+	// Prefixes are not sorted on disk here; the list sorts them
+	data := hashPrefixes(4, "malware.example/", "evil.com/phish/", "other.example/x.html", "1.2.3.4/")
+	list, err := ParseHashList("malware.bin", ThreatMalware, 4, data)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if list.Len() != 4 {
+		t.Errorf("expected 4 prefixes, got %d", list.Len())
+	}
+
+	tests := []struct {
+		url  string
+		rule string
+	}{
+		{"http://malware.example/", "malware.example/"},
+		{"https://cdn.malware.example/payload.exe", "malware.example/"},
+		{"http://evil.com/phish/login.html?u=1", "evil.com/phish/"},
+		{"http://evil.com/%70hish/", "evil.com/phish/"},
+		{"http://evil.com/", ""},
+		{"http://other.example/x.html", "other.example/x.html"},
+		{"http://other.example/y.html", ""},
+		{"http://0x01020304/anything", "1.2.3.4/"},
+	}
+
+	for _, tt := range tests {
+		verdict, err := list.Check(context.Background(), tt.url)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.url, err)
+		}
+		switch {
+		case tt.rule == "" && verdict != nil:
+			t.Errorf("%s: expected no match, got %v", tt.url, verdict)
+		case tt.rule != "" && (verdict == nil || verdict.Rule != tt.rule):
+			t.Errorf("%s: expected a match on %s, got %v", tt.url, tt.rule, verdict)
+		}
+	}
+}
+
+func TestParseHashListRejectsInvalidData(t *testing.T) {
+	if _, err := ParseHashList("bad.bin", ThreatMalware, 4, make([]byte, 6)); !errors.Is(err, ErrInvalidList) {
+		t.Errorf("expected ErrInvalidList for a truncated prefix, got %v", err)
+	}
+	if _, err := ParseHashList("bad.bin", ThreatMalware, 2, make([]byte, 6)); !errors.Is(err, ErrInvalidList) {
+		t.Errorf("expected ErrInvalidList for a short prefix size, got %v", err)
+	}
+}
+
+func TestRegexList(t *testing.T) {
+	list, err := NewRegexList("rules", []RegexRule{
+		{Pattern: `^https?://[^/]*paypal[^/]*\.(xyz|top)/`, Threat: ThreatPhishing},
+		{Pattern: `\.exe$`},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	verdict, _ := list.Check(context.Background(), "https://secure-PAYPAL.xyz/login")
+	if verdict == nil || verdict.Threat != ThreatPhishing {
+		t.Errorf("expected a phishing match, got %v", verdict)
+	}
+	// Rules match the canonical URL, so escaping does not hide a match
+	verdict, _ = list.Check(context.Background(), "https://example.com/setup%2Eexe")
+	if verdict == nil || verdict.Threat != ThreatBlocked {
+		t.Errorf("expected a default threat match, got %v", verdict)
+	}
+	if verdict, _ := list.Check(context.Background(), "https://www.paypal.com/"); verdict != nil {
+		t.Errorf("expected no match, got %v", verdict)
+	}
+
+	if _, err := NewRegexList("rules", []RegexRule{{Pattern: "("}}); !errors.Is(err, ErrInvalidRule) {
+		t.Errorf("expected ErrInvalidRule, got %v", err)
+	}
+}
+
+type failingChecker struct{}
+
+func (failingChecker) Check(ctx context.Context, rawURL string) (*Verdict, error) {
+	return nil, errors.New("lookup failed")
+}
+
+func TestChain(t *testing.T) {
+	chain := Chain{
+		failingChecker{},
+		NewDomainList("first", ThreatPhishing, []string{"evil.com"}),
+		NewDomainList("second", ThreatMalware, []string{"evil.com", "bad.example"}),
+	}
+
+	verdict, err := chain.Check(context.Background(), "https://evil.com/")
+	if err != nil || verdict == nil || verdict.Source != "first" {
+		t.Errorf("expected the first matching list to win, got %v, %v", verdict, err)
+	}
+	verdict, err = chain.Check(context.Background(), "https://bad.example/")
+	if err != nil || verdict == nil || verdict.Source != "second" {
+		t.Errorf("expected a match despite the failing checker, got %v, %v", verdict, err)
+	}
+	if _, err := chain.Check(context.Background(), "https://good.example/"); err == nil {
+		t.Error("expected the checker error without a match")
+	}
+}
+
+func TestFeedReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "domains.txt")
+
+	var errs []error
+	feed := NewDomainFeed(path, ThreatPhishing, func(err error) { errs = append(errs, err) })
+	if feed.Loaded() || len(errs) != 1 {
+		t.Fatalf("expected a missing feed to be reported, got loaded=%v errors=%v", feed.Loaded(), errs)
+	}
+	if verdict, err := feed.Check(context.Background(), "https://evil.com/"); verdict != nil || err != nil {
+		t.Errorf("expected an unloaded feed to flag nothing, got %v, %v", verdict, err)
+	}
+
+	if err := os.WriteFile(path, []byte("evil.com\n"), 0o644); err != nil {
+		t.Fatalf("failed to write feed: %v", err)
+	}
+	feed.Reload()
+	if verdict, _ := feed.Check(context.Background(), "https://evil.com/"); verdict == nil {
+		t.Error("expected a match after the feed appeared")
+	}
+
+	if err := os.WriteFile(path, []byte("other.example\n"), 0o644); err != nil {
+		t.Fatalf("failed to write feed: %v", err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("failed to touch feed: %v", err)
+	}
+	feed.Reload()
+	if verdict, _ := feed.Check(context.Background(), "https://evil.com/"); verdict != nil {
+		t.Errorf("expected the reloaded feed to drop the entry, got %v", verdict)
+	}
+}
+
+func TestHashFeedKeepsListOnInvalidFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "malware.bin")
+	if err := os.WriteFile(path, hashPrefixes(32, "evil.com/"), 0o644); err != nil {
+		t.Fatalf("failed to write feed: %v", err)
+	}
+
+	var errs []error
+	feed := NewHashFeed(path, ThreatMalware, 32, func(err error) { errs = append(errs, err) })
+	if !feed.Loaded() {
+		t.Fatalf("expected the feed to load, got %v", errs)
+	}
+
+	if err := os.WriteFile(path, []byte("truncated"), 0o644); err != nil {
+		t.Fatalf("failed to write feed: %v", err)
+	}
+	if err := os.Chtimes(path, time.Now(), time.Now().Add(time.Minute)); err != nil {
+		t.Fatalf("failed to touch feed: %v", err)
+	}
+	feed.Reload()
+	if len(errs) != 1 {
+		t.Errorf("expected the invalid file to be reported, got %v", errs)
+	}
+	if verdict, _ := feed.Check(context.Background(), "http://evil.com/"); verdict == nil {
+		t.Error("expected the previous list to stay in use")
+	}
+}
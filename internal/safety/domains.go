@@ -0,0 +1,109 @@
+package safety
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"strings"
+)
+
+// DomainList matches URLs by host. An entry matches its domain and every
+// subdomain, on label boundaries: "evil.com" matches "evil.com" and
+// "www.evil.com" but not "notevil.com". An entry starting with "=" matches
+// only that exact host.
+type DomainList struct {
+	source string
+	threat string
+	suffix map[string]bool
+	exact  map[string]bool
+}
+
+// NewDomainList creates a domain list. Entries may also be IP addresses,
+// which match every notation of the address.
+func NewDomainList(source, threat string, domains []string) *DomainList {
+	l := &DomainList{
+		source: source,
+		threat: threat,
+		suffix: make(map[string]bool),
+		exact:  make(map[string]bool),
+	}
+	for _, domain := range domains {
+		l.add(domain)
+	}
+	return l
+}
+
+// ParseDomainList parses a domain list file with one entry per line. Blank
+// lines and lines starting with "#" are ignored.
+func ParseDomainList(source, threat string, data []byte) *DomainList {
+	var domains []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			domains = append(domains, line)
+		}
+	}
+	return NewDomainList(source, threat, domains)
+}
+
+// add adds an entry to the list
+func (l *DomainList) add(domain string) {
+	domain = strings.TrimSpace(domain)
+	exact := strings.HasPrefix(domain, "=")
+	// "*.evil.com" is accepted as another way to write "evil.com"
+	host, _ := canonicalHost(strings.TrimPrefix(strings.TrimPrefix(domain, "="), "*."))
+	if host == "" {
+		return
+	}
+	if exact {
+		l.exact[host] = true
+	} else {
+		l.suffix[host] = true
+	}
+}
+
+// Len returns the number of entries
+func (l *DomainList) Len() int {
+	return len(l.suffix) + len(l.exact)
+}
+
+// Check flags URLs whose host is listed
+func (l *DomainList) Check(ctx context.Context, rawURL string) (*Verdict, error) {
+	u, err := canonicalize(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if entry, ok := l.match(u.host, u.ip); ok {
+		return &Verdict{Threat: l.threat, Source: l.source, Rule: entry}, nil
+	}
+	return nil, nil
+}
+
+// Contains reports whether a host is listed
+func (l *DomainList) Contains(host string) bool {
+	host, ip := canonicalHost(host)
+	_, ok := l.match(host, ip)
+	return ok
+}
+
+// match returns the entry that matches a canonical host
+func (l *DomainList) match(host string, ip bool) (string, bool) {
+	if l.exact[host] {
+		return "=" + host, true
+	}
+	if ip {
+		return host, l.suffix[host]
+	}
+	for domain := host; ; {
+		if l.suffix[domain] {
+			return domain, true
+		}
+		i := strings.IndexByte(domain, '.')
+		if i < 0 {
+			return "", false
+		}
+		domain = domain[i+1:]
+	}
+}
@@ -0,0 +1,54 @@
+package safety
+
+import (
+	"context"
+
+	"github.com/urlshortener/internal/filewatch"
+)
+
+// Feed is a list loaded from a local file, such as a blocklist that another
+// process keeps up to date. The file is reloaded when its size or
+// modification time changes. A feed whose file has never loaded flags
+// nothing; a feed whose file becomes invalid keeps its current list.
+type Feed struct {
+	file *filewatch.File[Checker]
+}
+
+// NewFeed creates a feed of the file at path, parsed by parse, and loads it
+func NewFeed(path string, parse func(data []byte) (Checker, error), onError func(error)) *Feed {
+	return &Feed{file: filewatch.New(path, parse, onError)}
+}
+
+// NewDomainFeed creates a feed of a domain list file
+func NewDomainFeed(path, threat string, onError func(error)) *Feed {
+	return NewFeed(path, func(data []byte) (Checker, error) {
+		return ParseDomainList(path, threat, data), nil
+	}, onError)
+}
+
+// NewHashFeed creates a feed of a file of raw hash prefixes of size bytes each
+func NewHashFeed(path, threat string, size int, onError func(error)) *Feed {
+	return NewFeed(path, func(data []byte) (Checker, error) {
+		return ParseHashList(path, threat, size, data)
+	}, onError)
+}
+
+// Loaded reports whether the feed's file has been loaded
+func (f *Feed) Loaded() bool {
+	_, ok := f.file.Value()
+	return ok
+}
+
+// Check looks the URL up in the current list
+func (f *Feed) Check(ctx context.Context, rawURL string) (*Verdict, error) {
+	checker, ok := f.file.Value()
+	if !ok {
+		return nil, nil
+	}
+	return checker.Check(ctx, rawURL)
+}
+
+// Reload loads the file if it differs from the one in use
+func (f *Feed) Reload() {
+	f.file.Reload()
+}
@@ -0,0 +1,101 @@
+package safety
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+)
+
+// FullHashSize is the size of a full SHA-256 hash, which lists can use to
+// match only the URLs they list
+const FullHashSize = sha256.Size
+
+// HashList matches URLs against SHA-256 hash prefixes of URL expressions, as
+// stored in a Safe Browsing local database. Prefixes are kept sorted in one
+// slice, so large lists stay compact.
+//
+// A prefix shorter than a full hash can match URLs that are not listed.
+// Safe Browsing clients confirm such matches with a full hash request; a
+// HashList has no server to ask, so lists of full 32-byte hashes avoid
+// false positives.
+type HashList struct {
+	source   string
+	threat   string
+	size     int
+	prefixes []byte
+}
+
+// ParseHashList parses raw hash prefixes of size bytes each, concatenated
+// as in the rawHashes of a Safe Browsing threat list update
+func ParseHashList(source, threat string, size int, data []byte) (*HashList, error) {
+	if size < 4 || size > FullHashSize {
+		return nil, fmt.Errorf("%w: %s: prefix size must be between 4 and %d bytes", ErrInvalidList, source, FullHashSize)
+	}
+	if len(data)%size != 0 {
+		return nil, fmt.Errorf("%w: %s: length %d is not a multiple of the prefix size %d", ErrInvalidList, source, len(data), size)
+	}
+
+	prefixes := make([]byte, len(data))
+	copy(prefixes, data)
+	sort.Sort(prefixSlice{data: prefixes, size: size})
+
+	return &HashList{
+		source:   source,
+		threat:   threat,
+		size:     size,
+		prefixes: prefixes,
+	}, nil
+}
+
+// Len returns the number of hash prefixes
+func (l *HashList) Len() int {
+	return len(l.prefixes) / l.size
+}
+
+// Check flags URLs with an expression whose hash starts with a listed prefix
+func (l *HashList) Check(ctx context.Context, rawURL string) (*Verdict, error) {
+	u, err := canonicalize(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, expression := range u.expressions() {
+		sum := sha256.Sum256([]byte(expression))
+		if l.contains(sum[:l.size]) {
+			return &Verdict{Threat: l.threat, Source: l.source, Rule: expression}, nil
+		}
+	}
+	return nil, nil
+}
+
+// contains reports whether a prefix is listed
+func (l *HashList) contains(prefix []byte) bool {
+	n := l.Len()
+	i := sort.Search(n, func(i int) bool {
+		return bytes.Compare(l.prefixes[i*l.size:(i+1)*l.size], prefix) >= 0
+	})
+	return i < n && bytes.Equal(l.prefixes[i*l.size:(i+1)*l.size], prefix)
+}
+
+// prefixSlice sorts fixed-size prefixes stored back to back
+type prefixSlice struct {
+	data []byte
+	size int
+}
+
+func (p prefixSlice) Len() int {
+	return len(p.data) / p.size
+}
+
+func (p prefixSlice) Less(i, j int) bool {
+	return bytes.Compare(p.data[i*p.size:(i+1)*p.size], p.data[j*p.size:(j+1)*p.size]) < 0
+}
+
+func (p prefixSlice) Swap(i, j int) {
+	a, b := p.data[i*p.size:(i+1)*p.size], p.data[j*p.size:(j+1)*p.size]
+	for k := range a {
+		a[k], b[k] = b[k], a[k]
+	}
+}
@@ -0,0 +1,59 @@
+package safety
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+)
+
+// RegexRule flags URLs matching a regular expression
+type RegexRule struct {
+	Pattern string
+	Threat  string
+}
+
+// compiledRule is a RegexRule with its compiled expression
+type compiledRule struct {
+	RegexRule
+	re *regexp.Regexp
+}
+
+// RegexList matches canonical URLs against regular expressions. URLs are
+// canonicalized first, so rules see "http://evil.com/login" however the
+// URL was encoded.
+type RegexList struct {
+	source string
+	rules  []compiledRule
+}
+
+// NewRegexList compiles a list of rules
+func NewRegexList(source string, rules []RegexRule) (*RegexList, error) {
+	l := &RegexList{source: source}
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q: %v", ErrInvalidRule, rule.Pattern, err)
+		}
+		if rule.Threat == "" {
+			rule.Threat = ThreatBlocked
+		}
+		l.rules = append(l.rules, compiledRule{RegexRule: rule, re: re})
+	}
+	return l, nil
+}
+
+// Check flags URLs that match any rule
+func (l *RegexList) Check(ctx context.Context, rawURL string) (*Verdict, error) {
+	u, err := canonicalize(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	canonical := u.String()
+	for _, rule := range l.rules {
+		if rule.re.MatchString(canonical) {
+			return &Verdict{Threat: rule.Threat, Source: l.source, Rule: rule.Pattern}, nil
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,163 @@
+package safety
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+// ScanStore lists the links to re-check
+type ScanStore interface {
+	// GetURLsForScan returns up to limit links that are not suspended, with
+	// IDs above afterID, in ID order
+	GetURLsForScan(ctx context.Context, afterID int64, limit int) ([]models.ShortURL, error)
+}
+
+// Suspender suspends links whose destination was flagged
+type Suspender interface {
+	SuspendUnsafeURL(ctx context.Context, code string, verdict *Verdict) error
+}
+
+// ScannerConfig holds scanner configuration
+type ScannerConfig struct {
+	Interval  time.Duration // time between scans
+	BatchSize int           // links loaded per query
+	Timeout   time.Duration // time limit for a single scan
+}
+
+// Scanner periodically re-checks the destinations of existing links, so
+// links created before their destination was listed are caught too
+type Scanner struct {
+	store   ScanStore
+	checker Checker
+	suspend Suspender
+	config  ScannerConfig
+	onError func(error)
+	onFlag  func(code string, verdict *Verdict)
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewScanner creates a scanner. onFlag is called for every link suspended.
+func NewScanner(store ScanStore, checker Checker, suspend Suspender, config ScannerConfig, onError func(error), onFlag func(code string, verdict *Verdict)) *Scanner {
+	if config.Interval <= 0 {
+		config.Interval = 6 * time.Hour
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 500
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = time.Hour
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+	if onFlag == nil {
+		onFlag = func(string, *Verdict) {}
+	}
+
+	return &Scanner{
+		store:   store,
+		checker: checker,
+		suspend: suspend,
+		config:  config,
+		onError: onError,
+		onFlag:  onFlag,
+	}
+}
+
+// Start scans in the background every interval until Stop is called. The
+// first scan runs after one interval, so restarts do not trigger scans.
+func (s *Scanner) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	s.wg.Add(1)
+	go s.run(ctx)
+}
+
+// Stop cancels any in-flight scan and waits for the scanner to exit
+func (s *Scanner) Stop() {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	s.wg.Wait()
+}
+
+// run scans on every tick
+func (s *Scanner) run(ctx context.Context) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runWithTimeout(ctx)
+		}
+	}
+}
+
+// runWithTimeout performs one scan, reporting its failure
+func (s *Scanner) runWithTimeout(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, s.config.Timeout)
+	defer cancel()
+
+	if _, err := s.RunOnce(ctx); err != nil && ctx.Err() == nil {
+		s.onError(err)
+	}
+}
+
+// RunOnce checks every link that is not suspended or cleared by a moderator
+// and suspends those whose destination is flagged. It returns the number of
+// links suspended. Links that fail to be checked are reported to onError
+// and skipped.
+func (s *Scanner) RunOnce(ctx context.Context) (int, error) {
+	var afterID int64
+	suspended := 0
+	for {
+		urls, err := s.store.GetURLsForScan(ctx, afterID, s.config.BatchSize)
+		if err != nil {
+			return suspended, fmt.Errorf("failed to list links: %w", err)
+		}
+
+		for _, url := range urls {
+			afterID = url.ID
+			if clearedByModerator(&url) {
+				// A moderator already decided this link is safe
+				continue
+			}
+
+			verdict, err := s.checker.Check(ctx, url.LongURL)
+			if err != nil {
+				s.onError(fmt.Errorf("failed to check link %s: %w", url.Code, err))
+				continue
+			}
+			if verdict == nil {
+				continue
+			}
+
+			if err := s.suspend.SuspendUnsafeURL(ctx, url.Code, verdict); err != nil {
+				return suspended, fmt.Errorf("failed to suspend link %s: %w", url.Code, err)
+			}
+			suspended++
+			s.onFlag(url.Code, verdict)
+		}
+
+		if len(urls) < s.config.BatchSize {
+			return suspended, nil
+		}
+	}
+}
+
+// clearedByModerator reports whether a moderator reviewed the link and left
+// it active, such as after unsuspending a false positive
+func clearedByModerator(url *models.ShortURL) bool {
+	return url.ReviewedAt != nil && url.Status == models.LinkActive
+}
@@ -0,0 +1,153 @@
+package safety
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+type fakeScanStore struct {
+	urls  []models.ShortURL
+	calls int
+}
+
+func (s *fakeScanStore) GetURLsForScan(ctx context.Context, afterID int64, limit int) ([]models.ShortURL, error) {
+	s.calls++
+	var page []models.ShortURL
+	for _, url := range s.urls {
+		if url.ID > afterID && len(page) < limit {
+			page = append(page, url)
+		}
+	}
+	return page, nil
+}
+
+type fakeSuspender struct {
+	suspended map[string]*Verdict
+	err       error
+}
+
+func (s *fakeSuspender) SuspendUnsafeURL(ctx context.Context, code string, verdict *Verdict) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.suspended[code] = verdict
+	return nil
+}
+
+func TestScannerRunOnce(t *testing.T) {
+	store := &fakeScanStore{urls: []models.ShortURL{
+		{ID: 1, Code: "good1", LongURL: "https://example.com/"},
+		{ID: 2, Code: "bad1", LongURL: "https://evil.com/login"},
+		{ID: 5, Code: "good2", LongURL: "https://example.org/"},
+		{ID: 7, Code: "bad2", LongURL: "https://cdn.evil.com/x"},
+		{ID: 9, Code: "good3", LongURL: "https://notevil.com/"},
+	}}
+	suspender := &fakeSuspender{suspended: make(map[string]*Verdict)}
+	checker := NewDomainList("blocked_domains", ThreatBlocked, []string{"evil.com"})
+
+	var flagged []string
+	scanner := NewScanner(store, checker, suspender, ScannerConfig{BatchSize: 2}, nil, func(code string, verdict *Verdict) {
+		flagged = append(flagged, code)
+	})
+
+	count, err := scanner.RunOnce(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if count != 2 || len(suspender.suspended) != 2 || suspender.suspended["bad1"] == nil || suspender.suspended["bad2"] == nil {
+		t.Errorf("expected bad1 and bad2 to be suspended, got %d: %v", count, suspender.suspended)
+	}
+	if len(flagged) != 2 {
+		t.Errorf("expected 2 flag callbacks, got %v", flagged)
+	}
+	// Three pages of two, the last one short
+	if store.calls != 3 {
+		t.Errorf("expected 3 queries, got %d", store.calls)
+	}
+}
+
+func TestScannerSkipsLinksThatFailToBeChecked(t *testing.T) {
+	store := &fakeScanStore{urls: []models.ShortURL{
+		{ID: 1, Code: "broken", LongURL: "http:///nohost"},
+		{ID: 2, Code: "bad", LongURL: "https://evil.com/"},
+	}}
+	suspender := &fakeSuspender{suspended: make(map[string]*Verdict)}
+	checker := NewDomainList("blocked_domains", ThreatBlocked, []string{"evil.com"})
+
+	var errs []error
+	scanner := NewScanner(store, checker, suspender, ScannerConfig{}, func(err error) { errs = append(errs, err) }, nil)
+
+	count, err := scanner.RunOnce(context.Background())
+	if err != nil || count != 1 {
+		t.Errorf("expected 1 link to be suspended, got %d, %v", count, err)
+	}
+	if len(errs) != 1 {
+		t.Errorf("expected the failed check to be reported, got %v", errs)
+	}
+}
+
+func TestScannerStopsWhenSuspendingFails(t *testing.T) {
+	store := &fakeScanStore{urls: []models.ShortURL{{ID: 1, Code: "bad", LongURL: "https://evil.com/"}}}
+	suspender := &fakeSuspender{err: errors.New("database unavailable")}
+	checker := NewDomainList("blocked_domains", ThreatBlocked, []string{"evil.com"})
+
+	scanner := NewScanner(store, checker, suspender, ScannerConfig{}, nil, nil)
+	if _, err := scanner.RunOnce(context.Background()); err == nil {
+		t.Error("expected the suspension error")
+	}
+}
+
+// statusStore is a scan store that keeps link statuses like the database
+type statusStore struct {
+	urls []models.ShortURL
+}
+
+func (s *statusStore) GetURLsForScan(ctx context.Context, afterID int64, limit int) ([]models.ShortURL, error) {
+	var page []models.ShortURL
+	for _, url := range s.urls {
+		if url.ID > afterID && url.Status != models.LinkSuspended && len(page) < limit {
+			page = append(page, url)
+		}
+	}
+	return page, nil
+}
+
+func (s *statusStore) SuspendUnsafeURL(ctx context.Context, code string, verdict *Verdict) error {
+	for i := range s.urls {
+		if s.urls[i].Code == code {
+			s.urls[i].Status = models.LinkSuspended
+		}
+	}
+	return nil
+}
+
+func TestScannerKeepsLinksClearedByModerator(t *testing.T) {
+	store := &statusStore{urls: []models.ShortURL{
+		{ID: 1, Code: "falsepos", LongURL: "https://evil.com/", Status: models.LinkActive},
+		{ID: 2, Code: "bad", LongURL: "https://cdn.evil.com/", Status: models.LinkActive},
+	}}
+	checker := NewDomainList("blocked_domains", ThreatBlocked, []string{"evil.com"})
+	scanner := NewScanner(store, checker, store, ScannerConfig{}, nil, nil)
+
+	count, err := scanner.RunOnce(context.Background())
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 links to be suspended, got %d, %v", count, err)
+	}
+
+	// A moderator unsuspends the false positive
+	reviewedAt := time.Now()
+	store.urls[0].Status = models.LinkActive
+	store.urls[0].ReviewedAt = &reviewedAt
+
+	count, err = scanner.RunOnce(context.Background())
+	if err != nil || count != 0 {
+		t.Errorf("expected no links to be suspended on rescan, got %d, %v", count, err)
+	}
+	if store.urls[0].Status != models.LinkActive {
+		t.Errorf("expected the cleared link to stay active, got %s", store.urls[0].Status)
+	}
+}
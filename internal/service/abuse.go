@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"strings"
+
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/privacy"
+)
+
+// ReportURL records a visitor's abuse report on a link. Each client network
+// counts once per link, and links are moved under review or suspended once
+// enough reports have been made since the last moderator review.
+func (s *ShortenerService) ReportURL(ctx context.Context, code string, req *models.ReportURLRequest, ipAddress string) error {
+	reason := strings.ToLower(strings.TrimSpace(req.Reason))
+	valid := false
+	for _, r := range models.ReportReasons {
+		if reason == r {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ErrInvalidReport.WithFields(errors.Field("reason", "must be one of "+strings.Join(models.ReportReasons, ", ")))
+	}
+
+	report := &models.LinkReport{
+		Code:     code,
+		Reason:   reason,
+		Details:  req.Details,
+		Reporter: reporterID(s.config.ReporterSecret, code, ipAddress),
+	}
+	status, err := s.repo.ReportURL(ctx, report, s.config.ReportReviewThreshold, s.config.ReportSuspendThreshold)
+	if err != nil {
+		return err
+	}
+
+	// Redirects must see the new status right away
+	if status != models.LinkActive {
+		if err := s.cache.Delete(ctx, code); err != nil {
+			// Log error but don't fail the request
+		}
+	}
+
+	return nil
+}
+
+// SuspendURL stops a link from redirecting
+func (s *ShortenerService) SuspendURL(ctx context.Context, code string, req *models.LinkStatusRequest) error {
+	return s.setURLStatus(ctx, code, models.LinkSuspended, req.Reason)
+}
+
+// UnsuspendURL restores a suspended or reviewed link. Earlier reports no
+// longer count towards automatic suspension.
+func (s *ShortenerService) UnsuspendURL(ctx context.Context, code string, req *models.LinkStatusRequest) error {
+	return s.setURLStatus(ctx, code, models.LinkActive, req.Reason)
+}
+
+// GetURLModeration returns a link's moderation state and a page of its reports
+func (s *ShortenerService) GetURLModeration(ctx context.Context, code string, page, pageSize int) (*models.LinkModeration, error) {
+	return s.repo.GetURLModeration(ctx, code, page, pageSize)
+}
+
+// setURLStatus stores a moderator's decision and invalidates the cached link
+func (s *ShortenerService) setURLStatus(ctx context.Context, code, status, reason string) error {
+	if err := s.repo.SetURLStatus(ctx, code, status, nilIfEmpty(strings.TrimSpace(reason))); err != nil {
+		return err
+	}
+
+	if err := s.cache.Delete(ctx, code); err != nil {
+		// Log error but don't fail the request
+	}
+
+	return nil
+}
+
+// reporterID identifies a reporter per link without storing their address.
+// Reporters are keyed by their /24 (IPv4) or /48 (IPv6) network, so a single
+// host cannot rotate through its addresses to report a link many times. The
+// hash is keyed with a server-side secret; an unkeyed hash could be reversed
+// by hashing every IPv4 network.
+func reporterID(secret []byte, code, ipAddress string) string {
+	if ip := net.ParseIP(ipAddress); ip != nil {
+		ipAddress = privacy.TruncateIP(ip).String()
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(code + "|" + ipAddress))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Custom errors
+var (
+	ErrInvalidReport = errors.Validation("invalid_report", "invalid report")
+)
@@ -0,0 +1,24 @@
+package service
+
+import "testing"
+
+func TestReporterIDKeysByNetwork(t *testing.T) {
+	secret := []byte("secret")
+	id := func(ip string) string { return reporterID(secret, "abc123", ip) }
+
+	if id("2001:db8:1:2::1") != id("2001:db8:1:ffff::99") {
+		t.Error("expected addresses in one IPv6 /48 to count as one reporter")
+	}
+	if id("203.0.113.7") != id("203.0.113.200") {
+		t.Error("expected addresses in one IPv4 /24 to count as one reporter")
+	}
+	if id("203.0.113.7") == id("198.51.100.7") {
+		t.Error("expected different networks to count as different reporters")
+	}
+	if id("203.0.113.7") == reporterID(secret, "xyz789", "203.0.113.7") {
+		t.Error("expected reporter IDs to differ between links")
+	}
+	if id("203.0.113.7") == reporterID([]byte("other"), "abc123", "203.0.113.7") {
+		t.Error("expected reporter IDs to depend on the secret")
+	}
+}
@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/repo"
+)
+
+const (
+	maxTagLength  = 64
+	maxTagsPerURL = 20
+)
+
+// AddTags attaches tags to a URL and returns the normalized tag names
+func (s *ShortenerService) AddTags(ctx context.Context, code string, tags []string) ([]string, error) {
+	normalized, err := normalizeTags(tags)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.AddTags(ctx, code, normalized, maxTagsPerURL); err != nil {
+		if errors.Is(err, repo.ErrTooManyTags) {
+			return nil, repo.ErrTooManyTags.WithFields(errors.Field("tags", fmt.Sprintf("at most %d tags per URL", maxTagsPerURL)))
+		}
+		return nil, err
+	}
+
+	return normalized, nil
+}
+
+// RemoveTag detaches a tag from a URL
+func (s *ShortenerService) RemoveTag(ctx context.Context, code, tag string) error {
+	return s.repo.RemoveTag(ctx, code, strings.ToLower(strings.TrimSpace(tag)))
+}
+
+// GetUserURLs lists a user's URLs, optionally narrowed by tags or campaign
+func (s *ShortenerService) GetUserURLs(ctx context.Context, user string, filter models.URLFilter, page, pageSize int) (*models.URLListResponse, error) {
+	if len(filter.Tags) > 0 {
+		tags, err := normalizeTags(filter.Tags)
+		if err != nil {
+			return nil, err
+		}
+		filter.Tags = tags
+	}
+
+	return s.repo.GetURLsByUser(ctx, user, filter, page, pageSize)
+}
+
+// CreateCampaign creates a campaign owned by user
+func (s *ShortenerService) CreateCampaign(ctx context.Context, user string, req *models.CreateCampaignRequest) (*models.Campaign, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, ErrInvalidCampaign.WithFields(errors.Field("name", "must not be blank"))
+	}
+
+	campaign := &models.Campaign{
+		Name:        name,
+		Description: req.Description,
+		CreatedBy:   user,
+	}
+
+	if err := s.repo.CreateCampaign(ctx, campaign); err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+// GetCampaign retrieves a campaign by ID
+func (s *ShortenerService) GetCampaign(ctx context.Context, id int64) (*models.Campaign, error) {
+	return s.repo.GetCampaign(ctx, id)
+}
+
+// GetUserCampaigns lists the campaigns owned by user
+func (s *ShortenerService) GetUserCampaigns(ctx context.Context, user string) ([]models.Campaign, error) {
+	return s.repo.GetCampaignsByUser(ctx, user)
+}
+
+// GetCampaignURLs lists the URLs assigned to a campaign
+func (s *ShortenerService) GetCampaignURLs(ctx context.Context, id int64, page, pageSize int) (*models.URLListResponse, error) {
+	campaign, err := s.repo.GetCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetURLsByUser(ctx, campaign.CreatedBy, models.URLFilter{CampaignID: &campaign.ID}, page, pageSize)
+}
+
+// GetCampaignStats aggregates click statistics over a campaign's URLs
+func (s *ShortenerService) GetCampaignStats(ctx context.Context, id int64) (*models.CampaignStats, error) {
+	return s.repo.GetCampaignStats(ctx, id)
+}
+
+// SetURLCampaign assigns a URL to a campaign, or removes it when campaignID is nil.
+// Links can only join campaigns owned by their creator.
+func (s *ShortenerService) SetURLCampaign(ctx context.Context, code string, campaignID *int64) error {
+	if campaignID != nil {
+		url, err := s.repo.GetURLByCode(ctx, code)
+		if err != nil {
+			return err
+		}
+		if err := s.checkCampaignOwner(ctx, *campaignID, url.CreatedBy); err != nil {
+			return err
+		}
+	}
+
+	return s.repo.SetURLCampaign(ctx, code, campaignID)
+}
+
+// checkCampaignOwner verifies that a campaign belongs to the link owner
+func (s *ShortenerService) checkCampaignOwner(ctx context.Context, campaignID int64, owner *string) error {
+	campaign, err := s.repo.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return err
+	}
+
+	if owner == nil || *owner != campaign.CreatedBy {
+		return ErrCampaignOwner
+	}
+
+	return nil
+}
+
+// normalizeTags lowercases, trims and de-duplicates tag names
+func normalizeTags(tags []string) ([]string, error) {
+	seen := make(map[string]bool, len(tags))
+	normalized := make([]string, 0, len(tags))
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || len(tag) > maxTagLength {
+			return nil, ErrInvalidTag.WithFields(errors.Field("tags", fmt.Sprintf("must be 1-%d characters", maxTagLength)))
+		}
+		for _, r := range tag {
+			if !isTagChar(r) {
+				return nil, ErrInvalidTag.WithFields(errors.Field("tags", fmt.Sprintf("%q contains unsupported characters", tag)))
+			}
+		}
+		if !seen[tag] {
+			seen[tag] = true
+			normalized = append(normalized, tag)
+		}
+	}
+
+	if len(normalized) > maxTagsPerURL {
+		return nil, ErrInvalidTag.WithFields(errors.Field("tags", fmt.Sprintf("at most %d tags per URL", maxTagsPerURL)))
+	}
+
+	return normalized, nil
+}
+
+// isTagChar reports whether r may appear in a tag name
+func isTagChar(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_' || r == '.' || r == ' '
+}
+
+// Custom errors
+var (
+	ErrInvalidTag      = errors.Validation("invalid_tag", "invalid tag")
+	ErrInvalidCampaign = errors.Validation("invalid_campaign", "invalid campaign")
+	ErrCampaignOwner   = errors.Forbidden("campaign_forbidden", "campaign belongs to a different owner")
+)
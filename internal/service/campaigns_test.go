@@ -0,0 +1,55 @@
+package service
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/urlshortener/internal/errors"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	tooMany := make([]string, maxTagsPerURL+1)
+	for i := range tooMany {
+		tooMany[i] = fmt.Sprintf("tag-%d", i)
+	}
+	// Duplicates collapse before the limit is applied
+	duplicated := append(append([]string{}, tooMany[:maxTagsPerURL]...), "TAG-0", " tag-1 ")
+
+	tests := []struct {
+		name     string
+		tags     []string
+		expected []string
+		wantErr  bool
+	}{
+		{"valid", []string{"launch", "q3_2024", "v1.2", "spring sale"}, []string{"launch", "q3_2024", "v1.2", "spring sale"}, false},
+		{"lowercased and trimmed", []string{"  Launch ", "EMAIL"}, []string{"launch", "email"}, false},
+		{"duplicates removed in order", []string{"b", "a", "B", "a "}, []string{"b", "a"}, false},
+		{"longest tag", []string{strings.Repeat("x", maxTagLength)}, []string{strings.Repeat("x", maxTagLength)}, false},
+		{"too long", []string{strings.Repeat("x", maxTagLength+1)}, nil, true},
+		{"empty", []string{"ok", "   "}, nil, true},
+		{"unsupported characters", []string{"tag/with/slashes"}, nil, true},
+		{"non-ASCII", []string{"café"}, nil, true},
+		{"too many", tooMany, nil, true},
+		{"at the limit after dedupe", duplicated, tooMany[:maxTagsPerURL], false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := normalizeTags(tt.tags)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidTag) {
+					t.Errorf("expected ErrInvalidTag, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(result, tt.expected) {
+				t.Errorf("expected %v, got %v", tt.expected, result)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/netutil"
+	"github.com/urlshortener/internal/safety"
+)
+
+// resolveTimeout bounds the DNS lookup made for a new link's destination
+const resolveTimeout = 2 * time.Second
+
+// Resolver looks up the addresses of a host name. *net.Resolver satisfies it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// WithResolver replaces the resolver used to check that destination hosts
+// resolve to public addresses
+func WithResolver(resolver Resolver) Option {
+	return func(s *ShortenerService) {
+		s.resolver = resolver
+	}
+}
+
+// newShortenerList lists our own host and the configured shortener domains,
+// whose links are not accepted as destinations
+func newShortenerList(baseURL string, domains []string) *safety.DomainList {
+	entries := append([]string{}, domains...)
+	if parsed, err := url.Parse(baseURL); err == nil && parsed.Hostname() != "" {
+		entries = append(entries, parsed.Hostname())
+	}
+	return safety.NewDomainList("shortener_domains", "", entries)
+}
+
+// checkDestination rejects destinations that point back at a URL shortener,
+// which could chain redirects into a loop, and destinations on loopback,
+// private, link-local or other non-public addresses. Hosts that fail to
+// resolve are accepted: the unfurler and webhook worker check addresses
+// again when they connect.
+func (s *ShortenerService) checkDestination(ctx context.Context, longURL string) error {
+	parsed, err := url.Parse(longURL)
+	if err != nil {
+		return fmt.Errorf("invalid URL format: %w", err)
+	}
+	host := parsed.Hostname()
+
+	if s.shorteners.Contains(host) {
+		return ErrRedirectLoop.WithFields(errors.Field("url", host+" is a URL shortener"))
+	}
+
+	// HostIP takes the bracketed form of IPv6 literals, as in the URL
+	if ip := safety.HostIP(parsed.Host); ip != nil {
+		if !netutil.IsPublicIP(ip) {
+			return ErrPrivateDestination
+		}
+		return nil
+	}
+
+	// localhost names never reach the public internet (RFC 6761)
+	name := strings.ToLower(strings.TrimSuffix(host, "."))
+	if name == "localhost" || strings.HasSuffix(name, ".localhost") {
+		return ErrPrivateDestination
+	}
+
+	if s.resolver == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	addrs, err := s.resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+	// The address is not reported, so link creation cannot be used to probe
+	// internal DNS
+	for _, addr := range addrs {
+		if !netutil.IsPublicIP(addr.IP) {
+			return ErrPrivateDestination
+		}
+	}
+
+	return nil
+}
+
+// Custom errors
+var (
+	ErrPrivateDestination = errors.Validation("url_private_address", "URL destination is not a public address")
+	ErrRedirectLoop       = errors.Validation("url_redirect_loop", "URL destination is a URL shortener")
+)
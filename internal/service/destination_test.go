@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/urlshortener/internal/errors"
+)
+
+// fakeResolver resolves host names from a fixed table
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ips, ok := r[host]
+	if !ok {
+		return nil, fmt.Errorf("no such host: %s", host)
+	}
+	addrs := make([]net.IPAddr, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.IPAddr{IP: net.ParseIP(ip)}
+	}
+	return addrs, nil
+}
+
+func TestCheckDestination(t *testing.T) {
+	resolver := fakeResolver{
+		"example.com":      {"93.184.216.34"},
+		"internal.example": {"10.1.2.3"},
+		"mixed.example":    {"93.184.216.34", "192.168.0.10"},
+		"v6.example":       {"2606:2800:220:1::1"},
+	}
+	s := NewShortenerService(nil, nil, Config{
+		BaseURL:          "https://sho.rt",
+		ShortenerDomains: []string{"bit.ly"},
+	}, WithResolver(resolver))
+
+	tests := []struct {
+		name string
+		url  string
+		want error
+	}{
+		{"public host", "https://example.com/page", nil},
+		{"public IPv6 host", "https://v6.example/", nil},
+		{"unresolvable host", "https://nowhere.example/", nil},
+		{"public IPv4 literal", "http://93.184.216.34/", nil},
+		{"loopback", "http://127.0.0.1/", ErrPrivateDestination},
+		{"IPv6 loopback", "http://[::1]/", ErrPrivateDestination},
+		{"private IPv4", "http://192.168.1.1/admin", ErrPrivateDestination},
+		{"IPv4-mapped IPv6", "http://[::ffff:10.0.0.1]/", ErrPrivateDestination},
+		{"NAT64", "http://[64:ff9b::a00:1]/", ErrPrivateDestination},
+		{"octal IPv4", "http://0177.0.0.1/", ErrPrivateDestination},
+		{"hex IPv4", "http://0x7f000001/", ErrPrivateDestination},
+		{"cloud metadata", "http://169.254.169.254/latest/meta-data/", ErrPrivateDestination},
+		{"localhost", "http://localhost:8080/", ErrPrivateDestination},
+		{"localhost subdomain", "http://app.localhost/", ErrPrivateDestination},
+		{"resolves to private", "https://internal.example/", ErrPrivateDestination},
+		{"any address private", "https://mixed.example/", ErrPrivateDestination},
+		{"own host", "https://sho.rt/abc123", ErrRedirectLoop},
+		{"shortener", "https://bit.ly/xyz", ErrRedirectLoop},
+		{"shortener subdomain", "https://go.bit.ly/xyz", ErrRedirectLoop},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.checkDestination(context.Background(), tt.url)
+			if tt.want == nil {
+				if err != nil {
+					t.Errorf("expected %s to be accepted, got %v", tt.url, err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v for %s, got %v", tt.want, tt.url, err)
+			}
+			if tt.want == ErrPrivateDestination && err != ErrPrivateDestination {
+				t.Errorf("expected no address details for %s, got %v", tt.url, err)
+			}
+		})
+	}
+}
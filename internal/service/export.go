@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/export"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/repo"
+)
+
+// defaultExportSpan is the range exported when no start is requested
+const defaultExportSpan = 30 * 24 * time.Hour
+
+// ClickExport is a validated click export ready to be streamed
+type ClickExport struct {
+	Format   export.Format
+	Filename string
+	filter   models.ClickExportFilter
+	repo     repo.URLRepository
+}
+
+// NewClickExport validates a request to export the click events of a link,
+// or of every link created by user when code is empty
+func (s *ShortenerService) NewClickExport(ctx context.Context, code, user string, query models.ExportQuery) (*ClickExport, error) {
+	format, err := export.ParseFormat(query.Format)
+	if err != nil {
+		return nil, ErrInvalidExportQuery.WithFields(errors.Field("format", "must be one of csv, ndjson, parquet"))
+	}
+
+	to := time.Now()
+	if query.To != "" {
+		if to, err = parseStatsTime("to", query.To, time.UTC); err != nil {
+			return nil, ErrInvalidExportQuery.WithFields(errors.Field("to", "must be RFC 3339 or YYYY-MM-DD"))
+		}
+	}
+	from := to.Add(-defaultExportSpan)
+	if query.From != "" {
+		if from, err = parseStatsTime("from", query.From, time.UTC); err != nil {
+			return nil, ErrInvalidExportQuery.WithFields(errors.Field("from", "must be RFC 3339 or YYYY-MM-DD"))
+		}
+	}
+	if !from.Before(to) {
+		return nil, ErrInvalidExportQuery.WithFields(errors.Field("from", "must be before to"))
+	}
+
+	name := "clicks"
+	if code != "" {
+		if _, err := s.lookupURL(ctx, code); err != nil {
+			return nil, err
+		}
+		name += "-" + code
+	}
+
+	return &ClickExport{
+		Format:   format,
+		Filename: fmt.Sprintf("%s-%s-%s.%s", name, from.UTC().Format("20060102"), to.UTC().Format("20060102"), format.Extension()),
+		filter: models.ClickExportFilter{
+			Code: code,
+			User: user,
+			From: from,
+			To:   to,
+		},
+		repo: s.repo,
+	}, nil
+}
+
+// Stream writes the export to w
+func (e *ClickExport) Stream(ctx context.Context, w io.Writer) error {
+	writer := export.NewWriter(e.Format, w)
+
+	err := e.repo.ExportClickEvents(ctx, e.filter, writer.Write)
+	if closeErr := writer.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Custom errors
+var (
+	ErrInvalidExportQuery = errors.Validation("invalid_export_query", "invalid export query")
+)
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urlshortener/internal/live"
+)
+
+// SubscribeLiveClicks opens a live stream of a link's clicks. The caller
+// must close the subscription when the client goes away.
+func (s *ShortenerService) SubscribeLiveClicks(ctx context.Context, code string) (*live.Subscription, error) {
+	if s.live == nil {
+		return nil, ErrLiveUnavailable
+	}
+
+	if _, err := s.lookupURL(ctx, code); err != nil {
+		return nil, err
+	}
+
+	return s.live.Subscribe(ctx, code)
+}
+
+// Custom errors
+var (
+	ErrLiveUnavailable = fmt.Errorf("live click streams are disabled")
+)
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/safety"
+)
+
+// SafetyChecker looks destinations up in lists of unsafe URLs
+type SafetyChecker interface {
+	Check(ctx context.Context, rawURL string) (*safety.Verdict, error)
+}
+
+// WithSafetyChecker rejects new links to destinations the checker flags
+func WithSafetyChecker(checker SafetyChecker) Option {
+	return func(s *ShortenerService) {
+		s.safety = checker
+	}
+}
+
+// checkSafety rejects destinations flagged by the safety checker
+func (s *ShortenerService) checkSafety(ctx context.Context, longURL string) error {
+	if s.safety == nil {
+		return nil
+	}
+
+	verdict, err := s.safety.Check(ctx, longURL)
+	if err != nil {
+		return fmt.Errorf("failed to check URL safety: %w", err)
+	}
+	if verdict != nil {
+		// Only the threat type is reported; list names and entries stay private
+		return ErrUnsafeURL.WithFields(errors.Field("url", "flagged as "+verdict.Threat))
+	}
+
+	return nil
+}
+
+// SuspendUnsafeURL suspends a link whose destination was flagged after it
+// was created
+func (s *ShortenerService) SuspendUnsafeURL(ctx context.Context, code string, verdict *safety.Verdict) error {
+	reason := "Destination flagged as " + verdict.String()
+	if err := s.repo.FlagURL(ctx, code, models.LinkSuspended, reason); err != nil {
+		return err
+	}
+
+	if err := s.cache.Delete(ctx, code); err != nil {
+		// Log error but don't fail the request
+	}
+
+	return nil
+}
+
+// Custom errors
+var (
+	ErrUnsafeURL = errors.Forbidden("url_blocked", "URL destination is blocked")
+)
@@ -3,49 +3,186 @@ package service
 import (
 	"context"
 	"fmt"
-	"net/url"
-	"strings"
+	"net"
 	"time"
 
 	"github.com/urlshortener/internal/cache"
+	"github.com/urlshortener/internal/errors"
 	"github.com/urlshortener/internal/id"
+	"github.com/urlshortener/internal/live"
 	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/privacy"
 	"github.com/urlshortener/internal/repo"
+	"github.com/urlshortener/internal/safety"
+	"github.com/urlshortener/internal/ua"
+	"github.com/urlshortener/internal/urlnorm"
 )
 
 // ShortenerService provides URL shortening business logic
 type ShortenerService struct {
-	repo   repo.URLRepository
-	cache  cache.Cache
-	idGen  *id.Generator
-	config Config
+	repo     repo.URLRepository
+	cache    cache.Cache
+	idGen    *id.Generator
+	config   Config
+	previews PreviewQueue
+	clicks   ClickSink
+	geo      GeoResolver
+	privacy  ClickAnonymizer
+	visitors VisitorCounter
+	live     LiveFeed
+	safety   SafetyChecker
+	allowed  *safety.DomainList
+	resolver Resolver
+	// shorteners lists our own host and other URL shorteners
+	shorteners *safety.DomainList
+}
+
+// Option configures optional ShortenerService dependencies
+type Option func(*ShortenerService)
+
+// PreviewQueue schedules destination metadata fetches for new links
+type PreviewQueue interface {
+	Enqueue(code, longURL string) bool
+}
+
+// WithPreviewQueue enables background unfurling of destination metadata
+func WithPreviewQueue(queue PreviewQueue) Option {
+	return func(s *ShortenerService) {
+		s.previews = queue
+	}
+}
+
+// ClickSink accepts click events for asynchronous persistence
+type ClickSink interface {
+	Submit(event *models.ClickEvent) bool
+}
+
+// WithClickSink routes click events through a buffered sink instead of
+// writing them to the repository on the request path
+func WithClickSink(sink ClickSink) Option {
+	return func(s *ShortenerService) {
+		s.clicks = sink
+	}
+}
+
+// GeoResolver maps client IP addresses to ISO country codes
+type GeoResolver interface {
+	Country(ip net.IP) string
+}
+
+// WithGeoResolver records the country of each click's client IP address
+func WithGeoResolver(resolver GeoResolver) Option {
+	return func(s *ShortenerService) {
+		s.geo = resolver
+	}
+}
+
+// ClickAnonymizer removes identifying detail from click events before storage
+type ClickAnonymizer interface {
+	Anonymize(ctx context.Context, event *models.ClickEvent)
+}
+
+// WithClickAnonymizer truncates or hashes client IP addresses before clicks
+// are stored
+func WithClickAnonymizer(anonymizer ClickAnonymizer) Option {
+	return func(s *ShortenerService) {
+		s.privacy = anonymizer
+	}
+}
+
+// VisitorCounter estimates unique visitors per link and day
+type VisitorCounter interface {
+	Add(ctx context.Context, code, ipAddress, userAgent string)
+}
+
+// WithVisitorCounter counts unique visitors on every human click
+func WithVisitorCounter(counter VisitorCounter) Option {
+	return func(s *ShortenerService) {
+		s.visitors = counter
+	}
+}
+
+// LiveFeed streams clicks to live dashboards as they happen
+type LiveFeed interface {
+	Publish(event *models.ClickEvent)
+	Subscribe(ctx context.Context, code string) (*live.Subscription, error)
+}
+
+// WithLiveFeed publishes every click to live click streams
+func WithLiveFeed(feed LiveFeed) Option {
+	return func(s *ShortenerService) {
+		s.live = feed
+	}
 }
 
 // Config holds service configuration
 type Config struct {
-	BaseURL      string
-	CodeLength   int
-	MaxURLLength int
-	AllowedHosts []string
-	BlockedHosts []string
+	BaseURL         string
+	CodeLength      int
+	MaxURLLength    int
+	AllowedHosts    []string // when set, only these domains and their subdomains
+	HonorDoNotTrack bool // record only aggregate data for DNT/Sec-GPC clicks
+	// Other URL shorteners whose links are rejected as destinations, in
+	// addition to the host of BaseURL
+	ShortenerDomains []string
+	// Reports since the last review that move a link under review or
+	// suspend it; zero or less disables the step
+	ReportReviewThreshold  int
+	ReportSuspendThreshold int
+	// ReporterSecret keys the hashes that tell reporters apart
+	ReporterSecret []byte
 }
 
 // NewShortenerService creates a new shortener service
-func NewShortenerService(repo repo.URLRepository, cache cache.Cache, config Config) *ShortenerService {
-	return &ShortenerService{
+func NewShortenerService(repo repo.URLRepository, cache cache.Cache, config Config, opts ...Option) *ShortenerService {
+	s := &ShortenerService{
 		repo:   repo,
 		cache:  cache,
 		idGen:  id.NewGenerator(config.CodeLength),
 		config: config,
+
+		resolver:   net.DefaultResolver,
+		shorteners: newShortenerList(config.BaseURL, config.ShortenerDomains),
+	}
+	if len(config.AllowedHosts) > 0 {
+		s.allowed = safety.NewDomainList("allowed_hosts", "", config.AllowedHosts)
+	}
+
+	for _, opt := range opts {
+		opt(s)
 	}
+
+	return s
 }
 
 // CreateShortURL creates a new short URL
 func (s *ShortenerService) CreateShortURL(ctx context.Context, req *models.CreateURLRequest) (*models.CreateURLResponse, error) {
-	// Validate URL
-	if err := s.validateURL(req.URL); err != nil {
+	// Validate URL; the normalized form is checked, stored and redirected to
+	normalized, err := s.validateURL(req.URL)
+	if err != nil {
 		return nil, err
 	}
+	if err := s.checkDestination(ctx, normalized.URL); err != nil {
+		return nil, err
+	}
+	if err := s.checkSafety(ctx, normalized.URL); err != nil {
+		return nil, err
+	}
+
+	// Validate tags and campaign before anything is written
+	var tags []string
+	if len(req.Tags) > 0 {
+		normalized, err := normalizeTags(req.Tags)
+		if err != nil {
+			return nil, err
+		}
+		tags = normalized
+	}
+	if req.CampaignID != nil {
+		if err := s.checkCampaignOwner(ctx, *req.CampaignID, req.CreatedBy); err != nil {
+			return nil, err
+		}
+	}
 
 	// Generate or validate custom alias
 	var code string
@@ -56,7 +193,7 @@ func (s *ShortenerService) CreateShortURL(ctx context.Context, req *models.Creat
 		
 		// Check if custom code already exists
 		if exists, _ := s.codeExists(ctx, code); exists {
-			return nil, fmt.Errorf("custom alias already exists")
+			return nil, ErrAliasExists
 		}
 	} else {
 		// Generate unique code
@@ -71,16 +208,32 @@ func (s *ShortenerService) CreateShortURL(ctx context.Context, req *models.Creat
 
 	// Create short URL
 	shortURL := &models.ShortURL{
-		Code:        code,
-		LongURL:     req.URL,
-		ExpireAt:    req.ExpireAt,
-		CustomAlias: customAlias,
-		CreatedBy:   req.CreatedBy,
-		Metadata:    req.Metadata,
-	}
-
-	// Save to database
-	if err := s.repo.CreateURL(ctx, shortURL); err != nil {
+		Code:             code,
+		LongURL:          normalized.URL,
+		OriginalURL:      &req.URL,
+		ExpireAt:         req.ExpireAt,
+		CustomAlias:      customAlias,
+		CreatedBy:        req.CreatedBy,
+		Metadata:         req.Metadata,
+		Title:            req.Title,
+		ShowInterstitial: req.ShowInterstitial,
+		OGTitle:          req.OGTitle,
+		OGDescription:    req.OGDescription,
+		OGImage:          req.OGImage,
+		CampaignID:       req.CampaignID,
+	}
+
+	// Hosts that may imitate another host are redirected through a warning
+	// page until a moderator reviews them
+	if normalized.Homograph {
+		reason := fmt.Sprintf("Host %s mixes scripts and may imitate another host", normalized.DisplayHost)
+		shortURL.Status = models.LinkUnderReview
+		shortURL.StatusReason = &reason
+	}
+
+	// Save to database; tags are written in the same transaction, so a
+	// failed request leaves nothing behind for a retry to trip over
+	if err := s.repo.CreateURL(ctx, shortURL, tags); err != nil {
 		return nil, fmt.Errorf("failed to create URL: %w", err)
 	}
 
@@ -90,31 +243,54 @@ func (s *ShortenerService) CreateShortURL(ctx context.Context, req *models.Creat
 		// In production, you might want to send this to a monitoring system
 	}
 
+	// Unfurl destination metadata in the background; a full queue just
+	// means the link has no preview
+	if s.previews != nil {
+		s.previews.Enqueue(code, shortURL.LongURL)
+	}
+
 	// Build response
 	shortURLStr := fmt.Sprintf("%s/%s", s.config.BaseURL, code)
 	response := &models.CreateURLResponse{
 		Code:      code,
 		ShortURL:  shortURLStr,
-		LongURL:   req.URL,
+		LongURL:   shortURL.LongURL,
 		ExpireAt:  req.ExpireAt,
 		CreatedAt: shortURL.CreatedAt,
+		Status:    shortURL.Status,
+	}
+	if shortURL.LongURL != req.URL {
+		response.OriginalURL = req.URL
 	}
 
 	return response, nil
 }
 
-// GetLongURL retrieves the long URL for a given code
-func (s *ShortenerService) GetLongURL(ctx context.Context, code string, userAgent, ipAddress, referer string) (*models.ShortURL, error) {
+// RecordClick records a visitor following a link. doNotTrack reports
+// whether the visitor opted out of tracking. Failures are not reported, so
+// analytics never hold up a redirect.
+func (s *ShortenerService) RecordClick(ctx context.Context, code string, userAgent, ipAddress, referer string, doNotTrack bool) {
+	if err := s.recordClick(ctx, code, userAgent, ipAddress, referer, doNotTrack); err != nil {
+		// Log error but don't fail the request
+	}
+}
+
+// LookupURL resolves a code to its short URL without recording a click.
+// It backs the preview page, where following the link is left to the user.
+func (s *ShortenerService) LookupURL(ctx context.Context, code string) (*models.ShortURL, error) {
+	return s.lookupURL(ctx, code)
+}
+
+// lookupURL resolves a code through the cache, falling back to the database
+func (s *ShortenerService) lookupURL(ctx context.Context, code string) (*models.ShortURL, error) {
 	// Try cache first
 	url, err := s.cache.Get(ctx, code)
 	if err == nil {
-		// Cache hit - record click asynchronously
-		go s.recordClickAsync(context.Background(), code, userAgent, ipAddress, referer)
 		return url, nil
 	}
 
 	// Cache miss - check if it's a negative cache hit
-	if err == cache.ErrURLDeleted || err == cache.ErrURLExpired {
+	if errors.Is(err, cache.ErrURLDeleted) || errors.Is(err, cache.ErrURLExpired) {
 		return nil, err
 	}
 
@@ -122,7 +298,7 @@ func (s *ShortenerService) GetLongURL(ctx context.Context, code string, userAgen
 	url, err = s.repo.GetURLByCode(ctx, code)
 	if err != nil {
 		// Set negative cache for not found
-		if err == repo.ErrURLNotFound {
+		if errors.Is(err, repo.ErrURLNotFound) {
 			s.cache.SetNegative(ctx, code)
 		}
 		return nil, err
@@ -133,11 +309,6 @@ func (s *ShortenerService) GetLongURL(ctx context.Context, code string, userAgen
 		// Log error but continue
 	}
 
-	// Record click
-	if err := s.recordClick(ctx, code, userAgent, ipAddress, referer); err != nil {
-		// Log error but don't fail the request
-	}
-
 	return url, nil
 }
 
@@ -162,11 +333,17 @@ func (s *ShortenerService) GetURLMetadata(ctx context.Context, code string) (*mo
 
 	// Warm cache with basic info
 	shortURL := &models.ShortURL{
-		Code:      metadata.Code,
-		LongURL:   metadata.LongURL,
-		CreatedAt: metadata.CreatedAt,
-		ExpireAt:  metadata.ExpireAt,
-		IsDeleted: metadata.IsDeleted,
+		Code:             metadata.Code,
+		LongURL:          metadata.LongURL,
+		CreatedAt:        metadata.CreatedAt,
+		ExpireAt:         metadata.ExpireAt,
+		IsDeleted:        metadata.IsDeleted,
+		Title:            metadata.Title,
+		ShowInterstitial: metadata.ShowInterstitial,
+		OGTitle:          metadata.OGTitle,
+		OGDescription:    metadata.OGDescription,
+		OGImage:          metadata.OGImage,
+		Status:           metadata.Status,
 	}
 	
 	if err := s.cache.Set(ctx, code, shortURL); err != nil {
@@ -216,51 +393,40 @@ func (s *ShortenerService) CleanupExpiredURLs(ctx context.Context) error {
 	return nil
 }
 
-// validateURL validates the input URL
-func (s *ShortenerService) validateURL(longURL string) error {
+// validateURL validates the input URL and returns its normalized form
+func (s *ShortenerService) validateURL(longURL string) (*urlnorm.URL, error) {
 	// Check length
+	tooLong := ErrURLTooLong.WithFields(errors.Field("url", fmt.Sprintf("must be at most %d characters", s.config.MaxURLLength)))
 	if len(longURL) > s.config.MaxURLLength {
-		return fmt.Errorf("URL too long (max %d characters)", s.config.MaxURLLength)
+		return nil, tooLong
 	}
 
-	// Parse URL
-	parsed, err := url.Parse(longURL)
+	// Normalize, converting internationalized hosts to punycode
+	normalized, err := urlnorm.Normalize(longURL)
 	if err != nil {
-		return fmt.Errorf("invalid URL format: %w", err)
+		return nil, ErrInvalidURL.WithFields(errors.Field("url", "is not a valid URL"))
+	}
+	if len(normalized.URL) > s.config.MaxURLLength {
+		return nil, tooLong
 	}
 
 	// Check scheme
-	if parsed.Scheme != "http" && parsed.Scheme != "https" {
-		return fmt.Errorf("only HTTP and HTTPS URLs are allowed")
+	if normalized.Scheme != "http" && normalized.Scheme != "https" {
+		return nil, ErrInvalidURL.WithFields(errors.Field("url", "must use http or https"))
 	}
 
 	// Check host
-	if parsed.Host == "" {
-		return fmt.Errorf("URL must have a valid host")
+	if normalized.Host == "" {
+		return nil, ErrInvalidURL.WithFields(errors.Field("url", "must have a host"))
 	}
 
-	// Check blocked hosts
-	for _, blocked := range s.config.BlockedHosts {
-		if strings.Contains(parsed.Host, blocked) {
-			return fmt.Errorf("URL host is blocked")
-		}
+	// Check allowed hosts if specified; blocked hosts are left to the
+	// safety checker
+	if s.allowed != nil && !s.allowed.Contains(normalized.Host) {
+		return nil, ErrHostNotAllowed
 	}
 
-	// Check allowed hosts if specified
-	if len(s.config.AllowedHosts) > 0 {
-		allowed := false
-		for _, allowedHost := range s.config.AllowedHosts {
-			if strings.Contains(parsed.Host, allowedHost) {
-				allowed = true
-				break
-			}
-		}
-		if !allowed {
-			return fmt.Errorf("URL host is not in allowed list")
-		}
-	}
-
-	return nil
+	return normalized, nil
 }
 
 // codeExists checks if a code already exists
@@ -273,7 +439,7 @@ func (s *ShortenerService) codeExists(ctx context.Context, code string) (bool, e
 
 	// Check database
 	_, err = s.repo.GetURLByCode(ctx, code)
-	if err == repo.ErrURLNotFound {
+	if errors.Is(err, repo.ErrURLNotFound) {
 		return false, nil
 	}
 	if err != nil {
@@ -283,23 +449,65 @@ func (s *ShortenerService) codeExists(ctx context.Context, code string) (bool, e
 	return true, nil
 }
 
-// recordClick records a click event
-func (s *ShortenerService) recordClick(ctx context.Context, code, userAgent, ipAddress, referer string) error {
+// recordClick records a click event, handing it to the click sink when one
+// is configured. Visitors who opted out of tracking are counted without
+// anything that identifies them.
+func (s *ShortenerService) recordClick(ctx context.Context, code, userAgent, ipAddress, referer string, doNotTrack bool) error {
+	agent := ua.Parse(userAgent)
+
 	event := &models.ClickEvent{
-		Code:      code,
-		UserAgent: &userAgent,
-		IPAddress: &ipAddress,
-		Referer:   &referer,
+		Code:       code,
+		Timestamp:  time.Now(),
+		UserAgent:  nilIfEmpty(userAgent),
+		IPAddress:  nilIfEmpty(ipAddress),
+		Referer:    nilIfEmpty(referer),
+		DeviceType: &agent.DeviceType,
+		OS:         &agent.OS,
+		Browser:    &agent.Browser,
+		IsBot:      agent.IsBot,
+	}
+	if s.geo != nil {
+		if ip := net.ParseIP(ipAddress); ip != nil {
+			event.Country = nilIfEmpty(s.geo.Country(ip))
+		}
+	}
+
+	if doNotTrack && s.config.HonorDoNotTrack {
+		privacy.StripVisitor(event)
+	} else {
+		if s.visitors != nil && !agent.IsBot && ipAddress != "" {
+			s.visitors.Add(ctx, code, ipAddress, userAgent)
+		}
+		if s.privacy != nil {
+			s.privacy.Anonymize(ctx, event)
+		}
+	}
+
+	if s.live != nil {
+		s.live.Publish(event)
+	}
+
+	if s.clicks != nil {
+		// Dropped events are counted by the sink
+		s.clicks.Submit(event)
+		return nil
 	}
 
 	return s.repo.RecordClick(ctx, event)
 }
 
-// recordClickAsync records a click event asynchronously
-func (s *ShortenerService) recordClickAsync(ctx context.Context, code, userAgent, ipAddress, referer string) {
-	// Use a separate context with timeout for async operations
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	_ = s.recordClick(ctx, code, userAgent, ipAddress, referer)
+// nilIfEmpty returns nil for empty strings so they are stored as NULL
+func nilIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
 }
+
+// Custom errors
+var (
+	ErrInvalidURL     = errors.Validation("invalid_url", "invalid URL")
+	ErrURLTooLong     = errors.Validation("url_too_long", "URL too long")
+	ErrAliasExists    = errors.Conflict("alias_exists", "custom alias already exists")
+	ErrHostNotAllowed = errors.Forbidden("url_host_not_allowed", "URL host is not in allowed list")
+)
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/repo"
+)
+
+const (
+	defaultStatsTop = 10
+	maxStatsTop     = 50
+	maxStatsBuckets = 1000
+)
+
+// defaultStatsSpans is the range covered when no start is requested
+var defaultStatsSpans = map[string]time.Duration{
+	"hour": 24 * time.Hour,
+	"day":  30 * 24 * time.Hour,
+	"week": 12 * 7 * 24 * time.Hour,
+}
+
+// GetClickStats returns a click time series and breakdowns for a URL
+func (s *ShortenerService) GetClickStats(ctx context.Context, code string, query models.StatsQuery) (*models.ClickStats, error) {
+	interval := query.Interval
+	if interval == "" {
+		interval = "day"
+	}
+	span, ok := defaultStatsSpans[interval]
+	if !ok {
+		return nil, ErrInvalidStatsQuery.WithFields(errors.Field("interval", "must be one of hour, day, week"))
+	}
+
+	timezone := query.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return nil, ErrInvalidStatsQuery.WithFields(errors.Field("timezone", "is not a known time zone"))
+	}
+
+	to := time.Now()
+	if query.To != "" {
+		if to, err = parseStatsTime("to", query.To, loc); err != nil {
+			return nil, err
+		}
+	}
+	from := to.Add(-span)
+	if query.From != "" {
+		if from, err = parseStatsTime("from", query.From, loc); err != nil {
+			return nil, err
+		}
+	}
+	if !from.Before(to) {
+		return nil, ErrInvalidStatsQuery.WithFields(errors.Field("from", "must be before to"))
+	}
+
+	// Widen the range to whole buckets so the first and last are complete
+	from = bucketStart(from, interval, loc)
+	if end := bucketStart(to, interval, loc); end.Before(to) {
+		to = nextBucket(end, interval)
+	}
+
+	starts := []time.Time{}
+	for t := from; t.Before(to); t = nextBucket(t, interval) {
+		if len(starts) == maxStatsBuckets {
+			return nil, ErrInvalidStatsQuery.WithFields(errors.Field("from", fmt.Sprintf("range spans more than %d %s buckets", maxStatsBuckets, interval)))
+		}
+		// Rollups are hourly in UTC, so they cannot be split at a local
+		// hour that falls mid-way through a UTC hour
+		if _, offset := t.Zone(); offset%3600 != 0 {
+			return nil, ErrInvalidStatsQuery.WithFields(errors.Field("timezone", "is not a whole number of hours from UTC"))
+		}
+		starts = append(starts, t)
+	}
+
+	top := query.Top
+	if top <= 0 {
+		top = defaultStatsTop
+	}
+	if top > maxStatsTop {
+		top = maxStatsTop
+	}
+
+	// Make sure the link exists before querying analytics
+	if _, err := s.lookupURL(ctx, code); err != nil {
+		return nil, err
+	}
+
+	buckets, err := s.repo.GetClickSeries(ctx, code, from, to, interval, loc.String())
+	if err != nil {
+		return nil, err
+	}
+
+	// Fill in empty buckets so the series is continuous
+	counts := make(map[int64]models.ClickBucket, len(buckets))
+	for _, bucket := range buckets {
+		key := bucketStart(bucket.Start, interval, loc).Unix()
+		count := counts[key]
+		count.Clicks += bucket.Clicks
+		count.BotClicks += bucket.BotClicks
+		counts[key] = count
+	}
+
+	stats := &models.ClickStats{
+		Code:     code,
+		From:     from,
+		To:       to.In(loc),
+		Interval: interval,
+		Timezone: loc.String(),
+		Series:   make([]models.ClickBucket, 0, len(starts)),
+	}
+	for _, start := range starts {
+		bucket := counts[start.Unix()]
+		bucket.Start = start
+		stats.Series = append(stats.Series, bucket)
+		stats.TotalClicks += bucket.Clicks
+		stats.BotClicks += bucket.BotClicks
+	}
+
+	// Unique visitors are estimated per UTC day, so they are summed into day
+	// and week buckets but not split across hours
+	days, err := s.repo.GetDailyVisitors(ctx, code, from, to)
+	if err != nil {
+		return nil, err
+	}
+	visitors := make(map[int64]int64)
+	for _, day := range days {
+		visitors[bucketStart(day.Day, interval, loc).Unix()] += day.Visitors
+		stats.UniqueVisitors += day.Visitors
+	}
+	if interval != "hour" {
+		for i := range stats.Series {
+			count := visitors[stats.Series[i].Start.Unix()]
+			stats.Series[i].UniqueVisitors = &count
+		}
+	}
+
+	breakdowns := []struct {
+		dimension string
+		target    *[]models.DimensionCount
+	}{
+		{repo.DimensionReferrer, &stats.TopReferrers},
+		{repo.DimensionCountry, &stats.TopCountries},
+		{repo.DimensionDeviceType, &stats.TopDevices},
+		{repo.DimensionBrowser, &stats.TopBrowsers},
+	}
+	for _, b := range breakdowns {
+		if *b.target, err = s.repo.GetTopDimension(ctx, code, b.dimension, from, to, top); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
+}
+
+// parseStatsTime parses the RFC 3339 timestamp or date at midnight in loc
+// given for a query field
+func parseStatsTime(field, value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, nil
+	}
+	return time.Time{}, ErrInvalidStatsQuery.WithFields(errors.Field(field, "must be RFC 3339 or YYYY-MM-DD"))
+}
+
+// bucketStart returns the start of the bucket containing t in loc. Weeks
+// start on Monday, matching Postgres date_trunc.
+func bucketStart(t time.Time, interval string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	switch interval {
+	case "hour":
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case "week":
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	}
+}
+
+// nextBucket returns the start of the bucket following start
+func nextBucket(start time.Time, interval string) time.Time {
+	switch interval {
+	case "hour":
+		return start.Add(time.Hour)
+	case "week":
+		return start.AddDate(0, 0, 7)
+	default:
+		return start.AddDate(0, 0, 1)
+	}
+}
+
+// Custom errors
+var (
+	ErrInvalidStatsQuery = errors.Validation("invalid_stats_query", "invalid stats query")
+)
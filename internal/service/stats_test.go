@@ -0,0 +1,31 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/models"
+)
+
+func TestGetClickStatsRejectsPartialHourTimeZones(t *testing.T) {
+	for _, zone := range []string{"Asia/Kolkata", "Asia/Kathmandu", "Australia/Adelaide"} {
+		if _, err := time.LoadLocation(zone); err != nil {
+			t.Skipf("time zone database unavailable: %v", err)
+		}
+	}
+
+	// Rejected before the link is looked up, so no repository is needed
+	s := NewShortenerService(nil, nil, Config{})
+	for _, zone := range []string{"Asia/Kolkata", "Asia/Kathmandu", "Australia/Adelaide"} {
+		_, err := s.GetClickStats(context.Background(), "abc123", models.StatsQuery{
+			From:     "2024-05-01",
+			To:       "2024-05-03",
+			Timezone: zone,
+		})
+		if !errors.Is(err, ErrInvalidStatsQuery) {
+			t.Errorf("%s: expected ErrInvalidStatsQuery, got %v", zone, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/urlshortener/internal/errors"
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/repo"
+	"github.com/urlshortener/internal/webhook"
+)
+
+// webhookSecretBytes is the length of generated signing secrets
+const webhookSecretBytes = 32
+
+// CreateWebhook registers a webhook owned by owner. The signing secret is
+// generated here and only returned by this call.
+func (s *ShortenerService) CreateWebhook(ctx context.Context, owner string, req *models.CreateWebhookRequest) (*models.WebhookSubscription, error) {
+	endpoint, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" {
+		return nil, ErrInvalidWebhook.WithFields(errors.Field("url", "must be an absolute http or https URL"))
+	}
+
+	events := make([]string, 0, len(req.Events))
+	seen := make(map[string]bool)
+	for _, event := range req.Events {
+		if !webhook.ValidEvent(event) {
+			return nil, ErrInvalidWebhook.WithFields(errors.Field("events", fmt.Sprintf("%q is not a known event", event)))
+		}
+		if !seen[event] {
+			seen[event] = true
+			events = append(events, event)
+		}
+	}
+
+	thresholds := make([]int64, 0, len(req.ClickThresholds))
+	for _, threshold := range req.ClickThresholds {
+		if threshold < 1 {
+			return nil, ErrInvalidWebhook.WithFields(errors.Field("click_thresholds", "must be positive"))
+		}
+		thresholds = append(thresholds, threshold)
+	}
+	sort.Slice(thresholds, func(i, j int) bool { return thresholds[i] < thresholds[j] })
+
+	if seen[webhook.EventLinkClickThreshold] != (len(thresholds) > 0) {
+		return nil, ErrInvalidWebhook.WithFields(errors.Field("click_thresholds",
+			"require the "+webhook.EventLinkClickThreshold+" event and vice versa"))
+	}
+
+	secret := make([]byte, webhookSecretBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	subscription := &models.WebhookSubscription{
+		Owner:           owner,
+		URL:             endpoint.String(),
+		Secret:          hex.EncodeToString(secret),
+		Events:          events,
+		ClickThresholds: thresholds,
+	}
+
+	if err := s.repo.CreateWebhook(ctx, subscription); err != nil {
+		return nil, err
+	}
+
+	return subscription, nil
+}
+
+// GetWebhooks lists the webhooks owned by owner
+func (s *ShortenerService) GetWebhooks(ctx context.Context, owner string) ([]models.WebhookSubscription, error) {
+	return s.repo.GetWebhooksByOwner(ctx, owner)
+}
+
+// DeleteWebhook removes a webhook owned by owner
+func (s *ShortenerService) DeleteWebhook(ctx context.Context, owner string, id int64) error {
+	if _, err := s.getOwnedWebhook(ctx, owner, id); err != nil {
+		return err
+	}
+
+	return s.repo.DeleteWebhook(ctx, id)
+}
+
+// GetWebhookDeliveries returns a page of the delivery log of a webhook owned
+// by owner, optionally filtered by delivery status
+func (s *ShortenerService) GetWebhookDeliveries(ctx context.Context, owner string, id int64, status string, page, pageSize int) (*models.WebhookDeliveryList, error) {
+	switch status {
+	case "", models.DeliveryPending, models.DeliverySucceeded, models.DeliveryDead:
+	default:
+		return nil, ErrInvalidWebhook.WithFields(errors.Field("status", fmt.Sprintf("%q is not a delivery status", status)))
+	}
+
+	if _, err := s.getOwnedWebhook(ctx, owner, id); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetWebhookDeliveries(ctx, id, status, page, pageSize)
+}
+
+// RetryWebhookDelivery requeues a dead-lettered delivery of a webhook owned by owner
+func (s *ShortenerService) RetryWebhookDelivery(ctx context.Context, owner string, id, deliveryID int64) error {
+	if _, err := s.getOwnedWebhook(ctx, owner, id); err != nil {
+		return err
+	}
+
+	return s.repo.RetryWebhookDelivery(ctx, id, deliveryID)
+}
+
+// getOwnedWebhook loads a webhook, hiding webhooks of other owners
+func (s *ShortenerService) getOwnedWebhook(ctx context.Context, owner string, id int64) (*models.WebhookSubscription, error) {
+	subscription, err := s.repo.GetWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if subscription.Owner != owner {
+		return nil, repo.ErrWebhookNotFound
+	}
+
+	return subscription, nil
+}
+
+// Custom errors
+var (
+	ErrInvalidWebhook = errors.Validation("invalid_webhook", "invalid webhook")
+)
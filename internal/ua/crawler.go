@@ -0,0 +1,43 @@
+package ua
+
+import (
+	"strings"
+)
+
+// socialCrawlerTokens are User-Agent substrings sent by link unfurlers of
+// chat apps and social networks. Matching is case-insensitive.
+var socialCrawlerTokens = []string{
+	"facebookexternalhit",
+	"facebot",
+	"twitterbot",
+	"slackbot",
+	"slack-imgproxy",
+	"linkedinbot",
+	"discordbot",
+	"telegrambot",
+	"whatsapp",
+	"skypeuripreview",
+	"pinterestbot",
+	"redditbot",
+	"embedly",
+	"vkshare",
+	"mastodon",
+	"iframely",
+}
+
+// IsSocialCrawler reports whether the User-Agent belongs to a bot that fetches
+// links to render a preview card rather than a person following the link
+func IsSocialCrawler(userAgent string) bool {
+	if userAgent == "" {
+		return false
+	}
+
+	lower := strings.ToLower(userAgent)
+	for _, token := range socialCrawlerTokens {
+		if strings.Contains(lower, token) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,29 @@
+package ua
+
+import "testing"
+
+func TestIsSocialCrawler(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  bool
+	}{
+		{"empty", "", false},
+		{"facebook", "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", true},
+		{"twitter", "Twitterbot/1.0", true},
+		{"slack", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"linkedin", "LinkedInBot/1.0 (compatible; Mozilla/5.0; Apache-HttpClient +http://www.linkedin.com)", true},
+		{"discord", "Mozilla/5.0 (compatible; Discordbot/2.0; +https://discordapp.com)", true},
+		{"whatsapp", "WhatsApp/2.23.20.0", true},
+		{"chrome desktop", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", false},
+		{"googlebot is not a card renderer", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSocialCrawler(tt.userAgent); got != tt.expected {
+				t.Errorf("IsSocialCrawler(%q) = %v, want %v", tt.userAgent, got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,202 @@
+package ua
+
+import (
+	"strings"
+)
+
+// Device types
+const (
+	DeviceDesktop = "desktop"
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceBot     = "bot"
+)
+
+// Other is reported when the OS or browser is not recognized
+const Other = "Other"
+
+// Agent is the classification of a User-Agent string
+type Agent struct {
+	DeviceType string
+	OS         string
+	Browser    string
+	IsBot      bool
+}
+
+// family maps a lowercase User-Agent token to a display name
+type family struct {
+	token string
+	name  string
+}
+
+// botTokens identify automated clients. Social crawlers are matched
+// separately via socialCrawlerTokens.
+var botTokens = []string{
+	"bot",
+	"crawl",
+	"spider",
+	"slurp",
+	"scrapy",
+	"curl/",
+	"wget/",
+	"httpie/",
+	"python-requests",
+	"python-urllib",
+	"aiohttp",
+	"go-http-client",
+	"java/",
+	"okhttp",
+	"apache-httpclient",
+	"libwww-perl",
+	"node-fetch",
+	"axios/",
+	"headlesschrome",
+	"phantomjs",
+	"lighthouse",
+	"pingdom",
+	"uptimerobot",
+	"statuscake",
+	"feedfetcher",
+	"mediapartners-google",
+	"google-inspectiontool",
+	"bingpreview",
+	"preview",
+}
+
+// botFalsePositives contain a bot token but are real devices
+var botFalsePositives = []string{
+	"cubot",
+}
+
+// osFamilies are checked in order; more specific tokens come first
+var osFamilies = []family{
+	{"windows phone", "Windows Phone"},
+	{"windows", "Windows"},
+	{"iphone", "iOS"},
+	{"ipad", "iOS"},
+	{"ipod", "iOS"},
+	{"android", "Android"},
+	{"cros ", "Chrome OS"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"ubuntu", "Linux"},
+	{"fedora", "Linux"},
+	{"linux", "Linux"},
+	{"freebsd", "FreeBSD"},
+	{"openbsd", "OpenBSD"},
+}
+
+// browserFamilies are checked in order. Chromium-based browsers also
+// announce Chrome and Safari, and Chrome announces Safari, so specific
+// brands must precede the engines they are built on.
+var browserFamilies = []family{
+	{"edg/", "Edge"},
+	{"edge/", "Edge"},
+	{"edga/", "Edge"},
+	{"edgios/", "Edge"},
+	{"opr/", "Opera"},
+	{"opt/", "Opera"},
+	{"opera", "Opera"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"yabrowser/", "Yandex Browser"},
+	{"ucbrowser/", "UC Browser"},
+	{"vivaldi/", "Vivaldi"},
+	{"duckduckgo/", "DuckDuckGo"},
+	{"fban/", "Facebook"},
+	{"fbav/", "Facebook"},
+	{"instagram", "Instagram"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"headlesschrome/", "Headless Chrome"},
+	{"chromium/", "Chromium"},
+	{"chrome/", "Chrome"},
+	{"msie ", "Internet Explorer"},
+	{"trident/", "Internet Explorer"},
+	{"safari/", "Safari"},
+}
+
+// Parse classifies a User-Agent string. An empty User-Agent is treated as a
+// bot, since every browser sends one.
+func Parse(userAgent string) Agent {
+	lower := strings.ToLower(strings.TrimSpace(userAgent))
+
+	agent := Agent{
+		OS:      matchFamily(lower, osFamilies),
+		Browser: matchFamily(lower, browserFamilies),
+		IsBot:   lower == "" || isBot(lower),
+	}
+
+	switch {
+	case agent.IsBot:
+		agent.DeviceType = DeviceBot
+	case isTablet(lower):
+		agent.DeviceType = DeviceTablet
+	case isMobile(lower):
+		agent.DeviceType = DeviceMobile
+	default:
+		agent.DeviceType = DeviceDesktop
+	}
+
+	// Android WebView identifies itself as Chrome with a "wv" marker
+	if agent.Browser == "Chrome" && strings.Contains(lower, "; wv)") {
+		agent.Browser = "Android WebView"
+	}
+
+	// Safari is only the browser when it also reports its own version;
+	// otherwise it is an embedded web view
+	if agent.Browser == "Safari" && !strings.Contains(lower, "version/") {
+		agent.Browser = Other
+	}
+
+	return agent
+}
+
+// IsBot reports whether the User-Agent belongs to an automated client
+func IsBot(userAgent string) bool {
+	return Parse(userAgent).IsBot
+}
+
+// isBot matches bot tokens in a lowercase User-Agent
+func isBot(lower string) bool {
+	for _, token := range botFalsePositives {
+		if strings.Contains(lower, token) {
+			return false
+		}
+	}
+	return containsAny(lower, botTokens) || containsAny(lower, socialCrawlerTokens)
+}
+
+// isTablet matches tablets in a lowercase User-Agent. Android tablets omit
+// the "mobile" token that Android phone browsers send.
+func isTablet(lower string) bool {
+	if containsAny(lower, []string{"ipad", "tablet", "kindle", "silk/", "playbook"}) {
+		return true
+	}
+	return strings.Contains(lower, "android") && !containsAny(lower, []string{"mobi", "opera mini"})
+}
+
+// isMobile matches phones in a lowercase User-Agent
+func isMobile(lower string) bool {
+	return containsAny(lower, []string{"mobi", "iphone", "ipod", "android", "windows phone", "blackberry", "opera mini"})
+}
+
+// matchFamily returns the name of the first family whose token appears in lower
+func matchFamily(lower string, families []family) string {
+	for _, f := range families {
+		if strings.Contains(lower, f.token) {
+			return f.name
+		}
+	}
+	return Other
+}
+
+// containsAny reports whether s contains any of the tokens
+func containsAny(s string, tokens []string) bool {
+	for _, token := range tokens {
+		if strings.Contains(s, token) {
+			return true
+		}
+	}
+	return false
+}
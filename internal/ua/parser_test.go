@@ -0,0 +1,271 @@
+package ua
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		expected  Agent
+	}{
+		// Desktop browsers
+		{
+			"chrome windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Agent{DeviceDesktop, "Windows", "Chrome", false},
+		},
+		{
+			"chrome macos",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Agent{DeviceDesktop, "macOS", "Chrome", false},
+		},
+		{
+			"chrome linux",
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Agent{DeviceDesktop, "Linux", "Chrome", false},
+		},
+		{
+			"chrome os",
+			"Mozilla/5.0 (X11; CrOS x86_64 14541.0.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Agent{DeviceDesktop, "Chrome OS", "Chrome", false},
+		},
+		{
+			"edge windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			Agent{DeviceDesktop, "Windows", "Edge", false},
+		},
+		{
+			"legacy edge",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.19582",
+			Agent{DeviceDesktop, "Windows", "Edge", false},
+		},
+		{
+			"opera",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36 OPR/105.0.0.0",
+			Agent{DeviceDesktop, "Windows", "Opera", false},
+		},
+		{
+			"vivaldi",
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Vivaldi/6.5.3206.48",
+			Agent{DeviceDesktop, "Linux", "Vivaldi", false},
+		},
+		{
+			"yandex",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 YaBrowser/23.11.0.0 Safari/537.36",
+			Agent{DeviceDesktop, "Windows", "Yandex Browser", false},
+		},
+		{
+			"firefox windows",
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			Agent{DeviceDesktop, "Windows", "Firefox", false},
+		},
+		{
+			"firefox ubuntu",
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			Agent{DeviceDesktop, "Linux", "Firefox", false},
+		},
+		{
+			"safari macos",
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+			Agent{DeviceDesktop, "macOS", "Safari", false},
+		},
+		{
+			"internet explorer 11",
+			"Mozilla/5.0 (Windows NT 10.0; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			Agent{DeviceDesktop, "Windows", "Internet Explorer", false},
+		},
+		{
+			"internet explorer 9",
+			"Mozilla/5.0 (compatible; MSIE 9.0; Windows NT 6.1; Trident/5.0)",
+			Agent{DeviceDesktop, "Windows", "Internet Explorer", false},
+		},
+
+		// Phones
+		{
+			"safari iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			Agent{DeviceMobile, "iOS", "Safari", false},
+		},
+		{
+			"chrome iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			Agent{DeviceMobile, "iOS", "Chrome", false},
+		},
+		{
+			"firefox iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/121.0 Mobile/15E148 Safari/605.1.15",
+			Agent{DeviceMobile, "iOS", "Firefox", false},
+		},
+		{
+			"edge iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 EdgiOS/120.0.2210.126 Mobile/15E148 Safari/605.1.15",
+			Agent{DeviceMobile, "iOS", "Edge", false},
+		},
+		{
+			"facebook in-app iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBAV/444.0.0.29.110;FBBV/541594364]",
+			Agent{DeviceMobile, "iOS", "Facebook", false},
+		},
+		{
+			"instagram in-app iphone",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 311.0.2.26.103",
+			Agent{DeviceMobile, "iOS", "Instagram", false},
+		},
+		{
+			"ios web view",
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+			Agent{DeviceMobile, "iOS", Other, false},
+		},
+		{
+			"chrome android phone",
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			Agent{DeviceMobile, "Android", "Chrome", false},
+		},
+		{
+			"android web view",
+			"Mozilla/5.0 (Linux; Android 13; SM-S908B; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/120.0.6099.144 Mobile Safari/537.36",
+			Agent{DeviceMobile, "Android", "Android WebView", false},
+		},
+		{
+			"samsung internet",
+			"Mozilla/5.0 (Linux; Android 13; SAMSUNG SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			Agent{DeviceMobile, "Android", "Samsung Internet", false},
+		},
+		{
+			"firefox android",
+			"Mozilla/5.0 (Android 14; Mobile; rv:121.0) Gecko/121.0 Firefox/121.0",
+			Agent{DeviceMobile, "Android", "Firefox", false},
+		},
+		{
+			"uc browser",
+			"Mozilla/5.0 (Linux; U; Android 10; en-US; RMX2020 Build/QP1A.190711.020) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/78.0.3904.108 UCBrowser/13.4.0.1306 Mobile Safari/537.36",
+			Agent{DeviceMobile, "Android", "UC Browser", false},
+		},
+		{
+			"opera mini",
+			"Opera/9.80 (Android; Opera Mini/36.2.2254/119.132; U; id) Presto/2.12.423 Version/12.16",
+			Agent{DeviceMobile, "Android", "Opera", false},
+		},
+		{
+			"cubot phone is not a bot",
+			"Mozilla/5.0 (Linux; Android 9; CUBOT X19) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			Agent{DeviceMobile, "Android", "Chrome", false},
+		},
+		{
+			"windows phone",
+			"Mozilla/5.0 (Windows Phone 10.0; Android 6.0.1; Microsoft; Lumia 950) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/52.0.2743.116 Mobile Safari/537.36 Edge/15.15063",
+			Agent{DeviceMobile, "Windows Phone", "Edge", false},
+		},
+
+		// Tablets
+		{
+			"safari ipad",
+			"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			Agent{DeviceTablet, "iOS", "Safari", false},
+		},
+		{
+			"chrome android tablet",
+			"Mozilla/5.0 (Linux; Android 13; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			Agent{DeviceTablet, "Android", "Chrome", false},
+		},
+		{
+			"firefox android tablet",
+			"Mozilla/5.0 (Android 13; Tablet; rv:121.0) Gecko/121.0 Firefox/121.0",
+			Agent{DeviceTablet, "Android", "Firefox", false},
+		},
+		{
+			"kindle fire silk",
+			"Mozilla/5.0 (Linux; Android 9; KFMAWI) AppleWebKit/537.36 (KHTML, like Gecko) Silk/120.3.1 like Chrome/120.0.6099.230 Safari/537.36",
+			Agent{DeviceTablet, "Android", "Chrome", false},
+		},
+
+		// Bots
+		{
+			"empty",
+			"",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"googlebot",
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"googlebot smartphone",
+			"Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.129 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			Agent{DeviceBot, "Android", "Chrome", true},
+		},
+		{
+			"bingbot",
+			"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"yahoo slurp",
+			"Mozilla/5.0 (compatible; Yahoo! Slurp; http://help.yahoo.com/help/us/ysearch/slurp)",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"baidu spider",
+			"Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"facebook crawler",
+			"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"whatsapp preview",
+			"WhatsApp/2.23.20.0",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"curl",
+			"curl/8.4.0",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"wget",
+			"Wget/1.21.4",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"python requests",
+			"python-requests/2.31.0",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"go http client",
+			"Go-http-client/1.1",
+			Agent{DeviceBot, Other, Other, true},
+		},
+		{
+			"headless chrome",
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36",
+			Agent{DeviceBot, "Linux", "Headless Chrome", true},
+		},
+		{
+			"uptime monitor",
+			"Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)",
+			Agent{DeviceBot, Other, Other, true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.userAgent); got != tt.expected {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.userAgent, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestIsBot(t *testing.T) {
+	if !IsBot("Twitterbot/1.0") {
+		t.Error("expected Twitterbot to be a bot")
+	}
+	if IsBot("Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0") {
+		t.Error("expected Firefox not to be a bot")
+	}
+}
@@ -0,0 +1,142 @@
+package unfurl
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/netutil"
+)
+
+const (
+	defaultTimeout      = 5 * time.Second
+	defaultMaxBodyBytes = 512 * 1024
+	defaultMaxRedirects = 5
+	defaultUserAgent    = "URLShortenerBot/1.0 (+link preview)"
+)
+
+// Config holds fetcher configuration
+type Config struct {
+	Timeout      time.Duration
+	MaxBodyBytes int64
+	MaxRedirects int
+	UserAgent    string
+	// AllowPrivateNetworks disables SSRF protection; only meant for tests
+	AllowPrivateNetworks bool
+}
+
+// Fetcher downloads destination pages and extracts their preview metadata
+type Fetcher struct {
+	client *http.Client
+	config Config
+}
+
+// NewFetcher creates a new metadata fetcher
+func NewFetcher(config Config) *Fetcher {
+	if config.Timeout <= 0 {
+		config.Timeout = defaultTimeout
+	}
+	if config.MaxBodyBytes <= 0 {
+		config.MaxBodyBytes = defaultMaxBodyBytes
+	}
+	if config.MaxRedirects <= 0 {
+		config.MaxRedirects = defaultMaxRedirects
+	}
+	if config.UserAgent == "" {
+		config.UserAgent = defaultUserAgent
+	}
+
+	transport := netutil.NewTransport(netutil.TransportConfig{
+		Timeout:              config.Timeout,
+		MaxIdleConns:         10,
+		AllowPrivateNetworks: config.AllowPrivateNetworks,
+	})
+
+	client := &http.Client{
+		Transport: transport,
+		Timeout:   config.Timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > config.MaxRedirects {
+				return ErrTooManyRedirects
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("redirect to unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+
+	return &Fetcher{
+		client: client,
+		config: config,
+	}
+}
+
+// Fetch downloads longURL and extracts its title, description, image and favicon
+func (f *Fetcher) Fetch(ctx context.Context, longURL string) (*models.LinkPreview, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, longURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	req.Header.Set("User-Agent", f.config.UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch destination: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("destination returned status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return nil, ErrNotHTML
+	}
+
+	// Metadata lives in <head>, so a truncated document is still useful
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.config.MaxBodyBytes))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read destination: %w", err)
+	}
+
+	preview := parseHTML(body, resp.Request.URL)
+	preview.FetchedAt = time.Now()
+
+	return preview, nil
+}
+
+// resolveReference resolves a possibly relative URL found in the page
+func resolveReference(base *url.URL, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+
+	parsed, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+
+	resolved := base.ResolveReference(parsed)
+	if resolved.Scheme != "http" && resolved.Scheme != "https" {
+		return ""
+	}
+
+	return resolved.String()
+}
+
+// Custom errors
+var (
+	ErrPrivateAddress   = netutil.ErrPrivateAddress
+	ErrTooManyRedirects = fmt.Errorf("too many redirects")
+	ErrNotHTML          = fmt.Errorf("destination is not an HTML page")
+)
@@ -0,0 +1,154 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestFetcher(config Config) *Fetcher {
+	config.AllowPrivateNetworks = true
+	return NewFetcher(config)
+}
+
+func TestFetchExtractsMetadata(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, `<!DOCTYPE html>
+<html><head>
+<title> Plain   title </title>
+<meta name="description" content="Plain description">
+<meta property="og:title" content="OG &amp; title">
+<meta property="og:image" content="/images/card.png">
+<link rel="shortcut icon" href="/static/icon.ico">
+</head><body><meta property="og:title" content="ignored"></body></html>`)
+	}))
+	defer srv.Close()
+
+	preview, err := newTestFetcher(Config{}).Fetch(context.Background(), srv.URL+"/page")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if preview.Title != "OG & title" {
+		t.Errorf("expected OG title, got %q", preview.Title)
+	}
+	if preview.Description != "Plain description" {
+		t.Errorf("expected description fallback, got %q", preview.Description)
+	}
+	if preview.ImageURL != srv.URL+"/images/card.png" {
+		t.Errorf("expected absolute image URL, got %q", preview.ImageURL)
+	}
+	if preview.FaviconURL != srv.URL+"/static/icon.ico" {
+		t.Errorf("expected favicon from link tag, got %q", preview.FaviconURL)
+	}
+	if preview.FetchedAt.IsZero() {
+		t.Error("expected fetched_at to be set")
+	}
+}
+
+func TestFetchDefaultsFavicon(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><title>Only title</title></head></html>`)
+	}))
+	defer srv.Close()
+
+	preview, err := newTestFetcher(Config{}).Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if preview.Title != "Only title" {
+		t.Errorf("expected title, got %q", preview.Title)
+	}
+	if preview.FaviconURL != srv.URL+"/favicon.ico" {
+		t.Errorf("expected default favicon, got %q", preview.FaviconURL)
+	}
+}
+
+func TestFetchRejectsNonHTML(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pdf")
+		fmt.Fprint(w, "%PDF-1.4")
+	}))
+	defer srv.Close()
+
+	_, err := newTestFetcher(Config{}).Fetch(context.Background(), srv.URL)
+	if !errors.Is(err, ErrNotHTML) {
+		t.Errorf("expected ErrNotHTML, got %v", err)
+	}
+}
+
+func TestFetchLimitsBodySize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head>`+strings.Repeat(" ", 4096)+`<title>Too far</title></head></html>`)
+	}))
+	defer srv.Close()
+
+	preview, err := newTestFetcher(Config{MaxBodyBytes: 1024}).Fetch(context.Background(), srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if preview.Title != "" {
+		t.Errorf("expected title beyond the size limit to be ignored, got %q", preview.Title)
+	}
+}
+
+func TestFetchLimitsRedirects(t *testing.T) {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, srv.URL+r.URL.Path+"x", http.StatusFound)
+	}))
+	defer srv.Close()
+
+	_, err := newTestFetcher(Config{MaxRedirects: 3}).Fetch(context.Background(), srv.URL+"/")
+	if !errors.Is(err, ErrTooManyRedirects) {
+		t.Errorf("expected ErrTooManyRedirects, got %v", err)
+	}
+}
+
+func TestFetchTimesOut(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(2 * time.Second):
+		}
+	}))
+	defer srv.Close()
+
+	start := time.Now()
+	_, err := newTestFetcher(Config{Timeout: 100 * time.Millisecond}).Fetch(context.Background(), srv.URL)
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected fetch to give up quickly, took %v", elapsed)
+	}
+}
+
+func TestFetchBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request should not reach a loopback server")
+	}))
+	defer srv.Close()
+
+	// httptest listens on loopback, which the default fetcher must refuse
+	_, err := NewFetcher(Config{}).Fetch(context.Background(), srv.URL)
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("expected ErrPrivateAddress, got %v", err)
+	}
+}
+
+func TestFetchBlocksMetadataEndpoint(t *testing.T) {
+	_, err := NewFetcher(Config{}).Fetch(context.Background(), "http://169.254.169.254/latest/meta-data/")
+	if !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("expected ErrPrivateAddress for link-local address, got %v", err)
+	}
+}
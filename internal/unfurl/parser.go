@@ -0,0 +1,132 @@
+package unfurl
+
+import (
+	"bytes"
+	"net/url"
+	"strings"
+
+	"github.com/urlshortener/internal/models"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const maxFieldLength = 1024
+
+// parseHTML extracts preview metadata from the <head> of an HTML document.
+// Open Graph tags take precedence over <title> and the description meta tag.
+func parseHTML(body []byte, base *url.URL) *models.LinkPreview {
+	var (
+		title, ogTitle       string
+		description, ogDesc  string
+		ogImage, favicon     string
+		inTitle, seenHeadEnd bool
+	)
+
+	tokenizer := html.NewTokenizer(bytes.NewReader(body))
+	for !seenHeadEnd {
+		switch tokenizer.Next() {
+		case html.ErrorToken:
+			seenHeadEnd = true
+
+		case html.StartTagToken, html.SelfClosingTagToken:
+			token := tokenizer.Token()
+			switch token.DataAtom {
+			case atom.Title:
+				inTitle = title == ""
+			case atom.Meta:
+				key := strings.ToLower(attr(token, "property"))
+				if key == "" {
+					key = strings.ToLower(attr(token, "name"))
+				}
+				content := attr(token, "content")
+
+				switch key {
+				case "og:title":
+					ogTitle = firstNonEmpty(ogTitle, content)
+				case "twitter:title":
+					title = firstNonEmpty(title, content)
+				case "og:description":
+					ogDesc = firstNonEmpty(ogDesc, content)
+				case "description", "twitter:description":
+					description = firstNonEmpty(description, content)
+				case "og:image", "og:image:url", "og:image:secure_url", "twitter:image":
+					ogImage = firstNonEmpty(ogImage, content)
+				}
+			case atom.Link:
+				if isIconRel(attr(token, "rel")) {
+					favicon = firstNonEmpty(favicon, attr(token, "href"))
+				}
+			case atom.Body:
+				seenHeadEnd = true
+			}
+
+		case html.TextToken:
+			if inTitle {
+				title = strings.TrimSpace(string(tokenizer.Text()))
+			}
+
+		case html.EndTagToken:
+			name, _ := tokenizer.TagName()
+			switch atom.Lookup(name) {
+			case atom.Title:
+				inTitle = false
+			case atom.Head:
+				seenHeadEnd = true
+			}
+		}
+	}
+
+	preview := &models.LinkPreview{
+		Title:       clean(firstNonEmpty(ogTitle, title)),
+		Description: clean(firstNonEmpty(ogDesc, description)),
+		ImageURL:    resolveReference(base, ogImage),
+		FaviconURL:  resolveReference(base, favicon),
+	}
+
+	// Browsers fall back to /favicon.ico, so do we
+	if preview.FaviconURL == "" {
+		preview.FaviconURL = resolveReference(base, "/favicon.ico")
+	}
+
+	return preview
+}
+
+// attr returns the value of the named attribute, or "" if absent
+func attr(token html.Token, name string) string {
+	for _, a := range token.Attr {
+		if strings.EqualFold(a.Key, name) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+// isIconRel reports whether a <link rel> value declares a favicon
+func isIconRel(rel string) bool {
+	for _, value := range strings.Fields(strings.ToLower(rel)) {
+		if value == "icon" || value == "apple-touch-icon" {
+			return true
+		}
+	}
+	return false
+}
+
+// firstNonEmpty returns the first argument that is not blank
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// clean collapses whitespace and bounds the length of a text field
+func clean(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) > maxFieldLength {
+		// Trim on a rune boundary
+		s = strings.ToValidUTF8(s[:maxFieldLength], "")
+	}
+	return s
+}
@@ -0,0 +1,115 @@
+package unfurl
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+// Store persists unfurled previews
+type Store interface {
+	UpdateLinkPreview(ctx context.Context, code string, preview *models.LinkPreview) error
+}
+
+// ErrorHandler is notified when a preview could not be fetched or stored
+type ErrorHandler func(code string, err error)
+
+// job is a pending preview fetch
+type job struct {
+	code    string
+	longURL string
+}
+
+// Queue fetches previews in the background with a fixed number of workers
+type Queue struct {
+	fetcher *Fetcher
+	store   Store
+	onError ErrorHandler
+	jobs    chan job
+	workers int
+	wg      sync.WaitGroup
+	cancel  context.CancelFunc
+}
+
+// NewQueue creates a new preview queue. Jobs beyond size are dropped rather
+// than blocking link creation.
+func NewQueue(fetcher *Fetcher, store Store, workers, size int, onError ErrorHandler) *Queue {
+	if workers <= 0 {
+		workers = 1
+	}
+	if size <= 0 {
+		size = 100
+	}
+	if onError == nil {
+		onError = func(string, error) {}
+	}
+
+	return &Queue{
+		fetcher: fetcher,
+		store:   store,
+		onError: onError,
+		jobs:    make(chan job, size),
+		workers: workers,
+	}
+}
+
+// Start launches the worker goroutines
+func (q *Queue) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	for i := 0; i < q.workers; i++ {
+		q.wg.Add(1)
+		go q.work(ctx)
+	}
+}
+
+// Stop cancels in-flight fetches and waits for the workers to exit
+func (q *Queue) Stop() {
+	if q.cancel != nil {
+		q.cancel()
+	}
+	q.wg.Wait()
+}
+
+// Enqueue schedules a preview fetch, reporting false if the queue is full
+func (q *Queue) Enqueue(code, longURL string) bool {
+	select {
+	case q.jobs <- job{code: code, longURL: longURL}:
+		return true
+	default:
+		return false
+	}
+}
+
+// work processes jobs until the queue is stopped
+func (q *Queue) work(ctx context.Context) {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-q.jobs:
+			q.process(ctx, j)
+		}
+	}
+}
+
+// process fetches and stores a single preview
+func (q *Queue) process(ctx context.Context, j job) {
+	preview, err := q.fetcher.Fetch(ctx, j.longURL)
+	if err != nil {
+		q.onError(j.code, err)
+		return
+	}
+
+	storeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if err := q.store.UpdateLinkPreview(storeCtx, j.code, preview); err != nil {
+		q.onError(j.code, err)
+	}
+}
@@ -0,0 +1,76 @@
+package urlnorm
+
+import (
+	"strings"
+	"unicode"
+)
+
+// mixableScripts lists the scripts a label may combine, as in East Asian
+// names written with Latin letters, Han and a native script. Any other
+// combination of scripts is treated as a potential homograph.
+var mixableScripts = [][]string{
+	{"Latin", "Han", "Hiragana", "Katakana"},
+	{"Latin", "Han", "Bopomofo"},
+	{"Latin", "Han", "Hangul"},
+}
+
+// isHomograph reports whether a label of a Unicode host name mixes scripts
+// in a way that is not explained by mixableScripts. Digits, hyphens and
+// other characters shared between scripts are ignored.
+func isHomograph(host string) bool {
+	for _, label := range strings.Split(host, ".") {
+		scripts := labelScripts(label)
+		if len(scripts) > 1 && !isMixable(scripts) {
+			return true
+		}
+	}
+	return false
+}
+
+// labelScripts returns the scripts used in a label
+func labelScripts(label string) map[string]bool {
+	scripts := make(map[string]bool)
+	for _, r := range label {
+		if r < 0x80 {
+			if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' {
+				scripts["Latin"] = true
+			}
+			continue
+		}
+		if name := script(r); name != "" {
+			scripts[name] = true
+		}
+	}
+	return scripts
+}
+
+// script returns the name of the script a rune belongs to, or "" for
+// characters shared between scripts
+func script(r rune) string {
+	for name, table := range unicode.Scripts {
+		if name == "Common" || name == "Inherited" {
+			continue
+		}
+		if unicode.Is(table, r) {
+			return name
+		}
+	}
+	return ""
+}
+
+// isMixable reports whether all scripts appear together in one of the
+// allowed combinations
+func isMixable(scripts map[string]bool) bool {
+	for _, allowed := range mixableScripts {
+		matched := 0
+		for _, name := range allowed {
+			if scripts[name] {
+				matched++
+			}
+		}
+		if matched == len(scripts) {
+			return true
+		}
+	}
+	return false
+}
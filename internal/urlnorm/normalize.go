@@ -0,0 +1,190 @@
+package urlnorm
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/idna"
+)
+
+// profile converts hosts to their ASCII form as browsers do, but allows
+// underscores, which appear in real host names
+var profile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.StrictDomainName(false))
+
+// defaultPorts are dropped from normalized URLs
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+}
+
+// URL is a normalized URL
+type URL struct {
+	// URL is the normalized form of the URL
+	URL string
+	// Scheme is the lowercased scheme
+	Scheme string
+	// Host is the host name in lowercase ASCII, with internationalized
+	// labels in punycode, and without port
+	Host string
+	// DisplayHost is Host with punycode labels decoded, as users see it
+	DisplayHost string
+	// Homograph reports whether a label of the host mixes scripts, such as
+	// a Cyrillic "а" among Latin letters, and may be imitating another host
+	Homograph bool
+}
+
+// Normalize normalizes a URL. The scheme and host are lowercased, the host
+// is converted to punycode and a default port is dropped. Percent-escapes
+// of unreserved characters are decoded, other escapes are uppercased, and
+// characters that may not appear unescaped are escaped. An empty path
+// becomes "/". User info and opaque URLs are left as they are.
+func Normalize(raw string) (*URL, error) {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := strings.ToLower(parsed.Scheme)
+	if parsed.Opaque != "" || parsed.Host == "" {
+		parsed.Scheme = scheme
+		return &URL{URL: parsed.String(), Scheme: scheme}, nil
+	}
+
+	host, display, err := normalizeHost(parsed.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString(scheme)
+	b.WriteString("://")
+	if parsed.User != nil {
+		b.WriteString(parsed.User.String())
+		b.WriteByte('@')
+	}
+	if strings.Contains(host, ":") {
+		b.WriteString("[" + host + "]")
+	} else {
+		b.WriteString(host)
+	}
+	if port := parsed.Port(); port != "" && port != defaultPorts[scheme] {
+		b.WriteString(":" + port)
+	}
+
+	path := normalizeEscapes(parsed.EscapedPath())
+	if path == "" {
+		path = "/"
+	}
+	b.WriteString(path)
+	if parsed.RawQuery != "" || parsed.ForceQuery {
+		b.WriteByte('?')
+		b.WriteString(normalizeEscapes(parsed.RawQuery))
+	}
+	if parsed.Fragment != "" {
+		b.WriteByte('#')
+		b.WriteString(normalizeEscapes(parsed.EscapedFragment()))
+	}
+
+	return &URL{
+		URL:         b.String(),
+		Scheme:      scheme,
+		Host:        host,
+		DisplayHost: display,
+		Homograph:   isHomograph(display),
+	}, nil
+}
+
+// normalizeHost returns the ASCII and Unicode forms of a host name. IP
+// addresses are only lowercased.
+func normalizeHost(host string) (string, string, error) {
+	if strings.Contains(host, ":") {
+		host = strings.ToLower(host)
+		return host, host, nil
+	}
+
+	host = strings.TrimSuffix(host, ".")
+	ascii, err := profile.ToASCII(host)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidHost, err)
+	}
+	display, err := profile.ToUnicode(ascii)
+	if err != nil {
+		return "", "", fmt.Errorf("%w: %v", ErrInvalidHost, err)
+	}
+
+	return ascii, display, nil
+}
+
+// normalizeEscapes decodes percent-escapes of unreserved characters,
+// uppercases the hex digits of other escapes and escapes bytes that may not
+// appear unescaped in a URL. A "%" that does not start an escape is escaped.
+func normalizeEscapes(s string) string {
+	var b strings.Builder
+	b.Grow(len(s))
+
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '%' && i+2 < len(s) && isHex(s[i+1]) && isHex(s[i+2]):
+			decoded := unhex(s[i+1])<<4 | unhex(s[i+2])
+			if isUnreserved(decoded) {
+				b.WriteByte(decoded)
+			} else {
+				writeEscape(&b, decoded)
+			}
+			i += 2
+		case c == '%' || mustEscape(c):
+			writeEscape(&b, c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// writeEscape writes a byte as an uppercase percent-escape
+func writeEscape(b *strings.Builder, c byte) {
+	const hex = "0123456789ABCDEF"
+	b.WriteByte('%')
+	b.WriteByte(hex[c>>4])
+	b.WriteByte(hex[c&0x0f])
+}
+
+// isUnreserved reports whether c never needs escaping (RFC 3986, 2.3)
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// mustEscape reports whether c may not appear unescaped in a URL: control
+// characters, spaces, non-ASCII bytes and the characters RFC 3986 excludes
+func mustEscape(c byte) bool {
+	if c <= ' ' || c >= 0x7f {
+		return true
+	}
+	return strings.IndexByte(`"<>\^`+"`{|}", c) >= 0
+}
+
+// isHex reports whether c is a hexadecimal digit
+func isHex(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// unhex returns the value of a hexadecimal digit
+func unhex(c byte) byte {
+	switch {
+	case '0' <= c && c <= '9':
+		return c - '0'
+	case 'a' <= c && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
+
+// Custom errors
+var (
+	ErrInvalidHost = fmt.Errorf("invalid host name")
+)
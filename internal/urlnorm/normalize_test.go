@@ -0,0 +1,141 @@
+package urlnorm
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected string
+		host     string
+		display  string
+	}{
+		{
+			raw:      "HTTP://WWW.Example.COM",
+			expected: "http://www.example.com/",
+			host:     "www.example.com",
+			display:  "www.example.com",
+		},
+		{
+			raw:      "https://Bücher.example/katalog",
+			expected: "https://xn--bcher-kva.example/katalog",
+			host:     "xn--bcher-kva.example",
+			display:  "bücher.example",
+		},
+		{
+			raw:      "https://xn--bcher-kva.example./",
+			expected: "https://xn--bcher-kva.example/",
+			host:     "xn--bcher-kva.example",
+			display:  "bücher.example",
+		},
+		{
+			raw:      "http://example.com:80/a?b=1",
+			expected: "http://example.com/a?b=1",
+			host:     "example.com",
+			display:  "example.com",
+		},
+		{
+			raw:      "https://example.com:8443/",
+			expected: "https://example.com:8443/",
+			host:     "example.com",
+			display:  "example.com",
+		},
+		{
+			raw:      "https://example.com/%7euser/a%2fb/%41?q=%e2%82%ac&r=%zz#Top%2d",
+			expected: "https://example.com/~user/a%2Fb/A?q=%E2%82%AC&r=%25zz#Top-",
+			host:     "example.com",
+			display:  "example.com",
+		},
+		{
+			raw:      "https://example.com/café?q=a b",
+			expected: "https://example.com/caf%C3%A9?q=a%20b",
+			host:     "example.com",
+			display:  "example.com",
+		},
+		{
+			raw:      "http://[2001:DB8::1]:8080/",
+			expected: "http://[2001:db8::1]:8080/",
+			host:     "2001:db8::1",
+			display:  "2001:db8::1",
+		},
+		{
+			raw:      "https://my_host.example.com/",
+			expected: "https://my_host.example.com/",
+			host:     "my_host.example.com",
+			display:  "my_host.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		u, err := Normalize(tt.raw)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.raw, err)
+		}
+		if u.URL != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.raw, tt.expected, u.URL)
+		}
+		if u.Host != tt.host || u.DisplayHost != tt.display {
+			t.Errorf("%q: expected host %q (%q), got %q (%q)", tt.raw, tt.host, tt.display, u.Host, u.DisplayHost)
+		}
+	}
+}
+
+func TestNormalizeIsIdempotent(t *testing.T) {
+	for _, raw := range []string{
+		"https://Bücher.example/%7e/a%2fb?q=%e2%82%ac",
+		"https://example.com/café?q=a b#x y",
+	} {
+		first, err := Normalize(raw)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", raw, err)
+		}
+		second, err := Normalize(first.URL)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", first.URL, err)
+		}
+		if second.URL != first.URL {
+			t.Errorf("%q: expected %q, got %q", raw, first.URL, second.URL)
+		}
+	}
+}
+
+func TestNormalizeRejectsInvalidHosts(t *testing.T) {
+	for _, raw := range []string{
+		"https://-example.com/",
+		"https://xn--a.com/",
+		"https://a‍b.com/",
+	} {
+		if _, err := Normalize(raw); !errors.Is(err, ErrInvalidHost) {
+			t.Errorf("%q: expected ErrInvalidHost, got %v", raw, err)
+		}
+	}
+}
+
+func TestHomograph(t *testing.T) {
+	tests := []struct {
+		raw       string
+		homograph bool
+	}{
+		{"https://example.com/", false},
+		{"https://bücher.example/", false},
+		{"https://яндекс.рф/", false},
+		{"https://аpple.com/", true},        // Cyrillic "а"
+		{"https://xn--pple-43d.com/", true}, // the same host in punycode
+		{"https://paypaӏ.com/", true},       // Cyrillic palochka
+		{"https://gοogle.com/", true},       // Greek omicron
+		{"https://sonyストア.jp/", false},
+		{"https://apple.рф/", false}, // scripts differ only between labels
+	}
+
+	for _, tt := range tests {
+		u, err := Normalize(tt.raw)
+		if err != nil {
+			t.Fatalf("%q: unexpected error: %v", tt.raw, err)
+		}
+		if u.Homograph != tt.homograph {
+			t.Errorf("%q (%s): expected homograph %v", tt.raw, u.DisplayHost, tt.homograph)
+		}
+	}
+}
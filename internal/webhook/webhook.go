@@ -0,0 +1,87 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event types a subscription can receive
+const (
+	EventLinkCreated        = "link.created"
+	EventLinkUpdated        = "link.updated"
+	EventLinkDeleted        = "link.deleted"
+	EventLinkExpired        = "link.expired"
+	EventLinkClickThreshold = "link.click_threshold"
+)
+
+// Events lists every supported event type
+var Events = []string{
+	EventLinkCreated,
+	EventLinkUpdated,
+	EventLinkDeleted,
+	EventLinkExpired,
+	EventLinkClickThreshold,
+}
+
+// Request headers sent with every delivery
+const (
+	HeaderID        = "X-Webhook-ID"
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// signaturePrefix names the algorithm in the signature header
+const signaturePrefix = "sha256="
+
+// ValidEvent reports whether event is a supported event type
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign computes the signature header for a delivery: the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the subscription secret. Including the
+// timestamp lets receivers reject replayed requests.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp.Unix(), 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a signature header in constant time. Receivers written in
+// Go can use it together with a tolerance check on the timestamp.
+func Verify(secret string, timestamp time.Time, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// Backoff returns the delay before retrying after the given number of failed
+// attempts: base doubled per attempt and capped at max, less up to 20%
+// jitter so failing endpoints are not hit by synchronized bursts
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	delay := base
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	if jitter := int64(delay) / 5; jitter > 0 {
+		delay -= time.Duration(rand.Int63n(jitter))
+	}
+	return delay
+}
@@ -0,0 +1,205 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	ts := time.Unix(1714564800, 0)
+	body := []byte(`{"type":"link.created"}`)
+
+	signature := Sign("secret", ts, body)
+	if signature != Sign("secret", ts, body) {
+		t.Error("expected signatures to be deterministic")
+	}
+	if !Verify("secret", ts, body, signature) {
+		t.Error("expected signature to verify")
+	}
+
+	tests := map[string]struct {
+		secret    string
+		ts        time.Time
+		body      []byte
+		signature string
+	}{
+		"wrong secret":    {"other", ts, body, signature},
+		"wrong timestamp": {"secret", ts.Add(time.Second), body, signature},
+		"tampered body":   {"secret", ts, []byte(`{"type":"link.deleted"}`), signature},
+		"missing prefix":  {"secret", ts, body, signature[len(signaturePrefix):]},
+		"empty signature": {"secret", ts, body, ""},
+	}
+	for name, tt := range tests {
+		if Verify(tt.secret, tt.ts, tt.body, tt.signature) {
+			t.Errorf("%s: expected verification to fail", name)
+		}
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base, max := time.Second, time.Minute
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{7, time.Minute},
+		{50, time.Minute},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			got := Backoff(tt.attempts, base, max)
+			if got > tt.expected || got < tt.expected*4/5 {
+				t.Errorf("Backoff(%d): expected %v less up to 20%%, got %v", tt.attempts, tt.expected, got)
+			}
+		}
+	}
+}
+
+// fakeStore serves a fixed set of deliveries and records their attempts
+type fakeStore struct {
+	mu         sync.Mutex
+	pending    []models.WebhookDelivery
+	dispatched int
+	attempts   map[int64]models.WebhookAttempt
+}
+
+func (s *fakeStore) DispatchWebhookEvents(ctx context.Context, limit int) (int, error) {
+	s.dispatched++
+	return 0, nil
+}
+
+func (s *fakeStore) ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error) {
+	claimed := s.pending
+	s.pending = nil
+	return claimed, nil
+}
+
+func (s *fakeStore) RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attempts[attempt.DeliveryID] = attempt
+	return nil
+}
+
+func (s *fakeStore) PruneWebhookDeliveries(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func TestWorkerRunOnce(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	var mu sync.Mutex
+	received := make(map[string]http.Header)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		if !Verify("secret", time.Unix(ts, 0), body, r.Header.Get(HeaderSignature)) {
+			http.Error(w, "bad signature", http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		received[r.URL.Path] = r.Header.Clone()
+		mu.Unlock()
+
+		switch r.URL.Path {
+		case "/fail":
+			http.Error(w, "maintenance", http.StatusServiceUnavailable)
+		case "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer srv.Close()
+
+	payload := json.RawMessage(`{"id":7,"type":"link.created","data":{"code":"abc123"}}`)
+	delivery := func(id int64, path string, attempts int) models.WebhookDelivery {
+		return models.WebhookDelivery{
+			ID: id, EventID: 7, EventType: EventLinkCreated, Payload: payload,
+			Attempts: attempts, URL: srv.URL + path, Secret: "secret",
+		}
+	}
+	store := &fakeStore{
+		pending: []models.WebhookDelivery{
+			delivery(1, "/ok", 0),
+			delivery(2, "/fail", 0),
+			delivery(3, "/redirect", 0),
+			delivery(4, "/fail", 4),
+		},
+		attempts: make(map[int64]models.WebhookAttempt),
+	}
+
+	worker := NewWorker(store, Config{
+		BatchSize:            10,
+		MaxAttempts:          5,
+		BaseBackoff:          time.Minute,
+		MaxBackoff:           time.Hour,
+		AllowPrivateNetworks: true,
+	}, nil)
+	worker.now = func() time.Time { return now }
+
+	if err := worker.RunOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if store.dispatched != 1 {
+		t.Errorf("expected one dispatch, got %d", store.dispatched)
+	}
+
+	if a := store.attempts[1]; !a.Succeeded || a.StatusCode != http.StatusNoContent || a.RetryAt != nil {
+		t.Errorf("expected delivery 1 to succeed, got %+v", a)
+	}
+	if h := received["/ok"]; h.Get(HeaderEvent) != EventLinkCreated || h.Get(HeaderID) != "7" ||
+		h.Get(HeaderTimestamp) != strconv.FormatInt(now.Unix(), 10) {
+		t.Errorf("unexpected headers %v", h)
+	}
+
+	a := store.attempts[2]
+	if a.Succeeded || a.StatusCode != http.StatusServiceUnavailable || a.Error == "" {
+		t.Errorf("expected delivery 2 to fail, got %+v", a)
+	}
+	if a.RetryAt == nil || a.RetryAt.After(now.Add(time.Minute)) || a.RetryAt.Before(now.Add(48*time.Second)) {
+		t.Errorf("expected a retry about a minute later, got %v", a.RetryAt)
+	}
+
+	if a := store.attempts[3]; a.Succeeded || a.StatusCode != http.StatusFound {
+		t.Errorf("expected the redirect to fail, got %+v", a)
+	}
+	if _, ok := received["/ok"]; !ok || len(received) != 3 {
+		t.Errorf("expected the redirect not to be followed, got requests for %v", received)
+	}
+
+	if a := store.attempts[4]; a.Succeeded || a.RetryAt != nil {
+		t.Errorf("expected delivery 4 to be dead-lettered, got %+v", a)
+	}
+}
+
+func TestWorkerBlocksPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("request reached a loopback endpoint")
+	}))
+	defer srv.Close()
+
+	store := &fakeStore{
+		pending:  []models.WebhookDelivery{{ID: 1, URL: srv.URL, Secret: "secret", Payload: json.RawMessage(`{}`)}},
+		attempts: make(map[int64]models.WebhookAttempt),
+	}
+	if err := NewWorker(store, Config{}, nil).RunOnce(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a := store.attempts[1]; a.Succeeded || a.RetryAt == nil {
+		t.Errorf("expected a failed attempt to be retried, got %+v", a)
+	}
+}
@@ -0,0 +1,280 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/urlshortener/internal/models"
+	"github.com/urlshortener/internal/netutil"
+)
+
+// maxErrorLength bounds the response excerpt kept in the delivery log
+const maxErrorLength = 512
+
+// Store moves events from the outbox to deliveries and tracks their attempts
+type Store interface {
+	// DispatchWebhookEvents fans queued outbox events out to one delivery per
+	// matching subscription and returns the number of events consumed
+	DispatchWebhookEvents(ctx context.Context, limit int) (int, error)
+	// ClaimWebhookDeliveries returns due deliveries, hiding them from other
+	// workers for the lease duration
+	ClaimWebhookDeliveries(ctx context.Context, limit int, lease time.Duration) ([]models.WebhookDelivery, error)
+	RecordWebhookAttempt(ctx context.Context, attempt models.WebhookAttempt) error
+	PruneWebhookDeliveries(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Config holds delivery worker configuration
+type Config struct {
+	Interval     time.Duration // how often the outbox is polled
+	BatchSize    int           // events dispatched and deliveries claimed per poll
+	Concurrency  int           // deliveries sent in parallel
+	Timeout      time.Duration // time limit for a single request
+	MaxAttempts  int           // attempts before a delivery is dead-lettered
+	BaseBackoff  time.Duration // delay after the first failure
+	MaxBackoff   time.Duration // upper bound on the retry delay
+	LogRetention time.Duration // how long finished deliveries are kept
+	UserAgent    string
+	// AllowPrivateNetworks disables SSRF protection; only meant for tests
+	AllowPrivateNetworks bool
+}
+
+// Worker delivers outbox events to subscribed endpoints. Every request is
+// signed with the subscription secret; failed deliveries are retried with
+// exponential backoff and dead-lettered after the last attempt.
+type Worker struct {
+	store   Store
+	config  Config
+	client  *http.Client
+	onError func(error)
+	now     func() time.Time
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewWorker creates a delivery worker
+func NewWorker(store Store, config Config, onError func(error)) *Worker {
+	if config.Interval <= 0 {
+		config.Interval = 5 * time.Second
+	}
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+	if config.Concurrency <= 0 {
+		config.Concurrency = 4
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = 8
+	}
+	if config.BaseBackoff <= 0 {
+		config.BaseBackoff = 30 * time.Second
+	}
+	if config.MaxBackoff <= 0 {
+		config.MaxBackoff = 6 * time.Hour
+	}
+	if config.LogRetention <= 0 {
+		config.LogRetention = 7 * 24 * time.Hour
+	}
+	if config.UserAgent == "" {
+		config.UserAgent = "URLShortener-Webhooks/1.0"
+	}
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	client := &http.Client{
+		Transport: netutil.NewTransport(netutil.TransportConfig{
+			Timeout:              config.Timeout,
+			MaxIdleConns:         config.Concurrency,
+			AllowPrivateNetworks: config.AllowPrivateNetworks,
+		}),
+		Timeout: config.Timeout,
+		// A redirect is reported as a failure instead of re-posting the event
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return &Worker{
+		store:   store,
+		config:  config,
+		client:  client,
+		onError: onError,
+		now:     time.Now,
+	}
+}
+
+// Start runs the worker in the background until Stop is called
+func (w *Worker) Start() {
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+
+	w.wg.Add(1)
+	go w.run(ctx)
+}
+
+// Stop cancels in-flight deliveries and waits for the worker to exit.
+// Interrupted deliveries are retried once their lease expires.
+func (w *Worker) Stop() {
+	if w.cancel != nil {
+		w.cancel()
+	}
+	w.wg.Wait()
+}
+
+// run polls for events on every tick and prunes the delivery log hourly
+func (w *Worker) run(ctx context.Context) {
+	defer w.wg.Done()
+
+	ticker := time.NewTicker(w.config.Interval)
+	defer ticker.Stop()
+
+	var lastPrune time.Time
+	for {
+		if err := w.RunOnce(ctx); err != nil && ctx.Err() == nil {
+			w.onError(err)
+		}
+
+		if now := w.now(); now.Sub(lastPrune) >= time.Hour {
+			lastPrune = now
+			if _, err := w.store.PruneWebhookDeliveries(ctx, now.Add(-w.config.LogRetention)); err != nil && ctx.Err() == nil {
+				w.onError(err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce dispatches queued events and sends every delivery that is due
+func (w *Worker) RunOnce(ctx context.Context) error {
+	for {
+		count, err := w.store.DispatchWebhookEvents(ctx, w.config.BatchSize)
+		if err != nil {
+			return err
+		}
+		if count < w.config.BatchSize {
+			break
+		}
+	}
+
+	// A claimed delivery stays hidden for long enough to finish every request
+	// in the batch, even one slot at a time
+	lease := w.config.Timeout*time.Duration(w.config.BatchSize/w.config.Concurrency+1) + time.Minute
+
+	for {
+		deliveries, err := w.store.ClaimWebhookDeliveries(ctx, w.config.BatchSize, lease)
+		if err != nil {
+			return err
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+
+		w.sendAll(ctx, deliveries)
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if len(deliveries) < w.config.BatchSize {
+			return nil
+		}
+	}
+}
+
+// sendAll sends deliveries with bounded concurrency and records each outcome
+func (w *Worker) sendAll(ctx context.Context, deliveries []models.WebhookDelivery) {
+	slots := make(chan struct{}, w.config.Concurrency)
+	var wg sync.WaitGroup
+
+	for i := range deliveries {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(delivery *models.WebhookDelivery) {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+
+			attempt := w.send(ctx, delivery)
+			if ctx.Err() != nil {
+				// Shutting down; the lease expires and another run retries
+				return
+			}
+			if err := w.store.RecordWebhookAttempt(ctx, attempt); err != nil {
+				w.onError(err)
+			}
+		}(&deliveries[i])
+	}
+
+	wg.Wait()
+}
+
+// send makes one delivery attempt
+func (w *Worker) send(ctx context.Context, delivery *models.WebhookDelivery) models.WebhookAttempt {
+	attempt := models.WebhookAttempt{DeliveryID: delivery.ID}
+
+	statusCode, err := w.post(ctx, delivery)
+	attempt.StatusCode = statusCode
+	if err == nil {
+		attempt.Succeeded = true
+		return attempt
+	}
+
+	attempt.Error = err.Error()
+	if delivery.Attempts+1 < w.config.MaxAttempts {
+		retryAt := w.now().Add(Backoff(delivery.Attempts+1, w.config.BaseBackoff, w.config.MaxBackoff))
+		attempt.RetryAt = &retryAt
+	}
+	return attempt
+}
+
+// post sends the signed payload and returns the response status. Any status
+// outside 2xx is an error.
+func (w *Worker) post(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %w", err)
+	}
+
+	timestamp := w.now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", w.config.UserAgent)
+	req.Header.Set(HeaderID, strconv.FormatInt(delivery.EventID, 10))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp.Unix(), 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, delivery.Payload))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("failed to send webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorLength))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if body := strings.TrimSpace(strings.ToValidUTF8(string(body), "")); body != "" {
+			return resp.StatusCode, fmt.Errorf("endpoint returned status %d: %s", resp.StatusCode, body)
+		}
+		return resp.StatusCode, fmt.Errorf("endpoint returned status %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// Custom errors
+var (
+	ErrPrivateAddress = netutil.ErrPrivateAddress
+)
@@ -0,0 +1,2 @@
+ALTER TABLE short_urls DROP COLUMN IF EXISTS show_interstitial;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS title;
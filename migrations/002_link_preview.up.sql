@@ -0,0 +1,5 @@
+-- Owner supplied title shown on the preview page
+ALTER TABLE short_urls ADD COLUMN title VARCHAR(255) NULL;
+
+-- Links flagged to always show the interstitial before redirecting
+ALTER TABLE short_urls ADD COLUMN show_interstitial BOOLEAN NOT NULL DEFAULT FALSE;
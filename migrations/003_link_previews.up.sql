@@ -0,0 +1,9 @@
+-- Metadata unfurled from the destination page after a link is created
+CREATE TABLE link_previews (
+    code VARCHAR(16) PRIMARY KEY REFERENCES short_urls(code) ON DELETE CASCADE,
+    title TEXT NULL,
+    description TEXT NULL,
+    image_url TEXT NULL,
+    favicon_url TEXT NULL,
+    fetched_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
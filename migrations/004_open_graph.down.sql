@@ -0,0 +1,3 @@
+ALTER TABLE short_urls DROP COLUMN IF EXISTS og_image;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS og_description;
+ALTER TABLE short_urls DROP COLUMN IF EXISTS og_title;
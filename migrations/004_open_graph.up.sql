@@ -0,0 +1,4 @@
+-- Per-link Open Graph overrides served to social crawlers
+ALTER TABLE short_urls ADD COLUMN og_title VARCHAR(255) NULL;
+ALTER TABLE short_urls ADD COLUMN og_description TEXT NULL;
+ALTER TABLE short_urls ADD COLUMN og_image TEXT NULL;
@@ -0,0 +1,8 @@
+DROP INDEX IF EXISTS idx_short_urls_campaign_id;
+DROP INDEX IF EXISTS idx_short_urls_created_by;
+
+DROP TABLE IF EXISTS url_tags;
+DROP TABLE IF EXISTS tags;
+
+ALTER TABLE short_urls DROP COLUMN IF EXISTS campaign_id;
+DROP TABLE IF EXISTS campaigns;
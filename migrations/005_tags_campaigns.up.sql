@@ -0,0 +1,28 @@
+-- Campaigns group links (acting as folders) for an owner
+CREATE TABLE campaigns (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NULL,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (created_by, name)
+);
+
+ALTER TABLE short_urls ADD COLUMN campaign_id BIGINT NULL REFERENCES campaigns(id) ON DELETE SET NULL;
+
+-- Tags are shared names attached to links through url_tags
+CREATE TABLE tags (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(64) UNIQUE NOT NULL
+);
+
+CREATE TABLE url_tags (
+    code VARCHAR(16) NOT NULL REFERENCES short_urls(code) ON DELETE CASCADE,
+    tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (code, tag_id)
+);
+
+CREATE INDEX idx_short_urls_created_by ON short_urls(created_by, created_at DESC);
+CREATE INDEX idx_short_urls_campaign_id ON short_urls(campaign_id) WHERE campaign_id IS NOT NULL;
+CREATE INDEX idx_url_tags_tag_id ON url_tags(tag_id);
@@ -0,0 +1,16 @@
+CREATE OR REPLACE FUNCTION update_click_stats()
+RETURNS TRIGGER AS $$
+BEGIN
+    INSERT INTO click_stats (code, total_clicks, last_access_at, first_access_at)
+    VALUES (NEW.code, 1, NEW.ts, NEW.ts)
+    ON CONFLICT (code) DO UPDATE SET
+        total_clicks = click_stats.total_clicks + 1,
+        last_access_at = NEW.ts;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_update_click_stats
+    AFTER INSERT ON click_events
+    FOR EACH ROW
+    EXECUTE FUNCTION update_click_stats();
@@ -0,0 +1,4 @@
+-- Click statistics are now aggregated per batch by the application, so the
+-- per-row trigger would double count and serialize writes on hot links
+DROP TRIGGER IF EXISTS trigger_update_click_stats ON click_events;
+DROP FUNCTION IF EXISTS update_click_stats();
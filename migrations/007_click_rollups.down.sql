@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS click_rollup_state;
+DROP TABLE IF EXISTS click_dimension_rollups_hourly;
+DROP TABLE IF EXISTS click_rollups_hourly;
+
+ALTER TABLE click_events DROP COLUMN IF EXISTS browser;
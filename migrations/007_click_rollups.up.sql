@@ -0,0 +1,58 @@
+ALTER TABLE click_events ADD COLUMN browser VARCHAR(50) NULL;
+
+-- Hourly click counts per link, maintained by the background aggregator
+CREATE TABLE click_rollups_hourly (
+    code VARCHAR(16) NOT NULL REFERENCES short_urls(code) ON DELETE CASCADE,
+    bucket TIMESTAMPTZ NOT NULL,
+    clicks BIGINT NOT NULL,
+    PRIMARY KEY (code, bucket)
+);
+
+-- Hourly click counts per link broken down by referrer, country, device type and browser
+CREATE TABLE click_dimension_rollups_hourly (
+    code VARCHAR(16) NOT NULL REFERENCES short_urls(code) ON DELETE CASCADE,
+    bucket TIMESTAMPTZ NOT NULL,
+    dimension VARCHAR(16) NOT NULL,
+    value TEXT NOT NULL,
+    clicks BIGINT NOT NULL,
+    PRIMARY KEY (code, dimension, bucket, value)
+);
+
+-- Rollups are complete for every hour before rolled_up_until; later clicks
+-- are read from click_events directly
+CREATE TABLE click_rollup_state (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    rolled_up_until TIMESTAMPTZ NOT NULL
+);
+
+-- Backfill existing clicks
+INSERT INTO click_rollups_hourly (code, bucket, clicks)
+SELECT code, date_trunc('hour', ts), COUNT(*)
+FROM click_events
+WHERE ts < date_trunc('hour', NOW())
+GROUP BY 1, 2;
+
+INSERT INTO click_dimension_rollups_hourly (code, bucket, dimension, value, clicks)
+SELECT code, date_trunc('hour', ts), 'referrer',
+    CASE
+        WHEN referer IS NULL OR referer = '' THEN '(direct)'
+        ELSE COALESCE(lower(substring(referer from '^[A-Za-z][A-Za-z0-9+.-]*://([^/:?#]+)')), '(other)')
+    END,
+    COUNT(*)
+FROM click_events
+WHERE ts < date_trunc('hour', NOW())
+GROUP BY 1, 2, 4;
+
+INSERT INTO click_dimension_rollups_hourly (code, bucket, dimension, value, clicks)
+SELECT code, date_trunc('hour', ts), 'country', COALESCE(NULLIF(country, ''), 'unknown'), COUNT(*)
+FROM click_events
+WHERE ts < date_trunc('hour', NOW())
+GROUP BY 1, 2, 4;
+
+INSERT INTO click_dimension_rollups_hourly (code, bucket, dimension, value, clicks)
+SELECT code, date_trunc('hour', ts), 'device_type', COALESCE(NULLIF(device_type, ''), 'unknown'), COUNT(*)
+FROM click_events
+WHERE ts < date_trunc('hour', NOW())
+GROUP BY 1, 2, 4;
+
+INSERT INTO click_rollup_state (rolled_up_until) VALUES (date_trunc('hour', NOW()));
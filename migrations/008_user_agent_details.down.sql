@@ -0,0 +1,5 @@
+ALTER TABLE click_rollups_hourly DROP COLUMN IF EXISTS bot_clicks;
+ALTER TABLE click_stats DROP COLUMN IF EXISTS bot_clicks;
+
+ALTER TABLE click_events DROP COLUMN IF EXISTS is_bot;
+ALTER TABLE click_events DROP COLUMN IF EXISTS os;
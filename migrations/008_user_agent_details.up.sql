@@ -0,0 +1,6 @@
+ALTER TABLE click_events ADD COLUMN os VARCHAR(50) NULL;
+ALTER TABLE click_events ADD COLUMN is_bot BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Bot clicks are counted separately so totals and breakdowns reflect people
+ALTER TABLE click_stats ADD COLUMN bot_clicks BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE click_rollups_hourly ADD COLUMN bot_clicks BIGINT NOT NULL DEFAULT 0;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_click_events_identifiable_ts;
+ALTER TABLE click_events DROP COLUMN IF EXISTS ip_hash;
@@ -0,0 +1,6 @@
+-- Salted hashes of visitor IPs, stored instead of the address in hash mode
+ALTER TABLE click_events ADD COLUMN ip_hash VARCHAR(64) NULL;
+
+-- Finds old clicks that still carry per-visitor data for the retention worker
+CREATE INDEX idx_click_events_identifiable_ts ON click_events(ts)
+    WHERE ip_address IS NOT NULL OR ip_hash IS NOT NULL OR user_agent IS NOT NULL;
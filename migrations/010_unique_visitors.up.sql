@@ -0,0 +1,7 @@
+-- Daily unique visitor estimates, persisted from Redis HyperLogLogs
+CREATE TABLE daily_unique_visitors (
+    code VARCHAR(16) NOT NULL REFERENCES short_urls(code) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    visitors BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (code, day)
+);
@@ -0,0 +1,10 @@
+DROP TRIGGER IF EXISTS trigger_click_stats_webhook_events ON click_stats;
+DROP TRIGGER IF EXISTS trigger_short_urls_webhook_events ON short_urls;
+DROP FUNCTION IF EXISTS click_stats_webhook_events();
+DROP FUNCTION IF EXISTS short_urls_webhook_events();
+DROP FUNCTION IF EXISTS webhook_link_payload(short_urls);
+DROP FUNCTION IF EXISTS enqueue_webhook_event(TEXT, TEXT, JSONB, BIGINT);
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhook_outbox;
+DROP TABLE IF EXISTS webhook_subscriptions;
@@ -0,0 +1,137 @@
+-- Webhook endpoints registered by link owners
+CREATE TABLE webhook_subscriptions (
+    id BIGSERIAL PRIMARY KEY,
+    owner VARCHAR(255) NOT NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(64) NOT NULL,
+    events TEXT[] NOT NULL,
+    click_thresholds BIGINT[] NOT NULL DEFAULT '{}',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhook_subscriptions_owner ON webhook_subscriptions(owner) WHERE active;
+
+-- Link events waiting to be fanned out to subscriptions. Rows are written by
+-- triggers, so an event is recorded if and only if its change commits.
+CREATE TABLE webhook_outbox (
+    id BIGSERIAL PRIMARY KEY,
+    owner VARCHAR(255) NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSONB NOT NULL,
+    threshold BIGINT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- One row per event and subscription, doubling as the delivery log
+CREATE TABLE webhook_deliveries (
+    id BIGSERIAL PRIMARY KEY,
+    subscription_id BIGINT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    event_id BIGINT NOT NULL,
+    event_type VARCHAR(32) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_status_code INT NULL,
+    last_error TEXT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, created_at DESC);
+
+-- Queues an event for a link owner if they have any active subscription
+CREATE OR REPLACE FUNCTION enqueue_webhook_event(owner_name TEXT, event TEXT, data JSONB, crossed BIGINT)
+RETURNS VOID AS $$
+BEGIN
+    IF owner_name IS NULL THEN
+        RETURN;
+    END IF;
+    IF EXISTS (SELECT 1 FROM webhook_subscriptions WHERE owner = owner_name AND active) THEN
+        INSERT INTO webhook_outbox (owner, event_type, payload, threshold)
+        VALUES (owner_name, event, data, crossed);
+    END IF;
+END;
+$$ LANGUAGE plpgsql;
+
+-- The link fields included in lifecycle event payloads
+CREATE OR REPLACE FUNCTION webhook_link_payload(link short_urls)
+RETURNS JSONB AS $$
+    SELECT jsonb_build_object(
+        'code', link.code,
+        'long_url', link.long_url,
+        'title', link.title,
+        'campaign_id', link.campaign_id,
+        'created_at', link.created_at,
+        'expire_at', link.expire_at
+    );
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION short_urls_webhook_events()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        PERFORM enqueue_webhook_event(NEW.created_by, 'link.created', webhook_link_payload(NEW), NULL);
+    ELSIF NEW.is_deleted AND NOT OLD.is_deleted THEN
+        -- CleanupExpiredURLs deletes links whose expiry has passed
+        IF NEW.expire_at IS NOT NULL AND NEW.expire_at <= NOW() THEN
+            PERFORM enqueue_webhook_event(NEW.created_by, 'link.expired', webhook_link_payload(NEW), NULL);
+        ELSE
+            PERFORM enqueue_webhook_event(NEW.created_by, 'link.deleted', webhook_link_payload(NEW), NULL);
+        END IF;
+    ELSIF NOT NEW.is_deleted AND (
+        NEW.long_url, NEW.title, NEW.campaign_id, NEW.expire_at, NEW.show_interstitial,
+        NEW.og_title, NEW.og_description, NEW.og_image
+    ) IS DISTINCT FROM (
+        OLD.long_url, OLD.title, OLD.campaign_id, OLD.expire_at, OLD.show_interstitial,
+        OLD.og_title, OLD.og_description, OLD.og_image
+    ) THEN
+        PERFORM enqueue_webhook_event(NEW.created_by, 'link.updated', webhook_link_payload(NEW), NULL);
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_short_urls_webhook_events
+    AFTER INSERT OR UPDATE ON short_urls
+    FOR EACH ROW EXECUTE FUNCTION short_urls_webhook_events();
+
+-- Emits one event per subscribed threshold that a click_stats change crossed
+CREATE OR REPLACE FUNCTION click_stats_webhook_events()
+RETURNS TRIGGER AS $$
+DECLARE
+    previous BIGINT := 0;
+    link_owner TEXT;
+    crossed BIGINT;
+BEGIN
+    IF TG_OP = 'UPDATE' THEN
+        previous := OLD.total_clicks;
+    END IF;
+    IF NEW.total_clicks <= previous THEN
+        RETURN NULL;
+    END IF;
+
+    SELECT created_by INTO link_owner FROM short_urls WHERE code = NEW.code;
+    IF link_owner IS NULL THEN
+        RETURN NULL;
+    END IF;
+
+    FOR crossed IN
+        SELECT DISTINCT t
+        FROM webhook_subscriptions s, unnest(s.click_thresholds) AS t
+        WHERE s.owner = link_owner AND s.active AND t > previous AND t <= NEW.total_clicks
+        ORDER BY t
+    LOOP
+        PERFORM enqueue_webhook_event(link_owner, 'link.click_threshold',
+            jsonb_build_object('code', NEW.code, 'threshold', crossed, 'total_clicks', NEW.total_clicks),
+            crossed);
+    END LOOP;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trigger_click_stats_webhook_events
+    AFTER INSERT OR UPDATE OF total_clicks ON click_stats
+    FOR EACH ROW EXECUTE FUNCTION click_stats_webhook_events();
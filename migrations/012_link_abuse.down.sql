@@ -0,0 +1,9 @@
+DROP TABLE IF EXISTS link_reports;
+
+DROP INDEX IF EXISTS idx_short_urls_status;
+
+ALTER TABLE short_urls
+    DROP COLUMN IF EXISTS reviewed_at,
+    DROP COLUMN IF EXISTS status_changed_at,
+    DROP COLUMN IF EXISTS status_reason,
+    DROP COLUMN IF EXISTS status;
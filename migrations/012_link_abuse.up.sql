@@ -0,0 +1,27 @@
+-- Moderation state of a link. Suspended links are not redirected; links
+-- under review are redirected through a warning page.
+ALTER TABLE short_urls
+    ADD COLUMN status VARCHAR(16) NOT NULL DEFAULT 'active'
+        CHECK (status IN ('active', 'suspended', 'under_review')),
+    ADD COLUMN status_reason TEXT NULL,
+    ADD COLUMN status_changed_at TIMESTAMPTZ NULL,
+    -- Reports made before the last moderator decision no longer count
+    -- towards automatic suspension
+    ADD COLUMN reviewed_at TIMESTAMPTZ NULL;
+
+CREATE INDEX idx_short_urls_status ON short_urls(status) WHERE status <> 'active';
+
+-- Abuse reports made by visitors
+CREATE TABLE link_reports (
+    id BIGSERIAL PRIMARY KEY,
+    code VARCHAR(16) NOT NULL REFERENCES short_urls(code) ON DELETE CASCADE,
+    reason VARCHAR(16) NOT NULL,
+    details TEXT NULL,
+    -- Identifies the reporter per link, so repeated reports count once per
+    -- review; a report made before the last review is replaced by a new one
+    reporter VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (code, reporter)
+);
+
+CREATE INDEX idx_link_reports_code ON link_reports(code, created_at DESC);
@@ -0,0 +1,3 @@
+-- The destination as submitted. long_url holds its normalized form, which
+-- is what links redirect to. NULL for links created before normalization.
+ALTER TABLE short_urls ADD COLUMN original_url TEXT NULL;
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_click_events_stream_id;
+ALTER TABLE click_events DROP COLUMN IF EXISTS stream_id;
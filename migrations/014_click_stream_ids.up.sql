@@ -0,0 +1,7 @@
+-- The Redis stream entry a click was read from. Entries can be delivered
+-- again after they were written, so the ID keeps them from being counted
+-- twice. NULL for clicks that did not go through the stream.
+ALTER TABLE click_events ADD COLUMN stream_id VARCHAR(41) NULL;
+
+CREATE UNIQUE INDEX idx_click_events_stream_id ON click_events(stream_id)
+    WHERE stream_id IS NOT NULL;